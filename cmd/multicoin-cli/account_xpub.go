@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func accountXPubCmd(args []string) error {
+	fs := newFlagSet("accountXPub", "<wallet file>")
+	account := fs.Uint("a", 0, "bip44 account")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	xpubWallet := fs.String("xpub-wallet", "", "also save a watch-only xpub wallet deriving the account's addresses to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		bw, ok := w.(*wallet.Bip44Wallet)
+		if !ok {
+			return fmt.Errorf("wallet type is %q, account xpub keys are only available for %q wallets", w.Type(), wallet.WalletTypeBip44)
+		}
+
+		xpub, err := bw.AccountXPub(uint32(*account))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(xpub)
+
+		if *xpubWallet == "" {
+			return nil
+		}
+
+		xw, err := wallet.NewXPubWalletFromBip44(filepath.Base(*xpubWallet), bw, uint32(*account))
+		if err != nil {
+			return err
+		}
+
+		return wallet.Save(xw, filepath.Dir(*xpubWallet))
+	})
+}
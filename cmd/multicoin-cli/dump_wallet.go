@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func dumpWalletCmd(args []string) error {
+	fs := newFlagSet("dumpWallet", "<wallet file>")
+	output := fs.String("o", "", "file to write the dump to, defaults to stdout")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		d, err := wallet.NewDumpWallet(w)
+		if err != nil {
+			return err
+		}
+
+		if *output == "" {
+			return d.Write(os.Stdout)
+		}
+
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+
+		if err := d.Write(f); err != nil {
+			f.Close()
+			return err
+		}
+
+		return f.Close()
+	})
+}
+
+func importDumpWalletCmd(args []string) error {
+	fs := newFlagSet("importDumpWallet", "<dump file> <wallet file>")
+	addressType := fs.String("address-type", "", "address type of the keys without addr= comment in the dump, other keys use the type of their addresses")
+	label := fs.String("l", "", "wallet label")
+	encrypt := fs.Bool("e", false, "encrypt the wallet, the password is prompted for if not provided")
+	password := fs.String("p", "", "wallet password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("dump file and wallet file required")
+	}
+
+	walletFile := fs.Arg(1)
+	if _, err := os.Stat(walletFile); err == nil {
+		return fmt.Errorf("wallet file %s already exists", walletFile)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d, err := wallet.ReadDumpWallet(f)
+	if err != nil {
+		return err
+	}
+
+	opts := wallet.Options{
+		Type:        wallet.WalletTypeCollection,
+		Coin:        wallet.CoinTypeBitcoin,
+		AddressType: wallet.AddressType(*addressType),
+		Label:       *label,
+		Encrypt:     *encrypt,
+	}
+
+	if *encrypt {
+		p := *password
+		if p == "" {
+			p, err = readSecret("Enter password: ")
+			if err != nil {
+				return err
+			}
+		}
+		opts.Password = []byte(p)
+	} else if *password != "" {
+		return wallet.ErrMissingEncrypt
+	}
+
+	w, err := wallet.NewWalletFromDump(filepath.Base(walletFile), d, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := wallet.Save(w, filepath.Dir(walletFile)); err != nil {
+		return err
+	}
+
+	for _, a := range w.GetAddresses() {
+		fmt.Println(a)
+	}
+
+	return nil
+}
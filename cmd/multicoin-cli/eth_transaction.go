@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func signEthTxCmd(args []string) error {
+	fs := newFlagSet("signEthTx", "<wallet file>")
+	address := fs.String("a", "", "address of the signing key")
+	txJSON := fs.String("tx", "", `transaction as a JSON-RPC transaction object, e.g. {"chainId":"0x1","nonce":"0x0","gas":"0x5208","maxPriorityFeePerGas":"0x3b9aca00","maxFeePerGas":"0x6fc23ac00","to":"0x...","value":"0xde0b6b3a7640000"}`)
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *address == "" || *txJSON == "" {
+		fs.Usage()
+		return errors.New("-a and -tx are required")
+	}
+
+	var tx eth.Transaction
+	if err := json.Unmarshal([]byte(*txJSON), &tx); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	addr, err := eth.DecodeEthereumAddress(*address)
+	if err != nil {
+		return fmt.Errorf("invalid %s address: %v", wallet.CoinTypeEthereum, err)
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		e, ok := w.GetEntry(addr)
+		if !ok {
+			return wallet.ErrUnknownAddress
+		}
+
+		signed, err := wallet.SignEthereumTransaction(e, &tx)
+		if err != nil {
+			return err
+		}
+
+		raw, err := signed.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("0x" + hex.EncodeToString(raw))
+		return nil
+	})
+}
+
+func decodeEthTxCmd(args []string) error {
+	fs := newFlagSet("decodeEthTx", "<raw tx>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("raw transaction is required")
+	}
+
+	tx, err := eth.DecodeRawTransaction(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	from, err := tx.Sender()
+	if err != nil {
+		return err
+	}
+
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(struct {
+		Hash        string           `json:"hash"`
+		From        string           `json:"from"`
+		Transaction *eth.Transaction `json:"transaction"`
+	}{
+		Hash:        "0x" + hash.Hex(),
+		From:        from.String(),
+		Transaction: tx,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+	return nil
+}
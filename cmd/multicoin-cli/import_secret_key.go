@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func importSecretKeyCmd(args []string) error {
+	fs := newFlagSet("importSecretKey", "<wallet file>")
+	coin := fs.String("c", "", "coin of the secret key, defaults to the wallet's coin")
+	key := fs.String("k", "", "secret key, prompted for if not provided")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	coinType := w.Coin()
+	if *coin != "" {
+		coinType, err = wallet.ResolveCoinType(*coin)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoded := *key
+	if encoded == "" {
+		encoded, err = readSecret("Enter secret key: ")
+		if err != nil {
+			return err
+		}
+	}
+
+	return updateSecrets(w, fs.Arg(0), *password, func(w wallet.Wallet) error {
+		cw, ok := w.(*wallet.CollectionWallet)
+		if !ok {
+			return fmt.Errorf("wallet type is %q, secret keys can only be imported into %q wallets", w.Type(), wallet.WalletTypeCollection)
+		}
+
+		addr, err := cw.ImportSecretKey(coinType, encoded)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(addr)
+		return nil
+	})
+}
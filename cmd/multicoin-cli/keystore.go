@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func importKeystoreCmd(args []string) error {
+	fs := newFlagSet("importKeystore", "<wallet file> <keystore file>")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	keystorePassword := fs.String("kp", "", "keystore file password, prompted for if not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("wallet file and keystore file required")
+	}
+
+	keyjson, err := ioutil.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	w, err := wallet.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	kp, err := readKeystorePassword(*keystorePassword)
+	if err != nil {
+		return err
+	}
+
+	return updateSecrets(w, fs.Arg(0), *password, func(w wallet.Wallet) error {
+		cw, ok := w.(*wallet.CollectionWallet)
+		if !ok {
+			return fmt.Errorf("wallet type is %q, keystore files can only be imported into %q wallets", w.Type(), wallet.WalletTypeCollection)
+		}
+
+		addr, err := cw.ImportKeystore(keyjson, kp)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(addr)
+		return nil
+	})
+}
+
+func exportKeystoreCmd(args []string) error {
+	fs := newFlagSet("exportKeystore", "<wallet file>")
+	address := fs.String("a", "", "address of the entry to export, the keystore JSON is printed to stdout")
+	dir := fs.String("d", "", "keystore directory to export every entry of the wallet to")
+	light := fs.Bool("light", false, "use a weaker scrypt work factor, like geth's --lightkdf")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	keystorePassword := fs.String("kp", "", "keystore file password, prompted for if not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if (*address == "") == (*dir == "") {
+		fs.Usage()
+		return errors.New("exactly one of -a or -d is required")
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	kp, err := readKeystorePassword(*keystorePassword)
+	if err != nil {
+		return err
+	}
+
+	scryptN, scryptP := eth.StandardScryptN, eth.StandardScryptP
+	if *light {
+		scryptN, scryptP = eth.LightScryptN, eth.LightScryptP
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		if *dir != "" {
+			files, err := wallet.ExportKeystoreDir(w, *dir, kp, scryptN, scryptP)
+			for _, f := range files {
+				fmt.Println(f)
+			}
+			return err
+		}
+
+		addr, err := eth.DecodeEthereumAddress(*address)
+		if err != nil {
+			return err
+		}
+
+		keyjson, err := wallet.ExportKeystore(w, addr, kp, scryptN, scryptP)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, string(keyjson))
+		return err
+	})
+}
+
+func readKeystorePassword(password string) ([]byte, error) {
+	if password != "" {
+		return []byte(password), nil
+	}
+
+	p, err := readSecret("Enter keystore password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(p), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func signMessageCmd(args []string) error {
+	fs := newFlagSet("signMessage", "<wallet file>")
+	address := fs.String("a", "", "address of the signing key")
+	message := fs.String("m", "", "message to sign")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *address == "" || *message == "" {
+		fs.Usage()
+		return errors.New("-a and -m are required")
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	addr, err := wallet.DecodeAddress(w.Coin(), *address)
+	if err != nil {
+		return fmt.Errorf("invalid %s address: %v", w.Coin(), err)
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		e, ok := w.GetEntry(addr)
+		if !ok {
+			return wallet.ErrUnknownAddress
+		}
+
+		sig, err := wallet.SignMessage(w.Coin(), e, []byte(*message))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(sig)
+		return nil
+	})
+}
+
+func verifyMessageCmd(args []string) error {
+	fs := newFlagSet("verifyMessage", "")
+	coin := fs.String("c", "", "coin of the address")
+	address := fs.String("a", "", "address of the signing key")
+	message := fs.String("m", "", "signed message")
+	signature := fs.String("s", "", "message signature")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *coin == "" || *address == "" || *message == "" || *signature == "" {
+		fs.Usage()
+		return errors.New("-c, -a, -m and -s are required")
+	}
+
+	coinType, err := wallet.ResolveCoinType(*coin)
+	if err != nil {
+		return err
+	}
+
+	if err := wallet.VerifyMessage(coinType, *address, []byte(*message), *signature); err != nil {
+		return err
+	}
+
+	fmt.Println("signature is valid")
+	return nil
+}
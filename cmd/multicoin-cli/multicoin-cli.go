@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/SkycoinProject/skycoin/src/util/logging"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+// Note: multicoin-cli works directly on wallet files, so it can be used offline.
+// Usage: multicoin-cli <command> [flags] [args]
+
+// command is a multicoin-cli subcommand
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"accountXPub": {
+		usage: "Print the extended public key of a bip44 wallet account (xpub, ypub or zpub)",
+		run:   accountXPubCmd,
+	},
+	"changePassword": {
+		usage: "Re-encrypt a wallet with a new password or crypto type",
+		run:   changePasswordCmd,
+	},
+	"combinePSBT": {
+		usage: "Merge the signatures and fields of PSBTs of the same bitcoin transaction",
+		run:   combinePSBTCmd,
+	},
+	"decodeEthTx": {
+		usage: "Decode a signed raw ethereum transaction and recover its sender",
+		run:   decodeEthTxCmd,
+	},
+	"dumpWallet": {
+		usage: "Write the keys of a bitcoin wallet in Bitcoin Core's dumpwallet format",
+		run:   dumpWalletCmd,
+	},
+	"exportKeystore": {
+		usage: "Export ethereum keys to keystore V3 JSON files, compatible with geth, Clef and MetaMask",
+		run:   exportKeystoreCmd,
+	},
+	"finalizePSBT": {
+		usage: "Finalize the signed inputs of a PSBT, and optionally extract the raw bitcoin transaction",
+		run:   finalizePSBTCmd,
+	},
+	"importDumpWallet": {
+		usage: "Create a bitcoin collection wallet from a Bitcoin Core dumpwallet file",
+		run:   importDumpWalletCmd,
+	},
+	"importKeystore": {
+		usage: "Import an ethereum keystore V3 JSON file into a collection wallet",
+		run:   importKeystoreCmd,
+	},
+	"importSecretKey": {
+		usage: "Import a secret key (bitcoin WIF, skycoin or ethereum hex) into a collection wallet",
+		run:   importSecretKeyCmd,
+	},
+	"serveSigner": {
+		usage: "Sign for a wallet on a Unix socket, so that another process can sign without the wallet keys",
+		run:   serveSignerCmd,
+	},
+	"signEthTx": {
+		usage: "Sign a legacy or EIP-1559 ethereum transaction with the key of a wallet address",
+		run:   signEthTxCmd,
+	},
+	"signMessage": {
+		usage: "Sign a message with the key of a wallet address, to prove its ownership",
+		run:   signMessageCmd,
+	},
+	"signPSBT": {
+		usage: "Sign the inputs of a PSBT spent by a bitcoin wallet",
+		run:   signPSBTCmd,
+	},
+	"slip39Restore": {
+		usage: "Create a bip44 wallet from SLIP-39 mnemonic shares",
+		run:   slip39RestoreCmd,
+	},
+	"slip39Split": {
+		usage: "Split the seed of a bip44 wallet into SLIP-39 mnemonic shares",
+		run:   slip39SplitCmd,
+	},
+	"upgradeWallets": {
+		usage: "Re-encrypt the wallets of a directory encrypted with an insecure crypto type",
+		run:   upgradeWalletsCmd,
+	},
+	"verifyMessage": {
+		usage: "Check a message signature made by signMessage, or by another wallet of the coin",
+		run:   verifyMessageCmd,
+	},
+	"verifyWallet": {
+		usage: "Check a wallet's MAC and re-derive its entries, to detect changes made outside of the wallet software",
+		run:   verifyWalletCmd,
+	},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] [args]\n\nCommands:\n", os.Args[0])
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	logging.Disable()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(1)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// newFlagSet creates the flag set of a command
+func newFlagSet(name, argsUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n\nFlags:\n", os.Args[0], name, argsUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadWallet loads the wallet file given as the single argument of a command
+func loadWallet(fs *flag.FlagSet) (wallet.Wallet, error) {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("wallet file is required")
+	}
+
+	return wallet.Load(fs.Arg(0))
+}
+
+// readPassword returns the password of an encrypted wallet, prompting for it if not provided
+func readPassword(w wallet.Wallet, password string) ([]byte, error) {
+	if !w.IsEncrypted() {
+		if password != "" {
+			return nil, wallet.ErrWalletNotEncrypted
+		}
+		return nil, nil
+	}
+
+	if password != "" {
+		return []byte(password), nil
+	}
+
+	p, err := readSecret("Enter password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(p), nil
+}
+
+// viewSecrets calls f with the decrypted wallet
+func viewSecrets(w wallet.Wallet, password string, f func(wallet.Wallet) error) error {
+	p, err := readPassword(w, password)
+	if err != nil {
+		return err
+	}
+
+	if !w.IsEncrypted() {
+		return f(w)
+	}
+
+	return wallet.GuardView(w, p, f)
+}
+
+// updateSecrets calls f with the decrypted wallet, and saves the wallet file.
+// If f changed the wallet's encryption, the backups of the wallet file are removed, see wallet.SaveRekeyed.
+func updateSecrets(w wallet.Wallet, filename, password string, f func(wallet.Wallet) error) error {
+	p, err := readPassword(w, password)
+	if err != nil {
+		return err
+	}
+
+	encrypted, cryptoType := w.IsEncrypted(), w.CryptoType()
+
+	if w.IsEncrypted() {
+		err = wallet.GuardUpdate(w, p, f)
+	} else {
+		err = f(w)
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.IsEncrypted() != encrypted || w.CryptoType() != cryptoType {
+		return wallet.SaveRekeyed(w, filepath.Dir(filename))
+	}
+
+	return wallet.Save(w, filepath.Dir(filename))
+}
+
+// readSecret prompts for a secret value without echoing it
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	s, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
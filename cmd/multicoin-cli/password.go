@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func changePasswordCmd(args []string) error {
+	fs := newFlagSet("changePassword", "<wallet file>")
+	password := fs.String("p", "", "current wallet password, prompted for if not provided")
+	newPassword := fs.String("np", "", "new wallet password, prompted for if not provided")
+	cryptoType := fs.String("x", "", "crypto type to re-encrypt the wallet with, defaults to the wallet's crypto type")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	if !w.IsEncrypted() {
+		return wallet.ErrWalletNotEncrypted
+	}
+
+	ct, err := parseCryptoType(*cryptoType)
+	if err != nil {
+		return err
+	}
+
+	p, err := readPassword(w, *password)
+	if err != nil {
+		return err
+	}
+
+	np, err := readNewPassword(*newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := wallet.ChangePassword(w, p, np, ct); err != nil {
+		return err
+	}
+
+	// The backups hold the seed encrypted with the old password
+	return wallet.SaveRekeyed(w, filepath.Dir(fs.Arg(0)))
+}
+
+func upgradeWalletsCmd(args []string) error {
+	fs := newFlagSet("upgradeWallets", "<wallet dir>")
+	password := fs.String("p", "", "password of all the insecure wallets, prompted for each wallet if not provided")
+	cryptoType := fs.String("x", string(wallet.DefaultCryptoType), "crypto type to re-encrypt the wallets with")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("wallet dir is required")
+	}
+
+	ct, err := parseCryptoType(*cryptoType)
+	if err != nil {
+		return err
+	}
+
+	s, err := wallet.NewService(wallet.Config{
+		WalletDir:  fs.Arg(0),
+		CryptoType: ct,
+		Backups:    wallet.DefaultBackups,
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := s.InsecureWallets()
+	if len(ids) == 0 {
+		fmt.Println("No insecure wallets found")
+		return nil
+	}
+
+	passwords := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		p := *password
+		if p == "" {
+			p, err = readSecret(fmt.Sprintf("Enter password of %s: ", id))
+			if err != nil {
+				return err
+			}
+		}
+		passwords[id] = []byte(p)
+	}
+
+	upgraded, err := s.UpgradeInsecureWallets(passwords, ct)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range upgraded {
+		fmt.Printf("%s upgraded to %s\n", id, ct)
+	}
+
+	return nil
+}
+
+// readNewPassword returns the new password of a wallet, prompting for it twice if not provided
+func readNewPassword(password string) ([]byte, error) {
+	if password != "" {
+		return []byte(password), nil
+	}
+
+	p, err := readSecret("Enter new password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := readSecret("Confirm new password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	if p != c {
+		return nil, errors.New("passwords do not match")
+	}
+
+	return []byte(p), nil
+}
+
+func parseCryptoType(s string) (wallet.CryptoType, error) {
+	if s == "" {
+		return "", nil
+	}
+	return wallet.CryptoTypeFromString(s)
+}
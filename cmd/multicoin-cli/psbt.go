@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func signPSBTCmd(args []string) error {
+	fs := newFlagSet("signPSBT", "<wallet file>")
+	psbt := fs.String("psbt", "", "base64 PSBT to sign")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *psbt == "" {
+		fs.Usage()
+		return errors.New("-psbt is required")
+	}
+
+	p, err := btc.DecodePSBT(*psbt)
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := w.(wallet.Signer); !ok {
+		return fmt.Errorf("%q wallets can't sign", w.Type())
+	}
+
+	var n int
+	if err := viewSecrets(w, *password, func(w wallet.Wallet) error {
+		if err := wallet.UpdatePSBT(w, p); err != nil {
+			return err
+		}
+
+		var err error
+		n, err = wallet.SignPSBT(w.(wallet.Signer), p)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	s, err := p.B64Encode()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Signed %d of %d inputs\n", n, len(p.Inputs))
+	fmt.Println(s)
+	return nil
+}
+
+func combinePSBTCmd(args []string) error {
+	fs := newFlagSet("combinePSBT", "<psbt> <psbt>...")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("at least two PSBTs are required")
+	}
+
+	psbts := make([]*btc.PSBT, fs.NArg())
+	for i, s := range fs.Args() {
+		p, err := btc.DecodePSBT(s)
+		if err != nil {
+			return fmt.Errorf("psbt %d: %v", i, err)
+		}
+		psbts[i] = p
+	}
+
+	if err := psbts[0].Combine(psbts[1:]...); err != nil {
+		return err
+	}
+
+	s, err := psbts[0].B64Encode()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(s)
+	return nil
+}
+
+func finalizePSBTCmd(args []string) error {
+	fs := newFlagSet("finalizePSBT", "<psbt>")
+	extract := fs.Bool("extract", false, "print the signed raw transaction as hex instead of the finalized PSBT")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("psbt is required")
+	}
+
+	p, err := btc.DecodePSBT(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := p.Finalize(); err != nil {
+		return err
+	}
+
+	if !*extract {
+		s, err := p.B64Encode()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(s)
+		return nil
+	}
+
+	tx, err := p.Extract()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := tx.Serialize(&b); err != nil {
+		return err
+	}
+
+	fmt.Println(hex.EncodeToString(b.Bytes()))
+	return nil
+}
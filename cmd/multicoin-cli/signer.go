@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func serveSignerCmd(args []string) error {
+	fs := newFlagSet("serveSigner", "<wallet file>")
+	socket := fs.String("socket", "", "Unix socket to listen on, defaults to the wallet file name with a .sock extension")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := w.(wallet.Signer); !ok {
+		return fmt.Errorf("%q wallets can't sign", w.Type())
+	}
+
+	sock := *socket
+	if sock == "" {
+		sock = fs.Arg(0) + ".sock"
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		srv := wallet.NewSignerServer(w.(wallet.Signer))
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		go func() {
+			<-sigs
+			if err := srv.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+
+		fmt.Printf("Signing for %s on %s\n", fs.Arg(0), sock)
+		return srv.ListenAndServe(sock)
+	})
+}
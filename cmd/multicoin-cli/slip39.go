@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/slip39"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func slip39SplitCmd(args []string) error {
+	fs := newFlagSet("slip39Split", "<wallet file>")
+	groupThreshold := fs.Uint("t", 1, "number of groups required to restore the wallet")
+	groupsSpec := fs.String("g", "", "comma separated member thresholds of the groups, e.g. 1/1,3/5")
+	passphrase := fs.String("pp", "", "slip39 passphrase the master secret is encrypted with")
+	iterationExponent := fs.Uint("ie", 0, "iteration exponent of the master secret encryption")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	groups, err := parseSlip39Groups(*groupsSpec)
+	if err != nil {
+		return err
+	}
+
+	if *groupThreshold > 255 || *iterationExponent > 255 {
+		return errors.New("invalid group threshold or iteration exponent")
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	return viewSecrets(w, *password, func(w wallet.Wallet) error {
+		bw, ok := w.(*wallet.Bip44Wallet)
+		if !ok {
+			return fmt.Errorf("only %q wallets can be split into slip39 shares", wallet.WalletTypeBip44)
+		}
+
+		shares, err := bw.Slip39Shares(uint8(*groupThreshold), groups, []byte(*passphrase), uint8(*iterationExponent))
+		if err != nil {
+			return err
+		}
+
+		for i, g := range shares {
+			fmt.Printf("Group %d of %d, %d of %d shares required:\n", i+1, len(shares), groups[i].Threshold, groups[i].Count)
+			for _, m := range g {
+				fmt.Println(m)
+			}
+			fmt.Println()
+		}
+
+		return nil
+	})
+}
+
+func slip39RestoreCmd(args []string) error {
+	fs := newFlagSet("slip39Restore", "<wallet file>")
+	input := fs.String("i", "", "file with one slip39 share per line, defaults to stdin")
+	coin := fs.String("c", string(wallet.CoinTypeSkycoin), "wallet coin")
+	addressType := fs.String("address-type", "", "address type of bitcoin wallets")
+	label := fs.String("l", "", "wallet label")
+	passphrase := fs.String("pp", "", "slip39 passphrase the shares were generated with")
+	encrypt := fs.Bool("e", false, "encrypt the wallet, the password is prompted for if not provided")
+	password := fs.String("p", "", "wallet password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("wallet file is required")
+	}
+
+	walletFile := fs.Arg(0)
+	if _, err := os.Stat(walletFile); err == nil {
+		return fmt.Errorf("wallet file %s already exists", walletFile)
+	}
+
+	r := os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var mnemonics []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if m := strings.TrimSpace(scanner.Text()); m != "" {
+			mnemonics = append(mnemonics, m)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	opts := wallet.Options{
+		Type:        wallet.WalletTypeBip44,
+		Coin:        wallet.CoinType(*coin),
+		AddressType: wallet.AddressType(*addressType),
+		Label:       *label,
+		Encrypt:     *encrypt,
+	}
+
+	if *encrypt {
+		p := *password
+		if p == "" {
+			var err error
+			p, err = readSecret("Enter password: ")
+			if err != nil {
+				return err
+			}
+		}
+		opts.Password = []byte(p)
+	} else if *password != "" {
+		return wallet.ErrMissingEncrypt
+	}
+
+	w, err := wallet.NewWalletFromSlip39(filepath.Base(walletFile), mnemonics, []byte(*passphrase), opts)
+	if err != nil {
+		return err
+	}
+
+	if err := wallet.Save(w, filepath.Dir(walletFile)); err != nil {
+		return err
+	}
+
+	for _, a := range w.GetAddresses() {
+		fmt.Println(a)
+	}
+
+	return nil
+}
+
+// parseSlip39Groups parses comma separated group member thresholds, e.g. 1/1,3/5
+func parseSlip39Groups(s string) ([]slip39.Group, error) {
+	if s == "" {
+		return nil, errors.New("at least one group is required")
+	}
+
+	var groups []slip39.Group
+	for _, g := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(g), "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid group %q, expected <threshold>/<count>", g)
+		}
+
+		threshold, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group %q threshold: %v", g, err)
+		}
+
+		count, err := strconv.ParseUint(parts[1], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group %q count: %v", g, err)
+		}
+
+		groups = append(groups, slip39.Group{
+			Threshold: uint8(threshold),
+			Count:     uint8(count),
+		})
+	}
+
+	return groups, nil
+}
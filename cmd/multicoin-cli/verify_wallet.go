@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+)
+
+func verifyWalletCmd(args []string) error {
+	fs := newFlagSet("verifyWallet", "<wallet file>")
+	password := fs.String("p", "", "wallet password, prompted for if the wallet is encrypted and not provided")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := loadWallet(fs)
+	if err != nil {
+		return err
+	}
+
+	if err := viewSecrets(w, *password, wallet.VerifyEntries); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d entries verified\n", fs.Arg(0), w.EntriesLen())
+	return nil
+}
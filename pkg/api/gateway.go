@@ -4,6 +4,10 @@ import (
 	"net/http"
 
 	"github.com/SkycoinProject/multicoin-wallet/pkg/coin"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
 )
 
 //go:generate mockery -name Gatewayer -case underscore -inpkg -testonly
@@ -11,16 +15,32 @@ import (
 // Gateway is the api gateway
 type Gateway struct {
 	*coin.CoinManager
+	*wallet.Service
 }
 
 // NewGateway creates a Gateway
-func NewGateway(cm *coin.CoinManager) *Gateway {
+func NewGateway(cm *coin.CoinManager, wallets *wallet.Service) *Gateway {
 	return &Gateway{
 		cm,
+		wallets,
 	}
 }
 
+// SetupMultiCoinRoutes sets up the routes of all managed coins
+func (gw *Gateway) SetupMultiCoinRoutes(prefix string, handler func(endpoint string, handler http.Handler)) {
+	gw.SetupCoinRoutes(prefix, handler)
+}
+
 // Gatewayer interface for Gateway methods
 type Gatewayer interface {
 	SetupMultiCoinRoutes(prefix string, handler func(endpoint string, handler http.Handler))
+	AccountXPub(wltID string, password []byte, account uint32) (string, error)
+	ChangePassword(wltID string, oldPassword, newPassword []byte, cryptoType wallet.CryptoType) (wallet.Wallet, error)
+	GetWallet(wltID string) (wallet.Wallet, error)
+	ImportSecretKey(wltID string, password []byte, coin wallet.CoinType, encoded string) (cipher.Addresser, error)
+	InsecureWallets() []string
+	SignEthereumTransaction(wltID string, password []byte, addr string, tx *eth.Transaction) (*eth.Transaction, error)
+	SignMessage(wltID string, password []byte, coin wallet.CoinType, addr string, msg []byte) (string, error)
+	SignTypedData(wltID string, password []byte, addr string, td *eth.TypedData) (string, error)
+	UpgradeInsecureWallets(passwords map[string][]byte, cryptoType wallet.CryptoType) ([]string, error)
 }
@@ -115,5 +115,24 @@ func newServerMux(c muxConfig, gateway Gatewayer) *http.ServeMux {
 
 	gateway.SetupMultiCoinRoutes("/multicoin", webHandlerV1)
 
+	// Wallet endpoints
+	webHandlerV1("/multicoin/wallet/xpub", walletAccountXPubHandler(gateway))
+	webHandlerV1("/multicoin/wallet/key/import", walletImportSecretKeyHandler(gateway))
+	webHandlerV1("/multicoin/wallet/password", walletChangePasswordHandler(gateway))
+	webHandlerV1("/multicoin/wallets/insecure", insecureWalletsHandler(gateway))
+	webHandlerV1("/multicoin/wallets/upgrade", upgradeWalletsHandler(gateway))
+
+	// Message signing endpoints
+	for _, mc := range messageCoins {
+		webHandlerV1("/multicoin/"+mc.ticker+"/message/sign", messageSignHandler(gateway, mc.coin))
+		webHandlerV1("/multicoin/"+mc.ticker+"/message/verify", messageVerifyHandler(mc.coin))
+	}
+	webHandlerV1("/multicoin/eth/typeddata/sign", typedDataSignHandler(gateway))
+	webHandlerV1("/multicoin/eth/typeddata/verify", typedDataVerifyHandler())
+
+	// Transaction endpoints
+	webHandlerV1("/multicoin/eth/transaction/sign", ethTransactionSignHandler(gateway))
+	webHandlerV1("/multicoin/eth/transaction/decode", ethTransactionDecodeHandler())
+
 	return mux
 }
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	wh "github.com/SkycoinProject/skycoin/src/util/http"
+)
+
+// messageCoins are the coins with message signing endpoints, by ticker
+var messageCoins = []struct {
+	ticker string
+	coin   wallet.CoinType
+}{
+	{ticker: "btc", coin: wallet.CoinTypeBitcoin},
+	{ticker: "eth", coin: wallet.CoinTypeEthereum},
+	{ticker: "sky", coin: wallet.CoinTypeSkycoin},
+}
+
+// MessageSignResponse is returned by POST /api/v1/multicoin/{ticker}/message/sign
+type MessageSignResponse struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+}
+
+// messageSignHandler signs a message with the key of a wallet address, to prove its ownership.
+// The signature is in the coin's message signature format: a base64 Bitcoin Signed Message signature for btc,
+// an EIP-191 personal_sign signature for eth, and a hex cipher signature of the message's SHA256 for sky.
+// Method: POST
+// URI: /api/v1/multicoin/{ticker}/message/sign
+// Args:
+//     id: wallet id [required]
+//     address: address of the signing key [required]
+//     message: message to sign [required]
+//     password: wallet password [required for encrypted wallets]
+func messageSignHandler(gateway Gatewayer, coin wallet.CoinType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		address := r.FormValue("address")
+		if address == "" {
+			wh.Error400(w, "missing address")
+			return
+		}
+
+		message := r.FormValue("message")
+		if message == "" {
+			wh.Error400(w, "missing message")
+			return
+		}
+
+		password := r.FormValue("password")
+		defer func() {
+			password = ""
+		}()
+
+		sig, err := gateway.SignMessage(id, []byte(password), coin, address, []byte(message))
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, MessageSignResponse{
+			Address:   address,
+			Signature: sig,
+		})
+	}
+}
+
+// MessageVerifyResponse is returned by POST /api/v1/multicoin/{ticker}/message/verify
+type MessageVerifyResponse struct {
+	Address string `json:"address"`
+	Valid   bool   `json:"valid"`
+}
+
+// messageVerifyHandler checks that a message signature was made by the key of an address.
+// Invalid signatures are refused with a 400 error.
+// Method: POST
+// URI: /api/v1/multicoin/{ticker}/message/verify
+// Args:
+//     address: address of the signing key [required]
+//     message: signed message [required]
+//     signature: message signature, in the coin's message signature format [required]
+func messageVerifyHandler(coin wallet.CoinType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		address := r.FormValue("address")
+		if address == "" {
+			wh.Error400(w, "missing address")
+			return
+		}
+
+		message := r.FormValue("message")
+		if message == "" {
+			wh.Error400(w, "missing message")
+			return
+		}
+
+		signature := r.FormValue("signature")
+		if signature == "" {
+			wh.Error400(w, "missing signature")
+			return
+		}
+
+		if err := wallet.VerifyMessage(coin, address, []byte(message), signature); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, MessageVerifyResponse{
+			Address: address,
+			Valid:   true,
+		})
+	}
+}
+
+// TypedDataSignRequest is the request body of POST /api/v1/multicoin/eth/typeddata/sign
+type TypedDataSignRequest struct {
+	// ID of the ethereum wallet
+	ID string `json:"id"`
+	// Address of the signing key
+	Address string `json:"address"`
+	// Password of the wallet, required for encrypted wallets
+	Password string `json:"password"`
+	// TypedData is the EIP-712 typed data, in the JSON format of eth_signTypedData_v4, as an object or a string
+	TypedData json.RawMessage `json:"typed_data"`
+}
+
+// TypedDataSignResponse is returned by POST /api/v1/multicoin/eth/typeddata/sign
+type TypedDataSignResponse struct {
+	Address string `json:"address"`
+	// Hash is the signed EIP-712 digest
+	Hash string `json:"hash"`
+	// Signature is the 65 byte [r|s|v] signature, as hex
+	Signature string `json:"signature"`
+	V         uint8  `json:"v"`
+	R         string `json:"r"`
+	S         string `json:"s"`
+}
+
+// typedDataSignHandler signs EIP-712 typed structured data, e.g. an off-chain order or an EIP-2612 permit,
+// with the key of an ethereum wallet address
+// Method: POST
+// Content-Type: application/json
+// URI: /api/v1/multicoin/eth/typeddata/sign
+// Body: TypedDataSignRequest
+func typedDataSignHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		var req TypedDataSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		defer func() {
+			req = TypedDataSignRequest{}
+		}()
+
+		if req.ID == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		if req.Address == "" {
+			wh.Error400(w, "missing address")
+			return
+		}
+
+		td, hash, ok := parseTypedData(w, req.TypedData)
+		if !ok {
+			return
+		}
+
+		sig, err := gateway.SignTypedData(req.ID, []byte(req.Password), req.Address, td)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		s, err := eth.DecodeSignature(sig)
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, TypedDataSignResponse{
+			Address:   req.Address,
+			Hash:      "0x" + hash.Hex(),
+			Signature: sig,
+			V:         s[64] + 27,
+			R:         "0x" + hex.EncodeToString(s[:32]),
+			S:         "0x" + hex.EncodeToString(s[32:64]),
+		})
+	}
+}
+
+// TypedDataVerifyRequest is the request body of POST /api/v1/multicoin/eth/typeddata/verify
+type TypedDataVerifyRequest struct {
+	// Address of the signing key
+	Address string `json:"address"`
+	// TypedData is the EIP-712 typed data, in the JSON format of eth_signTypedData_v4, as an object or a string
+	TypedData json.RawMessage `json:"typed_data"`
+	// Signature is the 65 byte [r|s|v] signature, as hex
+	Signature string `json:"signature"`
+}
+
+// typedDataVerifyHandler checks that a signature of EIP-712 typed data was made by the key of an ethereum address.
+// Invalid signatures are refused with a 400 error.
+// Method: POST
+// Content-Type: application/json
+// URI: /api/v1/multicoin/eth/typeddata/verify
+// Body: TypedDataVerifyRequest
+func typedDataVerifyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		var req TypedDataVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if req.Address == "" {
+			wh.Error400(w, "missing address")
+			return
+		}
+
+		if req.Signature == "" {
+			wh.Error400(w, "missing signature")
+			return
+		}
+
+		td, _, ok := parseTypedData(w, req.TypedData)
+		if !ok {
+			return
+		}
+
+		if err := wallet.VerifyTypedData(req.Address, td, req.Signature); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, MessageVerifyResponse{
+			Address: req.Address,
+			Valid:   true,
+		})
+	}
+}
+
+// parseTypedData parses the typed data of a request and computes its digest, writing a 400 error if it's invalid.
+// The typed data is either a JSON object, or a string of JSON as in the parameters of eth_signTypedData_v4.
+func parseTypedData(w http.ResponseWriter, b json.RawMessage) (*eth.TypedData, cipher.SHA256, bool) {
+	if len(b) == 0 {
+		wh.Error400(w, "missing typed_data")
+		return nil, cipher.SHA256{}, false
+	}
+
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			wh.Error400(w, "invalid typed_data: "+err.Error())
+			return nil, cipher.SHA256{}, false
+		}
+		b = json.RawMessage(s)
+	}
+
+	td, err := eth.ParseTypedData(b)
+	if err != nil {
+		wh.Error400(w, "invalid typed_data: "+err.Error())
+		return nil, cipher.SHA256{}, false
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		wh.Error400(w, "invalid typed_data: "+err.Error())
+		return nil, cipher.SHA256{}, false
+	}
+
+	return td, hash, true
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	wh "github.com/SkycoinProject/skycoin/src/util/http"
+)
+
+// EthTransactionSignRequest is the request body of POST /api/v1/multicoin/eth/transaction/sign
+type EthTransactionSignRequest struct {
+	// ID of the ethereum wallet
+	ID string `json:"id"`
+	// Address of the signing key, the sender of the transaction
+	Address string `json:"address"`
+	// Password of the wallet, required for encrypted wallets
+	Password string `json:"password"`
+	// Transaction is the transaction to sign, as a JSON-RPC transaction object with hex quantities.
+	// It's an EIP-1559 transaction if maxFeePerGas is set, and a legacy transaction otherwise.
+	Transaction *eth.Transaction `json:"transaction"`
+}
+
+// EthTransactionResponse is returned by POST /api/v1/multicoin/eth/transaction/sign and /api/v1/multicoin/eth/transaction/decode
+type EthTransactionResponse struct {
+	// Hash is the transaction hash
+	Hash string `json:"hash"`
+	// From is the address of the signing key
+	From string `json:"from"`
+	// Raw is the 0x prefixed hex raw transaction, e.g. for eth_sendRawTransaction
+	Raw         string           `json:"raw"`
+	Transaction *eth.Transaction `json:"transaction"`
+}
+
+// newEthTransactionResponse returns the response of a signed transaction
+func newEthTransactionResponse(tx *eth.Transaction) (*EthTransactionResponse, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := tx.Sender()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthTransactionResponse{
+		Hash:        "0x" + hash.Hex(),
+		From:        from.String(),
+		Raw:         "0x" + hex.EncodeToString(raw),
+		Transaction: tx,
+	}, nil
+}
+
+// ethTransactionSignHandler signs a legacy (EIP-155) or EIP-1559 ethereum transaction with the key of an ethereum
+// wallet address, returning the raw transaction to broadcast
+// Method: POST
+// Content-Type: application/json
+// URI: /api/v1/multicoin/eth/transaction/sign
+// Body: EthTransactionSignRequest
+func ethTransactionSignHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		var req EthTransactionSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		defer func() {
+			req = EthTransactionSignRequest{}
+		}()
+
+		if req.ID == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		if req.Address == "" {
+			wh.Error400(w, "missing address")
+			return
+		}
+
+		if req.Transaction == nil {
+			wh.Error400(w, "missing transaction")
+			return
+		}
+
+		if err := req.Transaction.Validate(); err != nil {
+			wh.Error400(w, "invalid transaction: "+err.Error())
+			return
+		}
+
+		tx, err := gateway.SignEthereumTransaction(req.ID, []byte(req.Password), req.Address, req.Transaction)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		resp, err := newEthTransactionResponse(tx)
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, resp)
+	}
+}
+
+// ethTransactionDecodeHandler decodes a signed raw ethereum transaction and recovers its sender
+// Method: POST
+// URI: /api/v1/multicoin/eth/transaction/decode
+// Args:
+//     raw: hex raw transaction [required]
+func ethTransactionDecodeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		raw := r.FormValue("raw")
+		if raw == "" {
+			wh.Error400(w, "missing raw")
+			return
+		}
+
+		tx, err := eth.DecodeRawTransaction(raw)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		resp, err := newEthTransactionResponse(tx)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, resp)
+	}
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+
+	wh "github.com/SkycoinProject/skycoin/src/util/http"
+)
+
+// writeWalletError writes the http error response of a wallet error
+func writeWalletError(w http.ResponseWriter, err error) {
+	switch err {
+	case wallet.ErrWalletNotExist:
+		wh.Error404(w, "")
+	default:
+		switch err.(type) {
+		case wallet.Error:
+			wh.Error400(w, err.Error())
+		default:
+			wh.Error500(w, err.Error())
+		}
+	}
+}
+
+// WalletAccountXPubResponse is returned by POST /api/v1/multicoin/wallet/xpub
+type WalletAccountXPubResponse struct {
+	Account uint32 `json:"account"`
+	XPub    string `json:"xpub"`
+}
+
+// walletAccountXPubHandler returns the extended public key of a bip44 wallet account.
+// Bitcoin keys are returned as xpub, ypub or zpub keys depending on the wallet's address type.
+// Method: POST
+// URI: /api/v1/multicoin/wallet/xpub
+// Args:
+//     id: wallet id [required]
+//     account: bip44 account [optional, defaults to 0]
+//     password: wallet password [required for encrypted wallets]
+func walletAccountXPubHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		var account uint32
+		if s := r.FormValue("account"); s != "" {
+			a, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				wh.Error400(w, "invalid account value")
+				return
+			}
+			account = uint32(a)
+		}
+
+		password := r.FormValue("password")
+		defer func() {
+			password = ""
+		}()
+
+		xpub, err := gateway.AccountXPub(id, []byte(password), account)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, WalletAccountXPubResponse{
+			Account: account,
+			XPub:    xpub,
+		})
+	}
+}
+
+// WalletImportSecretKeyResponse is returned by POST /api/v1/multicoin/wallet/key/import
+type WalletImportSecretKeyResponse struct {
+	Address string `json:"address"`
+}
+
+// walletImportSecretKeyHandler imports a secret key into a collection wallet.
+// The key is encoded in the coin's native format: WIF for bitcoin, hex for skycoin and ethereum.
+// Method: POST
+// URI: /api/v1/multicoin/wallet/key/import
+// Args:
+//     id: wallet id [required]
+//     key: encoded secret key [required]
+//     coin: coin of the secret key [optional, defaults to the wallet's coin]
+//     password: wallet password [required for encrypted wallets]
+func walletImportSecretKeyHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		key := r.FormValue("key")
+		defer func() {
+			key = ""
+		}()
+		if key == "" {
+			wh.Error400(w, "missing key")
+			return
+		}
+
+		var coin wallet.CoinType
+		if s := r.FormValue("coin"); s != "" {
+			var err error
+			coin, err = wallet.ResolveCoinType(s)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		} else {
+			wlt, err := gateway.GetWallet(id)
+			if err != nil {
+				writeWalletError(w, err)
+				return
+			}
+			coin = wlt.Coin()
+		}
+
+		password := r.FormValue("password")
+		defer func() {
+			password = ""
+		}()
+
+		addr, err := gateway.ImportSecretKey(id, []byte(password), coin, key)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, WalletImportSecretKeyResponse{
+			Address: addr.String(),
+		})
+	}
+}
+
+// WalletChangePasswordResponse is returned by POST /api/v1/multicoin/wallet/password
+type WalletChangePasswordResponse struct {
+	ID         string `json:"id"`
+	CryptoType string `json:"crypto_type"`
+}
+
+// walletChangePasswordHandler re-encrypts an encrypted wallet with a new password and crypto type.
+// The wallet is never written to disk unencrypted.
+// Method: POST
+// URI: /api/v1/multicoin/wallet/password
+// Args:
+//     id: wallet id [required]
+//     old_password: current wallet password [required]
+//     new_password: new wallet password [required]
+//     crypto_type: new crypto type [optional, defaults to the wallet's crypto type]
+func walletChangePasswordHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		var cryptoType wallet.CryptoType
+		if s := r.FormValue("crypto_type"); s != "" {
+			var err error
+			cryptoType, err = wallet.CryptoTypeFromString(s)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		}
+
+		oldPassword := r.FormValue("old_password")
+		newPassword := r.FormValue("new_password")
+		defer func() {
+			oldPassword = ""
+			newPassword = ""
+		}()
+
+		wlt, err := gateway.ChangePassword(id, []byte(oldPassword), []byte(newPassword), cryptoType)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		wh.SendJSONOr500(logger, w, WalletChangePasswordResponse{
+			ID:         wlt.Filename(),
+			CryptoType: string(wlt.CryptoType()),
+		})
+	}
+}
+
+// InsecureWalletsResponse is returned by GET /api/v1/multicoin/wallets/insecure
+type InsecureWalletsResponse struct {
+	Wallets []string `json:"wallets"`
+}
+
+// insecureWalletsHandler returns the IDs of the wallets encrypted with an insecure crypto type
+// Method: GET
+// URI: /api/v1/multicoin/wallets/insecure
+func insecureWalletsHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wallets := gateway.InsecureWallets()
+		if wallets == nil {
+			wallets = []string{}
+		}
+
+		wh.SendJSONOr500(logger, w, InsecureWalletsResponse{
+			Wallets: wallets,
+		})
+	}
+}
+
+// UpgradeWalletsRequest is the request body of POST /api/v1/multicoin/wallets/upgrade
+type UpgradeWalletsRequest struct {
+	// Passwords of the wallets to upgrade, by wallet id
+	Passwords map[string]string `json:"passwords"`
+	// Password of all the insecure wallets that are not in Passwords
+	Password string `json:"password"`
+	// Crypto type to re-encrypt the wallets with, defaults to the service's crypto type
+	CryptoType string `json:"crypto_type"`
+}
+
+// UpgradeWalletsResponse is returned by POST /api/v1/multicoin/wallets/upgrade
+type UpgradeWalletsResponse struct {
+	Upgraded []string `json:"upgraded"`
+	Insecure []string `json:"insecure"`
+}
+
+// upgradeWalletsHandler re-encrypts wallets encrypted with an insecure crypto type, keeping their passwords.
+// No wallet is rewritten unless all of them can be decrypted with their password.
+// Method: POST
+// Content-Type: application/json
+// URI: /api/v1/multicoin/wallets/upgrade
+// Body: UpgradeWalletsRequest
+func upgradeWalletsHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		var req UpgradeWalletsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		defer func() {
+			req = UpgradeWalletsRequest{}
+		}()
+
+		var cryptoType wallet.CryptoType
+		if req.CryptoType != "" {
+			var err error
+			cryptoType, err = wallet.CryptoTypeFromString(req.CryptoType)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		}
+
+		passwords := make(map[string][]byte, len(req.Passwords))
+		for id, p := range req.Passwords {
+			passwords[id] = []byte(p)
+		}
+		if req.Password != "" {
+			for _, id := range gateway.InsecureWallets() {
+				if _, ok := passwords[id]; !ok {
+					passwords[id] = []byte(req.Password)
+				}
+			}
+		}
+
+		upgraded, err := gateway.UpgradeInsecureWallets(passwords, cryptoType)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		insecure := gateway.InsecureWallets()
+		if insecure == nil {
+			insecure = []string{}
+		}
+
+		wh.SendJSONOr500(logger, w, UpgradeWalletsResponse{
+			Upgraded: upgraded,
+			Insecure: insecure,
+		})
+	}
+}
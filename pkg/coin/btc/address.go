@@ -0,0 +1,262 @@
+package btc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcutil/bech32"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/base58"
+)
+
+const (
+	// Bech32HRP is the bech32 human readable part of mainnet segwit addresses
+	Bech32HRP = "bc"
+
+	// witnessVersionP2WPKH is the witness version of native segwit v0 outputs
+	witnessVersionP2WPKH = 0x00
+
+	// P2SHVersion is the base58 version byte of mainnet pay-to-script-hash addresses
+	P2SHVersion = 0x05
+)
+
+var (
+	// ErrInvalidWitnessVersion is returned when decoding a segwit address with an unsupported witness version
+	ErrInvalidWitnessVersion = errors.New("invalid segwit witness version")
+	// ErrInvalidWitnessProgram is returned when decoding a segwit address with an invalid witness program
+	ErrInvalidWitnessProgram = errors.New("invalid segwit witness program")
+	// ErrInvalidHRP is returned when decoding a segwit address that is not a mainnet address
+	ErrInvalidHRP = errors.New("invalid segwit address human readable part")
+)
+
+// SegwitAddress is a native segwit (bip173) pay-to-witness-pubkey-hash address
+type SegwitAddress struct {
+	Key cipher.Ripemd160 // 20 byte witness program, ripemd160(sha256(pubkey))
+}
+
+// SegwitAddressFromPubKey creates a mainnet SegwitAddress from a compressed PubKey
+func SegwitAddressFromPubKey(pubKey cipher.PubKey) SegwitAddress {
+	return SegwitAddress{
+		Key: cipher.BitcoinPubKeyRipemd160(pubKey),
+	}
+}
+
+// DecodeBech32SegwitAddress creates a SegwitAddress from its bech32 encoding
+func DecodeBech32SegwitAddress(addr string) (SegwitAddress, error) {
+	version, program, err := decodeSegwit(addr)
+	if err != nil {
+		return SegwitAddress{}, err
+	}
+
+	if version != witnessVersionP2WPKH {
+		return SegwitAddress{}, ErrInvalidWitnessVersion
+	}
+
+	if len(program) != len(cipher.Ripemd160{}) {
+		return SegwitAddress{}, ErrInvalidWitnessProgram
+	}
+
+	var a SegwitAddress
+	copy(a.Key[:], program)
+	return a, nil
+}
+
+// Null returns true if the address is null (0x0000....)
+func (addr SegwitAddress) Null() bool {
+	return addr == SegwitAddress{}
+}
+
+// Bytes returns the witness version followed by the witness program
+func (addr SegwitAddress) Bytes() []byte {
+	return append([]byte{witnessVersionP2WPKH}, addr.Key[:]...)
+}
+
+// Verify checks that the segwit address appears valid for the public key
+func (addr SegwitAddress) Verify(key cipher.PubKey) error {
+	if addr.Key != cipher.BitcoinPubKeyRipemd160(key) {
+		return cipher.ErrAddressInvalidPubKey
+	}
+	return nil
+}
+
+// String converts the segwit address to its bech32 encoding
+func (addr SegwitAddress) String() string {
+	s, err := encodeSegwit(witnessVersionP2WPKH, addr.Key[:])
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Checksum returns an empty checksum; the bech32 checksum is part of the encoded string
+func (addr SegwitAddress) Checksum() cipher.Checksum {
+	return cipher.Checksum{}
+}
+
+// NestedSegwitAddress is a bip49 pay-to-witness-pubkey-hash address nested in a
+// pay-to-script-hash (P2SH-P2WPKH) address
+type NestedSegwitAddress struct {
+	Key cipher.Ripemd160 // 20 byte script hash, ripemd160(sha256(redeemScript))
+}
+
+// NestedSegwitRedeemScript returns the P2SH redeem script of a P2SH-P2WPKH output, which is
+// the P2WPKH witness program: OP_0 <20 byte ripemd160(sha256(pubkey))>
+func NestedSegwitRedeemScript(pubKey cipher.PubKey) []byte {
+	h := cipher.BitcoinPubKeyRipemd160(pubKey)
+	return append([]byte{witnessVersionP2WPKH, byte(len(h))}, h[:]...)
+}
+
+// NestedSegwitAddressFromPubKey creates a mainnet NestedSegwitAddress from a compressed PubKey
+func NestedSegwitAddressFromPubKey(pubKey cipher.PubKey) NestedSegwitAddress {
+	r := cipher.SumSHA256(NestedSegwitRedeemScript(pubKey))
+	return NestedSegwitAddress{
+		Key: cipher.HashRipemd160(r[:]),
+	}
+}
+
+// DecodeBase58NestedSegwitAddress creates a NestedSegwitAddress from its base58 encoding
+func DecodeBase58NestedSegwitAddress(addr string) (NestedSegwitAddress, error) {
+	b, err := base58.Decode(addr)
+	if err != nil {
+		return NestedSegwitAddress{}, err
+	}
+	return nestedSegwitAddressFromBytes(b)
+}
+
+func nestedSegwitAddressFromBytes(b []byte) (NestedSegwitAddress, error) {
+	if len(b) != 20+1+4 {
+		return NestedSegwitAddress{}, cipher.ErrAddressInvalidLength
+	}
+
+	if b[0] != P2SHVersion {
+		return NestedSegwitAddress{}, cipher.ErrAddressInvalidVersion
+	}
+
+	a := NestedSegwitAddress{}
+	copy(a.Key[:], b[1:21])
+
+	var checksum cipher.Checksum
+	copy(checksum[:], b[21:25])
+	if checksum != a.Checksum() {
+		return NestedSegwitAddress{}, cipher.ErrAddressInvalidChecksum
+	}
+
+	return a, nil
+}
+
+// Null returns true if the address is null (0x0000....)
+func (addr NestedSegwitAddress) Null() bool {
+	return addr == NestedSegwitAddress{}
+}
+
+// Bytes returns the address as byte slice: version, script hash and checksum
+func (addr NestedSegwitAddress) Bytes() []byte {
+	b := make([]byte, 20+1+4)
+	b[0] = P2SHVersion
+	copy(b[1:21], addr.Key[:])
+	chksum := addr.Checksum()
+	copy(b[21:25], chksum[:])
+	return b
+}
+
+// Verify checks that the address is the hash of the P2WPKH redeem script of the public key
+func (addr NestedSegwitAddress) Verify(key cipher.PubKey) error {
+	if addr != NestedSegwitAddressFromPubKey(key) {
+		return cipher.ErrAddressInvalidPubKey
+	}
+	return nil
+}
+
+// String converts the address to its base58 encoding
+func (addr NestedSegwitAddress) String() string {
+	return base58.Encode(addr.Bytes())
+}
+
+// Checksum returns the address checksum, which is the first 4 bytes of sha256(sha256(version+key))
+func (addr NestedSegwitAddress) Checksum() cipher.Checksum {
+	r := cipher.DoubleSHA256(append([]byte{P2SHVersion}, addr.Key[:]...))
+	c := cipher.Checksum{}
+	copy(c[:], r[:len(c)])
+	return c
+}
+
+// encodeSegwit encodes a witness program with the bech32 encoding for version 0,
+// and the bech32m encoding for version 1+ (bip350)
+func encodeSegwit(version byte, program []byte) (string, error) {
+	data, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	variant := variantBech32
+	if version != witnessVersionP2WPKH {
+		variant = variantBech32m
+	}
+
+	return encodeBech32Variant(Bech32HRP, append([]byte{version}, data...), variant)
+}
+
+// decodeSegwit decodes a bech32 or bech32m encoded mainnet segwit address into its witness version and program
+func decodeSegwit(addr string) (byte, []byte, error) {
+	hrp, data, variant, err := decodeBech32Variant(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if hrp != Bech32HRP {
+		return 0, nil, ErrInvalidHRP
+	}
+
+	if len(data) == 0 || data[0] > 16 {
+		return 0, nil, ErrInvalidWitnessVersion
+	}
+
+	// Version 0 witness programs use bech32, later versions use bech32m
+	if (data[0] == witnessVersionP2WPKH) != (variant == variantBech32) {
+		return 0, nil, errors.New("invalid checksum variant for witness version")
+	}
+
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(program) < 2 || len(program) > 40 {
+		return 0, nil, ErrInvalidWitnessProgram
+	}
+
+	return data[0], program, nil
+}
+
+// DecodeAddress decodes a mainnet bitcoin address of any supported format
+func DecodeAddress(addr string) (cipher.Addresser, error) {
+	lower := strings.ToLower(addr)
+	if strings.HasPrefix(lower, Bech32HRP+"1p") {
+		a, err := DecodeBech32mTaprootAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid taproot address: %v", err)
+		}
+		return a, nil
+	}
+
+	if strings.HasPrefix(lower, Bech32HRP+"1") {
+		a, err := DecodeBech32SegwitAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segwit address: %v", err)
+		}
+		return a, nil
+	}
+
+	b, err := base58.Decode(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > 0 && b[0] == P2SHVersion {
+		return nestedSegwitAddressFromBytes(b)
+	}
+
+	return cipher.BitcoinAddressFromBytes(b)
+}
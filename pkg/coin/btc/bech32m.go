@@ -0,0 +1,129 @@
+package btc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The vendored bech32 package only implements the original bip173 checksum.
+// Witness version 1+ addresses use the bech32m checksum defined in bip350,
+// which differs only by the constant the polymod is xored with.
+
+// bech32Variant is the checksum variant of a bech32 string
+type bech32Variant int
+
+const (
+	variantBech32  bech32Variant = iota // bip173
+	variantBech32m                      // bip350
+)
+
+const (
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+var bech32Gen = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func (v bech32Variant) constant() uint32 {
+	if v == variantBech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= bech32Gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	v := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]>>5)
+	}
+	v = append(v, 0)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]&31)
+	}
+	return v
+}
+
+// encodeBech32Variant encodes 5-bit data with the human readable part and the checksum variant
+func encodeBech32Variant(hrp string, data []byte, variant bech32Variant) (string, error) {
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ variant.constant()
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		if int(d) >= len(bech32Charset) {
+			return "", fmt.Errorf("invalid bech32 data byte: %v", d)
+		}
+		sb.WriteByte(bech32Charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// decodeBech32Variant decodes a bech32 or bech32m string, returning the human readable part,
+// the 5-bit data excluding the checksum and the checksum variant
+func decodeBech32Variant(s string) (string, []byte, bech32Variant, error) {
+	if len(s) < 8 || len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("invalid bech32 string length %d", len(s))
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid character in string: '%c'", s[i])
+		}
+	}
+
+	lower := strings.ToLower(s)
+	if s != lower && s != strings.ToUpper(s) {
+		return "", nil, 0, errors.New("string not all lowercase or all uppercase")
+	}
+	s = lower
+
+	one := strings.LastIndexByte(s, '1')
+	if one < 1 || one+7 > len(s) {
+		return "", nil, 0, errors.New("invalid index of 1")
+	}
+
+	hrp := s[:one]
+	data := make([]byte, 0, len(s)-one-1)
+	for i := one + 1; i < len(s); i++ {
+		idx := strings.IndexByte(bech32Charset, s[i])
+		if idx < 0 {
+			return "", nil, 0, fmt.Errorf("invalid character not part of charset: %v", s[i])
+		}
+		data = append(data, byte(idx))
+	}
+
+	var variant bech32Variant
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case bech32Const:
+		variant = variantBech32
+	case bech32mConst:
+		variant = variantBech32m
+	default:
+		return "", nil, 0, errors.New("checksum failed")
+	}
+
+	return hrp, data[:len(data)-6], variant, nil
+}
@@ -0,0 +1,135 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/secp256k1-go"
+)
+
+// MessageMagic is the prefix of the messages signed with Bitcoin Signed Message signatures
+const MessageMagic = "Bitcoin Signed Message:\n"
+
+// The header byte of a bip137 message signature is the recovery id added to a base which identifies the address type
+const (
+	messageHeaderP2PKHUncompressed = 27
+	messageHeaderP2PKH             = 31
+	messageHeaderP2SHP2WPKH        = 35
+	messageHeaderP2WPKH            = 39
+	messageHeaderMax               = 42
+)
+
+var (
+	// ErrInvalidMessageSignature is returned when a message signature is malformed or wasn't made by the address
+	ErrInvalidMessageSignature = errors.New("invalid message signature")
+	// ErrMessageAddressType is returned when signing a message for an address type without a message signature format
+	ErrMessageAddressType = errors.New("messages can't be signed for this address type")
+)
+
+// MessageHash returns the digest signed by a Bitcoin Signed Message signature,
+// the double SHA256 of the magic prefix and the message, each prefixed with its length as a varint
+func MessageHash(msg []byte) cipher.SHA256 {
+	var b bytes.Buffer
+	writeVarInt(&b, uint64(len(MessageMagic)))
+	b.WriteString(MessageMagic)
+	writeVarInt(&b, uint64(len(msg)))
+	b.Write(msg)
+	return cipher.DoubleSHA256(b.Bytes())
+}
+
+// EncodeMessageSignature encodes a signature of a MessageHash as a base64 bip137 compact signature [header|r|s],
+// with the header of the address type which the signature is made for. Taproot addresses have no such format.
+func EncodeMessageSignature(addr cipher.Addresser, sig cipher.Sig) (string, error) {
+	var header byte
+	switch addr.(type) {
+	case cipher.BitcoinAddress:
+		header = messageHeaderP2PKH
+	case NestedSegwitAddress:
+		header = messageHeaderP2SHP2WPKH
+	case SegwitAddress:
+		header = messageHeaderP2WPKH
+	default:
+		return "", ErrMessageAddressType
+	}
+
+	b := make([]byte, len(sig))
+	b[0] = header + sig[64]
+	copy(b[1:], sig[:64])
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// VerifyMessage checks that a base64 bip137 compact signature of a message was made by the key of an address.
+// As Electrum does, signatures of segwit addresses may use the header of compressed P2PKH addresses.
+func VerifyMessage(addr cipher.Addresser, msg []byte, signature string) error {
+	b, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(b) != len(cipher.Sig{}) {
+		return ErrInvalidMessageSignature
+	}
+
+	header := b[0]
+	if header < messageHeaderP2PKHUncompressed || header > messageHeaderMax {
+		return ErrInvalidMessageSignature
+	}
+	recid := (header - messageHeaderP2PKHUncompressed) % 4
+
+	var sig cipher.Sig
+	copy(sig[:64], b[1:])
+	sig[64] = recid
+
+	pk, err := cipher.PubKeyFromSig(sig, MessageHash(msg))
+	if err != nil {
+		return ErrInvalidMessageSignature
+	}
+
+	var match bool
+	switch a := addr.(type) {
+	case cipher.BitcoinAddress:
+		switch {
+		case header < messageHeaderP2PKH:
+			h := cipher.SumSHA256(secp256k1.UncompressPubkey(pk[:]))
+			match = a.Key == cipher.HashRipemd160(h[:])
+		case header < messageHeaderP2SHP2WPKH:
+			match = a == cipher.BitcoinAddressFromPubKey(pk)
+		}
+	case NestedSegwitAddress:
+		if header >= messageHeaderP2PKH && header < messageHeaderP2WPKH {
+			match = a == NestedSegwitAddressFromPubKey(pk)
+		}
+	case SegwitAddress:
+		if (header >= messageHeaderP2PKH && header < messageHeaderP2SHP2WPKH) || header >= messageHeaderP2WPKH {
+			match = a == SegwitAddressFromPubKey(pk)
+		}
+	default:
+		return ErrMessageAddressType
+	}
+
+	if !match {
+		return ErrInvalidMessageSignature
+	}
+
+	return nil
+}
+
+// writeVarInt writes a bitcoin variable length integer
+func writeVarInt(b *bytes.Buffer, n uint64) {
+	var buf [9]byte
+	switch {
+	case n < 0xfd:
+		b.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf[0] = 0xfd
+		binary.LittleEndian.PutUint16(buf[1:], uint16(n))
+		b.Write(buf[:3])
+	case n <= 0xffffffff:
+		buf[0] = 0xfe
+		binary.LittleEndian.PutUint32(buf[1:], uint32(n))
+		b.Write(buf[:5])
+	default:
+		buf[0] = 0xff
+		binary.LittleEndian.PutUint64(buf[1:], n)
+		b.Write(buf[:9])
+	}
+}
@@ -0,0 +1,878 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// SigHashAll is the sighash type of signatures committing to all the inputs and outputs of a transaction
+const SigHashAll = 0x01
+
+// PSBT (bip174) key types
+const (
+	psbtGlobalUnsignedTx = 0x00
+	psbtGlobalVersion    = 0xfb
+
+	psbtInNonWitnessUtxo     = 0x00
+	psbtInWitnessUtxo        = 0x01
+	psbtInPartialSig         = 0x02
+	psbtInSighashType        = 0x03
+	psbtInRedeemScript       = 0x04
+	psbtInWitnessScript      = 0x05
+	psbtInBip32Derivation    = 0x06
+	psbtInFinalScriptSig     = 0x07
+	psbtInFinalScriptWitness = 0x08
+
+	psbtOutRedeemScript    = 0x00
+	psbtOutWitnessScript   = 0x01
+	psbtOutBip32Derivation = 0x02
+)
+
+// psbtMagic are the magic bytes "psbt" 0xff which start a serialized PSBT
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// maxPSBTValueSize bounds the size of the keys and values of a serialized PSBT
+const maxPSBTValueSize = wire.MaxMessagePayload
+
+var (
+	// ErrInvalidPSBT is returned when parsing a PSBT which isn't a valid bip174 serialization
+	ErrInvalidPSBT = errors.New("invalid psbt")
+	// ErrPSBTTxMismatch is returned when combining PSBTs of different transactions
+	ErrPSBTTxMismatch = errors.New("psbts are not for the same transaction")
+	// ErrPSBTNotFinalized is returned when extracting the transaction of a PSBT with inputs which aren't finalized
+	ErrPSBTNotFinalized = errors.New("psbt inputs are not all finalized")
+	// ErrUnsupportedInput is returned when signing or finalizing an input which doesn't spend a P2PKH, P2WPKH or P2SH-P2WPKH output
+	ErrUnsupportedInput = errors.New("unsupported psbt input script")
+	// ErrUnsupportedSigHash is returned when signing an input with a sighash type other than SIGHASH_ALL
+	ErrUnsupportedSigHash = errors.New("unsupported sighash type")
+	// ErrMissingUtxo is returned when signing or finalizing an input without the output it spends
+	ErrMissingUtxo = errors.New("psbt input has no utxo")
+)
+
+// PSBTUnknown is a key-value pair of a PSBT map whose key type is unknown, kept as is
+type PSBTUnknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// Bip32Derivation is the bip32 derivation path of a public key, from the master key of the given fingerprint
+type Bip32Derivation struct {
+	PubKey            []byte
+	MasterFingerprint [4]byte
+	Path              []uint32
+}
+
+// PartialSig is a signature of an input by a public key. The signature is DER encoded and followed by its sighash type.
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// PSBTInput is the input map of a PSBT
+type PSBTInput struct {
+	NonWitnessUtxo     *wire.MsgTx
+	WitnessUtxo        *wire.TxOut
+	PartialSigs        []PartialSig
+	SighashType        uint32 // 0 if unset
+	RedeemScript       []byte
+	WitnessScript      []byte
+	Bip32Derivation    []Bip32Derivation
+	FinalScriptSig     []byte
+	FinalScriptWitness wire.TxWitness
+	Unknown            []PSBTUnknown
+}
+
+// PSBTOutput is the output map of a PSBT
+type PSBTOutput struct {
+	RedeemScript    []byte
+	WitnessScript   []byte
+	Bip32Derivation []Bip32Derivation
+	Unknown         []PSBTUnknown
+}
+
+// PSBT is a bip174 partially signed bitcoin transaction, which carries an unsigned transaction
+// along with the information needed by signers to sign its inputs
+type PSBT struct {
+	UnsignedTx *wire.MsgTx
+	Unknown    []PSBTUnknown
+	Inputs     []PSBTInput
+	Outputs    []PSBTOutput
+}
+
+// NewPSBT creates a PSBT of an unsigned transaction, with empty input and output maps
+func NewPSBT(tx *wire.MsgTx) (*PSBT, error) {
+	if err := checkUnsignedTx(tx); err != nil {
+		return nil, err
+	}
+
+	return &PSBT{
+		UnsignedTx: tx.Copy(),
+		Inputs:     make([]PSBTInput, len(tx.TxIn)),
+		Outputs:    make([]PSBTOutput, len(tx.TxOut)),
+	}, nil
+}
+
+func checkUnsignedTx(tx *wire.MsgTx) error {
+	for _, in := range tx.TxIn {
+		if len(in.SignatureScript) != 0 || len(in.Witness) != 0 {
+			return fmt.Errorf("%v: transaction inputs must have empty scriptSigs and witnesses", ErrInvalidPSBT)
+		}
+	}
+	return nil
+}
+
+// DecodePSBT parses a base64 encoded PSBT
+func DecodePSBT(s string) (*PSBT, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidPSBT, err)
+	}
+	return ParsePSBT(b)
+}
+
+// ParsePSBT parses a serialized PSBT
+func ParsePSBT(b []byte) (*PSBT, error) {
+	if !bytes.HasPrefix(b, psbtMagic) {
+		return nil, fmt.Errorf("%v: bad magic bytes", ErrInvalidPSBT)
+	}
+
+	r := bytes.NewReader(b[len(psbtMagic):])
+	p, err := readPSBT(r)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidPSBT, err)
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%v: %d trailing bytes", ErrInvalidPSBT, r.Len())
+	}
+
+	return p, nil
+}
+
+func readPSBT(r *bytes.Reader) (*PSBT, error) {
+	var p PSBT
+	err := readPSBTMap(r, func(k, v []byte) error {
+		switch k[0] {
+		case psbtGlobalUnsignedTx:
+			if len(k) != 1 {
+				return errors.New("invalid unsigned tx key")
+			}
+			tx := wire.NewMsgTx(wire.TxVersion)
+			if err := tx.DeserializeNoWitness(bytes.NewReader(v)); err != nil {
+				return fmt.Errorf("invalid unsigned tx: %v", err)
+			}
+			p.UnsignedTx = tx
+		case psbtGlobalVersion:
+			if len(k) != 1 || len(v) != 4 {
+				return errors.New("invalid version")
+			}
+			if version := binary.LittleEndian.Uint32(v); version != 0 {
+				return fmt.Errorf("unsupported version %d", version)
+			}
+			p.Unknown = append(p.Unknown, PSBTUnknown{Key: k, Value: v})
+		default:
+			p.Unknown = append(p.Unknown, PSBTUnknown{Key: k, Value: v})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.UnsignedTx == nil {
+		return nil, errors.New("missing unsigned tx")
+	}
+
+	if err := checkUnsignedTx(p.UnsignedTx); err != nil {
+		return nil, err
+	}
+
+	p.Inputs = make([]PSBTInput, len(p.UnsignedTx.TxIn))
+	for i := range p.Inputs {
+		if err := readPSBTInput(r, &p.Inputs[i]); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+
+	p.Outputs = make([]PSBTOutput, len(p.UnsignedTx.TxOut))
+	for i := range p.Outputs {
+		if err := readPSBTOutput(r, &p.Outputs[i]); err != nil {
+			return nil, fmt.Errorf("output %d: %v", i, err)
+		}
+	}
+
+	return &p, nil
+}
+
+func readPSBTInput(r *bytes.Reader, in *PSBTInput) error {
+	return readPSBTMap(r, func(k, v []byte) error {
+		var err error
+		switch k[0] {
+		case psbtInNonWitnessUtxo:
+			err = checkKeyLen(k, 1)
+			if err == nil {
+				tx := wire.NewMsgTx(wire.TxVersion)
+				if err = tx.Deserialize(bytes.NewReader(v)); err == nil {
+					in.NonWitnessUtxo = tx
+				}
+			}
+		case psbtInWitnessUtxo:
+			err = checkKeyLen(k, 1)
+			if err == nil {
+				in.WitnessUtxo, err = parseTxOut(v)
+			}
+		case psbtInPartialSig:
+			err = checkPubKeyKey(k)
+			if err == nil {
+				in.PartialSigs = append(in.PartialSigs, PartialSig{PubKey: k[1:], Signature: v})
+			}
+		case psbtInSighashType:
+			err = checkKeyLen(k, 1)
+			if err == nil && len(v) != 4 {
+				err = errors.New("invalid sighash type")
+			}
+			if err == nil {
+				in.SighashType = binary.LittleEndian.Uint32(v)
+			}
+		case psbtInRedeemScript:
+			err = checkKeyLen(k, 1)
+			in.RedeemScript = v
+		case psbtInWitnessScript:
+			err = checkKeyLen(k, 1)
+			in.WitnessScript = v
+		case psbtInBip32Derivation:
+			var d Bip32Derivation
+			d, err = parseBip32Derivation(k, v)
+			in.Bip32Derivation = append(in.Bip32Derivation, d)
+		case psbtInFinalScriptSig:
+			err = checkKeyLen(k, 1)
+			in.FinalScriptSig = v
+		case psbtInFinalScriptWitness:
+			err = checkKeyLen(k, 1)
+			if err == nil {
+				in.FinalScriptWitness, err = parseWitness(v)
+			}
+		default:
+			in.Unknown = append(in.Unknown, PSBTUnknown{Key: k, Value: v})
+		}
+		return err
+	})
+}
+
+func readPSBTOutput(r *bytes.Reader, out *PSBTOutput) error {
+	return readPSBTMap(r, func(k, v []byte) error {
+		var err error
+		switch k[0] {
+		case psbtOutRedeemScript:
+			err = checkKeyLen(k, 1)
+			out.RedeemScript = v
+		case psbtOutWitnessScript:
+			err = checkKeyLen(k, 1)
+			out.WitnessScript = v
+		case psbtOutBip32Derivation:
+			var d Bip32Derivation
+			d, err = parseBip32Derivation(k, v)
+			out.Bip32Derivation = append(out.Bip32Derivation, d)
+		default:
+			out.Unknown = append(out.Unknown, PSBTUnknown{Key: k, Value: v})
+		}
+		return err
+	})
+}
+
+// readPSBTMap reads the key-value pairs of a map up to its 0x00 separator, calling f with each pair.
+// Keys must be unique within a map.
+func readPSBTMap(r *bytes.Reader, f func(k, v []byte) error) error {
+	keys := make(map[string]struct{})
+	for {
+		k, err := wire.ReadVarBytes(r, 0, maxPSBTValueSize, "psbt key")
+		if err != nil {
+			return err
+		}
+
+		if len(k) == 0 {
+			return nil
+		}
+
+		if _, ok := keys[string(k)]; ok {
+			return fmt.Errorf("duplicate key %x", k)
+		}
+		keys[string(k)] = struct{}{}
+
+		v, err := wire.ReadVarBytes(r, 0, maxPSBTValueSize, "psbt value")
+		if err != nil {
+			return err
+		}
+
+		if err := f(k, v); err != nil {
+			return fmt.Errorf("key %x: %v", k, err)
+		}
+	}
+}
+
+func checkKeyLen(k []byte, n int) error {
+	if len(k) != n {
+		return errors.New("invalid key length")
+	}
+	return nil
+}
+
+// checkPubKeyKey checks that a key is its type followed by a compressed or uncompressed public key
+func checkPubKeyKey(k []byte) error {
+	if len(k) != 1+len(cipher.PubKey{}) && len(k) != 1+65 {
+		return errors.New("invalid public key")
+	}
+	return nil
+}
+
+func parseTxOut(v []byte) (*wire.TxOut, error) {
+	r := bytes.NewReader(v)
+
+	var value int64
+	if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+		return nil, err
+	}
+
+	script, err := wire.ReadVarBytes(r, 0, maxPSBTValueSize, "pkScript")
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, errors.New("invalid txout")
+	}
+
+	return wire.NewTxOut(value, script), nil
+}
+
+func parseWitness(v []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(v)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(v)) {
+		return nil, errors.New("invalid witness")
+	}
+
+	w := make(wire.TxWitness, n)
+	for i := range w {
+		w[i], err = wire.ReadVarBytes(r, 0, maxPSBTValueSize, "witness item")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Len() != 0 {
+		return nil, errors.New("invalid witness")
+	}
+
+	return w, nil
+}
+
+func parseBip32Derivation(k, v []byte) (Bip32Derivation, error) {
+	if err := checkPubKeyKey(k); err != nil {
+		return Bip32Derivation{}, err
+	}
+
+	if len(v) < 4 || len(v)%4 != 0 {
+		return Bip32Derivation{}, errors.New("invalid bip32 derivation")
+	}
+
+	d := Bip32Derivation{
+		PubKey: k[1:],
+		Path:   make([]uint32, len(v)/4-1),
+	}
+	copy(d.MasterFingerprint[:], v[:4])
+	for i := range d.Path {
+		d.Path[i] = binary.LittleEndian.Uint32(v[4+4*i:])
+	}
+
+	return d, nil
+}
+
+// Serialize returns the bip174 serialization of the PSBT
+func (p *PSBT) Serialize() ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(psbtMagic)
+
+	var tx bytes.Buffer
+	if err := p.UnsignedTx.SerializeNoWitness(&tx); err != nil {
+		return nil, err
+	}
+	writePSBTPair(&b, []byte{psbtGlobalUnsignedTx}, tx.Bytes())
+	writeUnknown(&b, p.Unknown)
+	b.WriteByte(0x00)
+
+	for _, in := range p.Inputs {
+		if in.NonWitnessUtxo != nil {
+			var tx bytes.Buffer
+			if err := in.NonWitnessUtxo.Serialize(&tx); err != nil {
+				return nil, err
+			}
+			writePSBTPair(&b, []byte{psbtInNonWitnessUtxo}, tx.Bytes())
+		}
+
+		if in.WitnessUtxo != nil {
+			var out bytes.Buffer
+			if err := wire.WriteTxOut(&out, 0, 0, in.WitnessUtxo); err != nil {
+				return nil, err
+			}
+			writePSBTPair(&b, []byte{psbtInWitnessUtxo}, out.Bytes())
+		}
+
+		for _, s := range in.PartialSigs {
+			writePSBTPair(&b, append([]byte{psbtInPartialSig}, s.PubKey...), s.Signature)
+		}
+
+		if in.SighashType != 0 {
+			var v [4]byte
+			binary.LittleEndian.PutUint32(v[:], in.SighashType)
+			writePSBTPair(&b, []byte{psbtInSighashType}, v[:])
+		}
+
+		if in.RedeemScript != nil {
+			writePSBTPair(&b, []byte{psbtInRedeemScript}, in.RedeemScript)
+		}
+
+		if in.WitnessScript != nil {
+			writePSBTPair(&b, []byte{psbtInWitnessScript}, in.WitnessScript)
+		}
+
+		writeBip32Derivation(&b, psbtInBip32Derivation, in.Bip32Derivation)
+
+		if in.FinalScriptSig != nil {
+			writePSBTPair(&b, []byte{psbtInFinalScriptSig}, in.FinalScriptSig)
+		}
+
+		if in.FinalScriptWitness != nil {
+			var w bytes.Buffer
+			if err := wire.WriteVarInt(&w, 0, uint64(len(in.FinalScriptWitness))); err != nil {
+				return nil, err
+			}
+			for _, item := range in.FinalScriptWitness {
+				if err := wire.WriteVarBytes(&w, 0, item); err != nil {
+					return nil, err
+				}
+			}
+			writePSBTPair(&b, []byte{psbtInFinalScriptWitness}, w.Bytes())
+		}
+
+		writeUnknown(&b, in.Unknown)
+		b.WriteByte(0x00)
+	}
+
+	for _, out := range p.Outputs {
+		if out.RedeemScript != nil {
+			writePSBTPair(&b, []byte{psbtOutRedeemScript}, out.RedeemScript)
+		}
+
+		if out.WitnessScript != nil {
+			writePSBTPair(&b, []byte{psbtOutWitnessScript}, out.WitnessScript)
+		}
+
+		writeBip32Derivation(&b, psbtOutBip32Derivation, out.Bip32Derivation)
+		writeUnknown(&b, out.Unknown)
+		b.WriteByte(0x00)
+	}
+
+	return b.Bytes(), nil
+}
+
+// B64Encode returns the base64 encoding of the serialized PSBT
+func (p *PSBT) B64Encode() (string, error) {
+	b, err := p.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func writePSBTPair(w io.Writer, k, v []byte) {
+	// Writes to a bytes.Buffer never fail
+	wire.WriteVarBytes(w, 0, k) //nolint:errcheck
+	wire.WriteVarBytes(w, 0, v) //nolint:errcheck
+}
+
+func writeUnknown(w io.Writer, u []PSBTUnknown) {
+	for _, kv := range u {
+		writePSBTPair(w, kv.Key, kv.Value)
+	}
+}
+
+func writeBip32Derivation(w io.Writer, keyType byte, ds []Bip32Derivation) {
+	for _, d := range ds {
+		v := make([]byte, 4+4*len(d.Path))
+		copy(v, d.MasterFingerprint[:])
+		for i, n := range d.Path {
+			binary.LittleEndian.PutUint32(v[4+4*i:], n)
+		}
+		writePSBTPair(w, append([]byte{keyType}, d.PubKey...), v)
+	}
+}
+
+// Combine merges the fields of other PSBTs of the same transaction into the PSBT, e.g. the partial signatures of co-signers.
+// Fields which are already set are kept.
+func (p *PSBT) Combine(others ...*PSBT) error {
+	txid := p.UnsignedTx.TxHash()
+	for _, o := range others {
+		if o.UnsignedTx.TxHash() != txid {
+			return ErrPSBTTxMismatch
+		}
+	}
+
+	for _, o := range others {
+		p.Unknown = combineUnknown(p.Unknown, o.Unknown)
+
+		for i := range p.Inputs {
+			in, oin := &p.Inputs[i], &o.Inputs[i]
+			if in.NonWitnessUtxo == nil {
+				in.NonWitnessUtxo = oin.NonWitnessUtxo
+			}
+			if in.WitnessUtxo == nil {
+				in.WitnessUtxo = oin.WitnessUtxo
+			}
+			for _, s := range oin.PartialSigs {
+				if in.partialSig(s.PubKey) == nil {
+					in.PartialSigs = append(in.PartialSigs, s)
+				}
+			}
+			if in.SighashType == 0 {
+				in.SighashType = oin.SighashType
+			}
+			if in.RedeemScript == nil {
+				in.RedeemScript = oin.RedeemScript
+			}
+			if in.WitnessScript == nil {
+				in.WitnessScript = oin.WitnessScript
+			}
+			in.Bip32Derivation = combineBip32Derivation(in.Bip32Derivation, oin.Bip32Derivation)
+			if in.FinalScriptSig == nil {
+				in.FinalScriptSig = oin.FinalScriptSig
+			}
+			if in.FinalScriptWitness == nil {
+				in.FinalScriptWitness = oin.FinalScriptWitness
+			}
+			in.Unknown = combineUnknown(in.Unknown, oin.Unknown)
+		}
+
+		for i := range p.Outputs {
+			out, oout := &p.Outputs[i], &o.Outputs[i]
+			if out.RedeemScript == nil {
+				out.RedeemScript = oout.RedeemScript
+			}
+			if out.WitnessScript == nil {
+				out.WitnessScript = oout.WitnessScript
+			}
+			out.Bip32Derivation = combineBip32Derivation(out.Bip32Derivation, oout.Bip32Derivation)
+			out.Unknown = combineUnknown(out.Unknown, oout.Unknown)
+		}
+	}
+
+	return nil
+}
+
+func combineBip32Derivation(a, b []Bip32Derivation) []Bip32Derivation {
+	for _, d := range b {
+		if !hasBip32Derivation(a, d.PubKey) {
+			a = append(a, d)
+		}
+	}
+	return a
+}
+
+func hasBip32Derivation(ds []Bip32Derivation, pubKey []byte) bool {
+	for _, d := range ds {
+		if bytes.Equal(d.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func combineUnknown(a, b []PSBTUnknown) []PSBTUnknown {
+	for _, kv := range b {
+		found := false
+		for _, kv2 := range a {
+			if bytes.Equal(kv.Key, kv2.Key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, kv)
+		}
+	}
+	return a
+}
+
+// AddBip32Derivation adds the derivation path of a public key to the input, unless it already has one
+func (in *PSBTInput) AddBip32Derivation(d Bip32Derivation) {
+	in.Bip32Derivation = combineBip32Derivation(in.Bip32Derivation, []Bip32Derivation{d})
+}
+
+// AddBip32Derivation adds the derivation path of a public key to the output, unless it already has one
+func (out *PSBTOutput) AddBip32Derivation(d Bip32Derivation) {
+	out.Bip32Derivation = combineBip32Derivation(out.Bip32Derivation, []Bip32Derivation{d})
+}
+
+// partialSig returns the partial signature of a public key, or nil
+func (in *PSBTInput) partialSig(pubKey []byte) *PartialSig {
+	for i := range in.PartialSigs {
+		if bytes.Equal(in.PartialSigs[i].PubKey, pubKey) {
+			return &in.PartialSigs[i]
+		}
+	}
+	return nil
+}
+
+// HasPartialSig returns true if the input has a signature by a public key
+func (in *PSBTInput) HasPartialSig(pubKey []byte) bool {
+	return in.partialSig(pubKey) != nil
+}
+
+// IsFinalized returns true if the input has its final scriptSig or witness
+func (in *PSBTInput) IsFinalized() bool {
+	return in.FinalScriptSig != nil || in.FinalScriptWitness != nil
+}
+
+// Utxo returns the output spent by an input, from its witness utxo or its previous transaction
+func (p *PSBT) Utxo(i int) (*wire.TxOut, error) {
+	in := &p.Inputs[i]
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, nil
+	}
+
+	if in.NonWitnessUtxo != nil {
+		prevOut := p.UnsignedTx.TxIn[i].PreviousOutPoint
+		if in.NonWitnessUtxo.TxHash() != prevOut.Hash || int(prevOut.Index) >= len(in.NonWitnessUtxo.TxOut) {
+			return nil, fmt.Errorf("%v: previous transaction does not match the outpoint", ErrInvalidPSBT)
+		}
+		return in.NonWitnessUtxo.TxOut[prevOut.Index], nil
+	}
+
+	return nil, ErrMissingUtxo
+}
+
+// InputScriptType returns the type of the output script spent by an input, and the pubkey hash signing it.
+// P2SH inputs must have the redeem script of a P2SH-P2WPKH output, and their type is ScriptTypeP2SH.
+func (p *PSBT) InputScriptType(i int) (ScriptType, cipher.Ripemd160, error) {
+	utxo, err := p.Utxo(i)
+	if err != nil {
+		return ScriptTypeUnknown, cipher.Ripemd160{}, err
+	}
+
+	typ, h := ClassifyScript(utxo.PkScript)
+	switch typ {
+	case ScriptTypeP2PKH, ScriptTypeP2WPKH:
+	case ScriptTypeP2SH:
+		redeemScript := p.Inputs[i].RedeemScript
+		if redeemScript == nil {
+			return ScriptTypeUnknown, cipher.Ripemd160{}, fmt.Errorf("%v: P2SH input has no redeem script", ErrUnsupportedInput)
+		}
+		if rh := hash160(redeemScript); !bytes.Equal(h, rh[:]) {
+			return ScriptTypeUnknown, cipher.Ripemd160{}, fmt.Errorf("%v: redeem script does not match the P2SH script hash", ErrInvalidPSBT)
+		}
+
+		var rtyp ScriptType
+		rtyp, h = ClassifyScript(redeemScript)
+		if rtyp != ScriptTypeP2WPKH {
+			return ScriptTypeUnknown, cipher.Ripemd160{}, fmt.Errorf("%v: P2SH redeem script is not P2WPKH", ErrUnsupportedInput)
+		}
+	default:
+		return ScriptTypeUnknown, cipher.Ripemd160{}, ErrUnsupportedInput
+	}
+
+	var k cipher.Ripemd160
+	copy(k[:], h)
+	return typ, k, nil
+}
+
+// SigHash returns the SIGHASH_ALL digest signed by the key of an input: the legacy digest of P2PKH inputs,
+// and the bip143 digest of P2WPKH and P2SH-P2WPKH inputs.
+// The previous transaction of P2PKH inputs is required, since their digest doesn't commit to the amount spent.
+func (p *PSBT) SigHash(i int) (cipher.SHA256, error) {
+	if in := &p.Inputs[i]; in.SighashType != 0 && in.SighashType != SigHashAll {
+		return cipher.SHA256{}, ErrUnsupportedSigHash
+	}
+
+	typ, h, err := p.InputScriptType(i)
+	if err != nil {
+		return cipher.SHA256{}, err
+	}
+
+	if typ == ScriptTypeP2PKH {
+		if p.Inputs[i].NonWitnessUtxo == nil {
+			return cipher.SHA256{}, fmt.Errorf("%v: P2PKH input has no previous transaction", ErrMissingUtxo)
+		}
+		return legacySigHash(p.UnsignedTx, i, P2PKHScript(h)), nil
+	}
+
+	utxo, err := p.Utxo(i)
+	if err != nil {
+		return cipher.SHA256{}, err
+	}
+
+	return witnessV0SigHash(p.UnsignedTx, i, P2PKHScript(h), utxo.Value), nil
+}
+
+// legacySigHash returns the SIGHASH_ALL digest of a pre-segwit input
+func legacySigHash(tx *wire.MsgTx, idx int, scriptCode []byte) cipher.SHA256 {
+	tx = tx.Copy()
+	for i, in := range tx.TxIn {
+		in.Witness = nil
+		if i == idx {
+			in.SignatureScript = scriptCode
+		} else {
+			in.SignatureScript = nil
+		}
+	}
+
+	var b bytes.Buffer
+	// Writes to a bytes.Buffer never fail
+	tx.SerializeNoWitness(&b) //nolint:errcheck
+	writeUint32(&b, SigHashAll)
+
+	return cipher.DoubleSHA256(b.Bytes())
+}
+
+// witnessV0SigHash returns the bip143 SIGHASH_ALL digest of a segwit v0 input
+func witnessV0SigHash(tx *wire.MsgTx, idx int, scriptCode []byte, amount int64) cipher.SHA256 {
+	var prevouts, sequences, outputs bytes.Buffer
+	for _, in := range tx.TxIn {
+		prevouts.Write(in.PreviousOutPoint.Hash[:])
+		writeUint32(&prevouts, in.PreviousOutPoint.Index)
+		writeUint32(&sequences, in.Sequence)
+	}
+	for _, out := range tx.TxOut {
+		// Writes to a bytes.Buffer never fail
+		wire.WriteTxOut(&outputs, 0, 0, out) //nolint:errcheck
+	}
+
+	hashPrevouts := cipher.DoubleSHA256(prevouts.Bytes())
+	hashSequence := cipher.DoubleSHA256(sequences.Bytes())
+	hashOutputs := cipher.DoubleSHA256(outputs.Bytes())
+
+	in := tx.TxIn[idx]
+
+	var b bytes.Buffer
+	writeUint32(&b, uint32(tx.Version))
+	b.Write(hashPrevouts[:])
+	b.Write(hashSequence[:])
+	b.Write(in.PreviousOutPoint.Hash[:])
+	writeUint32(&b, in.PreviousOutPoint.Index)
+	wire.WriteVarBytes(&b, 0, scriptCode) //nolint:errcheck
+	var v [8]byte
+	binary.LittleEndian.PutUint64(v[:], uint64(amount))
+	b.Write(v[:])
+	writeUint32(&b, in.Sequence)
+	b.Write(hashOutputs[:])
+	writeUint32(&b, tx.LockTime)
+	writeUint32(&b, SigHashAll)
+
+	return cipher.DoubleSHA256(b.Bytes())
+}
+
+func writeUint32(b *bytes.Buffer, n uint32) {
+	var v [4]byte
+	binary.LittleEndian.PutUint32(v[:], n)
+	b.Write(v[:])
+}
+
+// AddPartialSig adds the SIGHASH_ALL signature of an input by a public key
+func (p *PSBT) AddPartialSig(i int, pubKey cipher.PubKey, sig cipher.Sig) {
+	in := &p.Inputs[i]
+	if in.HasPartialSig(pubKey[:]) {
+		return
+	}
+
+	in.PartialSigs = append(in.PartialSigs, PartialSig{
+		PubKey:    append([]byte(nil), pubKey[:]...),
+		Signature: append(SignatureDER(sig), SigHashAll),
+	})
+}
+
+// Finalize builds the final scriptSig and witness of the inputs which have the signature they need,
+// and removes their signing information. Inputs which can't be finalized are left as they are,
+// and the error of the first one is returned.
+func (p *PSBT) Finalize() error {
+	var firstErr error
+	for i := range p.Inputs {
+		if p.Inputs[i].IsFinalized() {
+			continue
+		}
+
+		if err := p.finalizeInput(i); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return firstErr
+}
+
+func (p *PSBT) finalizeInput(i int) error {
+	typ, h, err := p.InputScriptType(i)
+	if err != nil {
+		return err
+	}
+
+	in := &p.Inputs[i]
+
+	var sig *PartialSig
+	for j := range in.PartialSigs {
+		if hash160(in.PartialSigs[j].PubKey) == h {
+			sig = &in.PartialSigs[j]
+			break
+		}
+	}
+	if sig == nil {
+		return errors.New("input is not signed")
+	}
+
+	switch typ {
+	case ScriptTypeP2PKH:
+		in.FinalScriptSig = append(PushData(sig.Signature), PushData(sig.PubKey)...)
+	case ScriptTypeP2WPKH:
+		in.FinalScriptSig = nil
+		in.FinalScriptWitness = wire.TxWitness{sig.Signature, sig.PubKey}
+	case ScriptTypeP2SH:
+		in.FinalScriptSig = PushData(in.RedeemScript)
+		in.FinalScriptWitness = wire.TxWitness{sig.Signature, sig.PubKey}
+	}
+
+	in.PartialSigs = nil
+	in.SighashType = 0
+	in.RedeemScript = nil
+	in.WitnessScript = nil
+	in.Bip32Derivation = nil
+
+	return nil
+}
+
+// Extract returns the signed transaction of a PSBT whose inputs are all finalized
+func (p *PSBT) Extract() (*wire.MsgTx, error) {
+	tx := p.UnsignedTx.Copy()
+	for i, in := range p.Inputs {
+		if !in.IsFinalized() {
+			return nil, ErrPSBTNotFinalized
+		}
+
+		tx.TxIn[i].SignatureScript = in.FinalScriptSig
+		tx.TxIn[i].Witness = in.FinalScriptWitness
+	}
+	return tx, nil
+}
+
+// hash160 returns ripemd160(sha256(b)), the hash of P2PKH pubkeys and P2SH scripts
+func hash160(b []byte) cipher.Ripemd160 {
+	h := cipher.SumSHA256(b)
+	return cipher.HashRipemd160(h[:])
+}
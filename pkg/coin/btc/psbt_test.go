@@ -0,0 +1,108 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDecodeTx(t *testing.T, s string) *wire.MsgTx {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	require.NoError(t, tx.DeserializeNoWitness(bytes.NewReader(b)))
+	return tx
+}
+
+func mustHexDecode(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestPSBTSigHash(t *testing.T) {
+	// bip143 examples
+	t.Run("p2wpkh", func(t *testing.T) {
+		tx := mustDecodeTx(t, "0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000")
+		p, err := NewPSBT(tx)
+		require.NoError(t, err)
+		p.Inputs[1].WitnessUtxo = wire.NewTxOut(600000000, mustHexDecode(t, "00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1"))
+
+		h, err := p.SigHash(1)
+		require.NoError(t, err)
+		require.Equal(t, "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670", h.Hex())
+
+		// The P2PK input 0 isn't supported
+		p.Inputs[0].WitnessUtxo = wire.NewTxOut(625000000, mustHexDecode(t, "2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac"))
+		_, err = p.SigHash(0)
+		require.Equal(t, ErrUnsupportedInput, err)
+	})
+
+	t.Run("p2sh-p2wpkh", func(t *testing.T) {
+		tx := mustDecodeTx(t, "0100000001db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a54770100000000feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac92040000")
+		p, err := NewPSBT(tx)
+		require.NoError(t, err)
+		p.Inputs[0].WitnessUtxo = wire.NewTxOut(1000000000, mustHexDecode(t, "a9144733f37cf4db86fbc2efed2500b4f4e49f31202387"))
+
+		_, err = p.SigHash(0)
+		require.Error(t, err)
+
+		p.Inputs[0].RedeemScript = mustHexDecode(t, "001479091972186c449eb1ded22b78e40d009bdf0089")
+		h, err := p.SigHash(0)
+		require.NoError(t, err)
+		require.Equal(t, "64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6", h.Hex())
+
+		p.Inputs[0].SighashType = 0x03
+		_, err = p.SigHash(0)
+		require.Equal(t, ErrUnsupportedSigHash, err)
+	})
+}
+
+func TestPSBTSerialize(t *testing.T) {
+	// bip174 test vector with one P2PKH input and two outputs, which has an unknown global key
+	// and an input with a non-witness utxo
+	s := "cHNidP8BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAQD9pQEBAAAAAAECiaPHHqtNIOA3G7ukzGmPopXJRjr6Ljl/hTPMti+VZ+UBAAAAFxYAFL4Y0VKpsBIDna89p95PUzSe7LmF/////4b4qkOnHf8USIk6UwpyN+9rRgi7st0tAXHmOuxqSJC0AQAAABcWABT+Pp7xp0XpdNkCxDVZQ6vLNL1TU/////8CAMLrCwAAAAAZdqkUhc/xCX/Z4Ai7NK9wnGIZeziXikiIrHL++E4sAAAAF6kUM5cluiHv1irHU6m80GfWx6ajnQWHAkcwRAIgJxK+IuAnDzlPVoMR3HyppolwuAJf3TskAinwf4pfOiQCIAGLONfc0xTnNMkna9b7QPZzMlvEuqFEyADS8vAtsnZcASED0uFWdJQbrUqZY3LLh+GFbTZSYG2YVi/jnF6efkE/IQUCSDBFAiEA0SuFLYXc2WHS9fSrZgZU327tzHlMDDPOXMMJ/7X85Y0CIGczio4OFyXBl/saiK9Z9R5E5CVbIBZ8hoQDHAXR8lkqASECI7cr7vCWXRC+B3jv7NYfysb3mk6haTkzgHNEZPhPKrMAAAAAAAAA"
+	p, err := DecodePSBT(s)
+	require.NoError(t, err)
+	require.Len(t, p.Inputs, 1)
+	require.Len(t, p.Outputs, 2)
+	require.NotNil(t, p.Inputs[0].NonWitnessUtxo)
+	require.Equal(t, p.UnsignedTx.TxIn[0].PreviousOutPoint.Hash, p.Inputs[0].NonWitnessUtxo.TxHash())
+
+	s2, err := p.B64Encode()
+	require.NoError(t, err)
+	require.Equal(t, s, s2)
+
+	// Unknown keys are kept
+	p.Inputs[0].Unknown = append(p.Inputs[0].Unknown, PSBTUnknown{Key: []byte{0xf0, 0x01}, Value: []byte{0x02}})
+	b, err := p.Serialize()
+	require.NoError(t, err)
+	p2, err := ParsePSBT(b)
+	require.NoError(t, err)
+	require.Equal(t, p.Inputs[0].Unknown, p2.Inputs[0].Unknown)
+
+	for _, s := range []string{
+		// Not base64
+		"cHNidP8B!",
+		// Bad magic
+		"cHNidP4BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAA==",
+		// No unsigned tx
+		"cHNidP8AAA==",
+		// Missing input and output maps
+		"cHNidP8BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAA==",
+	} {
+		_, err := DecodePSBT(s)
+		require.Error(t, err, s)
+	}
+
+	// Duplicate keys are invalid
+	i := bytes.Index(b, []byte{0x01, 0x00, 0xfd})
+	require.True(t, i > 0)
+	dup := append(append([]byte{}, b[:i]...), []byte{0x01, 0x07, 0x00, 0x01, 0x07, 0x00}...)
+	dup = append(dup, b[i:]...)
+	_, err = ParsePSBT(dup)
+	require.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package btc
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// Script opcodes of the standard output scripts
+const (
+	opPushData1   = 0x4c
+	opPushData2   = 0x4d
+	opDup         = 0x76
+	opEqual       = 0x87
+	opEqualVerify = 0x88
+	opHash160     = 0xa9
+	opCheckSig    = 0xac
+	op1           = 0x51
+)
+
+// ErrUnsupportedAddress is returned when making the output script of an address type which isn't supported
+var ErrUnsupportedAddress = errors.New("unsupported bitcoin address type")
+
+// ScriptType is the type of a standard output script
+type ScriptType int
+
+// Standard output script types
+const (
+	ScriptTypeUnknown ScriptType = iota
+	ScriptTypeP2PKH
+	ScriptTypeP2SH
+	ScriptTypeP2WPKH
+	ScriptTypeP2TR
+)
+
+// PayToAddrScript returns the output script paying to an address
+func PayToAddrScript(addr cipher.Addresser) ([]byte, error) {
+	switch a := addr.(type) {
+	case cipher.BitcoinAddress:
+		return P2PKHScript(a.Key), nil
+	case NestedSegwitAddress:
+		return P2SHScript(a.Key), nil
+	case SegwitAddress:
+		return append([]byte{witnessVersionP2WPKH, byte(len(a.Key))}, a.Key[:]...), nil
+	case TaprootAddress:
+		return append([]byte{op1, byte(len(a.Key))}, a.Key[:]...), nil
+	default:
+		return nil, ErrUnsupportedAddress
+	}
+}
+
+// ScriptAddress returns the address of a standard output script, see PayToAddrScript
+func ScriptAddress(script []byte) (cipher.Addresser, error) {
+	typ, h := ClassifyScript(script)
+	switch typ {
+	case ScriptTypeP2PKH:
+		a := cipher.BitcoinAddress{}
+		copy(a.Key[:], h)
+		return a, nil
+	case ScriptTypeP2SH:
+		a := NestedSegwitAddress{}
+		copy(a.Key[:], h)
+		return a, nil
+	case ScriptTypeP2WPKH:
+		a := SegwitAddress{}
+		copy(a.Key[:], h)
+		return a, nil
+	case ScriptTypeP2TR:
+		a := TaprootAddress{}
+		copy(a.Key[:], h)
+		return a, nil
+	default:
+		return nil, ErrUnsupportedAddress
+	}
+}
+
+// P2PKHScript returns the pay-to-pubkey-hash script of a pubkey hash, which is also the bip143 script code of P2WPKH outputs:
+// OP_DUP OP_HASH160 <20 byte hash> OP_EQUALVERIFY OP_CHECKSIG
+func P2PKHScript(h cipher.Ripemd160) []byte {
+	s := append([]byte{opDup, opHash160, byte(len(h))}, h[:]...)
+	return append(s, opEqualVerify, opCheckSig)
+}
+
+// P2SHScript returns the pay-to-script-hash script of a script hash: OP_HASH160 <20 byte hash> OP_EQUAL
+func P2SHScript(h cipher.Ripemd160) []byte {
+	s := append([]byte{opHash160, byte(len(h))}, h[:]...)
+	return append(s, opEqual)
+}
+
+// ClassifyScript returns the type of a standard output script, and its pubkey hash, script hash or witness program
+func ClassifyScript(script []byte) (ScriptType, []byte) {
+	switch {
+	case len(script) == 25 && script[0] == opDup && script[1] == opHash160 && script[2] == 20 &&
+		script[23] == opEqualVerify && script[24] == opCheckSig:
+		return ScriptTypeP2PKH, script[3:23]
+	case len(script) == 23 && script[0] == opHash160 && script[1] == 20 && script[22] == opEqual:
+		return ScriptTypeP2SH, script[2:22]
+	case len(script) == 22 && script[0] == witnessVersionP2WPKH && script[1] == 20:
+		return ScriptTypeP2WPKH, script[2:]
+	case len(script) == 34 && script[0] == op1 && script[1] == 32:
+		return ScriptTypeP2TR, script[2:]
+	default:
+		return ScriptTypeUnknown, nil
+	}
+}
+
+// PushData returns a script pushing data onto the stack
+func PushData(data []byte) []byte {
+	var b bytes.Buffer
+	switch n := len(data); {
+	case n < opPushData1:
+		b.WriteByte(byte(n))
+	case n <= 0xff:
+		b.WriteByte(opPushData1)
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(opPushData2)
+		b.WriteByte(byte(n))
+		b.WriteByte(byte(n >> 8))
+	}
+	b.Write(data)
+	return b.Bytes()
+}
+
+// SignatureDER returns the strict DER encoding of the [r|s] values of a signature, as bitcoin scripts use them
+func SignatureDER(sig cipher.Sig) []byte {
+	r := derInteger(sig[:32])
+	s := derInteger(sig[32:64])
+
+	b := make([]byte, 0, 6+len(r)+len(s))
+	b = append(b, 0x30, byte(4+len(r)+len(s)))
+	b = append(b, 0x02, byte(len(r)))
+	b = append(b, r...)
+	b = append(b, 0x02, byte(len(s)))
+	return append(b, s...)
+}
+
+// derInteger returns the minimal encoding of a positive big-endian integer as a DER integer
+func derInteger(b []byte) []byte {
+	b = new(big.Int).SetBytes(b).Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		return append([]byte{0x00}, b...)
+	}
+	return b
+}
@@ -0,0 +1,83 @@
+package btc
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/base58"
+)
+
+// SLIP-132 version bytes of serialized extended public keys.
+// The version prefix indicates the address type the key is used for.
+var (
+	// XPubVersion is the version of extended public keys for p2pkh addresses ("xpub")
+	XPubVersion = []byte{0x04, 0x88, 0xB2, 0x1E}
+	// YPubVersion is the version of extended public keys for p2sh-p2wpkh addresses ("ypub")
+	YPubVersion = []byte{0x04, 0x9D, 0x7C, 0xB2}
+	// ZPubVersion is the version of extended public keys for p2wpkh addresses ("zpub")
+	ZPubVersion = []byte{0x04, 0xB2, 0x47, 0x46}
+)
+
+var (
+	// ErrInvalidExtendedKey is returned when a serialized extended key can't be decoded
+	ErrInvalidExtendedKey = errors.New("invalid serialized extended key")
+	// ErrUnknownExtendedKeyVersion is returned when a serialized extended key has an unknown version
+	ErrUnknownExtendedKeyVersion = errors.New("unknown extended public key version")
+)
+
+const serializedExtendedKeyLen = 82
+
+func decodeExtendedKey(key string) ([]byte, error) {
+	b, err := base58.Decode(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) != serializedExtendedKeyLen {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	cs := cipher.DoubleSHA256(b[:len(b)-4])
+	if !bytes.Equal(cs[:4], b[len(b)-4:]) {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	return b, nil
+}
+
+// ExtendedPublicKeyVersion returns the SLIP-132 version of a base58 serialized extended public key
+func ExtendedPublicKeyVersion(key string) ([]byte, error) {
+	b, err := decodeExtendedKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := b[:4]
+	for _, known := range [][]byte{XPubVersion, YPubVersion, ZPubVersion} {
+		if bytes.Equal(v, known) {
+			return known, nil
+		}
+	}
+
+	return nil, ErrUnknownExtendedKeyVersion
+}
+
+// ConvertExtendedPublicKey re-encodes a base58 serialized extended public key with another SLIP-132 version,
+// e.g. to convert a zpub to the equivalent xpub
+func ConvertExtendedPublicKey(key string, version []byte) (string, error) {
+	if _, err := ExtendedPublicKeyVersion(key); err != nil {
+		return "", err
+	}
+
+	b, err := decodeExtendedKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	copy(b[:4], version)
+	cs := cipher.DoubleSHA256(b[:len(b)-4])
+	copy(b[len(b)-4:], cs[:4])
+
+	return base58.Encode(b), nil
+}
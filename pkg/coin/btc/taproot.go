@@ -0,0 +1,166 @@
+package btc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+const (
+	// witnessVersionTaproot is the witness version of taproot outputs
+	witnessVersionTaproot = 0x01
+
+	// tagTapTweak is the bip340 tagged hash tag of the taproot output key tweak
+	tagTapTweak = "TapTweak"
+)
+
+var (
+	// ErrInvalidTaprootTweak is returned if the taproot tweak of a key is not a valid scalar,
+	// which happens with negligible probability
+	ErrInvalidTaprootTweak = errors.New("invalid taproot tweak")
+)
+
+// TaprootAddress is a bip86 single key pay-to-taproot (P2TR) address
+type TaprootAddress struct {
+	Key [32]byte // 32 byte x-only output key, the tweaked internal key
+}
+
+// TaggedHash computes the bip340 tagged hash sha256(sha256(tag) || sha256(tag) || msg)
+func TaggedHash(tag string, msg ...[]byte) [32]byte {
+	th := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(th[:]) //nolint:errcheck
+	h.Write(th[:]) //nolint:errcheck
+	for _, m := range msg {
+		h.Write(m) //nolint:errcheck
+	}
+
+	var r [32]byte
+	copy(r[:], h.Sum(nil))
+	return r
+}
+
+// XOnlyPubKey returns the 32 byte x-only (bip340) encoding of a compressed PubKey
+func XOnlyPubKey(pubKey cipher.PubKey) [32]byte {
+	var x [32]byte
+	copy(x[:], pubKey[1:])
+	return x
+}
+
+// TaprootOutputKey returns the x-only taproot output key of an internal key with no script tree,
+// Q = lift_x(P) + hashTapTweak(x(P))G
+func TaprootOutputKey(internalKey cipher.PubKey) ([32]byte, error) {
+	curve := btcec.S256()
+
+	pk, err := btcec.ParsePubKey(internalKey[:], curve)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	// lift_x: the internal key is used with an even y coordinate
+	px := pk.X
+	py := pk.Y
+	if py.Bit(0) == 1 {
+		py = new(big.Int).Sub(curve.P, py)
+	}
+
+	xOnly := XOnlyPubKey(internalKey)
+	t := TaggedHash(tagTapTweak, xOnly[:])
+	if new(big.Int).SetBytes(t[:]).Cmp(curve.N) >= 0 {
+		return [32]byte{}, ErrInvalidTaprootTweak
+	}
+
+	tx, ty := curve.ScalarBaseMult(t[:])
+	qx, qy := curve.Add(px, py, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return [32]byte{}, ErrInvalidTaprootTweak
+	}
+
+	var q [32]byte
+	b := qx.Bytes()
+	copy(q[32-len(b):], b)
+	return q, nil
+}
+
+// TaprootAddressFromPubKey creates a mainnet TaprootAddress from a compressed internal PubKey
+func TaprootAddressFromPubKey(pubKey cipher.PubKey) (TaprootAddress, error) {
+	q, err := TaprootOutputKey(pubKey)
+	if err != nil {
+		return TaprootAddress{}, err
+	}
+
+	return TaprootAddress{
+		Key: q,
+	}, nil
+}
+
+// MustTaprootAddressFromPubKey creates a mainnet TaprootAddress from a compressed internal PubKey, panics on error
+func MustTaprootAddressFromPubKey(pubKey cipher.PubKey) TaprootAddress {
+	a, err := TaprootAddressFromPubKey(pubKey)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// DecodeBech32mTaprootAddress creates a TaprootAddress from its bech32m encoding
+func DecodeBech32mTaprootAddress(addr string) (TaprootAddress, error) {
+	version, program, err := decodeSegwit(addr)
+	if err != nil {
+		return TaprootAddress{}, err
+	}
+
+	if version != witnessVersionTaproot {
+		return TaprootAddress{}, ErrInvalidWitnessVersion
+	}
+
+	if len(program) != 32 {
+		return TaprootAddress{}, ErrInvalidWitnessProgram
+	}
+
+	var a TaprootAddress
+	copy(a.Key[:], program)
+	return a, nil
+}
+
+// Null returns true if the address is null (0x0000....)
+func (addr TaprootAddress) Null() bool {
+	return addr == TaprootAddress{}
+}
+
+// Bytes returns the witness version followed by the witness program
+func (addr TaprootAddress) Bytes() []byte {
+	return append([]byte{witnessVersionTaproot}, addr.Key[:]...)
+}
+
+// Verify checks that the address output key is the tweaked x-only internal key
+func (addr TaprootAddress) Verify(key cipher.PubKey) error {
+	q, err := TaprootOutputKey(key)
+	if err != nil {
+		return err
+	}
+
+	if addr.Key != q {
+		return cipher.ErrAddressInvalidPubKey
+	}
+
+	return nil
+}
+
+// String converts the taproot address to its bech32m encoding
+func (addr TaprootAddress) String() string {
+	s, err := encodeSegwit(witnessVersionTaproot, addr.Key[:])
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Checksum returns an empty checksum; the bech32m checksum is part of the encoded string
+func (addr TaprootAddress) Checksum() cipher.Checksum {
+	return cipher.Checksum{}
+}
@@ -2,6 +2,8 @@ package eth
 
 import (
 	"crypto/subtle"
+	"errors"
+	"strings"
 
 	"github.com/SkycoinProject/skycoin/src/cipher/bip44"
 
@@ -15,6 +17,13 @@ const (
 	CoinTypeEthereum bip44.CoinType = 60
 )
 
+var (
+	// ErrInvalidAddress is returned when decoding a string that is not a 20 byte hex address
+	ErrInvalidAddress = errors.New("invalid ethereum address")
+	// ErrInvalidAddressChecksum is returned when decoding a mixed case address with an invalid EIP-55 checksum
+	ErrInvalidAddressChecksum = errors.New("invalid ethereum address checksum")
+)
+
 // EthereumAddress is a eth address
 type EthereumAddress struct {
 	Addr common.Address // 20 byte address of an Ethereum account
@@ -57,3 +66,25 @@ func DecodeHexToEthereumAddress(addr string) EthereumAddress {
 		Addr: common.HexToAddress(addr),
 	}
 }
+
+// DecodeEthereumAddress creates an EthereumAddress from a hex string, validating the
+// EIP-55 checksum of mixed case addresses. All lowercase or uppercase addresses have no checksum.
+func DecodeEthereumAddress(addr string) (EthereumAddress, error) {
+	if !common.IsHexAddress(addr) {
+		return EthereumAddress{}, ErrInvalidAddress
+	}
+
+	a := common.HexToAddress(addr)
+
+	h := addr
+	if strings.HasPrefix(h, "0x") || strings.HasPrefix(h, "0X") {
+		h = h[2:]
+	}
+	if h != strings.ToLower(h) && h != strings.ToUpper(h) && h != a.Hex()[2:] {
+		return EthereumAddress{}, ErrInvalidAddressChecksum
+	}
+
+	return EthereumAddress{
+		Addr: a,
+	}, nil
+}
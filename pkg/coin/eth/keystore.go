@@ -0,0 +1,312 @@
+package eth
+
+import (
+	"bytes"
+	"crypto/aes"
+	gocipher "crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/pbkdf2"
+	"github.com/SkycoinProject/skycoin/src/cipher/scrypt"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Web3 Secret Storage (keystore V3) key file format, as used by geth, Clef and MetaMask.
+// See https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
+
+const (
+	// StandardScryptN is the N parameter of scrypt used by geth by default
+	StandardScryptN = 1 << 18
+	// StandardScryptP is the P parameter of scrypt used by geth by default
+	StandardScryptP = 1
+	// LightScryptN is the N parameter of scrypt used by geth with the --lightkdf flag
+	LightScryptN = 1 << 12
+	// LightScryptP is the P parameter of scrypt used by geth with the --lightkdf flag
+	LightScryptP = 6
+
+	keystoreVersion      = 3
+	keystoreCipher       = "aes-128-ctr"
+	keystoreKDFScrypt    = "scrypt"
+	keystoreKDFPBKDF2    = "pbkdf2"
+	keystorePBKDF2PRF    = "hmac-sha256"
+	keystoreScryptR      = 8
+	keystoreDKLen        = 32
+	keystoreSaltLen      = 32
+	keystoreMinSaltLen   = 16
+	keystoreFileNameTime = "2006-01-02T15-04-05.000000000Z"
+
+	// The kdf params of imported keystores are bounded, so that a hostile keystore can't exhaust memory or cpu.
+	// The scrypt memory use is 128*n*r bytes, at most 1 GiB.
+	keystoreMaxScryptN    = 1 << 20
+	keystoreMaxScryptR    = 8
+	keystoreMaxScryptP    = 16
+	keystoreMaxPBKDF2Iter = 1 << 24
+)
+
+var (
+	// ErrKeystoreDecrypt is returned when the keystore MAC does not match, usually due to a wrong password
+	ErrKeystoreDecrypt = errors.New("could not decrypt keystore with the given password")
+	// ErrKeystoreVersion is returned when the keystore is not version 3
+	ErrKeystoreVersion = errors.New("unsupported keystore version")
+	// ErrKeystoreCipher is returned when the keystore cipher is not aes-128-ctr
+	ErrKeystoreCipher = errors.New("unsupported keystore cipher")
+	// ErrKeystoreKDF is returned when the keystore key derivation function is not scrypt or pbkdf2
+	ErrKeystoreKDF = errors.New("unsupported keystore key derivation function")
+	// ErrKeystoreAddressMismatch is returned when the keystore address does not match the decrypted key
+	ErrKeystoreAddressMismatch = errors.New("keystore address does not match the decrypted key")
+)
+
+type keystoreJSON struct {
+	Address string         `json:"address"`
+	Crypto  keystoreCrypto `json:"crypto"`
+	ID      string         `json:"id"`
+	Version int            `json:"version"`
+}
+
+type keystoreCrypto struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams keystoreCipherParams   `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type keystoreCipherParams struct {
+	IV string `json:"iv"`
+}
+
+// EncryptKeystore encrypts a secret key into keystore V3 JSON,
+// deriving the encryption key from the password with scrypt
+func EncryptKeystore(sk cipher.SecKey, password []byte, scryptN, scryptP int) ([]byte, error) {
+	pk, err := cipher.PubKeyFromSecKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	addr := EthereumAddressFromPubKey(pk)
+
+	salt := make([]byte, keystoreSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key(password, salt, scryptN, keystoreScryptR, scryptP, keystoreDKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aesCTRXOR(derivedKey[:16], sk[:], iv)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(keystoreJSON{
+		Address: hex.EncodeToString(addr.Bytes()),
+		Crypto: keystoreCrypto{
+			Cipher:     keystoreCipher,
+			CipherText: hex.EncodeToString(cipherText),
+			CipherParams: keystoreCipherParams{
+				IV: hex.EncodeToString(iv),
+			},
+			KDF: keystoreKDFScrypt,
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     keystoreScryptR,
+				"p":     scryptP,
+				"dklen": keystoreDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      id,
+		Version: keystoreVersion,
+	})
+}
+
+// DecryptKeystore decrypts keystore V3 JSON with a password, returning the secret key.
+// Both the scrypt and pbkdf2 key derivation functions are supported.
+func DecryptKeystore(keyjson, password []byte) (cipher.SecKey, error) {
+	var k keystoreJSON
+	if err := json.Unmarshal(keyjson, &k); err != nil {
+		return cipher.SecKey{}, err
+	}
+
+	if k.Version != keystoreVersion {
+		return cipher.SecKey{}, ErrKeystoreVersion
+	}
+
+	if k.Crypto.Cipher != keystoreCipher {
+		return cipher.SecKey{}, ErrKeystoreCipher
+	}
+
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return cipher.SecKey{}, fmt.Errorf("invalid keystore mac: %v", err)
+	}
+
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return cipher.SecKey{}, fmt.Errorf("invalid keystore iv: %v", err)
+	}
+
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return cipher.SecKey{}, fmt.Errorf("invalid keystore ciphertext: %v", err)
+	}
+
+	derivedKey, err := keystoreDerivedKey(k.Crypto, password)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+
+	if subtle.ConstantTimeCompare(crypto.Keccak256(derivedKey[16:32], cipherText), mac) == 0 {
+		return cipher.SecKey{}, ErrKeystoreDecrypt
+	}
+
+	plainText, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+
+	sk, err := cipher.NewSecKey(plainText)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+
+	pk, err := cipher.PubKeyFromSecKey(sk)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+
+	// The address is optional, but must match the key if present
+	if k.Address != "" {
+		addr, err := hex.DecodeString(k.Address)
+		if err != nil {
+			return cipher.SecKey{}, fmt.Errorf("invalid keystore address: %v", err)
+		}
+		if !bytes.Equal(addr, EthereumAddressFromPubKey(pk).Bytes()) {
+			return cipher.SecKey{}, ErrKeystoreAddressMismatch
+		}
+	}
+
+	return sk, nil
+}
+
+// KeystoreFileName returns the file name geth uses for the keystore file of an address,
+// e.g. UTC--2020-03-23T10-13-39.123456789Z--008aeeda4d805471df9b2a5b0f38a0c3bcba786b
+func KeystoreFileName(t time.Time, addr EthereumAddress) string {
+	return fmt.Sprintf("UTC--%s--%s", t.UTC().Format(keystoreFileNameTime), hex.EncodeToString(addr.Bytes()))
+}
+
+func keystoreDerivedKey(c keystoreCrypto, password []byte) ([]byte, error) {
+	salt, err := hex.DecodeString(keystoreParamString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore salt: %v", err)
+	}
+	if len(salt) < keystoreMinSaltLen {
+		return nil, fmt.Errorf("invalid keystore salt length %d", len(salt))
+	}
+
+	dkLen := keystoreParamInt(c.KDFParams, "dklen")
+	if dkLen != keystoreDKLen {
+		return nil, fmt.Errorf("invalid keystore dklen %d", dkLen)
+	}
+
+	switch c.KDF {
+	case keystoreKDFScrypt:
+		n := keystoreParamInt(c.KDFParams, "n")
+		r := keystoreParamInt(c.KDFParams, "r")
+		p := keystoreParamInt(c.KDFParams, "p")
+		if n <= 1 || n > keystoreMaxScryptN || n&(n-1) != 0 {
+			return nil, fmt.Errorf("invalid keystore scrypt n %d", n)
+		}
+		if r <= 0 || r > keystoreMaxScryptR {
+			return nil, fmt.Errorf("invalid keystore scrypt r %d", r)
+		}
+		if p <= 0 || p > keystoreMaxScryptP {
+			return nil, fmt.Errorf("invalid keystore scrypt p %d", p)
+		}
+		return scrypt.Key(password, salt, n, r, p, dkLen)
+
+	case keystoreKDFPBKDF2:
+		if prf := keystoreParamString(c.KDFParams, "prf"); prf != keystorePBKDF2PRF {
+			return nil, fmt.Errorf("unsupported keystore pbkdf2 prf %q", prf)
+		}
+		iter := keystoreParamInt(c.KDFParams, "c")
+		if iter <= 0 || iter > keystoreMaxPBKDF2Iter {
+			return nil, fmt.Errorf("invalid keystore pbkdf2 iteration count %d", iter)
+		}
+		return pbkdf2.Key(password, salt, iter, dkLen, sha256.New), nil
+
+	default:
+		return nil, ErrKeystoreKDF
+	}
+}
+
+// keystoreParamInt returns an integer kdf param, JSON numbers are decoded as float64.
+// Missing, fractional and out of range params are returned as 0, which is invalid for every param.
+func keystoreParamInt(params map[string]interface{}, name string) int {
+	switch v := params[name].(type) {
+	case float64:
+		if v != math.Trunc(v) || v < 0 || v > math.MaxInt32 {
+			return 0
+		}
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+func keystoreParamString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+func aesCTRXOR(key, in, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid keystore iv length %d", len(iv))
+	}
+
+	out := make([]byte, len(in))
+	gocipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	u := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, u); err != nil {
+		return "", err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
@@ -0,0 +1,70 @@
+package eth
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidMessageSignature is returned when a message signature is malformed or wasn't made by the address
+var ErrInvalidMessageSignature = errors.New("invalid message signature")
+
+// TextHash returns the digest signed by an EIP-191 personal_sign signature,
+// keccak256("\x19Ethereum Signed Message:\n" + len(msg) + msg)
+func TextHash(msg []byte) cipher.SHA256 {
+	h := crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n"+strconv.Itoa(len(msg))), msg)
+	return cipher.MustSHA256FromBytes(h)
+}
+
+// EncodeSignature encodes a recoverable signature as 0x prefixed hex [r|s|v], with v = 27 + recovery id
+func EncodeSignature(sig cipher.Sig) string {
+	b := sig
+	b[64] += 27
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// DecodeSignature decodes a 0x prefixed hex [r|s|v] signature, with v either 27 + recovery id or the recovery id
+func DecodeSignature(signature string) (cipher.Sig, error) {
+	if strings.HasPrefix(signature, "0x") || strings.HasPrefix(signature, "0X") {
+		signature = signature[2:]
+	}
+
+	b, err := hex.DecodeString(signature)
+	if err != nil || len(b) != len(cipher.Sig{}) {
+		return cipher.Sig{}, ErrInvalidMessageSignature
+	}
+
+	var sig cipher.Sig
+	copy(sig[:], b)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return cipher.Sig{}, ErrInvalidMessageSignature
+	}
+
+	return sig, nil
+}
+
+// VerifyMessage checks that an EIP-191 personal_sign signature of a message was made by the key of an address
+func VerifyMessage(addr EthereumAddress, msg []byte, signature string) error {
+	sig, err := DecodeSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	pk, err := cipher.PubKeyFromSig(sig, TextHash(msg))
+	if err != nil {
+		return ErrInvalidMessageSignature
+	}
+
+	if EthereumAddressFromPubKey(pk) != addr {
+		return ErrInvalidMessageSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,531 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Transaction types
+const (
+	// LegacyTxType is the type of legacy transactions, which are signed with EIP-155 replay protection
+	LegacyTxType = 0x00
+	// DynamicFeeTxType is the EIP-2718 type of EIP-1559 dynamic fee transactions
+	DynamicFeeTxType = 0x02
+)
+
+var (
+	// ErrInvalidTransaction is returned when decoding a raw transaction which isn't a valid legacy or EIP-1559 transaction
+	ErrInvalidTransaction = errors.New("invalid ethereum transaction")
+	// ErrUnsupportedTxType is returned for transaction types other than legacy and EIP-1559 transactions
+	ErrUnsupportedTxType = errors.New("unsupported ethereum transaction type")
+	// ErrTxUnsigned is returned when encoding or recovering the sender of a transaction which isn't signed
+	ErrTxUnsigned = errors.New("ethereum transaction is not signed")
+	// ErrInvalidTxSignature is returned when the signature values of a transaction are invalid
+	ErrInvalidTxSignature = errors.New("invalid ethereum transaction signature")
+)
+
+// AccessTuple is an EIP-2930 access list entry, the storage keys of an address accessed by a transaction
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessList is an EIP-2930 access list
+type AccessList []AccessTuple
+
+// Transaction is an ethereum transaction, either a legacy transaction or an EIP-1559 dynamic fee transaction.
+// Legacy transactions pay GasPrice, and dynamic fee transactions pay GasTipCap and GasFeeCap.
+// V, R and S are the signature values, nil if the transaction isn't signed.
+type Transaction struct {
+	Type       uint8
+	ChainID    *big.Int // nil for legacy transactions signed without EIP-155 replay protection, which are only decoded
+	Nonce      uint64
+	GasPrice   *big.Int
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *EthereumAddress // nil for contract creations
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+
+	V *big.Int
+	R *big.Int
+	S *big.Int
+}
+
+// legacyTx is the RLP encoding of a legacy transaction
+type legacyTx struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       []byte
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+// dynamicFeeTx is the RLP encoding of an EIP-1559 transaction, after its type byte
+type dynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         []byte
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+	V, R, S    *big.Int
+}
+
+// Validate checks that the fields of a transaction are set for its type, and that its amounts aren't negative
+func (tx *Transaction) Validate() error {
+	switch tx.Type {
+	case LegacyTxType:
+		if tx.GasPrice == nil {
+			return errors.New("legacy transactions must have a gas price")
+		}
+		if tx.GasTipCap != nil || tx.GasFeeCap != nil {
+			return errors.New("legacy transactions can't have a gas tip or fee cap")
+		}
+		if len(tx.AccessList) != 0 {
+			return errors.New("legacy transactions can't have an access list")
+		}
+	case DynamicFeeTxType:
+		if tx.ChainID == nil {
+			return errors.New("EIP-1559 transactions must have a chain ID")
+		}
+		if tx.GasTipCap == nil || tx.GasFeeCap == nil {
+			return errors.New("EIP-1559 transactions must have a gas tip cap and a gas fee cap")
+		}
+		if tx.GasPrice != nil {
+			return errors.New("EIP-1559 transactions can't have a gas price")
+		}
+		if tx.GasTipCap.Cmp(tx.GasFeeCap) > 0 {
+			return errors.New("gas tip cap is higher than the gas fee cap")
+		}
+	default:
+		return ErrUnsupportedTxType
+	}
+
+	for _, n := range []*big.Int{tx.ChainID, tx.GasPrice, tx.GasTipCap, tx.GasFeeCap, tx.Value} {
+		if n != nil && n.Sign() < 0 {
+			return errors.New("transaction amounts can't be negative")
+		}
+	}
+
+	if tx.Gas == 0 {
+		return errors.New("transaction gas limit is zero")
+	}
+
+	return nil
+}
+
+// IsSigned returns true if the transaction has signature values
+func (tx *Transaction) IsSigned() bool {
+	return tx.V != nil && tx.R != nil && tx.S != nil
+}
+
+func (tx *Transaction) to() []byte {
+	if tx.To == nil {
+		return nil
+	}
+	return tx.To.Bytes()
+}
+
+func (tx *Transaction) value() *big.Int {
+	if tx.Value == nil {
+		return new(big.Int)
+	}
+	return tx.Value
+}
+
+// SigningHash returns the digest signed by the sender of the transaction: keccak256 of the RLP list of the legacy
+// transaction's fields followed by chainID, 0, 0 (EIP-155), or of 0x02 followed by the RLP list of the EIP-1559 fields
+func (tx *Transaction) SigningHash() (cipher.SHA256, error) {
+	if err := tx.Validate(); err != nil {
+		return cipher.SHA256{}, err
+	}
+
+	var b []byte
+	var err error
+	switch tx.Type {
+	case LegacyTxType:
+		fields := []interface{}{tx.Nonce, tx.GasPrice, tx.Gas, tx.to(), tx.value(), tx.Data}
+		if tx.ChainID != nil {
+			fields = append(fields, tx.ChainID, uint(0), uint(0))
+		}
+		b, err = rlp.EncodeToBytes(fields)
+	case DynamicFeeTxType:
+		b, err = rlp.EncodeToBytes([]interface{}{
+			tx.ChainID, tx.Nonce, tx.GasTipCap, tx.GasFeeCap, tx.Gas, tx.to(), tx.value(), tx.Data, tx.accessList(),
+		})
+		b = append([]byte{DynamicFeeTxType}, b...)
+	}
+	if err != nil {
+		return cipher.SHA256{}, err
+	}
+
+	return cipher.MustSHA256FromBytes(crypto.Keccak256(b)), nil
+}
+
+func (tx *Transaction) accessList() AccessList {
+	if tx.AccessList == nil {
+		return AccessList{}
+	}
+	return tx.AccessList
+}
+
+// WithSignature returns a copy of the transaction with the signature values of a recoverable signature of its SigningHash
+func (tx *Transaction) WithSignature(sig cipher.Sig) (*Transaction, error) {
+	if err := tx.Validate(); err != nil {
+		return nil, err
+	}
+
+	if sig[64] > 1 {
+		return nil, ErrInvalidTxSignature
+	}
+
+	cpy := *tx
+	cpy.R = new(big.Int).SetBytes(sig[:32])
+	cpy.S = new(big.Int).SetBytes(sig[32:64])
+	cpy.V = big.NewInt(int64(sig[64]))
+
+	if tx.Type == LegacyTxType {
+		if tx.ChainID != nil {
+			// v = recovery id + chainID * 2 + 35
+			cpy.V.Add(cpy.V, new(big.Int).Mul(tx.ChainID, big.NewInt(2)))
+			cpy.V.Add(cpy.V, big.NewInt(35))
+		} else {
+			cpy.V.Add(cpy.V, big.NewInt(27))
+		}
+	}
+
+	return &cpy, nil
+}
+
+// signature returns the recoverable signature [r|s|recovery id] of the signature values
+func (tx *Transaction) signature() (cipher.Sig, error) {
+	if !tx.IsSigned() {
+		return cipher.Sig{}, ErrTxUnsigned
+	}
+
+	v := new(big.Int).Set(tx.V)
+	if tx.Type == LegacyTxType {
+		if tx.ChainID != nil {
+			v.Sub(v, new(big.Int).Mul(tx.ChainID, big.NewInt(2)))
+			v.Sub(v, big.NewInt(35))
+		} else {
+			v.Sub(v, big.NewInt(27))
+		}
+	}
+
+	if !v.IsUint64() || v.Uint64() > 1 || !crypto.ValidateSignatureValues(byte(v.Uint64()), tx.R, tx.S, true) {
+		return cipher.Sig{}, ErrInvalidTxSignature
+	}
+
+	// r and s are lower than the secp256k1 order, so fit in 32 bytes
+	var sig cipher.Sig
+	r, s := tx.R.Bytes(), tx.S.Bytes()
+	copy(sig[32-len(r):32], r)
+	copy(sig[64-len(s):64], s)
+	sig[64] = byte(v.Uint64())
+	return sig, nil
+}
+
+// Sender recovers the address of the key which signed the transaction
+func (tx *Transaction) Sender() (EthereumAddress, error) {
+	sig, err := tx.signature()
+	if err != nil {
+		return EthereumAddress{}, err
+	}
+
+	h, err := tx.SigningHash()
+	if err != nil {
+		return EthereumAddress{}, err
+	}
+
+	pk, err := cipher.PubKeyFromSig(sig, h)
+	if err != nil {
+		return EthereumAddress{}, ErrInvalidTxSignature
+	}
+
+	return EthereumAddressFromPubKey(pk), nil
+}
+
+// MarshalBinary returns the raw transaction broadcast to the network: the RLP list of a legacy transaction,
+// or the EIP-2718 envelope 0x02 || RLP list of an EIP-1559 transaction
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if err := tx.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !tx.IsSigned() {
+		return nil, ErrTxUnsigned
+	}
+
+	switch tx.Type {
+	case LegacyTxType:
+		return rlp.EncodeToBytes(legacyTx{
+			Nonce:    tx.Nonce,
+			GasPrice: tx.GasPrice,
+			Gas:      tx.Gas,
+			To:       tx.to(),
+			Value:    tx.value(),
+			Data:     tx.Data,
+			V:        tx.V,
+			R:        tx.R,
+			S:        tx.S,
+		})
+	default:
+		b, err := rlp.EncodeToBytes(dynamicFeeTx{
+			ChainID:    tx.ChainID,
+			Nonce:      tx.Nonce,
+			GasTipCap:  tx.GasTipCap,
+			GasFeeCap:  tx.GasFeeCap,
+			Gas:        tx.Gas,
+			To:         tx.to(),
+			Value:      tx.value(),
+			Data:       tx.Data,
+			AccessList: tx.accessList(),
+			V:          tx.V,
+			R:          tx.R,
+			S:          tx.S,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{DynamicFeeTxType}, b...), nil
+	}
+}
+
+// Hash returns the transaction hash, keccak256 of the raw transaction
+func (tx *Transaction) Hash() (cipher.SHA256, error) {
+	b, err := tx.MarshalBinary()
+	if err != nil {
+		return cipher.SHA256{}, err
+	}
+	return cipher.MustSHA256FromBytes(crypto.Keccak256(b)), nil
+}
+
+// DecodeTransaction decodes a signed raw transaction, see MarshalBinary
+func DecodeTransaction(b []byte) (*Transaction, error) {
+	if len(b) == 0 {
+		return nil, ErrInvalidTransaction
+	}
+
+	var tx Transaction
+	var to []byte
+	switch {
+	case b[0] >= 0xc0:
+		// RLP lists start with 0xc0-0xff, the first byte of typed transactions is 0x00-0x7f
+		var ltx legacyTx
+		if err := rlp.DecodeBytes(b, &ltx); err != nil {
+			return nil, fmt.Errorf("%v: %v", ErrInvalidTransaction, err)
+		}
+
+		tx = Transaction{
+			Type:     LegacyTxType,
+			Nonce:    ltx.Nonce,
+			GasPrice: ltx.GasPrice,
+			Gas:      ltx.Gas,
+			Value:    ltx.Value,
+			Data:     ltx.Data,
+			V:        ltx.V,
+			R:        ltx.R,
+			S:        ltx.S,
+		}
+		to = ltx.To
+
+		// v is 27 or 28 for unprotected transactions, and recovery id + chainID * 2 + 35 with EIP-155
+		if tx.V.Cmp(big.NewInt(35)) >= 0 {
+			tx.ChainID = new(big.Int).Sub(tx.V, big.NewInt(35))
+			tx.ChainID.Rsh(tx.ChainID, 1)
+		}
+	case b[0] == DynamicFeeTxType:
+		var dtx dynamicFeeTx
+		if err := rlp.DecodeBytes(b[1:], &dtx); err != nil {
+			return nil, fmt.Errorf("%v: %v", ErrInvalidTransaction, err)
+		}
+
+		tx = Transaction{
+			Type:       DynamicFeeTxType,
+			ChainID:    dtx.ChainID,
+			Nonce:      dtx.Nonce,
+			GasTipCap:  dtx.GasTipCap,
+			GasFeeCap:  dtx.GasFeeCap,
+			Gas:        dtx.Gas,
+			Value:      dtx.Value,
+			Data:       dtx.Data,
+			AccessList: dtx.AccessList,
+			V:          dtx.V,
+			R:          dtx.R,
+			S:          dtx.S,
+		}
+		to = dtx.To
+	default:
+		return nil, ErrUnsupportedTxType
+	}
+
+	switch len(to) {
+	case 0:
+	case common.AddressLength:
+		tx.To = &EthereumAddress{Addr: common.BytesToAddress(to)}
+	default:
+		return nil, fmt.Errorf("%v: invalid recipient address", ErrInvalidTransaction)
+	}
+
+	if err := tx.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidTransaction, err)
+	}
+
+	if _, err := tx.signature(); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+// DecodeRawTransaction decodes a 0x prefixed hex signed raw transaction, see DecodeTransaction
+func DecodeRawTransaction(s string) (*Transaction, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidTransaction, err)
+	}
+
+	return DecodeTransaction(b)
+}
+
+// transactionJSON is the JSON representation of a transaction, with the fields of the transaction objects
+// of ethereum's JSON-RPC API, e.g. eth_signTransaction
+type transactionJSON struct {
+	Type                 *hexutil.Uint64 `json:"type,omitempty"`
+	ChainID              *hexutil.Big    `json:"chainId,omitempty"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	To                   *string         `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Input                *hexutil.Bytes  `json:"input"`
+	Data                 *hexutil.Bytes  `json:"data,omitempty"`
+	AccessList           *AccessList     `json:"accessList,omitempty"`
+	V                    *hexutil.Big    `json:"v,omitempty"`
+	R                    *hexutil.Big    `json:"r,omitempty"`
+	S                    *hexutil.Big    `json:"s,omitempty"`
+}
+
+// MarshalJSON encodes the transaction as a JSON-RPC transaction object, with hex quantities
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	typ := hexutil.Uint64(tx.Type)
+	nonce := hexutil.Uint64(tx.Nonce)
+	gas := hexutil.Uint64(tx.Gas)
+	input := hexutil.Bytes(tx.Data)
+	j := transactionJSON{
+		Type:                 &typ,
+		ChainID:              (*hexutil.Big)(tx.ChainID),
+		Nonce:                &nonce,
+		GasPrice:             (*hexutil.Big)(tx.GasPrice),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.GasTipCap),
+		MaxFeePerGas:         (*hexutil.Big)(tx.GasFeeCap),
+		Gas:                  &gas,
+		Value:                (*hexutil.Big)(tx.value()),
+		Input:                &input,
+		V:                    (*hexutil.Big)(tx.V),
+		R:                    (*hexutil.Big)(tx.R),
+		S:                    (*hexutil.Big)(tx.S),
+	}
+
+	if tx.To != nil {
+		to := tx.To.String()
+		j.To = &to
+	}
+
+	if tx.Type == DynamicFeeTxType {
+		al := tx.accessList()
+		j.AccessList = &al
+	}
+
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a JSON-RPC transaction object. The type defaults to EIP-1559 if maxFeePerGas is set,
+// and to legacy otherwise. The nonce and gas are required, and the data may be set as input or data.
+func (tx *Transaction) UnmarshalJSON(b []byte) error {
+	var j transactionJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	if j.Nonce == nil {
+		return errors.New("missing transaction nonce")
+	}
+
+	if j.Gas == nil {
+		return errors.New("missing transaction gas")
+	}
+
+	t := Transaction{
+		ChainID:   (*big.Int)(j.ChainID),
+		Nonce:     uint64(*j.Nonce),
+		GasPrice:  (*big.Int)(j.GasPrice),
+		GasTipCap: (*big.Int)(j.MaxPriorityFeePerGas),
+		GasFeeCap: (*big.Int)(j.MaxFeePerGas),
+		Gas:       uint64(*j.Gas),
+		Value:     (*big.Int)(j.Value),
+		V:         (*big.Int)(j.V),
+		R:         (*big.Int)(j.R),
+		S:         (*big.Int)(j.S),
+	}
+
+	switch {
+	case j.Type != nil:
+		if *j.Type > 0xff {
+			return ErrUnsupportedTxType
+		}
+		t.Type = uint8(*j.Type)
+	case j.MaxFeePerGas != nil:
+		t.Type = DynamicFeeTxType
+	}
+
+	if j.To != nil && *j.To != "" {
+		to, err := DecodeEthereumAddress(*j.To)
+		if err != nil {
+			return err
+		}
+		t.To = &to
+	}
+
+	switch {
+	case j.Input != nil && j.Data != nil && !bytes.Equal(*j.Input, *j.Data):
+		return errors.New("transaction input and data are different")
+	case j.Input != nil:
+		t.Data = *j.Input
+	case j.Data != nil:
+		t.Data = *j.Data
+	}
+
+	if j.AccessList != nil {
+		t.AccessList = *j.AccessList
+	}
+
+	*tx = t
+	return nil
+}
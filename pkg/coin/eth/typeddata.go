@@ -0,0 +1,517 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// eip712DomainType is the type of the EIP-712 domain
+const eip712DomainType = "EIP712Domain"
+
+// eip712DomainFields are the fields of the EIP-712 domain, in the order of the domain type
+var eip712DomainFields = []TypedDataField{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+	{Name: "salt", Type: "bytes32"},
+}
+
+var (
+	typeNameRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	arrayRegexp    = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+)
+
+// TypedDataField is a field of an EIP-712 struct type
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is EIP-712 typed structured data, in the JSON format of eth_signTypedData_v4
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// ParseTypedData parses EIP-712 typed data JSON. Numbers are decoded as json.Number,
+// so that integers larger than a float64 mantissa keep their value.
+func ParseTypedData(b []byte) (*TypedData, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var td TypedData
+	if err := d.Decode(&td); err != nil {
+		return nil, err
+	}
+
+	if err := td.validate(); err != nil {
+		return nil, err
+	}
+
+	return &td, nil
+}
+
+// validate checks the types of the typed data. The domain type may be omitted,
+// it's then made of the domain fields which are set, as ethers.js does.
+func (td *TypedData) validate() error {
+	if td.Types == nil {
+		td.Types = make(map[string][]TypedDataField)
+	}
+
+	if _, ok := td.Types[eip712DomainType]; !ok {
+		var fields []TypedDataField
+		for _, f := range eip712DomainFields {
+			if _, ok := td.Domain[f.Name]; ok {
+				fields = append(fields, f)
+			}
+		}
+		td.Types[eip712DomainType] = fields
+	}
+
+	for name, fields := range td.Types {
+		if !typeNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid type name %q", name)
+		}
+
+		names := make(map[string]struct{}, len(fields))
+		for _, f := range fields {
+			if f.Name == "" {
+				return fmt.Errorf("type %s has a field without a name", name)
+			}
+			if _, ok := names[f.Name]; ok {
+				return fmt.Errorf("type %s has duplicate field %q", name, f.Name)
+			}
+			names[f.Name] = struct{}{}
+
+			if err := td.checkType(f.Type); err != nil {
+				return fmt.Errorf("field %s.%s: %v", name, f.Name, err)
+			}
+		}
+	}
+
+	if td.PrimaryType == "" {
+		return errors.New("missing primaryType")
+	}
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return fmt.Errorf("primaryType %q is not a defined type", td.PrimaryType)
+	}
+
+	return nil
+}
+
+// checkType checks that a field type is an atomic type, a dynamic type, a defined struct type or an array of them
+func (td *TypedData) checkType(t string) error {
+	if m := arrayRegexp.FindStringSubmatch(t); m != nil {
+		return td.checkType(m[1])
+	}
+
+	if _, ok := td.Types[t]; ok {
+		return nil
+	}
+
+	switch t {
+	case "address", "bool", "string", "bytes":
+		return nil
+	}
+
+	if _, _, ok := parseIntType(t); ok {
+		return nil
+	}
+
+	if n, ok := parseBytesType(t); ok && n >= 1 && n <= 32 {
+		return nil
+	}
+
+	return fmt.Errorf("unknown type %q", t)
+}
+
+// Hash returns the digest signed by an EIP-712 signature, keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (td *TypedData) Hash() (cipher.SHA256, error) {
+	if err := td.validate(); err != nil {
+		return cipher.SHA256{}, err
+	}
+
+	domainSeparator, err := td.HashStruct(eip712DomainType, td.Domain)
+	if err != nil {
+		return cipher.SHA256{}, fmt.Errorf("domain: %v", err)
+	}
+
+	// Only the domain is hashed if it's the primary type
+	if td.PrimaryType == eip712DomainType {
+		return cipher.MustSHA256FromBytes(crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator)), nil
+	}
+
+	msgHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return cipher.SHA256{}, fmt.Errorf("message: %v", err)
+	}
+
+	return cipher.MustSHA256FromBytes(crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, msgHash)), nil
+}
+
+// HashStruct returns the EIP-712 hashStruct of a value of a struct type, keccak256(typeHash ‖ encodeData(data))
+func (td *TypedData) HashStruct(typeName string, data map[string]interface{}) ([]byte, error) {
+	enc, err := td.encodeData(typeName, data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(td.TypeHash(typeName), enc), nil
+}
+
+// TypeHash returns the keccak256 of the encoding of a struct type
+func (td *TypedData) TypeHash(typeName string) []byte {
+	return crypto.Keccak256([]byte(td.EncodeType(typeName)))
+}
+
+// EncodeType returns the EIP-712 encoding of a struct type, name ‖ "(" ‖ member₁ ‖ "," ‖ member₂ ‖ "," ‖ … ‖ ")",
+// followed by the encodings of the struct types it references, sorted by name
+func (td *TypedData) EncodeType(typeName string) string {
+	deps := make(map[string]struct{})
+	td.dependencies(typeName, deps)
+	delete(deps, typeName)
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range append([]string{typeName}, names...) {
+		b.WriteString(name)
+		b.WriteByte('(')
+		for i, f := range td.Types[name] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(f.Type)
+			b.WriteByte(' ')
+			b.WriteString(f.Name)
+		}
+		b.WriteByte(')')
+	}
+
+	return b.String()
+}
+
+// dependencies adds a struct type and the struct types it references, recursively, to deps
+func (td *TypedData) dependencies(typeName string, deps map[string]struct{}) {
+	typeName = baseType(typeName)
+	if _, ok := deps[typeName]; ok {
+		return
+	}
+
+	fields, ok := td.Types[typeName]
+	if !ok {
+		return
+	}
+
+	deps[typeName] = struct{}{}
+	for _, f := range fields {
+		td.dependencies(f.Type, deps)
+	}
+}
+
+// encodeData encodes the members of a struct value, each as 32 bytes, in the order of the struct type
+func (td *TypedData) encodeData(typeName string, data map[string]interface{}) ([]byte, error) {
+	fields := td.Types[typeName]
+
+	for name := range data {
+		found := false
+		for _, f := range fields {
+			if f.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no field %q", typeName, name)
+		}
+	}
+
+	buf := make([]byte, 0, 32*len(fields))
+	for _, f := range fields {
+		v, ok := data[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s.%s", typeName, f.Name)
+		}
+
+		enc, err := td.encodeValue(f.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", typeName, f.Name, err)
+		}
+		buf = append(buf, enc...)
+	}
+
+	return buf, nil
+}
+
+// encodeValue encodes a value as 32 bytes. Struct values are encoded as their hashStruct,
+// arrays as the keccak256 of their encoded elements, and dynamic values as their keccak256.
+func (td *TypedData) encodeValue(t string, v interface{}) ([]byte, error) {
+	if m := arrayRegexp.FindStringSubmatch(t); m != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s value is not an array", t)
+		}
+
+		if m[2] != "" {
+			n, err := strconv.Atoi(m[2])
+			if err != nil || n != len(items) {
+				return nil, fmt.Errorf("%s value has %d elements", t, len(items))
+			}
+		}
+
+		buf := make([]byte, 0, 32*len(items))
+		for i, item := range items {
+			enc, err := td.encodeValue(m[1], item)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
+			}
+			buf = append(buf, enc...)
+		}
+		return crypto.Keccak256(buf), nil
+	}
+
+	if _, ok := td.Types[t]; ok {
+		data, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s value is not an object", t)
+		}
+		return td.HashStruct(t, data)
+	}
+
+	switch t {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("string value is not a string")
+		}
+		return crypto.Keccak256([]byte(s)), nil
+
+	case "bytes":
+		b, err := decodeHexValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("bool value is not a boolean")
+		}
+		enc := make([]byte, 32)
+		if b {
+			enc[31] = 1
+		}
+		return enc, nil
+
+	case "address":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("address value is not a string")
+		}
+		a, err := DecodeEthereumAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		enc := make([]byte, 32)
+		copy(enc[12:], a.Bytes())
+		return enc, nil
+	}
+
+	if bits, signed, ok := parseIntType(t); ok {
+		n, err := parseIntegerValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return encodeInteger(n, bits, signed)
+	}
+
+	if size, ok := parseBytesType(t); ok {
+		b, err := decodeHexValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("%s value has %d bytes", t, len(b))
+		}
+		enc := make([]byte, 32)
+		copy(enc, b)
+		return enc, nil
+	}
+
+	return nil, fmt.Errorf("unknown type %q", t)
+}
+
+// baseType returns the element type of an array type, or the type itself
+func baseType(t string) string {
+	for {
+		m := arrayRegexp.FindStringSubmatch(t)
+		if m == nil {
+			return t
+		}
+		t = m[1]
+	}
+}
+
+// parseIntType parses an intN or uintN type, with N a multiple of 8 up to 256. int and uint are int256 and uint256.
+func parseIntType(t string) (bits int, signed bool, ok bool) {
+	var s string
+	switch {
+	case strings.HasPrefix(t, "uint"):
+		s = t[len("uint"):]
+	case strings.HasPrefix(t, "int"):
+		s = t[len("int"):]
+		signed = true
+	default:
+		return 0, false, false
+	}
+
+	if s == "" {
+		return 256, signed, true
+	}
+
+	bits, err := strconv.Atoi(s)
+	if err != nil || bits < 8 || bits > 256 || bits%8 != 0 || strconv.Itoa(bits) != s {
+		return 0, false, false
+	}
+
+	return bits, signed, true
+}
+
+// parseBytesType parses a bytesN type
+func parseBytesType(t string) (int, bool) {
+	if !strings.HasPrefix(t, "bytes") || t == "bytes" {
+		return 0, false
+	}
+
+	s := t[len("bytes"):]
+	n, err := strconv.Atoi(s)
+	if err != nil || strconv.Itoa(n) != s {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// parseIntegerValue parses an integer given as a JSON number, a decimal string or a 0x prefixed hex string
+func parseIntegerValue(v interface{}) (*big.Int, error) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = x.String()
+	case string:
+		s = x
+	case float64:
+		if x != float64(int64(x)) {
+			return nil, fmt.Errorf("integer value %v is not an integer", x)
+		}
+		return big.NewInt(int64(x)), nil
+	default:
+		return nil, errors.New("integer value is not a number or a string")
+	}
+
+	n := new(big.Int)
+	var ok bool
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		_, ok = n.SetString(s[2:], 16)
+	case strings.HasPrefix(s, "-0x") || strings.HasPrefix(s, "-0X"):
+		_, ok = n.SetString(s[3:], 16)
+		n.Neg(n)
+	default:
+		_, ok = n.SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid integer value %q", s)
+	}
+
+	return n, nil
+}
+
+// encodeInteger encodes an integer of a type of a number of bits as 32 bytes, two's complement if it's negative
+func encodeInteger(n *big.Int, bits int, signed bool) ([]byte, error) {
+	min := new(big.Int)
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("integer value %s overflows the type", n)
+	}
+
+	x := new(big.Int).Set(n)
+	if x.Sign() < 0 {
+		x.Add(x, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+
+	enc := make([]byte, 32)
+	b := x.Bytes()
+	copy(enc[32-len(b):], b)
+	return enc, nil
+}
+
+// decodeHexValue decodes a 0x prefixed hex string
+func decodeHexValue(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("bytes value is not a string")
+	}
+
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, errors.New("bytes value is not 0x prefixed hex")
+	}
+
+	return hex.DecodeString(s[2:])
+}
+
+// RecoverTypedDataAddress returns the address of the key which made a [r|s|v] signature of typed data
+func RecoverTypedDataAddress(td *TypedData, signature string) (EthereumAddress, error) {
+	hash, err := td.Hash()
+	if err != nil {
+		return EthereumAddress{}, err
+	}
+
+	sig, err := DecodeSignature(signature)
+	if err != nil {
+		return EthereumAddress{}, err
+	}
+
+	pk, err := cipher.PubKeyFromSig(sig, hash)
+	if err != nil {
+		return EthereumAddress{}, ErrInvalidMessageSignature
+	}
+
+	return EthereumAddressFromPubKey(pk), nil
+}
+
+// VerifyTypedData checks that a [r|s|v] signature of typed data was made by the key of an address
+func VerifyTypedData(addr EthereumAddress, td *TypedData, signature string) error {
+	a, err := RecoverTypedDataAddress(td, signature)
+	if err != nil {
+		return err
+	}
+
+	if a != addr {
+		return ErrInvalidMessageSignature
+	}
+
+	return nil
+}
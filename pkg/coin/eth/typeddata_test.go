@@ -0,0 +1,162 @@
+package eth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// eip712MailTypedData is the example of the EIP-712 specification
+const eip712MailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	td, err := ParseTypedData([]byte(eip712MailTypedData))
+	require.NoError(t, err)
+
+	require.Equal(t, "Mail(Person from,Person to,string contents)Person(string name,address wallet)", td.EncodeType("Mail"))
+	domainSeparator, err := td.HashStruct("EIP712Domain", td.Domain)
+	require.NoError(t, err)
+	require.Equal(t, "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f", hex.EncodeToString(domainSeparator))
+	msgHash, err := td.HashStruct("Mail", td.Message)
+	require.NoError(t, err)
+	require.Equal(t, "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e", hex.EncodeToString(msgHash))
+	hash, err := td.Hash()
+	require.NoError(t, err)
+	require.Equal(t, "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", hash.Hex())
+
+	// The signature of the specification's example, made with the key keccak256("cow")
+	specSig := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c"
+	a, err := RecoverTypedDataAddress(td, specSig)
+	require.NoError(t, err)
+	require.Equal(t, "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", a.String())
+
+	// Changed messages recover another address
+	td.Message["contents"] = "Hello, Alice!"
+	a, err = RecoverTypedDataAddress(td, specSig)
+	require.NoError(t, err)
+	require.NotEqual(t, "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", a.String())
+}
+
+func TestTypedDataEncoding(t *testing.T) {
+	// EIP-2612 permit, with an implicit domain type, and large integers
+	permit := `{
+		"types": {
+			"Permit": [
+				{"name": "owner", "type": "address"},
+				{"name": "spender", "type": "address"},
+				{"name": "value", "type": "uint256"},
+				{"name": "nonce", "type": "uint256"},
+				{"name": "deadline", "type": "uint256"}
+			]
+		},
+		"primaryType": "Permit",
+		"domain": {"name": "Token", "version": "1", "chainId": "0x1", "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},
+		"message": {
+			"owner": "0xcd2a3d9f938e13cd947ec05abc7fe734df8dd826",
+			"spender": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+			"value": 115792089237316195423570985008687907853269984665640564039457584007913129639935,
+			"nonce": 0,
+			"deadline": "1700000000"
+		}
+	}`
+	td, err := ParseTypedData([]byte(permit))
+	require.NoError(t, err)
+	require.Equal(t, "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)", td.EncodeType("EIP712Domain"))
+	_, err = td.Hash()
+	require.NoError(t, err)
+
+	// Nested structs and arrays
+	group := `{
+		"types": {
+			"Person": [{"name": "name", "type": "string"}, {"name": "wallets", "type": "address[]"}],
+			"Group": [{"name": "name", "type": "string"}, {"name": "members", "type": "Person[]"}, {"name": "tags", "type": "bytes32[2]"}, {"name": "delta", "type": "int8"}]
+		},
+		"primaryType": "Group",
+		"domain": {"name": "Groups"},
+		"message": {
+			"name": "Group",
+			"members": [
+				{"name": "Cow", "wallets": ["0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"]},
+				{"name": "Bob", "wallets": []}
+			],
+			"tags": ["0x` + strings.Repeat("01", 32) + `", "0x` + strings.Repeat("02", 32) + `"],
+			"delta": -128
+		}
+	}`
+	td, err = ParseTypedData([]byte(group))
+	require.NoError(t, err)
+	require.Equal(t, "Group(string name,Person[] members,bytes32[2] tags,int8 delta)Person(string name,address[] wallets)", td.EncodeType("Group"))
+	h1, err := td.Hash()
+	require.NoError(t, err)
+
+	// Negative integers are encoded in two's complement
+	enc, err := td.HashStruct("Group", td.Message)
+	require.NoError(t, err)
+	require.Len(t, enc, 32)
+
+	td.Message["delta"] = json.Number("-127")
+	h2, err := td.Hash()
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h2)
+
+	for _, tc := range []struct {
+		field string
+		value interface{}
+	}{
+		{"delta", json.Number("-129")},
+		{"delta", json.Number("128")},
+		{"tags", []interface{}{"0x" + strings.Repeat("01", 32)}},
+		{"tags", []interface{}{"0x01", "0x02"}},
+		{"members", []interface{}{map[string]interface{}{"name": "Cow"}}},
+		{"members", []interface{}{map[string]interface{}{"name": "Cow", "wallets": []interface{}{}, "age": json.Number("3")}}},
+		{"name", json.Number("1")},
+	} {
+		td, err := ParseTypedData([]byte(group))
+		require.NoError(t, err)
+		td.Message[tc.field] = tc.value
+		_, err = td.Hash()
+		require.Error(t, err, "%s: %v", tc.field, tc.value)
+	}
+
+	// Undefined types and missing primary types are refused
+	_, err = ParseTypedData([]byte(`{"types": {"A": [{"name": "b", "type": "B"}]}, "primaryType": "A", "domain": {}, "message": {}}`))
+	require.Error(t, err)
+	_, err = ParseTypedData([]byte(`{"types": {"A": [{"name": "b", "type": "uint7"}]}, "primaryType": "A", "domain": {}, "message": {}}`))
+	require.Error(t, err)
+	_, err = ParseTypedData([]byte(`{"types": {"A": []}, "primaryType": "B", "domain": {}, "message": {}}`))
+	require.Error(t, err)
+}
@@ -2,10 +2,13 @@ package multicoin
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
+
 	"github.com/SkycoinProject/skycoin/src/util/file"
 )
 
@@ -38,6 +41,13 @@ type Config struct {
 
 	// Data directory holds app data -- defaults to ~/.multicoin
 	DataDirectory string
+
+	// Number of timestamped backups kept of each wallet file
+	WalletBackups int
+	// Re-derive the entries of unencrypted wallets on startup. Encrypted wallets are authenticated by their MAC.
+	VerifyWallets bool
+	// Comma separated <wallet id>=<socket> pairs of the wallets which sign with a remote signer process
+	WalletSigners string
 }
 
 // NewAppConfig returns a new app config instance
@@ -60,6 +70,10 @@ func NewConfig(port int, datadir string) Config {
 		HTTPProfHost: "localhost:7070",
 
 		DataDirectory: datadir,
+
+		WalletBackups: wallet.DefaultBackups,
+		VerifyWallets: false,
+		WalletSigners: "",
 	}
 }
 
@@ -93,7 +107,33 @@ func (c *Config) RegisterFlags() {
 	flag.StringVar(&c.HTTPProfHost, "http-prof-host", c.HTTPProfHost, "hostname to bind the HTTP profiling interface to")
 
 	flag.StringVar(&c.DataDirectory, "data-dir", c.DataDirectory, "directory to store app data (defaults to ~/.multicoin)")
+	flag.IntVar(&c.WalletBackups, "wallet-backups", c.WalletBackups, "number of timestamped backups kept of each wallet file, 0 disables backups")
+	flag.StringVar(&c.WalletSigners, "wallet-signers", c.WalletSigners, "comma separated <wallet id>=<socket> pairs of the wallets which sign with the remote signer listening on the Unix socket, see multicoin-cli serveSigner")
+	flag.BoolVar(&c.VerifyWallets, "verify-wallets", c.VerifyWallets, "re-derive the entries of unencrypted wallets on startup, to detect changes made to the wallet files. Encrypted wallets are always authenticated on startup by their MAC, keyed by the wallets.key file of the data directory")
+
+}
+
+// walletSigners parses WalletSigners into a map of wallet IDs to signer sockets
+func (c *Config) walletSigners() (map[string]string, error) {
+	signers := make(map[string]string)
+	if c.WalletSigners == "" {
+		return signers, nil
+	}
+
+	for _, pair := range strings.Split(c.WalletSigners, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("%q is not a <wallet id>=<socket> pair", pair)
+		}
+
+		if _, ok := signers[kv[0]]; ok {
+			return nil, fmt.Errorf("wallet %q has more than one signer", kv[0])
+		}
+
+		signers[kv[0]] = replaceHome(kv[1], file.UserHome())
+	}
 
+	return signers, nil
 }
 
 func panicIfError(err error, msg string, args ...interface{}) { // nolint: unparam
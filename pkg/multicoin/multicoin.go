@@ -13,12 +13,17 @@ import (
 	"github.com/SkycoinProject/skycoin/src/util/logging"
 
 	"github.com/SkycoinProject/multicoin-wallet/pkg/api"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/wallet"
 )
 
 // MultiCoin represents a multcoin instance
 type MultiCoin struct {
-	config Config
-	logger *logging.Logger
+	config  Config
+	logger  *logging.Logger
+	wallets *wallet.Service
 }
 
 // NewMultiCoin returns a new multicoin instance
@@ -32,6 +37,7 @@ func NewMultiCoin(config Config, logger *logging.Logger) *MultiCoin {
 // Run starts the multicoin api server
 func (m *MultiCoin) Run() error {
 	var apiServer *api.Server
+	var coins *coin.CoinManager
 	var retErr error
 	errC := make(chan error, 10)
 
@@ -60,6 +66,37 @@ func (m *MultiCoin) Run() error {
 		}
 	}
 
+	m.wallets, err = wallet.NewService(wallet.Config{
+		WalletDir:     filepath.Join(m.config.DataDirectory, "wallets"),
+		CryptoType:    wallet.DefaultCryptoType,
+		Backups:       m.config.WalletBackups,
+		VerifyEntries: m.config.VerifyWallets,
+		MACKeyFile:    filepath.Join(m.config.DataDirectory, "wallets.key"),
+	})
+	if err != nil {
+		m.logger.WithError(err).Error("wallet.NewService failed")
+		return err
+	}
+
+	if unverified := m.wallets.UnverifiedWallets(); len(unverified) != 0 {
+		m.logger.WithField("wallets", unverified).Warning("Encrypted wallets weren't authenticated on load, they are authenticated when they are unlocked")
+	}
+
+	signers, err := m.config.walletSigners()
+	if err != nil {
+		err = fmt.Errorf("invalid -wallet-signers: %v", err)
+		m.logger.Error(err)
+		return err
+	}
+
+	for wltID, socket := range signers {
+		if err := m.wallets.SetSigner(wltID, wallet.NewRemoteSigner(socket)); err != nil {
+			m.logger.WithError(err).WithField("wallet", wltID).Error("wallet.Service.SetSigner failed")
+			return err
+		}
+		m.logger.WithField("wallet", wltID).WithField("socket", socket).Info("Wallet signs with a remote signer")
+	}
+
 	host := fmt.Sprintf("%s:%d", m.config.WebInterfaceAddr, m.config.WebInterfacePort)
 
 	if m.config.ProfileCPU {
@@ -94,7 +131,17 @@ func (m *MultiCoin) Run() error {
 	// Catch SIGUSR1 (prints runtime stack to stdout)
 	go apputil.CatchDebug()
 
-	apiServer, err = m.createServer(host, api.NewGateway())
+	coins, err = coin.NewCoinManager(map[coin.Ticker]coin.Coin{
+		"btc": btc.New(),
+		"eth": eth.New(),
+	})
+	if err != nil {
+		m.logger.Error(err)
+		retErr = err
+		goto earlyShutdown
+	}
+
+	apiServer, err = m.createServer(host, api.NewGateway(coins, m.wallets))
 	if err != nil {
 		m.logger.Error(err)
 		retErr = err
@@ -0,0 +1,610 @@
+// Package slip39 implements SLIP-39 Shamir's secret sharing of a master secret into mnemonic shares.
+// See https://github.com/satoshilabs/slips/blob/master/slip-0039.md
+package slip39
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/SkycoinProject/skycoin/src/cipher/pbkdf2"
+)
+
+const (
+	radixBits = 10
+	radix     = 1 << radixBits
+
+	idLengthBits        = 15
+	iterationExpBits    = 4
+	idExpLengthWords    = 2
+	paramsLengthWords   = 2
+	checksumLengthWords = 3
+	// metadataLengthWords is the number of words of a share that are not the share value
+	metadataLengthWords = idExpLengthWords + paramsLengthWords + checksumLengthWords
+
+	minStrengthBits        = 128
+	minMnemonicLengthWords = metadataLengthWords + (minStrengthBits+radixBits-1)/radixBits
+
+	digestLengthBytes  = 4
+	baseIterationCount = 10000
+	roundCount         = 4
+	maxShareCount      = 16
+
+	secretIndex = 255
+	digestIndex = 254
+
+	customizationString           = "shamir"
+	customizationStringExtendable = "shamir_extendable"
+)
+
+var (
+	// ErrInvalidMnemonic is returned for mnemonics with unknown words or an invalid length
+	ErrInvalidMnemonic = errors.New("invalid slip39 mnemonic")
+	// ErrInvalidChecksum is returned for mnemonics with an invalid checksum
+	ErrInvalidChecksum = errors.New("invalid slip39 mnemonic checksum")
+	// ErrInvalidPadding is returned for mnemonics with invalid share value padding
+	ErrInvalidPadding = errors.New("invalid slip39 mnemonic padding")
+	// ErrInvalidDigest is returned if the shares don't recover the secret they were split from
+	ErrInvalidDigest = errors.New("invalid digest of the shared secret")
+	// ErrInvalidMasterSecret is returned for master secrets shorter than 128 bits or with an odd length
+	ErrInvalidMasterSecret = errors.New("master secret must be at least 128 bits and have an even number of bytes")
+	// ErrInvalidPassphrase is returned for passphrases with characters other than printable ASCII
+	ErrInvalidPassphrase = errors.New("passphrase must only contain printable ASCII characters")
+	// ErrMixedShares is returned if shares from different sets are combined
+	ErrMixedShares = errors.New("all slip39 mnemonics must belong to the same set of shares")
+)
+
+// Group is the member share configuration of a group, e.g. 3-of-5
+type Group struct {
+	Threshold uint8 // Number of member shares required to recover the group share
+	Count     uint8 // Number of member shares of the group
+}
+
+// Share is a decoded mnemonic share
+type Share struct {
+	Identifier        uint16
+	Extendable        bool
+	IterationExponent uint8
+	GroupIndex        uint8
+	GroupThreshold    uint8
+	GroupCount        uint8
+	MemberIndex       uint8
+	MemberThreshold   uint8
+	Value             []byte
+}
+
+// GenerateMnemonics splits a master secret into groups of mnemonic shares. groupThreshold groups
+// are required to recover the master secret, and each group requires its member threshold of shares.
+// The master secret is encrypted with the passphrase, which may be empty.
+// The iteration exponent sets the work factor of the encryption, 10000 << iterationExponent PBKDF2 iterations.
+func GenerateMnemonics(groupThreshold uint8, groups []Group, masterSecret, passphrase []byte, iterationExponent uint8) ([][]string, error) {
+	if len(masterSecret)*8 < minStrengthBits || len(masterSecret)%2 != 0 {
+		return nil, ErrInvalidMasterSecret
+	}
+
+	if err := validatePassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	if iterationExponent >= 1<<iterationExpBits {
+		return nil, fmt.Errorf("iteration exponent must be less than %d", 1<<iterationExpBits)
+	}
+
+	if len(groups) == 0 || len(groups) > maxShareCount {
+		return nil, fmt.Errorf("the number of groups must be between 1 and %d", maxShareCount)
+	}
+
+	if groupThreshold == 0 || int(groupThreshold) > len(groups) {
+		return nil, fmt.Errorf("group threshold must be between 1 and the number of groups (%d)", len(groups))
+	}
+
+	for i, g := range groups {
+		if g.Threshold == 0 || g.Threshold > g.Count || g.Count > maxShareCount {
+			return nil, fmt.Errorf("group %d: member threshold must be between 1 and the member count, which must be at most %d", i, maxShareCount)
+		}
+		if g.Threshold == 1 && g.Count > 1 {
+			return nil, fmt.Errorf("group %d: creating multiple member shares with member threshold 1 is not allowed, use 1-of-1 member sharing instead", i)
+		}
+	}
+
+	var idBytes [2]byte
+	if _, err := io.ReadFull(rand.Reader, idBytes[:]); err != nil {
+		return nil, err
+	}
+	identifier := (uint16(idBytes[0])<<8 | uint16(idBytes[1])) & (1<<idLengthBits - 1)
+
+	ems := encrypt(masterSecret, passphrase, iterationExponent, identifier, false)
+
+	groupShares, err := splitSecret(groupThreshold, uint8(len(groups)), ems)
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonics := make([][]string, len(groups))
+	for i, g := range groups {
+		memberShares, err := splitSecret(g.Threshold, g.Count, groupShares[i].value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ms := range memberShares {
+			s := Share{
+				Identifier:        identifier,
+				IterationExponent: iterationExponent,
+				GroupIndex:        groupShares[i].x,
+				GroupThreshold:    groupThreshold,
+				GroupCount:        uint8(len(groups)),
+				MemberIndex:       ms.x,
+				MemberThreshold:   g.Threshold,
+				Value:             ms.value,
+			}
+			mnemonics[i] = append(mnemonics[i], s.Mnemonic())
+		}
+	}
+
+	return mnemonics, nil
+}
+
+// CombineMnemonics recovers the master secret from a set of mnemonic shares.
+// Exactly the group threshold of groups must be provided, each with exactly its member threshold of shares.
+func CombineMnemonics(mnemonics []string, passphrase []byte) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, errors.New("no slip39 mnemonics provided")
+	}
+
+	if err := validatePassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	shares := make([]*Share, len(mnemonics))
+	for i, m := range mnemonics {
+		s, err := DecodeMnemonic(m)
+		if err != nil {
+			return nil, err
+		}
+		shares[i] = s
+	}
+
+	first := shares[0]
+	groups := make(map[uint8][]*Share)
+	var groupOrder []uint8
+	for _, s := range shares {
+		if s.Identifier != first.Identifier || s.Extendable != first.Extendable || s.IterationExponent != first.IterationExponent {
+			return nil, ErrMixedShares
+		}
+		if s.GroupThreshold != first.GroupThreshold || s.GroupCount != first.GroupCount {
+			return nil, errors.New("all slip39 mnemonics must have the same group threshold and group count")
+		}
+		if len(s.Value) != len(first.Value) {
+			return nil, errors.New("all slip39 mnemonics must have the same length")
+		}
+
+		g, ok := groups[s.GroupIndex]
+		if !ok {
+			groupOrder = append(groupOrder, s.GroupIndex)
+		}
+		for _, gs := range g {
+			if gs.MemberThreshold != s.MemberThreshold {
+				return nil, fmt.Errorf("all slip39 mnemonics of group %d must have the same member threshold", s.GroupIndex)
+			}
+			if gs.MemberIndex == s.MemberIndex {
+				if !bytes.Equal(gs.Value, s.Value) {
+					return nil, fmt.Errorf("slip39 mnemonics of group %d have the same member index but different values", s.GroupIndex)
+				}
+				return nil, fmt.Errorf("duplicate slip39 mnemonic for member %d of group %d", s.MemberIndex, s.GroupIndex)
+			}
+		}
+		groups[s.GroupIndex] = append(g, s)
+	}
+
+	if len(groups) < int(first.GroupThreshold) {
+		return nil, fmt.Errorf("insufficient number of slip39 mnemonic groups, %d groups are required", first.GroupThreshold)
+	}
+	if len(groups) != int(first.GroupThreshold) {
+		return nil, fmt.Errorf("wrong number of slip39 mnemonic groups, expected %d groups but %d were provided", first.GroupThreshold, len(groups))
+	}
+
+	groupShares := make([]rawShare, 0, len(groups))
+	for _, gi := range groupOrder {
+		g := groups[gi]
+		if len(g) != int(g[0].MemberThreshold) {
+			return nil, fmt.Errorf("wrong number of slip39 mnemonics for group %d, expected %d but %d were provided", gi, g[0].MemberThreshold, len(g))
+		}
+
+		memberShares := make([]rawShare, len(g))
+		for i, s := range g {
+			memberShares[i] = rawShare{
+				x:     s.MemberIndex,
+				value: s.Value,
+			}
+		}
+
+		v, err := recoverSecret(g[0].MemberThreshold, memberShares)
+		if err != nil {
+			return nil, err
+		}
+
+		groupShares = append(groupShares, rawShare{
+			x:     gi,
+			value: v,
+		})
+	}
+
+	ems, err := recoverSecret(first.GroupThreshold, groupShares)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(ems, passphrase, first.IterationExponent, first.Identifier, first.Extendable), nil
+}
+
+// DecodeMnemonic decodes a mnemonic share, verifying its checksum
+func DecodeMnemonic(mnemonic string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minMnemonicLengthWords {
+		return nil, fmt.Errorf("%v: a mnemonic must have at least %d words", ErrInvalidMnemonic, minMnemonicLengthWords)
+	}
+
+	indices := make([]int, len(words))
+	for i, w := range words {
+		idx, ok := wordIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown word %q", ErrInvalidMnemonic, w)
+		}
+		indices[i] = idx
+	}
+
+	paddingLen := (radixBits * (len(words) - metadataLengthWords)) % 16
+	if paddingLen > 8 {
+		return nil, fmt.Errorf("%v: invalid length", ErrInvalidMnemonic)
+	}
+
+	idExp := intFromIndices(indices[:idExpLengthWords])
+	extendable := (idExp>>iterationExpBits)&1 == 1
+	if !rs1024VerifyChecksum(customization(extendable), indices) {
+		return nil, ErrInvalidChecksum
+	}
+
+	params := intFromIndices(indices[idExpLengthWords : idExpLengthWords+paramsLengthWords])
+	s := &Share{
+		Identifier:        uint16(idExp >> (iterationExpBits + 1)),
+		Extendable:        extendable,
+		IterationExponent: uint8(idExp & (1<<iterationExpBits - 1)),
+		GroupIndex:        uint8(params >> 16),
+		GroupThreshold:    uint8(params>>12&0xf) + 1,
+		GroupCount:        uint8(params>>8&0xf) + 1,
+		MemberIndex:       uint8(params >> 4 & 0xf),
+		MemberThreshold:   uint8(params&0xf) + 1,
+	}
+
+	if s.GroupCount < s.GroupThreshold {
+		return nil, fmt.Errorf("%v: group threshold cannot be greater than the group count", ErrInvalidMnemonic)
+	}
+
+	valueIndices := indices[idExpLengthWords+paramsLengthWords : len(indices)-checksumLengthWords]
+	valueByteCount := (radixBits*len(valueIndices) - paddingLen) / 8
+	v := bigIntFromIndices(valueIndices)
+	if v.BitLen() > valueByteCount*8 {
+		return nil, ErrInvalidPadding
+	}
+	b := v.Bytes()
+	s.Value = make([]byte, valueByteCount)
+	copy(s.Value[valueByteCount-len(b):], b)
+
+	return s, nil
+}
+
+// Mnemonic encodes the share as a mnemonic
+func (s Share) Mnemonic() string {
+	ext := 0
+	if s.Extendable {
+		ext = 1
+	}
+	idExp := int(s.Identifier)<<(iterationExpBits+1) | ext<<iterationExpBits | int(s.IterationExponent)
+	params := int(s.GroupIndex)<<16 | int(s.GroupThreshold-1)<<12 | int(s.GroupCount-1)<<8 |
+		int(s.MemberIndex)<<4 | int(s.MemberThreshold-1)
+
+	valueWordCount := (len(s.Value)*8 + radixBits - 1) / radixBits
+
+	indices := indicesFromInt(big.NewInt(int64(idExp)), idExpLengthWords)
+	indices = append(indices, indicesFromInt(big.NewInt(int64(params)), paramsLengthWords)...)
+	indices = append(indices, indicesFromInt(new(big.Int).SetBytes(s.Value), valueWordCount)...)
+	indices = append(indices, rs1024CreateChecksum(customization(s.Extendable), indices)...)
+
+	words := make([]string, len(indices))
+	for i, idx := range indices {
+		words[i] = wordlist[idx]
+	}
+
+	return strings.Join(words, " ")
+}
+
+func validatePassphrase(passphrase []byte) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return ErrInvalidPassphrase
+		}
+	}
+	return nil
+}
+
+func customization(extendable bool) []byte {
+	if extendable {
+		return []byte(customizationStringExtendable)
+	}
+	return []byte(customizationString)
+}
+
+func intFromIndices(indices []int) int {
+	v := 0
+	for _, idx := range indices {
+		v = v<<radixBits | idx
+	}
+	return v
+}
+
+func bigIntFromIndices(indices []int) *big.Int {
+	v := new(big.Int)
+	for _, idx := range indices {
+		v.Lsh(v, radixBits)
+		v.Or(v, big.NewInt(int64(idx)))
+	}
+	return v
+}
+
+func indicesFromInt(v *big.Int, n int) []int {
+	indices := make([]int, n)
+	x := new(big.Int).Set(v)
+	mask := big.NewInt(radix - 1)
+	for i := n - 1; i >= 0; i-- {
+		indices[i] = int(new(big.Int).And(x, mask).Int64())
+		x.Rsh(x, radixBits)
+	}
+	return indices
+}
+
+// Feistel network encryption of the master secret
+
+func encrypt(masterSecret, passphrase []byte, iterationExponent uint8, identifier uint16, extendable bool) []byte {
+	half := len(masterSecret) / 2
+	l := append([]byte{}, masterSecret[:half]...)
+	r := append([]byte{}, masterSecret[half:]...)
+	salt := feistelSalt(identifier, extendable)
+	for i := 0; i < roundCount; i++ {
+		l, r = r, xor(l, roundFunction(byte(i), passphrase, iterationExponent, salt, r))
+	}
+	return append(r, l...)
+}
+
+func decrypt(ems, passphrase []byte, iterationExponent uint8, identifier uint16, extendable bool) []byte {
+	half := len(ems) / 2
+	l := append([]byte{}, ems[:half]...)
+	r := append([]byte{}, ems[half:]...)
+	salt := feistelSalt(identifier, extendable)
+	for i := roundCount - 1; i >= 0; i-- {
+		l, r = r, xor(l, roundFunction(byte(i), passphrase, iterationExponent, salt, r))
+	}
+	return append(r, l...)
+}
+
+func roundFunction(i byte, passphrase []byte, iterationExponent uint8, salt, r []byte) []byte {
+	p := append([]byte{i}, passphrase...)
+	s := append(append([]byte{}, salt...), r...)
+	return pbkdf2.Key(p, s, (baseIterationCount<<iterationExponent)/roundCount, len(r), sha256.New)
+}
+
+func feistelSalt(identifier uint16, extendable bool) []byte {
+	if extendable {
+		return nil
+	}
+	return append([]byte(customizationString), byte(identifier>>8), byte(identifier))
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// Shamir's secret sharing over GF(256)
+
+type rawShare struct {
+	x     uint8
+	value []byte
+}
+
+func splitSecret(threshold, shareCount uint8, secret []byte) ([]rawShare, error) {
+	if threshold == 0 || threshold > shareCount || shareCount > maxShareCount {
+		return nil, errors.New("invalid threshold or share count")
+	}
+
+	if threshold == 1 {
+		shares := make([]rawShare, shareCount)
+		for i := range shares {
+			shares[i] = rawShare{
+				x:     uint8(i),
+				value: append([]byte{}, secret...),
+			}
+		}
+		return shares, nil
+	}
+
+	randomShareCount := threshold - 2
+	shares := make([]rawShare, 0, shareCount)
+	for i := uint8(0); i < randomShareCount; i++ {
+		v := make([]byte, len(secret))
+		if _, err := io.ReadFull(rand.Reader, v); err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{
+			x:     i,
+			value: v,
+		})
+	}
+
+	randomPart := make([]byte, len(secret)-digestLengthBytes)
+	if _, err := io.ReadFull(rand.Reader, randomPart); err != nil {
+		return nil, err
+	}
+
+	baseShares := append([]rawShare{}, shares...)
+	baseShares = append(baseShares, rawShare{
+		x:     digestIndex,
+		value: append(createDigest(randomPart, secret), randomPart...),
+	}, rawShare{
+		x:     secretIndex,
+		value: secret,
+	})
+
+	for i := randomShareCount; i < shareCount; i++ {
+		v, err := interpolate(baseShares, i)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{
+			x:     i,
+			value: v,
+		})
+	}
+
+	return shares, nil
+}
+
+func recoverSecret(threshold uint8, shares []rawShare) ([]byte, error) {
+	if threshold == 1 {
+		return shares[0].value, nil
+	}
+
+	secret, err := interpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	digestShare, err := interpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(digestShare[:digestLengthBytes], createDigest(digestShare[digestLengthBytes:], secret)) {
+		return nil, ErrInvalidDigest
+	}
+
+	return secret, nil
+}
+
+func createDigest(randomData, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomData)
+	mac.Write(secret) //nolint:errcheck
+	return mac.Sum(nil)[:digestLengthBytes]
+}
+
+// GF(256) exponent and logarithm tables, with the Rijndael polynomial x^8 + x^4 + x^3 + x + 1
+var expTable, logTable = func() ([255]int, [256]int) {
+	var exp [255]int
+	var log [256]int
+	poly := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = poly
+		log[poly] = i
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+	return exp, log
+}()
+
+// interpolate evaluates the polynomial through the shares at x
+func interpolate(shares []rawShare, x uint8) ([]byte, error) {
+	seen := make(map[uint8]struct{}, len(shares))
+	for _, s := range shares {
+		if _, ok := seen[s.x]; ok {
+			return nil, errors.New("share indices must be unique")
+		}
+		seen[s.x] = struct{}{}
+		if len(s.value) != len(shares[0].value) {
+			return nil, errors.New("all share values must have the same length")
+		}
+	}
+
+	for _, s := range shares {
+		if s.x == x {
+			return append([]byte{}, s.value...), nil
+		}
+	}
+
+	logProd := 0
+	for _, s := range shares {
+		logProd += logTable[s.x^x]
+	}
+
+	result := make([]byte, len(shares[0].value))
+	for _, s := range shares {
+		logBasis := logProd - logTable[s.x^x]
+		for _, o := range shares {
+			if o.x != s.x {
+				logBasis -= logTable[o.x^s.x]
+			}
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+
+		for i, y := range s.value {
+			if y != 0 {
+				result[i] ^= byte(expTable[(logTable[y]+logBasis)%255])
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// RS1024 checksum
+
+var rs1024Gen = [10]uint32{
+	0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009,
+	0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120,
+}
+
+func rs1024Polymod(values []int) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xFFFFF)<<10 ^ uint32(v)
+		for i := uint(0); i < 10; i++ {
+			if (b>>i)&1 != 0 {
+				chk ^= rs1024Gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func rs1024Values(cs []byte, data []int) []int {
+	values := make([]int, 0, len(cs)+len(data)+checksumLengthWords)
+	for _, c := range cs {
+		values = append(values, int(c))
+	}
+	return append(values, data...)
+}
+
+func rs1024VerifyChecksum(cs []byte, data []int) bool {
+	return rs1024Polymod(rs1024Values(cs, data)) == 1
+}
+
+func rs1024CreateChecksum(cs []byte, data []int) []int {
+	values := append(rs1024Values(cs, data), make([]int, checksumLengthWords)...)
+	polymod := rs1024Polymod(values) ^ 1
+	checksum := make([]int, checksumLengthWords)
+	for i := range checksum {
+		checksum[i] = int(polymod>>(uint(radixBits*(checksumLengthWords-1-i)))) & (radix - 1)
+	}
+	return checksum
+}
@@ -0,0 +1,174 @@
+package slip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from the SLIP-39 reference implementation, with the passphrase "TREZOR"
+func TestCombineMnemonics(t *testing.T) {
+	cases := []struct {
+		name         string
+		mnemonics    []string
+		masterSecret string
+		err          bool
+	}{
+		{
+			name: "valid mnemonic without sharing",
+			mnemonics: []string{
+				"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard",
+			},
+			masterSecret: "bb54aac4b89dc868ba37d9cc21b2cece",
+		},
+		{
+			name: "mnemonic with invalid checksum",
+			mnemonics: []string{
+				"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney",
+			},
+			err: true,
+		},
+		{
+			name: "basic sharing 2-of-3",
+			mnemonics: []string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+				"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+			},
+			masterSecret: "b43ceb7e57a0ea8766221624d01b0864",
+		},
+		{
+			name: "basic sharing 2-of-3, one share",
+			mnemonics: []string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+			},
+			err: true,
+		},
+		{
+			name: "mnemonics of different sets",
+			mnemonics: []string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+				"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard",
+			},
+			err: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms, err := CombineMnemonics(tc.mnemonics, []byte("TREZOR"))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.masterSecret, hex.EncodeToString(ms))
+		})
+	}
+}
+
+func TestGenerateMnemonics(t *testing.T) {
+	ms, err := hex.DecodeString("bb54aac4b89dc868ba37d9cc21b2cece")
+	require.NoError(t, err)
+
+	groups := []Group{{Threshold: 1, Count: 1}, {Threshold: 2, Count: 3}, {Threshold: 3, Count: 5}}
+	shares, err := GenerateMnemonics(2, groups, ms, []byte("TREZOR"), 0)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+	for i, g := range groups {
+		require.Len(t, shares[i], int(g.Count))
+	}
+
+	// Any 2 groups recover the master secret, with the member threshold of shares of each
+	recovered, err := CombineMnemonics([]string{shares[1][2], shares[2][4], shares[1][0], shares[2][1], shares[2][3]}, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, ms, recovered)
+
+	recovered, err = CombineMnemonics([]string{shares[0][0], shares[1][1], shares[1][2]}, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, ms, recovered)
+
+	// A different passphrase decrypts to a different master secret
+	recovered, err = CombineMnemonics([]string{shares[0][0], shares[1][1], shares[1][2]}, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, ms, recovered)
+
+	// Not enough members of a group
+	_, err = CombineMnemonics([]string{shares[0][0], shares[2][0], shares[2][1]}, []byte("TREZOR"))
+	require.Error(t, err)
+
+	// Not enough groups
+	_, err = CombineMnemonics([]string{shares[2][0], shares[2][1], shares[2][2]}, []byte("TREZOR"))
+	require.Error(t, err)
+
+	// Duplicate shares
+	_, err = CombineMnemonics([]string{shares[0][0], shares[1][1], shares[1][1]}, []byte("TREZOR"))
+	require.Error(t, err)
+
+	// Multiple member shares with a member threshold of 1 are not allowed
+	_, err = GenerateMnemonics(1, []Group{{Threshold: 1, Count: 3}}, ms, nil, 0)
+	require.Error(t, err)
+
+	// Group threshold greater than the number of groups
+	_, err = GenerateMnemonics(2, []Group{{Threshold: 2, Count: 3}}, ms, nil, 0)
+	require.Error(t, err)
+
+	// Master secrets must be at least 128 bits
+	_, err = GenerateMnemonics(1, []Group{{Threshold: 2, Count: 3}}, ms[:14], nil, 0)
+	require.Equal(t, ErrInvalidMasterSecret, err)
+}
+
+func TestRS1024Checksum(t *testing.T) {
+	mnemonic := "duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"
+	words := strings.Fields(mnemonic)
+	indices := make([]int, len(words))
+	for i, w := range words {
+		indices[i] = wordIndex[w]
+	}
+
+	// The last words of a mnemonic are the checksum of the others
+	cs := customization(false)
+	require.True(t, rs1024VerifyChecksum(cs, indices))
+	data := indices[:len(indices)-checksumLengthWords]
+	require.Equal(t, indices[len(data):], rs1024CreateChecksum(cs, data))
+
+	// The checksum detects changed words, and depends on the customization string
+	require.False(t, rs1024VerifyChecksum(customization(true), indices))
+	for i := range indices {
+		changed := append([]int{}, indices...)
+		changed[i] = (changed[i] + 1) % radix
+		require.False(t, rs1024VerifyChecksum(cs, changed), i)
+	}
+
+	_, err := DecodeMnemonic(strings.Join(append(words[:len(words)-1], "kidney"), " "))
+	require.Equal(t, ErrInvalidChecksum, err)
+}
+
+func TestFeistel(t *testing.T) {
+	// The share value of a mnemonic without sharing is the encrypted master secret of the reference vector
+	s, err := DecodeMnemonic("duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard")
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), s.GroupThreshold)
+	require.Equal(t, uint8(1), s.MemberThreshold)
+
+	ms := decrypt(s.Value, []byte("TREZOR"), s.IterationExponent, s.Identifier, s.Extendable)
+	require.Equal(t, "bb54aac4b89dc868ba37d9cc21b2cece", hex.EncodeToString(ms))
+	require.Equal(t, s.Value, encrypt(ms, []byte("TREZOR"), s.IterationExponent, s.Identifier, s.Extendable))
+
+	// The encryption depends on the passphrase, the identifier and whether the share set is extendable
+	for _, ems := range [][]byte{
+		encrypt(ms, nil, s.IterationExponent, s.Identifier, s.Extendable),
+		encrypt(ms, []byte("TREZOR"), s.IterationExponent, s.Identifier+1, s.Extendable),
+		encrypt(ms, []byte("TREZOR"), s.IterationExponent, s.Identifier, !s.Extendable),
+	} {
+		require.NotEqual(t, s.Value, ems)
+	}
+
+	// Secrets of any even length round trip
+	secret := bytes.Repeat([]byte{0x5a}, 32)
+	ems := encrypt(secret, []byte("pass"), 1, 42, true)
+	require.Len(t, ems, len(secret))
+	require.Equal(t, secret, decrypt(ems, []byte("pass"), 1, 42, true))
+}
@@ -0,0 +1,178 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/chacha20poly1305"
+)
+
+const (
+	argon2idChacha20MetaLengthSize = 2  // meta data length field size in bytes
+	argon2idChacha20SaltSize       = 32 // salt bytes number
+
+	// The argon2id parameters read from wallet files are bounded, so that a tampered wallet file
+	// can't make decryption allocate unbounded memory or run indefinitely
+	argon2idMaxTime   = 16
+	argon2idMaxMemory = 1024 * 1024 // 1 GiB
+)
+
+// Default argon2id parameters, from the second recommended option of RFC 9106
+const (
+	// Argon2idTime is the number of passes over the memory
+	Argon2idTime = 3
+	// Argon2idMemory is the memory size in KiB, 64 MiB
+	Argon2idMemory = 64 * 1024
+	// Argon2idThreads is the number of lanes
+	Argon2idThreads = 4
+	// Argon2idKeyLen is the length of the derived chacha20poly1305 key
+	Argon2idKeyLen = chacha20poly1305.KeySize
+)
+
+// DefaultArgon2idChacha20poly1305 default Argon2idChacha20poly1305 encryptor
+var DefaultArgon2idChacha20poly1305 = Argon2idChacha20poly1305{
+	Time:    Argon2idTime,
+	Memory:  Argon2idMemory,
+	Threads: Argon2idThreads,
+	KeyLen:  Argon2idKeyLen,
+}
+
+// Argon2idChacha20poly1305 provides methods for encryption/decryption with argon2id and chacha20poly1305.
+// The argon2id parameters are stored with the ciphertext, so they can be raised without breaking
+// the decryption of data encrypted with lower parameters.
+type Argon2idChacha20poly1305 struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+}
+
+type argon2idMeta struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	KeyLen  uint32 `json:"keyLen"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+}
+
+// Encrypt encrypts data with password, in the format of encrypt.ScryptChacha20poly1305:
+// base64([[length][metadata]][ciphertext]), where the JSON metadata has the argon2id parameters,
+// salt and nonce, and [length][metadata] is the additional data of the AEAD.
+func (a Argon2idChacha20poly1305) Encrypt(data, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("missing password")
+	}
+
+	m := argon2idMeta{
+		Time:    a.Time,
+		Memory:  a.Memory,
+		Threads: a.Threads,
+		KeyLen:  a.KeyLen,
+		Salt:    cipher.RandByte(argon2idChacha20SaltSize),
+		Nonce:   cipher.RandByte(chacha20poly1305.NonceSize),
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	dk := argon2.IDKey(password, m.Salt, m.Time, m.Memory, m.Threads, m.KeyLen)
+
+	ms, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ms) > math.MaxUint16 {
+		return nil, errors.New("metadata length beyond the math.MaxUint16")
+	}
+
+	length := make([]byte, argon2idChacha20MetaLengthSize)
+	binary.LittleEndian.PutUint16(length, uint16(len(ms)))
+
+	// Additional data for AEAD
+	ad := append(length, ms...)
+	aead, err := chacha20poly1305.New(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := aead.Seal(nil, m.Nonce, data, ad)
+
+	rawData := append(ad, ciphertext...)
+	enc := base64.StdEncoding
+	buf := make([]byte, enc.EncodedLen(len(rawData)))
+	enc.Encode(buf, rawData)
+	return buf, nil
+}
+
+// Decrypt decrypts the data with password, deriving the key with the argon2id parameters of the metadata
+func (a Argon2idChacha20poly1305) Decrypt(data, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("missing password")
+	}
+
+	enc := base64.StdEncoding
+	encData := make([]byte, enc.DecodedLen(len(data)))
+	n, err := enc.Decode(encData, data)
+	if err != nil {
+		return nil, err
+	}
+	encData = encData[:n]
+
+	if len(encData) < argon2idChacha20MetaLengthSize {
+		return nil, errors.New("invalid metadata length")
+	}
+
+	length := int(binary.LittleEndian.Uint16(encData[:argon2idChacha20MetaLengthSize]))
+	if argon2idChacha20MetaLengthSize+length > len(encData) {
+		return nil, errors.New("invalid metadata length")
+	}
+
+	var m argon2idMeta
+	if err := json.Unmarshal(encData[argon2idChacha20MetaLengthSize:argon2idChacha20MetaLengthSize+length], &m); err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	ad := encData[:argon2idChacha20MetaLengthSize+length]
+	dk := argon2.IDKey(password, m.Salt, m.Time, m.Memory, m.Threads, m.KeyLen)
+
+	aead, err := chacha20poly1305.New(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, m.Nonce, encData[argon2idChacha20MetaLengthSize+length:], ad)
+}
+
+func (m argon2idMeta) validate() error {
+	switch {
+	case m.Time == 0:
+		return errors.New("argon2id time must be at least 1")
+	case m.Time > argon2idMaxTime:
+		return errors.New("argon2id time is too large")
+	case m.Threads == 0:
+		return errors.New("argon2id threads must be at least 1")
+	case m.Memory < 8*uint32(m.Threads):
+		return errors.New("argon2id memory must be at least 8 KiB per thread")
+	case m.Memory > argon2idMaxMemory:
+		return errors.New("argon2id memory is too large")
+	case m.KeyLen != chacha20poly1305.KeySize:
+		return errors.New("invalid argon2id key length")
+	case len(m.Salt) != argon2idChacha20SaltSize:
+		return errors.New("invalid salt length")
+	case len(m.Nonce) != chacha20poly1305.NonceSize:
+		return errors.New("invalid nonce length")
+	default:
+		return nil
+	}
+}
@@ -142,15 +142,31 @@ func (w *Bip44Wallet) HasEntry(a cipher.Addresser) bool {
 	return w.ExternalEntries.has(a) || w.ChangeEntries.has(a)
 }
 
+// seed returns the bip32 seed of the wallet's mnemonic
+func (w *Bip44Wallet) seed() ([]byte, error) {
+	switch seedType := w.Meta.SeedType(); {
+	case seedType.IsElectrum():
+		return newElectrumSeed(w.Meta.Seed(), w.Meta.SeedPassphrase(), seedType)
+	case seedType == SeedTypeSlip39:
+		return decodeSlip39Seed(w.Meta.Seed())
+	}
+
+	// w.Meta.Seed() must return a valid bip39 mnemonic
+	return bip39.NewSeed(w.Meta.Seed(), w.Meta.SeedPassphrase())
+}
+
 // CoinHDNode return the "coin" level bip44 HDNode
 func (w *Bip44Wallet) CoinHDNode() (*bip44.Coin, error) {
-	// w.Meta.Seed() must return a valid bip39 mnemonic
-	seed, err := bip39.NewSeed(w.Meta.Seed(), w.Meta.SeedPassphrase())
+	if w.Meta.SeedType().IsElectrum() {
+		return nil, NewError(errors.New("electrum seeds do not derive keys along bip44 paths"))
+	}
+
+	seed, err := w.seed()
 	if err != nil {
 		return nil, err
 	}
 
-	c, err := bip44.NewCoin(seed, w.Meta.Bip44Coin())
+	c, err := newCoinHDNode(seed, w.Meta.bip32Purpose(), w.Meta.Bip44Coin())
 	if err != nil {
 		logger.Critical().WithError(err).Error("Failed to derive the bip44 purpose node")
 		if bip32.IsImpossibleChildError(err) {
@@ -162,6 +178,28 @@ func (w *Bip44Wallet) CoinHDNode() (*bip44.Coin, error) {
 	return c, nil
 }
 
+// newCoinHDNode creates the bip32 node at the "coin" level of a bip44-style
+// path m/purpose'/coin_type'. The purpose is 44 for bip44 wallets, and
+// differs for the other address types that follow the bip44 path layout (e.g. 84 for bip84).
+func newCoinHDNode(seed []byte, purpose uint32, coinType bip44.CoinType) (*bip44.Coin, error) {
+	if purpose == purposeBip44 {
+		return bip44.NewCoin(seed, coinType)
+	}
+
+	if uint32(coinType) >= bip32.FirstHardenedChild {
+		return nil, bip44.ErrInvalidCoinType
+	}
+
+	pk, err := bip32.NewPrivateKeyFromPath(seed, fmt.Sprintf("m/%d'/%d'", purpose, coinType))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bip44.Coin{
+		PrivateKey: pk,
+	}, nil
+}
+
 // nextChildIdx returns the next child index from a sequence of entries.
 // This assumes that entries are sorted by child number ascending.
 func nextChildIdx(e Entries) uint32 {
@@ -171,23 +209,86 @@ func nextChildIdx(e Entries) uint32 {
 	return e[len(e)-1].ChildNumber + 1
 }
 
-// generateEntries generates addresses for a change chain (should be 0 or 1) starting from an initial child number.
-func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uint32) (Entries, error) {
-	if w.Meta.IsEncrypted() {
-		return nil, ErrWalletEncrypted
+// Accounts returns the indices of the bip44 accounts in use by the wallet, in ascending order.
+// Account 0 is always in use.
+func (w *Bip44Wallet) Accounts() []uint32 {
+	seen := map[uint32]struct{}{
+		0: {},
+	}
+	for _, e := range w.ExternalEntries {
+		seen[e.Account] = struct{}{}
+	}
+	for _, e := range w.ChangeEntries {
+		seen[e.Account] = struct{}{}
 	}
 
-	if num > math.MaxUint32 {
-		return nil, NewError(errors.New("Bip44Wallet.generateEntries num too large"))
+	accounts := make([]uint32, 0, len(seen))
+	for a := range seen {
+		accounts = append(accounts, a)
 	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i] < accounts[j]
+	})
+	return accounts
+}
 
-	// Cap `num` in case it would exceed the maximum child index number
-	if math.MaxUint32-initialChildIdx < uint32(num) {
-		num = uint64(math.MaxUint32 - initialChildIdx)
+func (w *Bip44Wallet) hasAccount(account uint32) bool {
+	if account == 0 {
+		return true
+	}
+	for _, a := range w.Accounts() {
+		if a == account {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAccount creates the next bip44 account and generates its first external address.
+// Accounts are created sequentially, the new account index is one past the highest account in use.
+func (w *Bip44Wallet) NewAccount() (uint32, error) {
+	if w.Meta.SeedType().IsElectrum() {
+		return 0, NewError(errors.New("electrum seeds only have a single account"))
 	}
 
-	if num == 0 {
-		return nil, nil
+	accounts := w.Accounts()
+	account, err := mathutil.AddUint32(accounts[len(accounts)-1], 1)
+	if err != nil || account >= bip32.FirstHardenedChild {
+		return 0, NewError(bip44.ErrInvalidAccount)
+	}
+
+	entries, err := w.generateEntries(1, account, bip44.ExternalChainIndex, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	w.ExternalEntries = append(w.ExternalEntries, entries...)
+	w.ExternalEntries.sortByAccount()
+
+	return account, nil
+}
+
+// accountHDNode returns the "account" level bip44 HDNode.
+// For Electrum seeds, it is the node the receive and change chains derive from.
+func (w *Bip44Wallet) accountHDNode(account uint32) (*bip44.Account, error) {
+	if seedType := w.Meta.SeedType(); seedType.IsElectrum() {
+		if account != 0 {
+			return nil, NewError(errors.New("electrum seeds only have a single account"))
+		}
+
+		seed, err := w.seed()
+		if err != nil {
+			return nil, err
+		}
+
+		pk, err := electrumAccountNode(seed, seedType)
+		if err != nil {
+			return nil, err
+		}
+
+		return &bip44.Account{
+			PrivateKey: pk,
+		}, nil
 	}
 
 	c, err := w.CoinHDNode()
@@ -195,8 +296,7 @@ func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uin
 		return nil, err
 	}
 
-	// Generate the "account" HDNode. Multiple accounts are not supported; use 0.
-	account, err := c.Account(0)
+	a, err := c.Account(account)
 	if err != nil {
 		logger.Critical().WithError(err).Error("Failed to derive the bip44 account node")
 		if bip32.IsImpossibleChildError(err) {
@@ -205,6 +305,34 @@ func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uin
 		return nil, err
 	}
 
+	return a, nil
+}
+
+// generateEntries generates addresses for an account's chain (should be 0 or 1) starting from an initial child number.
+func (w *Bip44Wallet) generateEntries(num uint64, accountIdx, changeIdx, initialChildIdx uint32) (Entries, error) {
+	if w.Meta.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+
+	if num > math.MaxUint32 {
+		return nil, NewError(errors.New("Bip44Wallet.generateEntries num too large"))
+	}
+
+	// Cap `num` in case it would exceed the maximum child index number
+	if math.MaxUint32-initialChildIdx < uint32(num) {
+		num = uint64(math.MaxUint32 - initialChildIdx)
+	}
+
+	if num == 0 {
+		return nil, nil
+	}
+
+	// Generate the "account" HDNode
+	account, err := w.accountHDNode(accountIdx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate the external chain parent node
 	chain, err := account.NewPrivateChildKey(changeIdx)
 	if err != nil {
@@ -229,7 +357,7 @@ func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uin
 			logger.Critical().WithError(addErr).WithFields(logrus.Fields{
 				"num":             num,
 				"initialChildIdx": initialChildIdx,
-				"accountIdx":      0,
+				"accountIdx":      accountIdx,
 				"changeIdx":       changeIdx,
 				"childIdx":        j,
 				"i":               i,
@@ -240,14 +368,14 @@ func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uin
 		if err != nil {
 			if bip32.IsImpossibleChildError(err) {
 				logger.Critical().WithError(err).WithFields(logrus.Fields{
-					"accountIdx": 0,
+					"accountIdx": accountIdx,
 					"changeIdx":  changeIdx,
 					"childIdx":   j,
 				}).Error("ImpossibleChild for chain node child element")
 				continue
 			} else {
 				logger.Critical().WithError(err).WithFields(logrus.Fields{
-					"accountIdx": 0,
+					"accountIdx": accountIdx,
 					"changeIdx":  changeIdx,
 					"childIdx":   j,
 				}).Error("NewPrivateChildKey failed unexpectedly")
@@ -270,16 +398,21 @@ func (w *Bip44Wallet) generateEntries(num uint64, changeIdx, initialChildIdx uin
 			Public:      pk,
 			ChildNumber: addressIndices[i],
 			Change:      changeIdx,
+			Account:     accountIdx,
 		}
 	}
 
 	return entries, nil
 }
 
-// PeekChangeEntry creates and returns an entry for the change chain.
-// If used, the caller the append it with GenerateChangeEntry
-func (w *Bip44Wallet) PeekChangeEntry() (Entry, error) {
-	entries, err := w.generateEntries(1, bip44.ChangeChainIndex, nextChildIdx(w.ChangeEntries))
+// PeekChangeEntry creates and returns an entry for the change chain of an account.
+// If used, the caller appends it with GenerateChangeEntry
+func (w *Bip44Wallet) PeekChangeEntry(account uint32) (Entry, error) {
+	if !w.hasAccount(account) {
+		return Entry{}, NewError(fmt.Errorf("bip44 account %d does not exist", account))
+	}
+
+	entries, err := w.generateEntries(1, account, bip44.ChangeChainIndex, nextChildIdx(w.ChangeEntries.forAccount(account)))
 	if err != nil {
 		return Entry{}, err
 	}
@@ -291,37 +424,129 @@ func (w *Bip44Wallet) PeekChangeEntry() (Entry, error) {
 	return entries[0], nil
 }
 
-// GenerateChangeEntry creates, appends and returns an entry for the change chain
-func (w *Bip44Wallet) GenerateChangeEntry() (Entry, error) {
-	e, err := w.PeekChangeEntry()
+// GenerateChangeEntry creates, appends and returns an entry for the change chain of an account
+func (w *Bip44Wallet) GenerateChangeEntry(account uint32) (Entry, error) {
+	e, err := w.PeekChangeEntry(account)
 	if err != nil {
 		return Entry{}, err
 	}
 
 	w.ChangeEntries = append(w.ChangeEntries, Entries{e}...)
+	w.ChangeEntries.sortByAccount()
 
-	return w.ChangeEntries[len(w.ChangeEntries)-1], nil
+	return e, nil
 }
 
-// GenerateAddresses generates addresses for the external chain, and appends them to the wallet's entries array
+// GenerateAddresses generates addresses for the external chain of account 0,
+// and appends them to the wallet's entries array
 func (w *Bip44Wallet) GenerateAddresses(num uint64) ([]cipher.Addresser, error) {
-	entries, err := w.generateEntries(num, bip44.ExternalChainIndex, nextChildIdx(w.ExternalEntries))
+	return w.GenerateAccountAddresses(0, num)
+}
+
+// GenerateAccountAddresses generates addresses for the external chain of an account,
+// and appends them to the wallet's entries array
+func (w *Bip44Wallet) GenerateAccountAddresses(account uint32, num uint64) ([]cipher.Addresser, error) {
+	if !w.hasAccount(account) {
+		return nil, NewError(fmt.Errorf("bip44 account %d does not exist", account))
+	}
+
+	entries, err := w.generateEntries(num, account, bip44.ExternalChainIndex, nextChildIdx(w.ExternalEntries.forAccount(account)))
 	if err != nil {
 		return nil, err
 	}
 
 	w.ExternalEntries = append(w.ExternalEntries, entries...)
+	w.ExternalEntries.sortByAccount()
 
 	return entries.getAddresses(), nil
 }
 
+// ScanAddresses scans ahead the external and change chains of every account, until scanN
+// consecutive addresses without transaction history are found on each chain.
+// The scanned addresses are kept up to the last address with any transaction history.
+func (w *Bip44Wallet) ScanAddresses(scanN uint64, tf TransactionsFinder) error {
+	if w.Meta.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if scanN == 0 {
+		return nil
+	}
+
+	w2 := w.Clone().(*Bip44Wallet)
+
+	for _, account := range w.Accounts() {
+		account := account
+		for _, changeIdx := range []uint32{bip44.ExternalChainIndex, bip44.ChangeChainIndex} {
+			changeIdx := changeIdx
+
+			chainEntries := w2.ExternalEntries
+			if changeIdx == bip44.ChangeChainIndex {
+				chainEntries = w2.ChangeEntries
+			}
+
+			entries, err := scanAddresses(func(num uint64, childIdx uint32) (Entries, error) {
+				return w2.generateEntries(num, account, changeIdx, childIdx)
+			}, scanN, tf, nextChildIdx(chainEntries.forAccount(account)))
+			if err != nil {
+				return err
+			}
+
+			if changeIdx == bip44.ChangeChainIndex {
+				w2.ChangeEntries = append(w2.ChangeEntries, entries...)
+			} else {
+				w2.ExternalEntries = append(w2.ExternalEntries, entries...)
+			}
+		}
+	}
+
+	w2.ExternalEntries.sortByAccount()
+	w2.ChangeEntries.sortByAccount()
+
+	*w = *w2
+
+	return nil
+}
+
+// AccountXPub returns the serialized extended public key of an account, m/purpose'/coin_type'/account'.
+// Bitcoin keys are serialized with the SLIP-132 version of the wallet's address type (xpub, ypub or zpub).
+func (w *Bip44Wallet) AccountXPub(account uint32) (string, error) {
+	if w.Meta.IsEncrypted() {
+		return "", ErrWalletEncrypted
+	}
+
+	if !w.hasAccount(account) {
+		return "", NewError(fmt.Errorf("bip44 account %d does not exist", account))
+	}
+
+	a, err := w.accountHDNode(account)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeXPub(a.PublicKey(), w.Meta.Coin(), w.Meta.AddressType())
+}
+
+// accountPath returns the bip32 path of an account node, m/purpose'/coin_type'/account'
+func (w *Bip44Wallet) accountPath(account uint32) string {
+	if seedType := w.Meta.SeedType(); seedType.IsElectrum() {
+		return electrumAccountPath(seedType)
+	}
+	return fmt.Sprintf("m/%d'/%d'/%d'", w.Meta.bip32Purpose(), w.Meta.Bip44Coin(), account)
+}
+
+// GetAccountEntries returns a copy of the external and change entries of an account
+func (w *Bip44Wallet) GetAccountEntries(account uint32) (Entries, Entries) {
+	return w.ExternalEntries.forAccount(account).clone(), w.ChangeEntries.forAccount(account).clone()
+}
+
 // Fingerprint returns a unique ID fingerprint for this wallet, composed of its initial address
 // and wallet type
 func (w *Bip44Wallet) Fingerprint() string {
 	addr := ""
 	if len(w.ExternalEntries) == 0 {
 		if !w.IsEncrypted() {
-			entries, err := w.generateEntries(1, bip44.ExternalChainIndex, 0)
+			entries, err := w.generateEntries(1, 0, bip44.ExternalChainIndex, 0)
 			if err != nil {
 				logger.WithError(err).Panic("Fingerprint failed to generate initial entry for empty wallet")
 			}
@@ -390,13 +615,9 @@ func (rw *ReadableBip44Wallet) ToWallet() (Wallet, error) {
 		}
 	}
 
-	// Sort childNumber low to high
-	sort.Slice(w.ExternalEntries, func(i, j int) bool {
-		return w.ExternalEntries[i].ChildNumber < w.ExternalEntries[j].ChildNumber
-	})
-	sort.Slice(w.ChangeEntries, func(i, j int) bool {
-		return w.ChangeEntries[i].ChildNumber < w.ChangeEntries[j].ChildNumber
-	})
+	// Sort account and childNumber low to high
+	w.ExternalEntries.sortByAccount()
+	w.ChangeEntries.sortByAccount()
 
 	return w, err
 }
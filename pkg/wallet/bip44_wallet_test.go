@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip44"
+)
+
+// bip39 test mnemonic used by the bip44/bip49/bip84/bip86 reference test vectors
+const testVectorSeed = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func addressStrings(addrs []cipher.Addresser) []string {
+	ss := make([]string, len(addrs))
+	for i, a := range addrs {
+		ss[i] = a.String()
+	}
+	return ss
+}
+
+func TestBip44WalletAccounts(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+
+	require.Equal(t, []string{
+		"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+		"1Ak8PffB2meyfYnbXZR9EGfLfFZVpzJvQP",
+	}, addressStrings(bw.GetAddresses()))
+	require.Equal(t, []uint32{0}, bw.Accounts())
+
+	_, err = bw.GenerateAccountAddresses(1, 1)
+	require.Error(t, err)
+	_, err = bw.GenerateChangeEntry(1)
+	require.Error(t, err)
+
+	account, err := bw.NewAccount()
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), account)
+	require.Equal(t, []uint32{0, 1}, bw.Accounts())
+
+	addrs, err := bw.GenerateAccountAddresses(1, 2)
+	require.NoError(t, err)
+	require.Len(t, addrs, 2)
+
+	e, err := bw.GenerateChangeEntry(1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), e.Account)
+	require.Equal(t, bip44.ChangeChainIndex, e.Change)
+	require.Equal(t, uint32(0), e.ChildNumber)
+
+	// Account 0 is unaffected by account 1
+	addrs, err = bw.GenerateAddresses(1)
+	require.NoError(t, err)
+	external, change := bw.GetAccountEntries(0)
+	require.Len(t, external, 3)
+	require.Empty(t, change)
+	require.Equal(t, addrs[0], external[2].Address)
+
+	external, change = bw.GetAccountEntries(1)
+	require.Len(t, external, 3)
+	require.Len(t, change, 1)
+	for i, e := range external {
+		require.Equal(t, uint32(1), e.Account)
+		require.Equal(t, uint32(i), e.ChildNumber)
+		require.NoError(t, e.Verify())
+	}
+
+	// Accounts are persisted
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+	require.NoError(t, Save(bw, dir))
+	w2, err := Load(filepath.Join(dir, bw.Filename()))
+	require.NoError(t, err)
+	bw2 := w2.(*Bip44Wallet)
+	require.Equal(t, bw.Accounts(), bw2.Accounts())
+	require.Equal(t, bw.GetEntries(), bw2.GetEntries())
+	require.Equal(t, bw.Fingerprint(), bw2.Fingerprint())
+}
+
+func TestBip44WalletAddressTypes(t *testing.T) {
+	cases := []struct {
+		name        string
+		addressType AddressType
+		external    []string
+		change      string
+	}{
+		{
+			name:        "bip84 p2wpkh",
+			addressType: AddressTypeP2WPKH,
+			external: []string{
+				"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+				"bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+			},
+			change: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+		},
+		{
+			name:        "bip49 p2sh-p2wpkh",
+			addressType: AddressTypeP2SHP2WPKH,
+			external: []string{
+				"37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf",
+				"3LtMnn87fqUeHBUG414p9CWwnoV6E2pNKS",
+			},
+			change: "34K56kSjgUCUSD8GTtuF7c9Zzwokbs6uZ7",
+		},
+		{
+			name:        "bip86 p2tr",
+			addressType: AddressTypeP2TR,
+			external: []string{
+				"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+				"bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh",
+			},
+			change: "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:        WalletTypeBip44,
+				Coin:        CoinTypeBitcoin,
+				Seed:        testVectorSeed,
+				AddressType: tc.addressType,
+				GenerateN:   uint64(len(tc.external)),
+			})
+			require.NoError(t, err)
+			bw := w.(*Bip44Wallet)
+			require.Equal(t, tc.addressType, bw.AddressType())
+			require.Equal(t, tc.external, addressStrings(bw.GetAddresses()))
+
+			e, err := bw.GenerateChangeEntry(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.change, e.Address.String())
+
+			dir, teardown := prepareWltDir(t)
+			defer teardown()
+			require.NoError(t, Save(bw, dir))
+			w2, err := Load(filepath.Join(dir, bw.Filename()))
+			require.NoError(t, err)
+			require.Equal(t, bw.GetEntries(), w2.GetEntries())
+		})
+	}
+
+	_, err := NewWallet("test.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeSkycoin,
+		Seed:        testVectorSeed,
+		AddressType: AddressTypeP2WPKH,
+	})
+	require.Error(t, err)
+}
+
+func TestBip44WalletAccountXPub(t *testing.T) {
+	cases := []struct {
+		addressType AddressType
+		xpub        string
+	}{
+		{
+			addressType: AddressTypeP2PKH,
+			xpub:        "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj",
+		},
+		{
+			addressType: AddressTypeP2SHP2WPKH,
+			xpub:        "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP",
+		},
+		{
+			addressType: AddressTypeP2WPKH,
+			xpub:        "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.addressType), func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:        WalletTypeBip44,
+				Coin:        CoinTypeBitcoin,
+				Seed:        testVectorSeed,
+				AddressType: tc.addressType,
+				GenerateN:   3,
+			})
+			require.NoError(t, err)
+			bw := w.(*Bip44Wallet)
+
+			xpub, err := bw.AccountXPub(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.xpub, xpub)
+
+			_, err = bw.AccountXPub(1)
+			require.Error(t, err)
+
+			_, err = bw.GenerateChangeEntry(0)
+			require.NoError(t, err)
+
+			xw, err := NewXPubWalletFromBip44("xpub.wlt", bw, 0)
+			require.NoError(t, err)
+			require.Equal(t, tc.addressType, xw.AddressType())
+			require.Equal(t, XPubLevelAccount, xw.XPubLevel())
+			require.Equal(t, addressStrings(bw.GetAddresses()), addressStrings(xw.GetAddresses()))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package wallet
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/SkycoinProject/skycoin/src/cipher"
 	"github.com/SkycoinProject/skycoin/src/util/file"
@@ -13,6 +14,7 @@ import (
 // wallet is to explicitly add them.
 // This wallet does not support address scanning or generation.
 // This wallet does not use seeds.
+// A watch-only collection wallet holds addresses without keys, e.g. to track deposit addresses.
 type CollectionWallet struct {
 	Meta
 	Entries Entries
@@ -106,6 +108,11 @@ func (w *CollectionWallet) GenerateAddresses(num uint64) ([]cipher.Addresser, er
 	return nil, NewError(errors.New("A collection wallet does not implement GenerateAddresses"))
 }
 
+// ScanAddresses returns an error for "collection" wallets, which have no address sequence to scan
+func (w *CollectionWallet) ScanAddresses(scanN uint64, tf TransactionsFinder) error {
+	return NewError(errors.New("A collection wallet does not implement ScanAddresses"))
+}
+
 // GetAddresses returns all addresses in wallet
 func (w *CollectionWallet) GetAddresses() []cipher.Addresser {
 	return w.Entries.getAddresses()
@@ -118,25 +125,130 @@ func (w *CollectionWallet) Fingerprint() string {
 }
 
 // AddEntry adds a new entry to the wallet.
+// Watch-only wallets only accept watch-only entries, and other wallets only accept entries with keys.
 func (w *CollectionWallet) AddEntry(e Entry) error {
 	if w.IsEncrypted() {
 		return ErrWalletEncrypted
 	}
 
+	if e.IsWatchOnly() != w.Meta.IsWatchOnly() {
+		if w.Meta.IsWatchOnly() {
+			return NewError(errors.New("watch-only wallet entries must not have keys"))
+		}
+		return NewError(errors.New("wallet entry keys are missing"))
+	}
+
 	if err := e.Verify(); err != nil {
 		return err
 	}
 
-	for _, entry := range w.Entries {
-		if e.SkycoinAddress() == entry.SkycoinAddress() {
-			return errors.New("wallet already contains entry with this address")
-		}
+	if w.Entries.has(e.Address) {
+		return NewError(errors.New("wallet already contains entry with this address"))
 	}
 
 	w.Entries = append(w.Entries, e)
 	return nil
 }
 
+// DecodeSecretKey decodes a secret key from the native format of a coin:
+// WIF for bitcoin (compressed keys only), and hex for skycoin and ethereum (with or without 0x prefix)
+func DecodeSecretKey(coin CoinType, encoded string) (cipher.SecKey, error) {
+	var sk cipher.SecKey
+	var err error
+	switch coin {
+	case CoinTypeSkycoin:
+		sk, err = cipher.SecKeyFromHex(encoded)
+	case CoinTypeBitcoin:
+		sk, err = cipher.SecKeyFromBitcoinWalletImportFormat(encoded)
+	case CoinTypeEthereum:
+		if strings.HasPrefix(encoded, "0x") || strings.HasPrefix(encoded, "0X") {
+			encoded = encoded[2:]
+		}
+		sk, err = cipher.SecKeyFromHex(encoded)
+	default:
+		return cipher.SecKey{}, ErrInvalidCoinType
+	}
+	if err != nil {
+		return cipher.SecKey{}, NewError(fmt.Errorf("invalid %s secret key: %v", coin, err))
+	}
+
+	if err := sk.Verify(); err != nil {
+		return cipher.SecKey{}, NewError(fmt.Errorf("invalid %s secret key: %v", coin, err))
+	}
+
+	return sk, nil
+}
+
+// ImportSecretKey decodes a secret key from the native format of the wallet's coin,
+// and adds its entry to the wallet. The public key and address are derived from the secret key.
+// The coin must match the wallet's coin.
+func (w *CollectionWallet) ImportSecretKey(coin CoinType, encoded string) (cipher.Addresser, error) {
+	if err := w.checkImportSecretKey(coin); err != nil {
+		return nil, err
+	}
+
+	sk, err := DecodeSecretKey(coin, encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.importSecretKey(sk)
+}
+
+func (w *CollectionWallet) checkImportSecretKey(coin CoinType) error {
+	if w.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if w.Meta.IsWatchOnly() {
+		return ErrWalletWatchOnly
+	}
+
+	if coin != w.Meta.Coin() {
+		return NewError(fmt.Errorf("can't import a %s secret key into a %s wallet", coin, w.Meta.Coin()))
+	}
+
+	return nil
+}
+
+// importSecretKey adds the entry of a secret key, deriving its public key and address
+func (w *CollectionWallet) importSecretKey(sk cipher.SecKey) (cipher.Addresser, error) {
+	pk, err := cipher.PubKeyFromSecKey(sk)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("invalid %s secret key: %v", w.Meta.Coin(), err))
+	}
+
+	e := Entry{
+		Address: w.Meta.AddressConstructor()(pk),
+		Public:  pk,
+		Secret:  sk,
+	}
+
+	if err := w.AddEntry(e); err != nil {
+		return nil, err
+	}
+
+	return e.Address, nil
+}
+
+// AddWatchAddress decodes an address of the wallet's coin and adds it with an optional label
+// to a watch-only wallet
+func (w *CollectionWallet) AddWatchAddress(addr, label string) error {
+	if !w.Meta.IsWatchOnly() {
+		return NewError(errors.New("addresses without keys can only be added to watch-only wallets"))
+	}
+
+	e, err := newEntryFromReadable(w.Meta.Coin(), w.Meta.Type(), &ReadableEntry{
+		Address: addr,
+		Label:   label,
+	})
+	if err != nil {
+		return NewError(fmt.Errorf("invalid %s address: %v", w.Meta.Coin(), err))
+	}
+
+	return w.AddEntry(*e)
+}
+
 // ReadableCollectionWallet used for [de]serialization of a collection wallet
 type ReadableCollectionWallet struct {
 	Meta            `json:"meta"`
@@ -182,6 +294,14 @@ func (rw *ReadableCollectionWallet) ToWallet() (Wallet, error) {
 		return nil, err
 	}
 
+	for _, e := range ets {
+		if e.IsWatchOnly() != w.Meta.IsWatchOnly() {
+			err := fmt.Errorf("invalid wallet %q: entry keys don't match the watch-only mode of the wallet", w.Filename())
+			logger.WithError(err).Error("ReadableCollectionWallet.ToWallet invalid entry")
+			return nil, err
+		}
+	}
+
 	w.Entries = ets
 
 	return w, nil
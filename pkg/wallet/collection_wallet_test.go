@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+func TestCollectionWalletWatchOnly(t *testing.T) {
+	cases := []struct {
+		coin    CoinType
+		addr    string
+		invalid []string
+	}{
+		{
+			coin: CoinTypeSkycoin,
+			addr: "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv",
+			invalid: []string{
+				"2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qw",
+			},
+		},
+		{
+			coin: CoinTypeBitcoin,
+			addr: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+			invalid: []string{
+				"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyv",
+				"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabB",
+			},
+		},
+		{
+			coin: CoinTypeEthereum,
+			addr: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			invalid: []string{
+				"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD",
+				"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beae",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.coin), func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:      WalletTypeCollection,
+				Coin:      tc.coin,
+				WatchOnly: true,
+			})
+			require.NoError(t, err)
+			cw := w.(*CollectionWallet)
+			require.True(t, cw.IsWatchOnly())
+
+			require.NoError(t, cw.AddWatchAddress(tc.addr, "deposit 1"))
+			require.Error(t, cw.AddWatchAddress(tc.addr, ""))
+			for _, a := range tc.invalid {
+				require.Error(t, cw.AddWatchAddress(a, ""), a)
+			}
+
+			e := cw.GetEntryAt(0)
+			require.Equal(t, tc.addr, e.Address.String())
+			require.NoError(t, e.Verify())
+			require.NoError(t, e.VerifyPublic())
+
+			dir, teardown := prepareWltDir(t)
+			defer teardown()
+			require.NoError(t, Save(cw, dir))
+			w2, err := Load(filepath.Join(dir, cw.Filename()))
+			require.NoError(t, err)
+			require.Equal(t, cw.GetEntries(), w2.GetEntries())
+			require.Equal(t, "deposit 1", w2.GetEntryAt(0).Label)
+
+			// Watch-only wallets have no secrets to encrypt
+			require.Equal(t, ErrWalletWatchOnly, Lock(cw, []byte("pwd"), DefaultCryptoType))
+		})
+	}
+
+	// Only watch-only wallets hold addresses without keys
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.NoError(t, err)
+	require.Error(t, w.(*CollectionWallet).AddWatchAddress("bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", ""))
+
+	_, err = NewWallet("test.wlt", Options{
+		Type:      WalletTypeCollection,
+		WatchOnly: true,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+	})
+	require.Error(t, err)
+}
+
+func TestCollectionWalletImportSecretKey(t *testing.T) {
+	cases := []struct {
+		coin CoinType
+		key  string
+		addr string
+	}{
+		{
+			coin: CoinTypeBitcoin,
+			key:  "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn",
+			addr: "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		},
+		{
+			coin: CoinTypeEthereum,
+			key:  "0x0000000000000000000000000000000000000000000000000000000000000001",
+			addr: "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf",
+		},
+		{
+			coin: CoinTypeEthereum,
+			key:  "0000000000000000000000000000000000000000000000000000000000000001",
+			addr: "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.coin), func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type: WalletTypeCollection,
+				Coin: tc.coin,
+			})
+			require.NoError(t, err)
+			cw := w.(*CollectionWallet)
+
+			addr, err := cw.ImportSecretKey(tc.coin, tc.key)
+			require.NoError(t, err)
+			require.Equal(t, tc.addr, addr.String())
+			require.Equal(t, 1, w.EntriesLen())
+			e := w.GetEntryAt(0)
+			require.NoError(t, e.Verify())
+
+			// The same key can't be imported twice
+			_, err = cw.ImportSecretKey(tc.coin, tc.key)
+			require.Error(t, err)
+
+			// Invalid keys are rejected
+			_, err = cw.ImportSecretKey(tc.coin, tc.key[:len(tc.key)-2])
+			require.Error(t, err)
+		})
+	}
+
+	// Skycoin keys are hex encoded
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeSkycoin,
+	})
+	require.NoError(t, err)
+	cw := w.(*CollectionWallet)
+	sk := "0000000000000000000000000000000000000000000000000000000000000001"
+	addr, err := cw.ImportSecretKey(CoinTypeSkycoin, sk)
+	require.NoError(t, err)
+	require.Equal(t, cipher.MustSecKeyFromHex(sk), w.GetEntryAt(0).Secret)
+	require.Equal(t, addr, w.GetEntryAt(0).Address)
+
+	// The coin must match the wallet's coin
+	_, err = cw.ImportSecretKey(CoinTypeBitcoin, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn")
+	require.Error(t, err)
+
+	// Encrypted wallets must be unlocked first
+	require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, err = cw.ImportSecretKey(CoinTypeSkycoin, "0000000000000000000000000000000000000000000000000000000000000002")
+	require.Equal(t, ErrWalletEncrypted, err)
+
+	require.NoError(t, GuardUpdate(w, []byte("pwd"), func(w Wallet) error {
+		_, err := w.(*CollectionWallet).ImportSecretKey(CoinTypeSkycoin, "0000000000000000000000000000000000000000000000000000000000000002")
+		return err
+	}))
+	require.Equal(t, 2, w.EntriesLen())
+	require.True(t, w.GetEntryAt(1).Secret.Null())
+
+	// Watch-only wallets have no secret keys
+	w, err = NewWallet("test.wlt", Options{
+		Type:      WalletTypeCollection,
+		Coin:      CoinTypeSkycoin,
+		WatchOnly: true,
+	})
+	require.NoError(t, err)
+	_, err = w.(*CollectionWallet).ImportSecretKey(CoinTypeSkycoin, sk)
+	require.Equal(t, ErrWalletWatchOnly, err)
+}
@@ -24,6 +24,8 @@ func CryptoTypeFromString(s string) (CryptoType, error) {
 		return CryptoTypeScryptChacha20poly1305, nil
 	case CryptoTypeScryptChacha20poly1305Insecure:
 		return CryptoTypeScryptChacha20poly1305Insecure, nil
+	case CryptoTypeArgon2idChacha20poly1305:
+		return CryptoTypeArgon2idChacha20poly1305, nil
 	default:
 		return "", errors.New("unknown crypto type")
 	}
@@ -37,6 +39,8 @@ const (
 	CryptoTypeScryptChacha20poly1305 = CryptoType("scrypt-chacha20poly1305")
 	// CryptoTypeScryptChacha20poly1305Insecure uses chacha20poly1305 + scrypt key derivation with a weak work factor (unsafe)
 	CryptoTypeScryptChacha20poly1305Insecure = CryptoType("scrypt-chacha20poly1305-insecure")
+	// CryptoTypeArgon2idChacha20poly1305 uses chacha20poly1305 + argon2id key derivation
+	CryptoTypeArgon2idChacha20poly1305 = CryptoType("argon2id-chacha20poly1305")
 
 	// DefaultCryptoType is the default CryptoType used
 	DefaultCryptoType = CryptoTypeScryptChacha20poly1305
@@ -53,6 +57,20 @@ var cryptoTable = map[CryptoType]cryptor{
 		P:      encrypt.ScryptP,
 		KeyLen: encrypt.ScryptKeyLen,
 	},
+	CryptoTypeArgon2idChacha20poly1305: DefaultArgon2idChacha20poly1305,
+}
+
+// insecureCryptoTypes records the crypto methods that should no longer be used to encrypt wallets
+var insecureCryptoTypes = map[CryptoType]struct{}{
+	CryptoTypeSha256Xor:                      {},
+	CryptoTypeScryptChacha20poly1305Insecure: {},
+}
+
+// IsInsecure returns true if the crypto type is not safe to encrypt wallets with.
+// Wallets encrypted with an insecure crypto type should be upgraded with ChangePassword.
+func (t CryptoType) IsInsecure() bool {
+	_, ok := insecureCryptoTypes[t]
+	return ok
 }
 
 // getCrypto gets crypto of given type
@@ -1,9 +1,16 @@
 package wallet
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher/chacha20poly1305"
 )
 
 func TestSecrets(t *testing.T) {
@@ -27,3 +34,100 @@ func TestSecrets(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, s, s1)
 }
+
+func TestArgon2idChacha20poly1305(t *testing.T) {
+	data := []byte("secrets")
+	password := []byte("pwd")
+
+	weak := Argon2idChacha20poly1305{
+		Time:    1,
+		Memory:  64,
+		Threads: 1,
+		KeyLen:  Argon2idKeyLen,
+	}
+
+	enc, err := weak.Encrypt(data, password)
+	require.NoError(t, err)
+
+	// The argon2id parameters are read from the metadata, so the parameters can be raised
+	dec, err := DefaultArgon2idChacha20poly1305.Decrypt(enc, password)
+	require.NoError(t, err)
+	require.Equal(t, data, dec)
+
+	_, err = weak.Decrypt(enc, []byte("wrong"))
+	require.Error(t, err)
+
+	// The metadata is authenticated
+	raw, err := base64.StdEncoding.DecodeString(string(enc))
+	require.NoError(t, err)
+	tampered := bytes.Replace(raw, []byte(`"time":1`), []byte(`"time":2`), 1)
+	require.NotEqual(t, raw, tampered)
+	_, err = weak.Decrypt([]byte(base64.StdEncoding.EncodeToString(tampered)), password)
+	require.Error(t, err)
+
+	_, err = Argon2idChacha20poly1305{Time: 1, Memory: 64, KeyLen: Argon2idKeyLen}.Encrypt(data, password)
+	require.Error(t, err)
+
+	// Out of range parameters of a tampered wallet file are refused before deriving the key
+	valid := argon2idMeta{
+		Time:    1,
+		Memory:  64,
+		Threads: 1,
+		KeyLen:  Argon2idKeyLen,
+		Salt:    make([]byte, argon2idChacha20SaltSize),
+		Nonce:   make([]byte, chacha20poly1305.NonceSize),
+	}
+	for name, f := range map[string]func(m *argon2idMeta){
+		"time":            func(m *argon2idMeta) { m.Time = math.MaxUint32 },
+		"time over max":   func(m *argon2idMeta) { m.Time = argon2idMaxTime + 1 },
+		"memory":          func(m *argon2idMeta) { m.Memory = math.MaxUint32 },
+		"memory over max": func(m *argon2idMeta) { m.Memory = argon2idMaxMemory + 1 },
+		"short salt":      func(m *argon2idMeta) { m.Salt = m.Salt[:8] },
+		"no salt":         func(m *argon2idMeta) { m.Salt = nil },
+	} {
+		m := valid
+		f(&m)
+		ms, err := json.Marshal(m)
+		require.NoError(t, err)
+		raw := make([]byte, argon2idChacha20MetaLengthSize)
+		binary.LittleEndian.PutUint16(raw, uint16(len(ms)))
+		raw = append(append(raw, ms...), make([]byte, 32)...)
+		_, err = weak.Decrypt([]byte(base64.StdEncoding.EncodeToString(raw)), password)
+		require.Error(t, err, name)
+	}
+
+	// The bounds are at most 16 passes over 1 GiB, and allow the default parameters
+	m := valid
+	m.Time, m.Memory = 16, 1024*1024
+	require.NoError(t, m.validate())
+	m.Time++
+	require.EqualError(t, m.validate(), "argon2id time is too large")
+	m.Time, m.Memory = 16, 1024*1024+1
+	require.EqualError(t, m.validate(), "argon2id memory is too large")
+	m.Time, m.Memory, m.Threads = Argon2idTime, Argon2idMemory, Argon2idThreads
+	require.NoError(t, m.validate())
+
+	ct, err := CryptoTypeFromString("argon2id-chacha20poly1305")
+	require.NoError(t, err)
+	require.Equal(t, CryptoTypeArgon2idChacha20poly1305, ct)
+	require.False(t, ct.IsInsecure())
+
+	// Wallets can be encrypted with argon2id
+	w, err := NewWallet("test.wlt", Options{
+		Type:       WalletTypeBip44,
+		Coin:       CoinTypeBitcoin,
+		Seed:       testVectorSeed,
+		Encrypt:    true,
+		Password:   password,
+		CryptoType: CryptoTypeArgon2idChacha20poly1305,
+	})
+	require.NoError(t, err)
+	require.Equal(t, CryptoTypeArgon2idChacha20poly1305, w.CryptoType())
+
+	rw, err := w.ToReadable().ToWallet()
+	require.NoError(t, err)
+	require.NoError(t, GuardView(rw, password, func(w Wallet) error {
+		require.Equal(t, testVectorSeed, w.Seed())
+		return nil
+	}))
+}
@@ -157,6 +157,43 @@ func (w *DeterministicWallet) GenerateAddresses(num uint64) ([]cipher.Addresser,
 	return addrs, nil
 }
 
+// ScanAddresses scans ahead N addresses, truncating up to the highest address with any transaction history.
+func (w *DeterministicWallet) ScanAddresses(scanN uint64, tf TransactionsFinder) error {
+	if w.Meta.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if scanN == 0 {
+		return nil
+	}
+
+	w2 := w.Clone().(*DeterministicWallet)
+	nExistingAddrs := uint64(len(w2.Entries))
+
+	// Deterministic addresses can only be generated in sequence, the child index is unused
+	entries, err := scanAddresses(func(num uint64, _ uint32) (Entries, error) {
+		n := len(w2.Entries)
+		if _, err := w2.GenerateAddresses(num); err != nil {
+			return nil, err
+		}
+		return w2.Entries[n:], nil
+	}, scanN, tf, 0)
+	if err != nil {
+		return err
+	}
+
+	// Regenerate addresses up to nExistingAddrs + len(entries).
+	// This is necessary to keep the lastSeed updated.
+	w2.reset()
+	if _, err := w2.GenerateAddresses(nExistingAddrs + uint64(len(entries))); err != nil {
+		return err
+	}
+
+	*w = *w2
+
+	return nil
+}
+
 // reset resets the wallet entries and move the lastSeed to origin
 func (w *DeterministicWallet) reset() {
 	w.Entries = Entries{}
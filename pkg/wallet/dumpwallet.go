@@ -0,0 +1,449 @@
+package wallet
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip44"
+)
+
+// Bitcoin Core dumpwallet/importwallet text format.
+// Each key is written on its own line:
+//
+//	<WIF> <timestamp> <label=...|change=1|reserve=1|hdseed=1|inactivehdseed=1> # addr=<addr>[,<addr>...] [hdkeypath=<path>]
+//
+// Lines starting with # are comments, except for the extended private masterkey header.
+
+const (
+	dumpTimeFormat         = "2006-01-02T15:04:05Z"
+	dumpMasterKeyPrefix    = "# extended private masterkey: "
+	dumpFlagLabel          = "label="
+	dumpFlagChange         = "change=1"
+	dumpFlagReserve        = "reserve=1"
+	dumpFlagHDSeed         = "hdseed=1"
+	dumpFlagInactiveHDSeed = "inactivehdseed=1"
+	dumpFlagScript         = "script=1"
+	dumpCommentAddr        = "addr="
+	dumpCommentHDKeyPath   = "hdkeypath="
+)
+
+// DumpEntry is a key of a Bitcoin Core wallet dump
+type DumpEntry struct {
+	Secret    cipher.SecKey
+	Time      time.Time // Key birth time
+	Label     string
+	Change    bool
+	Reserve   bool     // Unused keys of the keypool
+	HDSeed    bool     // The seed of an HD wallet, active or not. It does not receive payments
+	HDKeyPath string   // bip32 path of keys derived from an HD wallet seed
+	Addresses []string // Addresses of the key, for information only
+}
+
+// DumpWallet is a Bitcoin Core wallet dump, as written by the dumpwallet RPC
+// and read by the importwallet RPC
+type DumpWallet struct {
+	ExtendedMasterKey string // xprv of an HD wallet, if any
+	Entries           []DumpEntry
+}
+
+// ReadDumpWallet parses a Bitcoin Core wallet dump.
+// Secret keys are decoded from WIF with cipher.SecKeyFromBitcoinWalletImportFormat,
+// which only supports compressed keys. Script lines are skipped.
+func ReadDumpWallet(r io.Reader) (*DumpWallet, error) {
+	var d DumpWallet
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, dumpMasterKeyPrefix) {
+			d.ExtendedMasterKey = strings.TrimSpace(strings.TrimPrefix(line, dumpMasterKeyPrefix))
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		e, err := parseDumpLine(line)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("wallet dump line %d: %v", n, err))
+		}
+		if e == nil {
+			continue
+		}
+
+		d.Entries = append(d.Entries, *e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// parseDumpLine parses a key line of a wallet dump. Returns nil for script lines.
+func parseDumpLine(line string) (*DumpEntry, error) {
+	var comment string
+	if i := strings.Index(line, "#"); i >= 0 {
+		line, comment = line[:i], line[i+1:]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("expected a key and a timestamp")
+	}
+
+	for _, f := range fields[2:] {
+		if f == dumpFlagScript {
+			return nil, nil
+		}
+	}
+
+	sk, err := DecodeSecretKey(CoinTypeBitcoin, fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(dumpTimeFormat, fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q", fields[1])
+	}
+
+	e := DumpEntry{
+		Secret: sk,
+		Time:   t,
+	}
+
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, dumpFlagLabel):
+			e.Label, err = decodeDumpString(strings.TrimPrefix(f, dumpFlagLabel))
+			if err != nil {
+				return nil, err
+			}
+		case f == dumpFlagChange:
+			e.Change = true
+		case f == dumpFlagReserve:
+			e.Reserve = true
+		case f == dumpFlagHDSeed, f == dumpFlagInactiveHDSeed:
+			e.HDSeed = true
+		}
+	}
+
+	for _, f := range strings.Fields(comment) {
+		switch {
+		case strings.HasPrefix(f, dumpCommentAddr):
+			e.Addresses = strings.Split(strings.TrimPrefix(f, dumpCommentAddr), ",")
+		case strings.HasPrefix(f, dumpCommentHDKeyPath):
+			e.HDKeyPath = strings.TrimPrefix(f, dumpCommentHDKeyPath)
+			if _, err := parseHDKeyPath(e.HDKeyPath); err != nil {
+				return nil, fmt.Errorf("invalid hdkeypath %q: %v", e.HDKeyPath, err)
+			}
+		}
+	}
+
+	return &e, nil
+}
+
+// Write writes the wallet dump in the format of Bitcoin Core's dumpwallet
+func (d *DumpWallet) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# Wallet dump created by multicoin-wallet")
+	fmt.Fprintf(bw, "# * Created on %s\n", time.Now().UTC().Format(dumpTimeFormat))
+	fmt.Fprintln(bw)
+
+	if d.ExtendedMasterKey != "" {
+		fmt.Fprintf(bw, "%s%s\n", dumpMasterKeyPrefix, d.ExtendedMasterKey)
+		fmt.Fprintln(bw)
+	}
+
+	for _, e := range d.Entries {
+		var flag string
+		switch {
+		case e.HDSeed:
+			flag = dumpFlagHDSeed
+		case e.Reserve:
+			flag = dumpFlagReserve
+		case e.Change:
+			flag = dumpFlagChange
+		default:
+			flag = dumpFlagLabel + encodeDumpString(e.Label)
+		}
+
+		fmt.Fprintf(bw, "%s %s %s # %s%s", cipher.BitcoinWalletImportFormatFromSeckey(e.Secret),
+			e.Time.UTC().Format(dumpTimeFormat), flag, dumpCommentAddr, strings.Join(e.Addresses, ","))
+		if e.HDKeyPath != "" {
+			fmt.Fprintf(bw, " %s%s", dumpCommentHDKeyPath, e.HDKeyPath)
+		}
+		fmt.Fprintln(bw)
+	}
+
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "# End of dump")
+
+	return bw.Flush()
+}
+
+// NewDumpWallet creates a Bitcoin Core wallet dump of the secret keys of a bitcoin wallet.
+// Entries of bip44 wallets are annotated with their derivation path, and change entries are flagged.
+// The wallet must not be encrypted, use GuardView to dump an encrypted wallet.
+func NewDumpWallet(w Wallet) (*DumpWallet, error) {
+	if w.Coin() != CoinTypeBitcoin {
+		return nil, NewError(fmt.Errorf("wallet coin is %q, only %q wallets can be dumped", w.Coin(), CoinTypeBitcoin))
+	}
+
+	if w.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+
+	switch w.Type() {
+	case WalletTypeXPub:
+		return nil, NewError(fmt.Errorf("%q wallets have no secret keys", WalletTypeXPub))
+	case WalletTypeCollection:
+		if w.Find(metaWatchOnly) == "true" {
+			return nil, ErrWalletWatchOnly
+		}
+	}
+
+	var d DumpWallet
+	bw, isBip44 := w.(*Bip44Wallet)
+	if isBip44 {
+		seed, err := bw.seed()
+		if err != nil {
+			return nil, err
+		}
+
+		mk, err := bip32.NewMasterKey(seed)
+		if err != nil {
+			return nil, err
+		}
+
+		d.ExtendedMasterKey = mk.String()
+	}
+
+	t := time.Unix(w.Timestamp(), 0).UTC()
+	for _, e := range w.GetEntries() {
+		de := DumpEntry{
+			Secret:    e.Secret,
+			Time:      t,
+			Label:     e.Label,
+			HDKeyPath: e.HDKeyPath,
+			Addresses: []string{e.Address.String()},
+		}
+
+		if isBip44 {
+			de.Change = e.Change == bip44.ChangeChainIndex
+			de.HDKeyPath = bw.EntryPath(e)
+		}
+
+		d.Entries = append(d.Entries, de)
+	}
+
+	return &d, nil
+}
+
+// NewWalletFromDump creates a bitcoin collection wallet from the keys of a Bitcoin Core wallet dump.
+// Labels and bip32 paths of the keys are kept with the entries. HD seed keys are not imported,
+// they don't receive payments. The address type of each entry is that of the addr= comment of its key,
+// opts.AddressType is preferred when a key has addresses of several types and is required for keys
+// without addresses. An addr= comment which isn't an address of its key is an error.
+// The wallet address type is opts.AddressType, or that of the first entry if it isn't set.
+// The other options are those of NewWallet, and opts.Encrypt encrypts the wallet once the keys are imported.
+func NewWalletFromDump(wltName string, d *DumpWallet, opts Options) (Wallet, error) {
+	if opts.Type == "" {
+		opts.Type = WalletTypeCollection
+	}
+	if opts.Type != WalletTypeCollection {
+		return nil, NewError(fmt.Errorf("wallet dumps can only be imported into %q wallets", WalletTypeCollection))
+	}
+
+	if opts.Coin == "" {
+		opts.Coin = CoinTypeBitcoin
+	}
+	coin, err := ResolveCoinType(string(opts.Coin))
+	if err != nil {
+		return nil, err
+	}
+	if coin != CoinTypeBitcoin {
+		return nil, NewError(fmt.Errorf("wallet dumps can only be imported into %q wallets", CoinTypeBitcoin))
+	}
+
+	encrypt, password, cryptoType := opts.Encrypt, opts.Password, opts.CryptoType
+	if !encrypt && len(password) != 0 {
+		return nil, ErrMissingEncrypt
+	}
+	if encrypt && len(password) == 0 {
+		return nil, ErrMissingPassword
+	}
+	opts.Encrypt = false
+	opts.Password = nil
+
+	if opts.AddressType != "" {
+		opts.AddressType, err = AddressTypeFromString(string(opts.AddressType))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	preferred := opts.AddressType
+	var entries []Entry
+	for _, de := range d.Entries {
+		if de.HDSeed {
+			continue
+		}
+
+		pk, err := cipher.PubKeyFromSecKey(de.Secret)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("invalid %s secret key: %v", coin, err))
+		}
+
+		addr, addrType, err := dumpEntryAddress(pk, de.Addresses, preferred)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.AddressType == "" {
+			opts.AddressType = addrType
+		}
+
+		entries = append(entries, Entry{
+			Address:   addr,
+			Public:    pk,
+			Secret:    de.Secret,
+			Label:     de.Label,
+			HDKeyPath: de.HDKeyPath,
+		})
+	}
+
+	w, err := NewWallet(wltName, opts)
+	if err != nil {
+		return nil, err
+	}
+	cw := w.(*CollectionWallet)
+
+	for _, e := range entries {
+		if err := cw.AddEntry(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if !encrypt {
+		return w, nil
+	}
+
+	if cryptoType == "" {
+		cryptoType = DefaultCryptoType
+	}
+
+	if err := Lock(w, password, cryptoType); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// parseHDKeyPath parses a bip32 path, hardened nodes may be marked with ' or h
+func parseHDKeyPath(p string) (*bip32.Path, error) {
+	return bip32.ParsePath(strings.Replace(p, "h", "'", -1))
+}
+
+// encodeDumpString percent-encodes the characters of a label that are
+// not printable ASCII, as Bitcoin Core's EncodeDumpString does
+func encodeDumpString(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 32 || c >= 128 || c == '%' {
+			fmt.Fprintf(&sb, "%%%02x", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// decodeDumpString decodes a label encoded with encodeDumpString
+func decodeDumpString(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' {
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("invalid label encoding %q", s)
+			}
+			b, err := hex.DecodeString(s[i+1 : i+3])
+			if err != nil {
+				return "", fmt.Errorf("invalid label encoding %q", s)
+			}
+			c = b[0]
+			i += 2
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), nil
+}
+
+// dumpAddressTypes are the bitcoin address types of the keys of a wallet dump
+var dumpAddressTypes = []AddressType{
+	AddressTypeP2PKH,
+	AddressTypeP2WPKH,
+	AddressTypeP2SHP2WPKH,
+	AddressTypeP2TR,
+}
+
+// dumpEntryAddress returns the address of a dump key and its address type.
+// The address type is preferred if the key has an address of that type,
+// otherwise the type of the first address of the key is used.
+// Keys without addresses use the preferred address type, which is then required.
+func dumpEntryAddress(pk cipher.PubKey, addrs []string, preferred AddressType) (cipher.Addresser, AddressType, error) {
+	if len(addrs) == 0 {
+		if preferred == "" {
+			return nil, "", NewError(fmt.Errorf("address type required for key %s without addr= comment", pk.Hex()))
+		}
+		return dumpAddress(pk, preferred), preferred, nil
+	}
+
+	var types []AddressType
+	for _, a := range addrs {
+		t, ok := dumpAddressType(pk, a)
+		if !ok {
+			return nil, "", NewError(fmt.Errorf("address %s is not an address of key %s", a, pk.Hex()))
+		}
+		types = append(types, t)
+	}
+
+	for _, t := range types {
+		if t == preferred {
+			return dumpAddress(pk, t), t, nil
+		}
+	}
+
+	return dumpAddress(pk, types[0]), types[0], nil
+}
+
+// dumpAddressType returns the address type of a key address
+func dumpAddressType(pk cipher.PubKey, addr string) (AddressType, bool) {
+	for _, t := range dumpAddressTypes {
+		if dumpAddress(pk, t).String() == addr {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func dumpAddress(pk cipher.PubKey, t AddressType) cipher.Addresser {
+	m := Meta{
+		metaCoin:        string(CoinTypeBitcoin),
+		metaAddressType: string(t),
+	}
+	return m.AddressConstructor()(pk)
+}
@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+func TestReadDumpWallet(t *testing.T) {
+	sk1 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000001")
+	sk2 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000002")
+	sk3 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000003")
+	sk4 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000004")
+	wif := cipher.BitcoinWalletImportFormatFromSeckey
+
+	dump := fmt.Sprintf(`# Wallet dump created by Bitcoin v0.21.0
+# * Created on 2021-02-03T10:11:12Z
+# * Best block at time of backup was 668000 (0000000000000000000a4d2ab2cd4d85c2fe1bb4e2b1bbf5ac8e1ae5d2b1a2e9),
+#   mined on 2021-02-03T10:00:00Z
+
+# extended private masterkey: xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu
+
+%s 2021-02-03T10:11:12Z hdseed=1 # addr=1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH,bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4 hdkeypath=m
+%s 2021-02-03T10:11:12Z label=my%%20savings%%25 # addr=1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP,bc1qq6hag67dl53wl99vzg42z8eyzfz2xlkvxechjp hdkeypath=m/84'/0'/0'/0/0
+%s 2021-02-03T10:11:12Z change=1 # addr=1CUNEBjYrCn2y1SdiUMohaKUi4wpP326Lb hdkeypath=m/84h/0h/0h/1/0
+%s 1970-01-01T00:00:01Z reserve=1 # addr=1JtK9CQw1syfWj1WtFMWomrYdV3W2tWBF9
+0014751e76e8199196d454941c45d1b3a323f1433bd6 0 script=1 # addr=bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4
+
+# End of dump
+`, wif(sk1), wif(sk2), wif(sk3), wif(sk4))
+
+	d, err := ReadDumpWallet(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Equal(t, "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu", d.ExtendedMasterKey)
+	require.Len(t, d.Entries, 4)
+
+	require.Equal(t, sk1, d.Entries[0].Secret)
+	require.True(t, d.Entries[0].HDSeed)
+	require.Equal(t, time.Date(2021, 2, 3, 10, 11, 12, 0, time.UTC), d.Entries[0].Time)
+
+	require.Equal(t, sk2, d.Entries[1].Secret)
+	require.Equal(t, "my savings%", d.Entries[1].Label)
+	require.Equal(t, "m/84'/0'/0'/0/0", d.Entries[1].HDKeyPath)
+	require.Equal(t, []string{"1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP", "bc1qq6hag67dl53wl99vzg42z8eyzfz2xlkvxechjp"}, d.Entries[1].Addresses)
+
+	require.True(t, d.Entries[2].Change)
+	require.Equal(t, "m/84h/0h/0h/1/0", d.Entries[2].HDKeyPath)
+	require.True(t, d.Entries[3].Reserve)
+
+	// HD seed keys are not imported
+	w, err := NewWalletFromDump("test.wlt", d, Options{})
+	require.NoError(t, err)
+	require.Equal(t, WalletTypeCollection, w.Type())
+	require.Equal(t, CoinTypeBitcoin, w.Coin())
+	require.Equal(t, 3, w.EntriesLen())
+	require.Equal(t, "my savings%", w.GetEntryAt(0).Label)
+	require.Equal(t, "m/84'/0'/0'/0/0", w.GetEntryAt(0).HDKeyPath)
+	for i, e := range d.Entries[1:] {
+		require.Equal(t, e.Addresses[0], w.GetEntryAt(i).Address.String())
+	}
+
+	// Labels and paths are kept in the wallet file
+	rw, err := w.ToReadable().ToWallet()
+	require.NoError(t, err)
+	require.Equal(t, w.GetEntries(), rw.GetEntries())
+
+	// Invalid keys are reported with their line number
+	_, err = ReadDumpWallet(strings.NewReader("# comment\n\nKwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWm 2021-02-03T10:11:12Z label=\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 3")
+
+	_, err = ReadDumpWallet(strings.NewReader(wif(sk1) + " yesterday label=\n"))
+	require.Error(t, err)
+
+	// Wallet dumps can be imported into encrypted wallets
+	w, err = NewWalletFromDump("test.wlt", d, Options{
+		AddressType: AddressTypeP2WPKH,
+		Encrypt:     true,
+		Password:    []byte("pwd"),
+		CryptoType:  CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+	require.True(t, w.IsEncrypted())
+	require.Equal(t, "bc1qq6hag67dl53wl99vzg42z8eyzfz2xlkvxechjp", w.GetEntryAt(0).Address.String())
+
+	// Only into bitcoin collection wallets
+	_, err = NewWalletFromDump("test.wlt", d, Options{
+		Coin: CoinTypeEthereum,
+	})
+	require.Error(t, err)
+}
+
+func TestNewWalletFromDumpAddressTypes(t *testing.T) {
+	sk1 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000001")
+	sk2 := cipher.MustSecKeyFromHex("0000000000000000000000000000000000000000000000000000000000000002")
+	wif := cipher.BitcoinWalletImportFormatFromSeckey
+
+	dump := fmt.Sprintf(`%s 2021-02-03T10:11:12Z label= # addr=bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4 hdkeypath=m/84'/0'/0'/0/0
+%s 2021-02-03T10:11:12Z label= # addr=1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP,bc1qq6hag67dl53wl99vzg42z8eyzfz2xlkvxechjp
+`, wif(sk1), wif(sk2))
+	d, err := ReadDumpWallet(strings.NewReader(dump))
+	require.NoError(t, err)
+
+	// The address type of each key is that of its addresses,
+	// the first one if no address type is given
+	w, err := NewWalletFromDump("test.wlt", d, Options{})
+	require.NoError(t, err)
+	require.Equal(t, AddressTypeP2WPKH, w.(*CollectionWallet).Meta.AddressType())
+	require.Equal(t, []string{
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		"1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+	}, addressStrings(w.GetAddresses()))
+	require.NoError(t, w.Validate())
+
+	rw, err := w.ToReadable().ToWallet()
+	require.NoError(t, err)
+	require.Equal(t, w.GetEntries(), rw.GetEntries())
+
+	// The given address type is preferred, keys without an address of that type keep theirs
+	w, err = NewWalletFromDump("test.wlt", d, Options{
+		AddressType: AddressTypeP2PKH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AddressTypeP2PKH, w.(*CollectionWallet).Meta.AddressType())
+	require.Equal(t, []string{
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		"1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+	}, addressStrings(w.GetAddresses()))
+
+	w, err = NewWalletFromDump("test.wlt", d, Options{
+		AddressType: AddressTypeP2WPKH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "bc1qq6hag67dl53wl99vzg42z8eyzfz2xlkvxechjp", w.GetEntryAt(1).Address.String())
+
+	// Addresses which aren't addresses of their key are refused
+	d.Entries[1].Addresses = []string{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}
+	_, err = NewWalletFromDump("test.wlt", d, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not an address of key")
+
+	// Keys without addresses need an address type
+	d.Entries[1].Addresses = nil
+	_, err = NewWalletFromDump("test.wlt", d, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "address type required")
+
+	w, err = NewWalletFromDump("test.wlt", d, Options{
+		AddressType: AddressTypeP2TR,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AddressTypeP2TR, w.(*CollectionWallet).Meta.AddressType())
+	require.Equal(t, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", w.GetEntryAt(0).Address.String())
+	require.Equal(t, btc.MustTaprootAddressFromPubKey(cipher.MustPubKeyFromSecKey(sk2)).String(), w.GetEntryAt(1).Address.String())
+}
+
+func TestDumpWalletRoundTrip(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeBitcoin,
+		AddressType: AddressTypeP2WPKH,
+		Seed:        testVectorSeed,
+		GenerateN:   3,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+	_, err = bw.GenerateChangeEntry(0)
+	require.NoError(t, err)
+
+	d, err := NewDumpWallet(w)
+	require.NoError(t, err)
+	require.Equal(t, "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu", d.ExtendedMasterKey)
+	require.Len(t, d.Entries, 4)
+	require.Equal(t, "m/84'/0'/0'/0/2", d.Entries[2].HDKeyPath)
+	require.False(t, d.Entries[2].Change)
+	require.Equal(t, "m/84'/0'/0'/1/0", d.Entries[3].HDKeyPath)
+	require.True(t, d.Entries[3].Change)
+
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf))
+	require.Contains(t, buf.String(), "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu")
+
+	d2, err := ReadDumpWallet(&buf)
+	require.NoError(t, err)
+	require.Equal(t, d.ExtendedMasterKey, d2.ExtendedMasterKey)
+	require.Equal(t, d.Entries, d2.Entries)
+
+	cw, err := NewWalletFromDump("test.wlt", d2, Options{
+		AddressType: AddressTypeP2WPKH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, addressStrings(w.GetAddresses()), addressStrings(cw.GetAddresses()))
+
+	// The collection wallet created from the dump can be dumped again
+	d3, err := NewDumpWallet(cw)
+	require.NoError(t, err)
+	require.Equal(t, d.Entries[3].HDKeyPath, d3.Entries[3].HDKeyPath)
+
+	// Only bitcoin wallets can be dumped
+	ew, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeEthereum,
+		Seed: testVectorSeed,
+	})
+	require.NoError(t, err)
+	_, err = NewDumpWallet(ew)
+	require.Error(t, err)
+
+	// Encrypted wallets must be unlocked first
+	require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, err = NewDumpWallet(w)
+	require.Equal(t, ErrWalletEncrypted, err)
+}
@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+	"github.com/SkycoinProject/skycoin/src/cipher/pbkdf2"
+)
+
+// SeedType is the type of mnemonic a bip44 wallet seed is, which determines how keys are derived from it
+type SeedType string
+
+const (
+	// SeedTypeBip39 bip39 mnemonics, keys are derived along bip44 style paths
+	SeedTypeBip39 SeedType = "bip39"
+	// SeedTypeElectrumStandard Electrum "standard" seeds, p2pkh keys are derived from the m/ path
+	SeedTypeElectrumStandard SeedType = "electrum-standard"
+	// SeedTypeElectrumSegwit Electrum "segwit" seeds, p2wpkh keys are derived from the m/0' path
+	SeedTypeElectrumSegwit SeedType = "electrum-segwit"
+	// SeedTypeSlip39 SLIP-39 master secrets, hex encoded. The master secret is the bip32 seed,
+	// keys are derived along bip44 style paths
+	SeedTypeSlip39 SeedType = "slip39"
+)
+
+var (
+	// ErrInvalidSeedType is returned for invalid seed types
+	ErrInvalidSeedType = NewError(errors.New("invalid seed type"))
+	// ErrInvalidElectrumSeed is returned if a seed does not have the version prefix of its Electrum seed type
+	ErrInvalidElectrumSeed = NewError(errors.New("seed is not a valid Electrum seed of this type"))
+	// ErrElectrumSeedNotASCII is returned for Electrum seeds or passphrases with non-ASCII characters.
+	// Electrum normalizes them with NFKD, which is not supported.
+	ErrElectrumSeedNotASCII = NewError(errors.New("only ASCII Electrum seeds and passphrases are supported"))
+)
+
+// Electrum seed version prefixes, the prefix of the hex encoded HMAC-SHA512 of the normalized
+// seed with the key "Seed version". The 2fa seed types are not supported.
+var electrumSeedPrefixes = map[SeedType]string{
+	SeedTypeElectrumStandard: "01",
+	SeedTypeElectrumSegwit:   "100",
+}
+
+const (
+	electrumSeedVersionKey = "Seed version"
+	electrumSeedSalt       = "electrum"
+	electrumSeedIterations = 2048
+	electrumSeedLen        = 64
+)
+
+// SeedTypeFromString converts a string to a SeedType
+func SeedTypeFromString(s string) (SeedType, error) {
+	switch SeedType(s) {
+	case SeedTypeBip39, SeedTypeElectrumStandard, SeedTypeElectrumSegwit, SeedTypeSlip39:
+		return SeedType(s), nil
+	default:
+		return "", ErrInvalidSeedType
+	}
+}
+
+// IsElectrum returns true for Electrum seed types
+func (t SeedType) IsElectrum() bool {
+	_, ok := electrumSeedPrefixes[t]
+	return ok
+}
+
+// addressType returns the address type of the keys derived from Electrum seeds
+func (t SeedType) addressType() AddressType {
+	switch t {
+	case SeedTypeElectrumStandard:
+		return AddressTypeP2PKH
+	case SeedTypeElectrumSegwit:
+		return AddressTypeP2WPKH
+	default:
+		return ""
+	}
+}
+
+// ElectrumSeedType returns the Electrum seed type of a seed, recognized by its seed version prefix.
+// Returns false if the seed is not an Electrum seed of a supported type.
+func ElectrumSeedType(seed string) (SeedType, bool) {
+	if !isASCII(seed) {
+		return "", false
+	}
+
+	mac := hmac.New(sha512.New, []byte(electrumSeedVersionKey))
+	mac.Write([]byte(normalizeElectrumText(seed))) //nolint:errcheck
+	h := hex.EncodeToString(mac.Sum(nil))
+
+	for _, t := range []SeedType{SeedTypeElectrumStandard, SeedTypeElectrumSegwit} {
+		if strings.HasPrefix(h, electrumSeedPrefixes[t]) {
+			return t, true
+		}
+	}
+
+	return "", false
+}
+
+// validateElectrumSeed checks that the seed has the version prefix of an Electrum seed type
+func validateElectrumSeed(seed string, seedType SeedType) error {
+	if !isASCII(seed) {
+		return ErrElectrumSeedNotASCII
+	}
+
+	if t, ok := ElectrumSeedType(seed); !ok || t != seedType {
+		return ErrInvalidElectrumSeed
+	}
+
+	return nil
+}
+
+// newElectrumSeed derives the bip32 seed of an Electrum seed, with Electrum's "electrum" PBKDF2 salt prefix
+func newElectrumSeed(seed, passphrase string, seedType SeedType) ([]byte, error) {
+	if err := validateElectrumSeed(seed, seedType); err != nil {
+		return nil, err
+	}
+
+	if !isASCII(passphrase) {
+		return nil, ErrElectrumSeedNotASCII
+	}
+
+	return pbkdf2.Key([]byte(normalizeElectrumText(seed)), []byte(electrumSeedSalt+normalizeElectrumText(passphrase)),
+		electrumSeedIterations, electrumSeedLen, sha512.New), nil
+}
+
+// electrumAccountNode returns the bip32 node the receive and change chains of an Electrum wallet derive from
+func electrumAccountNode(seed []byte, seedType SeedType) (*bip32.PrivateKey, error) {
+	mk, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	switch seedType {
+	case SeedTypeElectrumStandard:
+		return mk, nil
+	case SeedTypeElectrumSegwit:
+		return mk.NewPrivateChildKey(bip32.FirstHardenedChild)
+	default:
+		return nil, ErrInvalidSeedType
+	}
+}
+
+// electrumAccountPath returns the bip32 path of electrumAccountNode
+func electrumAccountPath(seedType SeedType) string {
+	if seedType == SeedTypeElectrumSegwit {
+		return "m/0'"
+	}
+	return "m"
+}
+
+// normalizeElectrumText normalizes ASCII text the way Electrum does: lowercase, with words separated by single spaces
+func normalizeElectrumText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func isASCII(s string) bool {
+	for _, c := range s {
+		if c > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
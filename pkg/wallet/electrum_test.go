@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from Electrum's wallet tests
+const (
+	electrumStandardSeed = "cycle rocket west magnet parrot shuffle foot correct salt library feed song"
+	electrumSegwitSeed   = "bitter grass shiver impose acquire brush forget axis eager alone wine silver"
+)
+
+func TestElectrumSeedType(t *testing.T) {
+	st, ok := ElectrumSeedType(electrumStandardSeed)
+	require.True(t, ok)
+	require.Equal(t, SeedTypeElectrumStandard, st)
+
+	// Seeds are normalized before checking their version
+	st, ok = ElectrumSeedType("  Bitter grass  shiver impose acquire brush forget axis eager alone wine SILVER ")
+	require.True(t, ok)
+	require.Equal(t, SeedTypeElectrumSegwit, st)
+
+	// bip39 mnemonics are not Electrum seeds
+	_, ok = ElectrumSeedType(testVectorSeed)
+	require.False(t, ok)
+}
+
+func TestElectrumWallet(t *testing.T) {
+	cases := []struct {
+		name     string
+		seed     string
+		seedType SeedType
+		xpub     string
+		external string
+		change   string
+	}{
+		{
+			name:     "standard",
+			seed:     electrumStandardSeed,
+			seedType: SeedTypeElectrumStandard,
+			xpub:     "xpub661MyMwAqRbcFWohJWt7PHsFEJfZAvw9ZxwQoDa4SoMgsDDM1T7WK3u9E4edkC4ugRnZ8E4xDZRpk8Rnts3Nbt97dPwT52CwBdDWroaZf8U",
+			external: "1NNkttn1YvVGdqBW4PR6zvc3Zx3H5owKRf",
+			change:   "1KSezYMhAJMWqFbVFB2JshYg69UpmEXR4D",
+		},
+		{
+			name:     "segwit",
+			seed:     electrumSegwitSeed,
+			seedType: SeedTypeElectrumSegwit,
+			xpub:     "zpub6nsHdRuY92FsMKdbn9BfjBCG6X8pyhCibNP6uDvpnw2cyrVhecvHRMa3Ne8kdJZxjxgwnpbHLkcR4bfnhHy6auHPJyDTQ3kianeuVLdkCYQ",
+			external: "bc1q3g5tmkmlvxryhh843v4dz026avatc0zzr6h3af",
+			change:   "bc1qdy94n2q5qcp0kg7v9yzwe6wvfkhnvyzje7nx2p",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:     WalletTypeBip44,
+				Coin:     CoinTypeBitcoin,
+				Seed:     tc.seed,
+				SeedType: tc.seedType,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.seedType, w.(*Bip44Wallet).Meta.SeedType())
+			require.Equal(t, tc.seedType.addressType(), w.(*Bip44Wallet).Meta.AddressType())
+			require.Equal(t, tc.external, w.GetEntryAt(0).Address.String())
+
+			bw := w.(*Bip44Wallet)
+			change, err := bw.GenerateChangeEntry(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.change, change.Address.String())
+
+			xpub, err := bw.AccountXPub(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.xpub, xpub)
+
+			_, err = bw.NewAccount()
+			require.Error(t, err)
+
+			// The seed type is persisted
+			rw, err := w.ToReadable().ToWallet()
+			require.NoError(t, err)
+			require.Equal(t, tc.seedType, rw.(*Bip44Wallet).Meta.SeedType())
+			require.Equal(t, w.GetEntries(), rw.GetEntries())
+
+			// Encrypted wallets keep deriving the same addresses
+			require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+			require.NoError(t, GuardUpdate(w, []byte("pwd"), func(w Wallet) error {
+				_, err := w.GenerateAddresses(1)
+				return err
+			}))
+			require.Equal(t, 3, w.EntriesLen())
+		})
+	}
+
+	// The seed must match its seed type
+	_, err := NewWallet("test.wlt", Options{
+		Type:     WalletTypeBip44,
+		Coin:     CoinTypeBitcoin,
+		Seed:     electrumStandardSeed,
+		SeedType: SeedTypeElectrumSegwit,
+	})
+	require.Equal(t, ErrInvalidElectrumSeed, err)
+
+	_, err = NewWallet("test.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeBitcoin,
+		Seed: electrumStandardSeed,
+	})
+	require.Error(t, err)
+
+	// Electrum seed types imply the address type
+	_, err = NewWallet("test.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeBitcoin,
+		Seed:        electrumSegwitSeed,
+		SeedType:    SeedTypeElectrumSegwit,
+		AddressType: AddressTypeP2PKH,
+	})
+	require.Error(t, err)
+
+	// Electrum seeds are only used for bitcoin
+	_, err = NewWallet("test.wlt", Options{
+		Type:     WalletTypeBip44,
+		Coin:     CoinTypeEthereum,
+		Seed:     electrumSegwitSeed,
+		SeedType: SeedTypeElectrumSegwit,
+	})
+	require.Error(t, err)
+}
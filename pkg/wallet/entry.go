@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
 
@@ -16,7 +17,10 @@ type Entry struct {
 	Public      cipher.PubKey
 	Secret      cipher.SecKey
 	ChildNumber uint32 // For bip32/bip44
-	Change      uint32 // For bip44
+	Change      uint32 // For bip44/xpub
+	Account     uint32 // For bip44
+	Label       string // For collection wallets
+	HDKeyPath   string // For collection wallets, the bip32 path of keys imported from an HD wallet
 }
 
 // SkycoinAddress returns the Skycoin address of an entry. Panics if Address is not a Skycoin address
@@ -33,9 +37,19 @@ func (we Entry) EthereumAddress() eth.EthereumAddress {
 	return we.Address.(eth.EthereumAddress)
 }
 
+// IsWatchOnly returns true if the entry only has an address, without keys
+func (we *Entry) IsWatchOnly() bool {
+	return we.Public.Null() && we.Secret.Null()
+}
+
 // Verify checks that the public key is derivable from the secret key,
-// and that the public key is associated with the address
+// and that the public key is associated with the address.
+// Watch-only entries have no keys to check, only their address is checked.
 func (we *Entry) Verify() error {
+	if we.IsWatchOnly() {
+		return we.verifyAddress()
+	}
+
 	pk, err := cipher.PubKeyFromSecKey(we.Secret)
 	if err != nil {
 		return err
@@ -48,14 +62,26 @@ func (we *Entry) Verify() error {
 	return we.VerifyPublic()
 }
 
-// VerifyPublic checks that the public key is associated with the address
+// VerifyPublic checks that the public key is associated with the address.
+// Watch-only entries have no public key to check, only their address is checked.
 func (we *Entry) VerifyPublic() error {
+	if we.IsWatchOnly() {
+		return we.verifyAddress()
+	}
+
 	if err := we.Public.Verify(); err != nil {
 		return err
 	}
 	return we.Address.Verify(we.Public)
 }
 
+func (we *Entry) verifyAddress() error {
+	if we.Address == nil || we.Address.Null() {
+		return errors.New("watch-only entry address is null")
+	}
+	return nil
+}
+
 // Entries are an array of wallet entries
 type Entries []Entry
 
@@ -86,6 +112,27 @@ func (entries Entries) get(a cipher.Addresser) (Entry, bool) {
 	return Entry{}, false
 }
 
+// forAccount returns the entries that belong to a given bip44 account
+func (entries Entries) forAccount(account uint32) Entries {
+	var es Entries
+	for _, e := range entries {
+		if e.Account == account {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+// sortByAccount sorts entries by account and child number, both ascending
+func (entries Entries) sortByAccount() {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Account != entries[j].Account {
+			return entries[i].Account < entries[j].Account
+		}
+		return entries[i].ChildNumber < entries[j].ChildNumber
+	})
+}
+
 func (entries Entries) getAddresses() []cipher.Addresser {
 	addrs := make([]cipher.Addresser, len(entries))
 	for i, e := range entries {
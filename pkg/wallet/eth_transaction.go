@@ -0,0 +1,46 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+)
+
+// ErrEthereumTxChainID is returned when signing an ethereum transaction without a chain ID
+var ErrEthereumTxChainID = NewError(errors.New("ethereum transactions must have a chain ID to be signed with EIP-155 replay protection"))
+
+// SignEthereumTransaction signs a legacy (EIP-155) or EIP-1559 ethereum transaction with the secret key of an ethereum entry,
+// and returns a signed copy of the transaction. Its raw encoding for broadcast is returned by MarshalBinary.
+// The transaction must have a chain ID: legacy transactions aren't signed without EIP-155 replay protection.
+// Entries of encrypted wallets must be decrypted first, e.g. with GuardView.
+func SignEthereumTransaction(e Entry, tx *eth.Transaction) (*eth.Transaction, error) {
+	if e.Secret.Null() {
+		return nil, ErrWalletWatchOnly
+	}
+
+	return signEthereumTransaction(e, tx, secretKeySigner(e))
+}
+
+// signEthereumTransaction signs an ethereum transaction of an ethereum entry's address with sign, see SignEthereumTransaction
+func signEthereumTransaction(e Entry, tx *eth.Transaction, sign digestSigner) (*eth.Transaction, error) {
+	if _, ok := e.Address.(eth.EthereumAddress); !ok {
+		return nil, NewError(errors.New("ethereum transactions can only be signed with the keys of ethereum addresses"))
+	}
+
+	if tx.ChainID == nil {
+		return nil, ErrEthereumTxChainID
+	}
+
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return nil, NewError(fmt.Errorf("invalid ethereum transaction: %v", err))
+	}
+
+	sig, err := sign(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.WithSignature(sig)
+}
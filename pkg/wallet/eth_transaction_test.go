@@ -0,0 +1,355 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+func TestEthereumTransactionEIP155(t *testing.T) {
+	// EIP-155 example: nonce 9, gas price 20 gwei, 21000 gas, 1 ether to 0x3535...35 on chain 1,
+	// signed by the key 0x4646...46
+	to := eth.DecodeHexToEthereumAddress("0x3535353535353535353535353535353535353535")
+	tx := &eth.Transaction{
+		Type:     eth.LegacyTxType,
+		ChainID:  big.NewInt(1),
+		Nonce:    9,
+		GasPrice: big.NewInt(20000000000),
+		Gas:      21000,
+		To:       &to,
+		Value:    new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil),
+	}
+
+	h, err := tx.SigningHash()
+	require.NoError(t, err)
+	require.Equal(t, "daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53", h.Hex())
+
+	raw := "f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	decoded, err := eth.DecodeRawTransaction("0x" + raw)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1), decoded.ChainID)
+	require.Equal(t, tx.Nonce, decoded.Nonce)
+	require.Equal(t, to, *decoded.To)
+
+	sk := cipher.MustSecKeyFromHex("4646464646464646464646464646464646464646464646464646464646464646")
+	from, err := decoded.Sender()
+	require.NoError(t, err)
+	require.Equal(t, eth.EthereumAddressFromPubKey(cipher.MustPubKeyFromSecKey(sk)), from)
+
+	b, err := decoded.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, raw, hex.EncodeToString(b))
+
+	// The transaction signed by the entry of the key is checked by go-ethereum
+	signed, err := SignEthereumTransaction(Entry{Address: from, Public: cipher.MustPubKeyFromSecKey(sk), Secret: sk}, tx)
+	require.NoError(t, err)
+	require.Nil(t, tx.V)
+	b, err = signed.MarshalBinary()
+	require.NoError(t, err)
+
+	var gtx types.Transaction
+	require.NoError(t, rlp.DecodeBytes(b, &gtx))
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	require.Equal(t, h[:], signer.Hash(&gtx).Bytes())
+	sender, err := types.Sender(signer, &gtx)
+	require.NoError(t, err)
+	require.Equal(t, from.Addr, sender)
+
+	hash, err := signed.Hash()
+	require.NoError(t, err)
+	require.Equal(t, gtx.Hash().Bytes(), hash[:])
+}
+
+func TestEthereumTransactionEIP1559(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeEthereum,
+		Seed:      testVectorSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	e := w.GetEntryAt(1)
+
+	var tx eth.Transaction
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"chainId": "0x5",
+		"nonce": "0x2a",
+		"gas": "0x186a0",
+		"maxPriorityFeePerGas": "0x3b9aca00",
+		"maxFeePerGas": "0x6fc23ac00",
+		"to": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+		"value": "0x1",
+		"data": "0xa9059cbb",
+		"accessList": [{
+			"address": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			"storageKeys": ["0x0000000000000000000000000000000000000000000000000000000000000001"]
+		}]
+	}`), &tx))
+	require.Equal(t, uint8(eth.DynamicFeeTxType), tx.Type)
+	require.Equal(t, []byte{0xa9, 0x05, 0x9c, 0xbb}, tx.Data)
+	require.Len(t, tx.AccessList, 1)
+
+	// The transaction signed by go-ethereum 1.10.26 with types.NewLondonSigner(5) and the key 0x4646...46
+	vectorRaw := "02f8a9052a843b9aca008506fc23ac00830186a094cd2a3d9f938e13cd947ec05abc7fe734df8dd8260184a9059cbbf838f794cd2a3d9f938e13cd947ec05abc7fe734df8dd826e1a0000000000000000000000000000000000000000000000000000000000000000101a0555c5d761af9d9eff0d5b67e923fe69875e1eef09d6115aed441b58bb6ce118aa0782a46430b61a32be09e70da9dd2b2b539f4f97db203b5feaa31b81f5f238695"
+	vectorSig := cipher.MustSigFromHex("555c5d761af9d9eff0d5b67e923fe69875e1eef09d6115aed441b58bb6ce118a782a46430b61a32be09e70da9dd2b2b539f4f97db203b5feaa31b81f5f23869501")
+	vectorFrom := eth.DecodeHexToEthereumAddress("0x9d8A62f656a8d1615C1294fd71e9CFb3E4855A4F")
+
+	h, err := tx.SigningHash()
+	require.NoError(t, err)
+	require.Equal(t, "0e0faca87d1086c8c58b958f1c5a65fa38df65a4f95188d34708c0630dbee056", h.Hex())
+
+	vector, err := tx.WithSignature(vectorSig)
+	require.NoError(t, err)
+	vectorBytes, err := vector.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, vectorRaw, hex.EncodeToString(vectorBytes))
+	vectorHash, err := vector.Hash()
+	require.NoError(t, err)
+	require.Equal(t, "20ae2936a826ab7d404be3c2ac931dee233bcb38c9ca1508c5f809ea0839fe85", vectorHash.Hex())
+
+	decoded, err := eth.DecodeRawTransaction("0x" + vectorRaw)
+	require.NoError(t, err)
+	vectorBytes, err = decoded.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, vectorRaw, hex.EncodeToString(vectorBytes))
+	from, err := decoded.Sender()
+	require.NoError(t, err)
+	require.Equal(t, vectorFrom, from)
+	sk := cipher.MustSecKeyFromHex("4646464646464646464646464646464646464646464646464646464646464646")
+	require.Equal(t, vectorFrom, eth.EthereumAddressFromPubKey(cipher.MustPubKeyFromSecKey(sk)))
+
+	signed, err := SignEthereumTransaction(e, &tx)
+	require.NoError(t, err)
+	require.True(t, signed.V.Uint64() <= 1)
+
+	raw, err := signed.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, byte(eth.DynamicFeeTxType), raw[0])
+
+	// The raw transaction is the type byte followed by the RLP list of the fields and signature values
+	var fields []rlp.RawValue
+	require.NoError(t, rlp.DecodeBytes(raw[1:], &fields))
+	require.Len(t, fields, 12)
+
+	// The signing hash is keccak256(0x02 || rlp(fields without the signature))
+	unsigned, err := rlp.EncodeToBytes(fields[:9])
+	require.NoError(t, err)
+	require.Equal(t, crypto.Keccak256(append([]byte{eth.DynamicFeeTxType}, unsigned...)), h[:])
+
+	// big.Int values are compared by their encodings, since equal values may differ in their internal representation
+	decoded, err = eth.DecodeTransaction(raw)
+	require.NoError(t, err)
+	b, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	signedJSON, err := json.Marshal(signed)
+	require.NoError(t, err)
+	require.JSONEq(t, string(signedJSON), string(b))
+	from, err = decoded.Sender()
+	require.NoError(t, err)
+	require.Equal(t, e.Address, from)
+
+	// The JSON encoding round trips
+	var tx2 eth.Transaction
+	require.NoError(t, json.Unmarshal(b, &tx2))
+	raw2, err := tx2.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, raw, raw2)
+
+	// Changing a field changes the sender
+	decoded.Nonce++
+	from, err = decoded.Sender()
+	require.NoError(t, err)
+	require.NotEqual(t, e.Address, from)
+
+	// Malformed raw transactions are refused
+	for _, b := range [][]byte{
+		nil,
+		{0x01, 0xc0},
+		raw[:len(raw)-1],
+		append(append([]byte{}, raw...), 0x00),
+	} {
+		_, err := eth.DecodeTransaction(b)
+		require.Error(t, err)
+	}
+
+	// High s values are refused
+	tx3 := *signed
+	tx3.S = new(big.Int).Sub(crypto.S256().Params().N, signed.S)
+	tx3.V = big.NewInt(1 - signed.V.Int64())
+	raw, err = tx3.MarshalBinary()
+	require.NoError(t, err)
+	_, err = eth.DecodeTransaction(raw)
+	require.Equal(t, eth.ErrInvalidTxSignature, err)
+}
+
+func TestEthereumTransactionValidate(t *testing.T) {
+	to := eth.EthereumAddress{Addr: common.HexToAddress("0x3535353535353535353535353535353535353535")}
+	cases := []struct {
+		name string
+		tx   eth.Transaction
+	}{
+		{
+			name: "legacy without gas price",
+			tx:   eth.Transaction{Type: eth.LegacyTxType, Gas: 21000, To: &to},
+		},
+		{
+			name: "legacy with fee caps",
+			tx:   eth.Transaction{Type: eth.LegacyTxType, GasPrice: big.NewInt(1), GasFeeCap: big.NewInt(1), Gas: 21000},
+		},
+		{
+			name: "dynamic fee without chain id",
+			tx:   eth.Transaction{Type: eth.DynamicFeeTxType, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Gas: 21000},
+		},
+		{
+			name: "tip higher than fee cap",
+			tx:   eth.Transaction{Type: eth.DynamicFeeTxType, ChainID: big.NewInt(1), GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(1), Gas: 21000},
+		},
+		{
+			name: "negative value",
+			tx:   eth.Transaction{Type: eth.LegacyTxType, GasPrice: big.NewInt(1), Gas: 21000, Value: big.NewInt(-1)},
+		},
+		{
+			name: "zero gas",
+			tx:   eth.Transaction{Type: eth.LegacyTxType, GasPrice: big.NewInt(1)},
+		},
+		{
+			name: "access list transaction",
+			tx:   eth.Transaction{Type: 0x01, ChainID: big.NewInt(1), GasPrice: big.NewInt(1), Gas: 21000},
+		},
+	}
+
+	sk := cipher.MustSecKeyFromHex("4646464646464646464646464646464646464646464646464646464646464646")
+	pk := cipher.MustPubKeyFromSecKey(sk)
+	e := Entry{Address: eth.EthereumAddressFromPubKey(pk), Public: pk, Secret: sk}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Error(t, tc.tx.Validate())
+			_, err := SignEthereumTransaction(e, &tc.tx)
+			require.Error(t, err)
+		})
+	}
+
+	// Unsigned transactions can't be encoded
+	tx := eth.Transaction{Type: eth.LegacyTxType, ChainID: big.NewInt(1), GasPrice: big.NewInt(1), Gas: 21000}
+	_, err := tx.MarshalBinary()
+	require.Equal(t, eth.ErrTxUnsigned, err)
+
+	// Only the keys of ethereum entries sign
+	_, err = SignEthereumTransaction(Entry{Address: cipher.AddressFromPubKey(pk), Public: pk, Secret: sk}, &tx)
+	require.Error(t, err)
+	_, err = SignEthereumTransaction(Entry{Address: e.Address, Public: pk}, &tx)
+	require.Equal(t, ErrWalletWatchOnly, err)
+
+	// Legacy transactions without a chain ID aren't replay protected, and aren't signed
+	tx.ChainID = nil
+	require.NoError(t, tx.Validate())
+	_, err = SignEthereumTransaction(e, &tx)
+	require.Equal(t, ErrEthereumTxChainID, err)
+
+	// Unprotected transactions signed elsewhere, with v 27 or 28, are still decoded and verified
+	h, err := tx.SigningHash()
+	require.NoError(t, err)
+	sig, err := cipher.SignHash(h, sk)
+	require.NoError(t, err)
+	unprotected, err := tx.WithSignature(sig)
+	require.NoError(t, err)
+	require.True(t, unprotected.V.Int64() == 27 || unprotected.V.Int64() == 28)
+	raw, err := unprotected.MarshalBinary()
+	require.NoError(t, err)
+	decoded, err := eth.DecodeTransaction(raw)
+	require.NoError(t, err)
+	require.Nil(t, decoded.ChainID)
+	from, err := decoded.Sender()
+	require.NoError(t, err)
+	require.Equal(t, e.Address, from)
+}
+
+func TestServiceSignEthereumTransaction(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeEthereum,
+		Seed:      testSeed,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	addr := w.GetEntryAt(1).Address
+
+	tx := &eth.Transaction{
+		Type:      eth.DynamicFeeTxType,
+		ChainID:   big.NewInt(1),
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(30000000000),
+		Gas:       21000,
+		To:        &eth.EthereumAddress{},
+		Value:     big.NewInt(1),
+	}
+
+	signed, err := s.SignEthereumTransaction(w.Filename(), []byte("pwd"), addr.String(), tx)
+	require.NoError(t, err)
+	from, err := signed.Sender()
+	require.NoError(t, err)
+	require.Equal(t, addr, from)
+
+	_, err = s.SignEthereumTransaction(w.Filename(), nil, addr.String(), tx)
+	require.Equal(t, ErrMissingPassword, err)
+
+	_, err = s.SignEthereumTransaction(w.Filename(), []byte("pwd"), eth.EthereumAddress{}.String(), tx)
+	require.Equal(t, ErrUnknownAddress, err)
+}
+
+func TestServiceSignEthereumTransactionWithSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	addr, signer := addSignerWatchWallet(t, s, "eth.wlt")
+	to := eth.DecodeHexToEthereumAddress("0x3535353535353535353535353535353535353535")
+	tx := &eth.Transaction{
+		Type:     eth.LegacyTxType,
+		ChainID:  big.NewInt(1),
+		GasPrice: big.NewInt(1),
+		Gas:      21000,
+		To:       &to,
+	}
+
+	_, err = s.SignEthereumTransaction("eth.wlt", nil, addr.String(), tx)
+	require.Equal(t, ErrWalletWatchOnly, err)
+
+	require.NoError(t, s.SetSigner("eth.wlt", signer))
+	signed, err := s.SignEthereumTransaction("eth.wlt", nil, addr.String(), tx)
+	require.NoError(t, err)
+	from, err := signed.Sender()
+	require.NoError(t, err)
+	require.Equal(t, addr, from)
+
+	_, err = s.SignEthereumTransaction("eth.wlt", []byte("pwd"), addr.String(), tx)
+	require.Equal(t, ErrSignerPassword, err)
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// ExportKeystore encrypts the secret key of an ethereum wallet entry into a keystore V3 JSON file,
+// compatible with geth, Clef and MetaMask. The keystore is encrypted with its own password,
+// using scrypt with the given work factor, e.g. eth.StandardScryptN and eth.StandardScryptP.
+// The wallet must not be encrypted, use GuardView to export keys of an encrypted wallet.
+func ExportKeystore(w Wallet, addr cipher.Addresser, password []byte, scryptN, scryptP int) ([]byte, error) {
+	if err := checkKeystoreExport(w, password); err != nil {
+		return nil, err
+	}
+
+	e, ok := w.GetEntry(addr)
+	if !ok {
+		return nil, ErrUnknownAddress
+	}
+
+	if e.Secret.Null() {
+		return nil, NewError(errors.New("wallet entry has no secret key"))
+	}
+
+	return eth.EncryptKeystore(e.Secret, password, scryptN, scryptP)
+}
+
+// ExportKeystoreDir writes a keystore V3 JSON file for every entry of an ethereum wallet to dir,
+// named the way geth names the files of its keystore directory. The paths of the written files are returned.
+// The wallet must not be encrypted, use GuardView to export keys of an encrypted wallet.
+func ExportKeystoreDir(w Wallet, dir string, password []byte, scryptN, scryptP int) ([]string, error) {
+	if err := checkKeystoreExport(w, password); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range w.GetEntries() {
+		if e.Secret.Null() {
+			continue
+		}
+
+		keyjson, err := eth.EncryptKeystore(e.Secret, password, scryptN, scryptP)
+		if err != nil {
+			return files, err
+		}
+
+		addr, ok := e.Address.(eth.EthereumAddress)
+		if !ok {
+			return files, fmt.Errorf("address %s is not an ethereum address", e.Address)
+		}
+
+		fn := filepath.Join(dir, eth.KeystoreFileName(time.Now(), addr))
+		if err := ioutil.WriteFile(fn, keyjson, 0600); err != nil {
+			return files, err
+		}
+
+		files = append(files, fn)
+	}
+
+	return files, nil
+}
+
+func checkKeystoreExport(w Wallet, password []byte) error {
+	if w.Coin() != CoinTypeEthereum {
+		return NewError(fmt.Errorf("wallet coin is %q, keystore files are only supported for %q wallets", w.Coin(), CoinTypeEthereum))
+	}
+
+	switch w.Type() {
+	case WalletTypeCollection, WalletTypeBip44:
+	default:
+		return NewError(fmt.Errorf("wallet type is %q, keystore files can only be exported from %q and %q wallets", w.Type(), WalletTypeCollection, WalletTypeBip44))
+	}
+
+	if w.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if len(password) == 0 {
+		return ErrMissingPassword
+	}
+
+	return nil
+}
+
+// ImportKeystore decrypts a keystore V3 JSON file and adds its secret key to an ethereum collection wallet.
+// keystorePassword is the password of the keystore file, not of the wallet.
+func (w *CollectionWallet) ImportKeystore(keyjson, keystorePassword []byte) (cipher.Addresser, error) {
+	if w.Meta.Coin() != CoinTypeEthereum {
+		return nil, NewError(fmt.Errorf("wallet coin is %q, keystore files are only supported for %q wallets", w.Meta.Coin(), CoinTypeEthereum))
+	}
+
+	if err := w.checkImportSecretKey(CoinTypeEthereum); err != nil {
+		return nil, err
+	}
+
+	sk, err := eth.DecryptKeystore(keyjson, keystorePassword)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("invalid keystore: %v", err))
+	}
+
+	return w.importSecretKey(sk)
+}
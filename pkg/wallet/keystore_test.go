@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// Test vectors from the Web3 Secret Storage Definition
+const (
+	keystoreTestSecKey  = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	keystoreTestAddress = "0x008AeEda4D805471dF9b2A5B0f38A0C3bCBA786b"
+	keystoreTestPBKDF2  = `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {
+				"c": 262144,
+				"dklen": 32,
+				"prf": "hmac-sha256",
+				"salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+			},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+	keystoreTestScrypt = `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "83dbcc02d8ccb40e466191a123791e0e"},
+			"ciphertext": "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+			"kdf": "scrypt",
+			"kdfparams": {
+				"dklen": 32,
+				"n": 262144,
+				"p": 8,
+				"r": 1,
+				"salt": "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"
+			},
+			"mac": "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+)
+
+func TestImportKeystore(t *testing.T) {
+	for _, keyjson := range []string{keystoreTestPBKDF2, keystoreTestScrypt} {
+		w, err := NewWallet("test.wlt", Options{
+			Type: WalletTypeCollection,
+			Coin: CoinTypeEthereum,
+		})
+		require.NoError(t, err)
+		cw := w.(*CollectionWallet)
+
+		_, err = cw.ImportKeystore([]byte(keyjson), []byte("wrongpassword"))
+		require.Error(t, err)
+		require.Equal(t, 0, w.EntriesLen())
+
+		addr, err := cw.ImportKeystore([]byte(keyjson), []byte("testpassword"))
+		require.NoError(t, err)
+		require.Equal(t, keystoreTestAddress, addr.String())
+		require.Equal(t, cipher.MustSecKeyFromHex(keystoreTestSecKey), w.GetEntryAt(0).Secret)
+	}
+
+	// Keystore files are only supported for ethereum wallets
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.NoError(t, err)
+	_, err = w.(*CollectionWallet).ImportKeystore([]byte(keystoreTestPBKDF2), []byte("testpassword"))
+	require.Error(t, err)
+}
+
+func TestDecryptKeystoreKDFParams(t *testing.T) {
+	salt := `"ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"`
+	cases := []struct {
+		name      string
+		kdf       string
+		kdfparams string
+	}{
+		{"scrypt n too large", "scrypt", `{"dklen": 32, "n": 4294967296, "p": 1, "r": 8, "salt": ` + salt + `}`},
+		{"scrypt n above limit", "scrypt", `{"dklen": 32, "n": 2097152, "p": 1, "r": 8, "salt": ` + salt + `}`},
+		{"scrypt n not a power of 2", "scrypt", `{"dklen": 32, "n": 262143, "p": 1, "r": 8, "salt": ` + salt + `}`},
+		{"scrypt r too large", "scrypt", `{"dklen": 32, "n": 262144, "p": 1, "r": 1024, "salt": ` + salt + `}`},
+		{"scrypt p too large", "scrypt", `{"dklen": 32, "n": 262144, "p": 1000000, "r": 8, "salt": ` + salt + `}`},
+		{"scrypt fractional r", "scrypt", `{"dklen": 32, "n": 262144, "p": 1, "r": 7.5, "salt": ` + salt + `}`},
+		{"dklen too large", "scrypt", `{"dklen": 1000000000, "n": 262144, "p": 1, "r": 8, "salt": ` + salt + `}`},
+		{"missing salt", "scrypt", `{"dklen": 32, "n": 262144, "p": 1, "r": 8}`},
+		{"short salt", "scrypt", `{"dklen": 32, "n": 262144, "p": 1, "r": 8, "salt": "ab0c78"}`},
+		{"pbkdf2 iterations too large", "pbkdf2", `{"c": 4294967295, "dklen": 32, "prf": "hmac-sha256", "salt": ` + salt + `}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyjson := `{
+				"crypto": {
+					"cipher": "aes-128-ctr",
+					"cipherparams": {"iv": "83dbcc02d8ccb40e466191a123791e0e"},
+					"ciphertext": "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+					"kdf": "` + tc.kdf + `",
+					"kdfparams": ` + tc.kdfparams + `,
+					"mac": "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"
+				},
+				"version": 3
+			}`
+			_, err := eth.DecryptKeystore([]byte(keyjson), []byte("testpassword"))
+			require.Error(t, err)
+			require.NotEqual(t, eth.ErrKeystoreDecrypt, err)
+		})
+	}
+}
+
+func TestExportKeystore(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeEthereum,
+		Seed:      testVectorSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+
+	e := w.GetEntryAt(1)
+	keyjson, err := ExportKeystore(w, e.Address, []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+	require.NoError(t, err)
+
+	sk, err := eth.DecryptKeystore(keyjson, []byte("pwd"))
+	require.NoError(t, err)
+	require.Equal(t, e.Secret, sk)
+
+	_, err = eth.DecryptKeystore(keyjson, []byte("wrong"))
+	require.Equal(t, eth.ErrKeystoreDecrypt, err)
+
+	// Unknown addresses
+	_, err = ExportKeystore(w, eth.DecodeHexToEthereumAddress(keystoreTestAddress), []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+	require.Equal(t, ErrUnknownAddress, err)
+
+	// Bulk export to a keystore directory
+	dir, err := ioutil.TempDir("", "keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files, err := ExportKeystoreDir(w, filepath.Join(dir, "keystore"), []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+	require.NoError(t, err)
+	require.Len(t, files, w.EntriesLen())
+
+	// The exported files can be imported into a collection wallet
+	cw, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeEthereum,
+	})
+	require.NoError(t, err)
+	for _, f := range files {
+		keyjson, err := ioutil.ReadFile(f)
+		require.NoError(t, err)
+		_, err = cw.(*CollectionWallet).ImportKeystore(keyjson, []byte("pwd"))
+		require.NoError(t, err)
+	}
+	require.Equal(t, w.GetEntries().getAddresses(), cw.GetEntries().getAddresses())
+
+	// Encrypted wallets must be unlocked first
+	require.NoError(t, Lock(w, []byte("walletpwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, err = ExportKeystore(w, e.Address, []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+	require.Equal(t, ErrWalletEncrypted, err)
+
+	require.NoError(t, GuardView(w, []byte("walletpwd"), func(w Wallet) error {
+		_, err := ExportKeystore(w, e.Address, []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+		return err
+	}))
+
+	// Keystore files are only supported for ethereum wallets
+	bw, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	_, err = ExportKeystore(bw, bw.GetEntryAt(0).Address, []byte("pwd"), eth.LightScryptN, eth.LightScryptP)
+	require.Error(t, err)
+}
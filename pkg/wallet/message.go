@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// ErrInvalidMessageSignature is returned when a message signature is malformed or wasn't made by the address
+var ErrInvalidMessageSignature = NewError(errors.New("invalid message signature"))
+
+// SignMessage signs a message with the secret key of an entry, to prove the ownership of its address.
+// The signature is in the coin's usual message signature format: a base64 Bitcoin Signed Message compact signature
+// (bip137) of a P2PKH, P2SH-P2WPKH or P2WPKH address for bitcoin, a 0x prefixed hex EIP-191 personal_sign signature
+// [r|s|v] for ethereum, and a hex signature of the SHA256 of the message for skycoin, see cipher.SignHash.
+// Entries of encrypted wallets must be decrypted first, e.g. with GuardView.
+func SignMessage(coin CoinType, e Entry, msg []byte) (string, error) {
+	if e.Secret.Null() {
+		return "", ErrWalletWatchOnly
+	}
+
+	return signMessage(coin, e, msg, secretKeySigner(e))
+}
+
+// signMessage signs a message of an entry's address with sign, see SignMessage
+func signMessage(coin CoinType, e Entry, msg []byte, sign digestSigner) (string, error) {
+	var hash cipher.SHA256
+	switch coin {
+	case CoinTypeSkycoin:
+		hash = cipher.SumSHA256(msg)
+	case CoinTypeBitcoin:
+		hash = btc.MessageHash(msg)
+	case CoinTypeEthereum:
+		hash = eth.TextHash(msg)
+	default:
+		return "", ErrInvalidCoinType
+	}
+
+	sig, err := sign(hash)
+	if err != nil {
+		return "", err
+	}
+
+	switch coin {
+	case CoinTypeBitcoin:
+		s, err := btc.EncodeMessageSignature(e.Address, sig)
+		if err != nil {
+			return "", NewError(fmt.Errorf("%v: %s", err, e.Address))
+		}
+		return s, nil
+	case CoinTypeEthereum:
+		return eth.EncodeSignature(sig), nil
+	default:
+		return sig.Hex(), nil
+	}
+}
+
+// VerifyMessage checks that a message signature, in the format of SignMessage, was made by the key of an address
+func VerifyMessage(coin CoinType, addr string, msg []byte, sig string) error {
+	a, err := DecodeAddress(coin, addr)
+	if err != nil {
+		if err == ErrInvalidCoinType {
+			return err
+		}
+		return NewError(fmt.Errorf("invalid %s address: %v", coin, err))
+	}
+
+	switch coin {
+	case CoinTypeSkycoin:
+		var s cipher.Sig
+		s, err = cipher.SigFromHex(sig)
+		if err == nil {
+			err = cipher.VerifyAddressSignedHash(a.(cipher.Address), s, cipher.SumSHA256(msg))
+		}
+	case CoinTypeBitcoin:
+		err = btc.VerifyMessage(a, msg, sig)
+		if err == btc.ErrMessageAddressType {
+			return NewError(fmt.Errorf("%v: %s", err, addr))
+		}
+	case CoinTypeEthereum:
+		err = eth.VerifyMessage(a.(eth.EthereumAddress), msg, sig)
+	}
+
+	if err != nil {
+		return ErrInvalidMessageSignature
+	}
+
+	return nil
+}
+
+// SignTypedData signs EIP-712 typed data with the secret key of an ethereum entry.
+// The signature is 0x prefixed hex [r|s|v], with v = 27 + recovery id, as eth_signTypedData_v4 returns it.
+// Entries of encrypted wallets must be decrypted first, e.g. with GuardView.
+func SignTypedData(e Entry, td *eth.TypedData) (string, error) {
+	if e.Secret.Null() {
+		return "", ErrWalletWatchOnly
+	}
+
+	return signTypedData(e, td, secretKeySigner(e))
+}
+
+// signTypedData signs EIP-712 typed data of an ethereum entry's address with sign, see SignTypedData
+func signTypedData(e Entry, td *eth.TypedData, sign digestSigner) (string, error) {
+	if _, ok := e.Address.(eth.EthereumAddress); !ok {
+		return "", NewError(errors.New("typed data can only be signed with the keys of ethereum addresses"))
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		return "", NewError(fmt.Errorf("invalid typed data: %v", err))
+	}
+
+	sig, err := sign(hash)
+	if err != nil {
+		return "", err
+	}
+
+	return eth.EncodeSignature(sig), nil
+}
+
+// VerifyTypedData checks that a signature of EIP-712 typed data was made by the key of an ethereum address
+func VerifyTypedData(addr string, td *eth.TypedData, sig string) error {
+	a, err := eth.DecodeEthereumAddress(addr)
+	if err != nil {
+		return NewError(fmt.Errorf("invalid %s address: %v", CoinTypeEthereum, err))
+	}
+
+	if _, err := td.Hash(); err != nil {
+		return NewError(fmt.Errorf("invalid typed data: %v", err))
+	}
+
+	if err := eth.VerifyTypedData(a, td, sig); err != nil {
+		return ErrInvalidMessageSignature
+	}
+
+	return nil
+}
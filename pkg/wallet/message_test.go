@@ -0,0 +1,420 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/secp256k1-go"
+)
+
+func TestSignMessage(t *testing.T) {
+	msg := []byte("message to sign")
+
+	cases := []struct {
+		coin        CoinType
+		addressType AddressType
+	}{
+		{coin: CoinTypeSkycoin},
+		{coin: CoinTypeBitcoin, addressType: AddressTypeP2PKH},
+		{coin: CoinTypeBitcoin, addressType: AddressTypeP2SHP2WPKH},
+		{coin: CoinTypeBitcoin, addressType: AddressTypeP2WPKH},
+		{coin: CoinTypeEthereum},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.coin)+string(tc.addressType), func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:        WalletTypeBip44,
+				Coin:        tc.coin,
+				Seed:        testVectorSeed,
+				AddressType: tc.addressType,
+				GenerateN:   2,
+			})
+			require.NoError(t, err)
+			e := w.GetEntryAt(1)
+			addr := e.Address.String()
+
+			sig, err := SignMessage(tc.coin, e, msg)
+			require.NoError(t, err)
+			require.NoError(t, VerifyMessage(tc.coin, addr, msg, sig))
+
+			require.Equal(t, ErrInvalidMessageSignature, VerifyMessage(tc.coin, addr, []byte("other message"), sig))
+			require.Equal(t, ErrInvalidMessageSignature, VerifyMessage(tc.coin, w.GetEntryAt(0).Address.String(), msg, sig))
+			require.Equal(t, ErrInvalidMessageSignature, VerifyMessage(tc.coin, addr, msg, sig[:len(sig)-4]))
+
+			// Entries of encrypted wallets sign once decrypted
+			require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+			_, err = SignMessage(tc.coin, w.GetEntryAt(1), msg)
+			require.Equal(t, ErrWalletWatchOnly, err)
+			require.NoError(t, GuardView(w, []byte("pwd"), func(w Wallet) error {
+				sig, err := SignMessage(tc.coin, w.GetEntryAt(1), msg)
+				require.NoError(t, err)
+				return VerifyMessage(tc.coin, addr, msg, sig)
+			}))
+		})
+	}
+}
+
+func TestSignMessageCompatibility(t *testing.T) {
+	msg := []byte("vires in numeris")
+	btcHash := btc.MessageHash(msg)
+	ethHash := eth.TextHash(msg)
+	k := cipher.SumSHA256([]byte("message key"))
+	sk := cipher.MustNewSecKey(k[:])
+	pk := cipher.MustPubKeyFromSecKey(sk)
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), sk[:])
+
+	// Bitcoin signatures are verified by btcec, which recovers the key of compact signatures like Bitcoin Core
+	p2pkh := cipher.BitcoinAddressFromPubKey(pk)
+	sig, err := SignMessage(CoinTypeBitcoin, Entry{Address: p2pkh, Public: pk, Secret: sk}, msg)
+	require.NoError(t, err)
+	b, err := base64.StdEncoding.DecodeString(sig)
+	require.NoError(t, err)
+	rpk, compressed, err := btcec.RecoverCompact(btcec.S256(), b, btcHash[:])
+	require.NoError(t, err)
+	require.True(t, compressed)
+	require.Equal(t, pk[:], rpk.SerializeCompressed())
+
+	// Signatures made by btcec are verified, including those of uncompressed keys
+	b, err = btcec.SignCompact(btcec.S256(), priv, btcHash[:], true)
+	require.NoError(t, err)
+	require.NoError(t, VerifyMessage(CoinTypeBitcoin, p2pkh.String(), msg, base64.StdEncoding.EncodeToString(b)))
+
+	// Electrum signs segwit messages with the P2PKH header
+	p2wpkh := btc.SegwitAddressFromPubKey(pk)
+	require.NoError(t, VerifyMessage(CoinTypeBitcoin, p2wpkh.String(), msg, base64.StdEncoding.EncodeToString(b)))
+
+	b, err = btcec.SignCompact(btcec.S256(), priv, btcHash[:], false)
+	require.NoError(t, err)
+	h := cipher.SumSHA256(secp256k1.UncompressPubkey(pk[:]))
+	uncompressed := cipher.BitcoinAddress{Key: cipher.HashRipemd160(h[:])}
+	require.NoError(t, VerifyMessage(CoinTypeBitcoin, uncompressed.String(), msg, base64.StdEncoding.EncodeToString(b)))
+	require.Equal(t, ErrInvalidMessageSignature, VerifyMessage(CoinTypeBitcoin, p2pkh.String(), msg, base64.StdEncoding.EncodeToString(b)))
+
+	// The P2WPKH header can't be used for P2PKH addresses
+	b[0] += 8
+	require.Equal(t, ErrInvalidMessageSignature, VerifyMessage(CoinTypeBitcoin, p2pkh.String(), msg, base64.StdEncoding.EncodeToString(b)))
+
+	// Taproot addresses have no message signature format
+	tr := btc.MustTaprootAddressFromPubKey(pk)
+	_, err = SignMessage(CoinTypeBitcoin, Entry{Address: tr, Public: pk, Secret: sk}, msg)
+	require.Error(t, err)
+	_, ok := err.(Error)
+	require.True(t, ok)
+
+	// Ethereum signatures are recovered by go-ethereum, and its signatures are verified
+	ea := eth.EthereumAddressFromPubKey(pk)
+	sig, err = SignMessage(CoinTypeEthereum, Entry{Address: ea, Public: pk, Secret: sk}, msg)
+	require.NoError(t, err)
+	require.Len(t, sig, 2+65*2)
+	es, err := eth.DecodeSignature(sig)
+	require.NoError(t, err)
+	epk, err := crypto.SigToPub(crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n16"), msg), es[:])
+	require.NoError(t, err)
+	require.Equal(t, ea.Addr, crypto.PubkeyToAddress(*epk))
+
+	ek, err := crypto.ToECDSA(sk[:])
+	require.NoError(t, err)
+	b, err = crypto.Sign(ethHash[:], ek)
+	require.NoError(t, err)
+	require.NoError(t, VerifyMessage(CoinTypeEthereum, ea.String(), msg, eth.EncodeSignature(cipher.MustNewSig(b))))
+	// v may be the recovery id, and addresses may be lowercase
+	require.NoError(t, VerifyMessage(CoinTypeEthereum, strings.ToLower(ea.String()), msg, "0x"+cipher.MustNewSig(b).Hex()))
+}
+
+func TestServiceSignMessage(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeEthereum,
+		Seed:      testSeed,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	addr := w.GetEntryAt(1).Address.String()
+	msg := []byte("message")
+
+	sig, err := s.SignMessage(w.Filename(), []byte("pwd"), CoinTypeEthereum, addr, msg)
+	require.NoError(t, err)
+	require.NoError(t, VerifyMessage(CoinTypeEthereum, addr, msg, sig))
+
+	_, err = s.SignMessage(w.Filename(), nil, CoinTypeEthereum, addr, msg)
+	require.Equal(t, ErrMissingPassword, err)
+
+	_, err = s.SignMessage(w.Filename(), []byte("pwd"), CoinTypeBitcoin, addr, msg)
+	require.Error(t, err)
+
+	_, err = s.SignMessage(w.Filename(), []byte("pwd"), CoinTypeEthereum, eth.EthereumAddress{}.String(), msg)
+	require.Equal(t, ErrUnknownAddress, err)
+
+	_, err = s.SignMessage(w.Filename(), []byte("pwd"), CoinTypeEthereum, "0x00", msg)
+	require.Error(t, err)
+}
+
+// addSignerWatchWallet adds a watch-only ethereum wallet to a service, and returns its address
+// and a Signer which holds the address's key
+func addSignerWatchWallet(t *testing.T, s *Service, wltID string) (cipher.Addresser, Signer) {
+	signer, err := NewWallet("signer.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeEthereum,
+	})
+	require.NoError(t, err)
+	addr, err := signer.(*CollectionWallet).ImportSecretKey(CoinTypeEthereum, "4646464646464646464646464646464646464646464646464646464646464646")
+	require.NoError(t, err)
+
+	_, err = s.CreateWallet(wltID, Options{
+		Type:      WalletTypeCollection,
+		Coin:      CoinTypeEthereum,
+		WatchOnly: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Update(wltID, func(w Wallet) error {
+		return w.(*CollectionWallet).AddWatchAddress(addr.String(), "")
+	}))
+
+	return addr, signer.(Signer)
+}
+
+// wrongKeySigner signs every path with the first key of a wallet
+type wrongKeySigner struct {
+	Signer
+}
+
+func (s *wrongKeySigner) SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error) {
+	entries := s.Signer.(Wallet).GetEntries()
+	return s.Signer.SignDigest(entries[0].Address.String(), digest)
+}
+
+func TestServiceSignMessageWithSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	// The signer holds the keys of a watch-only wallet of the service
+	addr, signer := addSignerWatchWallet(t, s, "eth.wlt")
+	msg := []byte("hello")
+
+	_, err = s.SignMessage("eth.wlt", nil, CoinTypeEthereum, addr.String(), msg)
+	require.Equal(t, ErrWalletWatchOnly, err)
+
+	require.NoError(t, s.SetSigner("eth.wlt", signer))
+	sig, err := s.SignMessage("eth.wlt", nil, CoinTypeEthereum, addr.String(), msg)
+	require.NoError(t, err)
+	require.NoError(t, VerifyMessage(CoinTypeEthereum, addr.String(), msg, sig))
+
+	_, err = s.SignMessage("eth.wlt", []byte("pwd"), CoinTypeEthereum, addr.String(), msg)
+	require.Equal(t, ErrSignerPassword, err)
+
+	// Signatures of other keys than the address's are refused
+	other, err := NewWallet("other.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeEthereum,
+	})
+	require.NoError(t, err)
+	_, err = other.(*CollectionWallet).ImportSecretKey(CoinTypeEthereum, "4747474747474747474747474747474747474747474747474747474747474747")
+	require.NoError(t, err)
+	require.NoError(t, s.SetSigner("eth.wlt", &wrongKeySigner{other.(Signer)}))
+	_, err = s.SignMessage("eth.wlt", nil, CoinTypeEthereum, addr.String(), msg)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't match the key")
+}
+
+func TestServiceSignMessageSharedHDKeyPath(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	// The keys of a Bitcoin Core wallet's previous seeds have the same HD key paths as its current keys
+	_, err = s.CreateWallet("eth.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeEthereum,
+	})
+	require.NoError(t, err)
+	var addrs []string
+	for _, b := range []byte{0x46, 0x47} {
+		sk := cipher.MustNewSecKey(bytes.Repeat([]byte{b}, 32))
+		pk := cipher.MustPubKeyFromSecKey(sk)
+		addr := eth.EthereumAddressFromPubKey(pk)
+		require.NoError(t, s.Update("eth.wlt", func(w Wallet) error {
+			return w.(*CollectionWallet).AddEntry(Entry{
+				Address:   addr,
+				Public:    pk,
+				Secret:    sk,
+				HDKeyPath: "m/0'/0'/0'",
+			})
+		}))
+		addrs = append(addrs, addr.String())
+	}
+	msg := []byte("hello")
+
+	for _, addr := range addrs {
+		sig, err := s.SignMessage("eth.wlt", nil, CoinTypeEthereum, addr, msg)
+		require.NoError(t, err)
+		require.NoError(t, VerifyMessage(CoinTypeEthereum, addr, msg, sig))
+	}
+
+	// A signer holding the same keys finds them by their addresses
+	signer, err := s.GetWallet("eth.wlt")
+	require.NoError(t, err)
+	require.NoError(t, s.SetSigner("eth.wlt", signer.(Signer)))
+	for _, addr := range addrs {
+		sig, err := s.SignMessage("eth.wlt", nil, CoinTypeEthereum, addr, msg)
+		require.NoError(t, err)
+		require.NoError(t, VerifyMessage(CoinTypeEthereum, addr, msg, sig))
+	}
+}
+
+// eip712MailTypedData is the example of the EIP-712 specification
+const eip712MailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestSignTypedData(t *testing.T) {
+	td, err := eth.ParseTypedData([]byte(eip712MailTypedData))
+	require.NoError(t, err)
+
+	// The signature of the specification's example, made with the key keccak256("cow")
+	cow := "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"
+	specSig := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c"
+	require.NoError(t, VerifyTypedData(cow, td, specSig))
+
+	sk := cipher.MustNewSecKey(crypto.Keccak256([]byte("cow")))
+	pk := cipher.MustPubKeyFromSecKey(sk)
+	e := Entry{Address: eth.EthereumAddressFromPubKey(pk), Public: pk, Secret: sk}
+	require.Equal(t, cow, e.Address.String())
+
+	sig, err := SignTypedData(e, td)
+	require.NoError(t, err)
+	require.Len(t, sig, 2+65*2)
+	require.NoError(t, VerifyTypedData(cow, td, sig))
+	a, err := eth.RecoverTypedDataAddress(td, sig)
+	require.NoError(t, err)
+	require.Equal(t, e.Address, a)
+
+	// Changed messages don't verify
+	td.Message["contents"] = "Hello, Alice!"
+	require.Equal(t, ErrInvalidMessageSignature, VerifyTypedData(cow, td, sig))
+
+	// Only ethereum entries sign typed data
+	btcEntry := Entry{Address: cipher.BitcoinAddressFromPubKey(pk), Public: pk, Secret: sk}
+	_, err = SignTypedData(btcEntry, td)
+	require.Error(t, err)
+}
+
+func TestServiceSignTypedData(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:     WalletTypeCollection,
+		Coin:     CoinTypeEthereum,
+		Encrypt:  true,
+		Password: []byte("pwd"),
+	})
+	require.NoError(t, err)
+
+	addr, err := s.ImportSecretKey(w.Filename(), []byte("pwd"), CoinTypeEthereum, hex.EncodeToString(crypto.Keccak256([]byte("cow"))))
+	require.NoError(t, err)
+
+	td, err := eth.ParseTypedData([]byte(eip712MailTypedData))
+	require.NoError(t, err)
+
+	sig, err := s.SignTypedData(w.Filename(), []byte("pwd"), strings.ToLower(addr.String()), td)
+	require.NoError(t, err)
+	require.NoError(t, VerifyTypedData(addr.String(), td, sig))
+
+	_, err = s.SignTypedData(w.Filename(), []byte("pwd"), "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", td)
+	require.Equal(t, ErrUnknownAddress, err)
+}
+
+func TestServiceSignTypedDataWithSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	addr, signer := addSignerWatchWallet(t, s, "eth.wlt")
+	td, err := eth.ParseTypedData([]byte(eip712MailTypedData))
+	require.NoError(t, err)
+
+	_, err = s.SignTypedData("eth.wlt", nil, addr.String(), td)
+	require.Equal(t, ErrWalletWatchOnly, err)
+
+	require.NoError(t, s.SetSigner("eth.wlt", signer))
+	sig, err := s.SignTypedData("eth.wlt", nil, addr.String(), td)
+	require.NoError(t, err)
+	require.NoError(t, VerifyTypedData(addr.String(), td, sig))
+
+	_, err = s.SignTypedData("eth.wlt", []byte("pwd"), addr.String(), td)
+	require.Equal(t, ErrSignerPassword, err)
+}
@@ -1,10 +1,13 @@
 package wallet
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
 
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
 	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
 
 	"github.com/SkycoinProject/skycoin/src/cipher"
@@ -28,6 +31,20 @@ const (
 	metaBip44Coin      = "bip44Coin"      // bip44 coin type
 	metaSeedPassphrase = "seedPassphrase" // seed passphrase [bip44 wallets]
 	metaXPub           = "xpub"           // xpub key [xpub wallets]
+	metaAddressType    = "addressType"    // address type [bitcoin wallets]
+	metaXPubLevel      = "xpubLevel"      // xpub key level [xpub wallets]
+	metaWatchOnly      = "watchOnly"      // whether the wallet only holds addresses [collection wallets]
+	metaSeedType       = "seedType"       // seed mnemonic type [bip44 wallets]
+	metaMAC            = "mac"            // MAC of the wallet, keyed by a secret [encrypted wallets]
+	metaLoadMAC        = "loadMac"        // MAC of the wallet, keyed by the wallet service's MAC key file [encrypted wallets]
+)
+
+// bip32 purpose indices of the HD derivation paths
+const (
+	purposeBip44 uint32 = 44 // legacy p2pkh addresses
+	purposeBip49 uint32 = 49 // p2sh-p2wpkh addresses
+	purposeBip84 uint32 = 84 // native segwit p2wpkh addresses
+	purposeBip86 uint32 = 86 // single key p2tr addresses
 )
 
 // Meta holds wallet metadata
@@ -104,10 +121,30 @@ func (m Meta) validate() error {
 		if s := m[metaLastSeed]; s != "" {
 			return errors.New("lastSeed should not be visible in encrypted wallets")
 		}
+
+		if s := m[metaMAC]; s != "" {
+			if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+				return errors.New("invalid mac")
+			}
+		}
+
+		if s := m[metaLoadMAC]; s != "" {
+			if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+				return errors.New("invalid loadMac")
+			}
+		}
 	} else {
 		if s := m[metaSecrets]; s != "" {
 			return errors.New("secrets should not be in unencrypted wallets")
 		}
+
+		if s := m[metaMAC]; s != "" {
+			return errors.New("mac should not be in unencrypted wallets")
+		}
+
+		if s := m[metaLoadMAC]; s != "" {
+			return errors.New("loadMac should not be in unencrypted wallets")
+		}
 	}
 
 	switch walletType {
@@ -130,13 +167,40 @@ func (m Meta) validate() error {
 			}
 		}
 	case WalletTypeBip44:
+		seedType, err := SeedTypeFromString(string(m.SeedType()))
+		if err != nil {
+			return err
+		}
+
 		if !isEncrypted {
-			// bip44 wallet seeds must be a valid bip39 mnemonic
+			// bip44 wallet seeds must be a valid bip39 mnemonic, an Electrum seed of the wallet's seed type
+			// or a SLIP-39 master secret
 			if s := m[metaSeed]; s == "" {
 				return errors.New("seed missing in unencrypted bip44 wallet")
+			} else if seedType.IsElectrum() {
+				if err := validateElectrumSeed(s, seedType); err != nil {
+					return err
+				}
+			} else if seedType == SeedTypeSlip39 {
+				if _, err := decodeSlip39Seed(s); err != nil {
+					return err
+				}
 			} else if err := bip39.ValidateMnemonic(s); err != nil {
 				return err
 			}
+
+			if seedType == SeedTypeSlip39 && m[metaSeedPassphrase] != "" {
+				return errors.New("seedPassphrase is not used with slip39 master secrets")
+			}
+		}
+
+		if seedType.IsElectrum() {
+			if CoinType(m[metaCoin]) != CoinTypeBitcoin {
+				return errors.New("electrum seeds are only used for bitcoin wallets")
+			}
+			if m.AddressType() != seedType.addressType() {
+				return fmt.Errorf("%s seeds are only used for %s addresses", seedType, seedType.addressType())
+			}
 		}
 
 		if s := m[metaBip44Coin]; s == "" {
@@ -164,6 +228,41 @@ func (m Meta) validate() error {
 		return errors.New("xpub is only used for xpub wallets")
 	}
 
+	if s := m[metaWatchOnly]; s != "" {
+		watchOnly, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("watchOnly field is not a valid bool")
+		}
+		if watchOnly && walletType != WalletTypeCollection {
+			return errors.New("watchOnly is only used for collection wallets")
+		}
+		if watchOnly && isEncrypted {
+			return errors.New("watch-only wallets can't be encrypted")
+		}
+	}
+
+	if s := m[metaSeedType]; s != "" && walletType != WalletTypeBip44 {
+		return errors.New("seedType is only used for bip44 wallets")
+	}
+
+	if s := m[metaXPubLevel]; s != "" {
+		if walletType != WalletTypeXPub {
+			return errors.New("xpubLevel is only used for xpub wallets")
+		}
+		if _, err := XPubLevelFromString(s); err != nil {
+			return err
+		}
+	}
+
+	if s := m[metaAddressType]; s != "" {
+		if CoinType(m[metaCoin]) != CoinTypeBitcoin {
+			return errors.New("addressType is only used for bitcoin wallets")
+		}
+		if _, err := AddressTypeFromString(s); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -234,6 +333,19 @@ func (m Meta) setSeedPassphrase(p string) {
 	m[metaSeedPassphrase] = p
 }
 
+// SeedType returns the type of the wallet's seed mnemonic.
+// Wallets created before seed types were supported use bip39 mnemonics.
+func (m Meta) SeedType() SeedType {
+	if t := m[metaSeedType]; t != "" {
+		return SeedType(t)
+	}
+	return SeedTypeBip39
+}
+
+func (m Meta) setSeedType(t SeedType) {
+	m[metaSeedType] = string(t)
+}
+
 // Coin returns the wallet's coin type
 func (m Meta) Coin() CoinType {
 	return CoinType(m[metaCoin])
@@ -280,6 +392,8 @@ func (m Meta) SetDecrypted() {
 	m.setIsEncrypted(false)
 	m.setSecrets("")
 	m.setCryptoType("")
+	delete(m, metaMAC)
+	delete(m, metaLoadMAC)
 }
 
 // IsEncrypted checks whether the wallet is encrypted.
@@ -318,6 +432,24 @@ func (m Meta) setSecrets(s string) {
 	m[metaSecrets] = s
 }
 
+// MAC returns the MAC of an encrypted wallet
+func (m Meta) MAC() string {
+	return m[metaMAC]
+}
+
+func (m Meta) setMAC(mac string) {
+	m[metaMAC] = mac
+}
+
+// LoadMAC returns the MAC of an encrypted wallet checked when it is loaded, see Config.MACKeyFile
+func (m Meta) LoadMAC() string {
+	return m[metaLoadMAC]
+}
+
+func (m Meta) setLoadMAC(mac string) {
+	m[metaLoadMAC] = mac
+}
+
 // Timestamp returns the timestamp
 func (m Meta) Timestamp() int64 {
 	// Intentionally ignore the error when parsing the timestamp,
@@ -340,8 +472,23 @@ func (m Meta) AddressConstructor() func(cipher.PubKey) cipher.Addresser {
 			return cipher.AddressFromPubKey(pk)
 		}
 	case CoinTypeBitcoin:
-		return func(pk cipher.PubKey) cipher.Addresser {
-			return cipher.BitcoinAddressFromPubKey(pk)
+		switch m.AddressType() {
+		case AddressTypeP2WPKH:
+			return func(pk cipher.PubKey) cipher.Addresser {
+				return btc.SegwitAddressFromPubKey(pk)
+			}
+		case AddressTypeP2SHP2WPKH:
+			return func(pk cipher.PubKey) cipher.Addresser {
+				return btc.NestedSegwitAddressFromPubKey(pk)
+			}
+		case AddressTypeP2TR:
+			return func(pk cipher.PubKey) cipher.Addresser {
+				return btc.MustTaprootAddressFromPubKey(pk)
+			}
+		default:
+			return func(pk cipher.PubKey) cipher.Addresser {
+				return cipher.BitcoinAddressFromPubKey(pk)
+			}
 		}
 	case CoinTypeEthereum:
 		return func(pk cipher.PubKey) cipher.Addresser {
@@ -361,3 +508,48 @@ func (m Meta) setXPub(xpub string) {
 func (m Meta) XPub() string {
 	return m[metaXPub]
 }
+
+// IsWatchOnly returns true if the wallet is a watch-only collection wallet, whose entries only have an address
+func (m Meta) IsWatchOnly() bool {
+	watchOnly, err := strconv.ParseBool(m[metaWatchOnly])
+	return err == nil && watchOnly
+}
+
+// XPubLevel returns the xpub key level of an xpub wallet.
+// Wallets created before account level xpub keys were supported use chain level keys.
+func (m Meta) XPubLevel() XPubLevel {
+	if l := m[metaXPubLevel]; l != "" {
+		return XPubLevel(l)
+	}
+	return XPubLevelChain
+}
+
+func (m Meta) setXPubLevel(l XPubLevel) {
+	m[metaXPubLevel] = string(l)
+}
+
+// AddressType returns the wallet's address type.
+// Bitcoin wallets created before address types were supported use p2pkh addresses.
+func (m Meta) AddressType() AddressType {
+	if t := m[metaAddressType]; t != "" {
+		return AddressType(t)
+	}
+	if m.Coin() == CoinTypeBitcoin {
+		return AddressTypeP2PKH
+	}
+	return ""
+}
+
+// bip32Purpose returns the purpose index of the wallet's HD derivation path
+func (m Meta) bip32Purpose() uint32 {
+	switch m.AddressType() {
+	case AddressTypeP2WPKH:
+		return purposeBip84
+	case AddressTypeP2SHP2WPKH:
+		return purposeBip49
+	case AddressTypeP2TR:
+		return purposeBip86
+	default:
+		return purposeBip44
+	}
+}
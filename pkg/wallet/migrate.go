@@ -0,0 +1,339 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrWalletVersionUnsupported is returned when loading a wallet file of a newer version than Version
+var ErrWalletVersionUnsupported = NewError(errors.New("wallet file version is newer than the supported version"))
+
+// BackupExt is the extension of the backup files of a wallet file: <filename>.<version>.bak for the copy
+// of the file made before migrating it from version, and <filename>.<timestamp>.bak for the copies made
+// before saving it, see SaveWithBackups
+const BackupExt = "bak"
+
+// migrationError is an error migrating a wallet file which could be read. Load doesn't recover from it
+// by loading a backup of the file, which would silently drop the changes made since the backup.
+type migrationError struct {
+	error
+}
+
+// walletFile is the JSON of a wallet file. Migrations modify it without loading it into a Wallet,
+// so that they don't depend on the current wallet format. Unknown entry fields are kept as is.
+type walletFile struct {
+	Meta    map[string]string `json:"meta"`
+	Entries []*walletEntry    `json:"entries"`
+}
+
+// walletEntry is an entry of a wallet file, which keeps the order of its fields
+type walletEntry struct {
+	keys   []string
+	fields map[string]json.RawMessage
+}
+
+func (e *walletEntry) has(key string) bool {
+	_, ok := e.fields[key]
+	return ok
+}
+
+func (e *walletEntry) set(key string, v json.RawMessage) {
+	if !e.has(key) {
+		e.keys = append(e.keys, key)
+	}
+	e.fields[key] = v
+}
+
+func (e *walletEntry) UnmarshalJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if t, err := d.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return errors.New("wallet entry is not a JSON object")
+	}
+
+	e.keys = nil
+	e.fields = make(map[string]json.RawMessage)
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		var v json.RawMessage
+		if err := d.Decode(&v); err != nil {
+			return err
+		}
+
+		e.set(t.(string), v)
+	}
+
+	_, err := d.Token()
+	return err
+}
+
+func (e walletEntry) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range e.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(e.fields[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// migration upgrades a wallet file from one version to the next
+type migration struct {
+	from    string
+	to      string
+	migrate func(f *walletFile) error
+}
+
+// apply upgrades a wallet file of version m.from to version m.to
+func (m migration) apply(f *walletFile) error {
+	if v := f.Meta[metaVersion]; v != m.from {
+		return fmt.Errorf("wallet version is %q, migration is from %q", v, m.from)
+	}
+
+	if err := m.migrate(f); err != nil {
+		return err
+	}
+
+	f.Meta[metaVersion] = m.to
+	return nil
+}
+
+// migrations are the wallet file upgrades, in version order. The last one upgrades to Version.
+var migrations = []migration{
+	{from: "0.1", to: "0.2", migrate: migrateAddEncryptionFields},
+	{from: "0.2", to: "0.3", migrate: migrateCoinNames},
+	{from: "0.3", to: "0.4", migrate: migrateNoop},
+	{from: "0.4", to: "0.5", migrate: migrateEntryDerivationFields},
+}
+
+// migrateAddEncryptionFields adds the encryption fields of wallet version 0.2 to unencrypted wallets
+func migrateAddEncryptionFields(f *walletFile) error {
+	if _, ok := f.Meta[metaEncrypted]; !ok {
+		f.Meta[metaEncrypted] = "false"
+	}
+	if _, ok := f.Meta[metaCryptoType]; !ok {
+		f.Meta[metaCryptoType] = ""
+	}
+	if _, ok := f.Meta[metaSecrets]; !ok {
+		f.Meta[metaSecrets] = ""
+	}
+	return nil
+}
+
+// migrateCoinNames replaces the short coin names of older wallets, e.g. "sky", with the full coin names
+func migrateCoinNames(f *walletFile) error {
+	if c := f.Meta[metaCoin]; c != "" {
+		ct, err := ResolveCoinType(c)
+		if err != nil {
+			return err
+		}
+		f.Meta[metaCoin] = string(ct)
+	}
+	return nil
+}
+
+// migrateNoop is used for versions which only added new wallet types
+func migrateNoop(f *walletFile) error {
+	return nil
+}
+
+// migrateEntryDerivationFields sets the account of bip44 wallet entries, and the chain of xpub wallet entries.
+// Wallets created before multiple bip44 accounts and account level xpub keys were supported don't have them.
+// The xpub keys of these xpub wallets are chain nodes, so their xpub level is set to chain level.
+func migrateEntryDerivationFields(f *walletFile) error {
+	var field string
+	switch f.Meta[metaType] {
+	case WalletTypeBip44:
+		field = "account"
+	case WalletTypeXPub:
+		field = "change"
+		if _, ok := f.Meta[metaXPubLevel]; !ok {
+			f.Meta[metaXPubLevel] = string(XPubLevelChain)
+		}
+	default:
+		return nil
+	}
+
+	for _, e := range f.Entries {
+		if !e.has(field) {
+			e.set(field, json.RawMessage("0"))
+		}
+	}
+
+	return nil
+}
+
+// migrateFile upgrades a wallet file to Version. The original file is copied to
+// <filename>.<version>.bak before the file is rewritten. Files of a newer version are refused.
+// An existing backup is reused if it's a copy of the file, e.g. if an earlier migration was interrupted,
+// otherwise the migration fails, so that a different backup is never overwritten.
+// Errors of files which could be read are returned as a migrationError.
+func migrateFile(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var f walletFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+
+	version := f.Meta[metaVersion]
+	if version == Version {
+		return nil
+	}
+
+	if err := migrate(&f); err == ErrWalletVersionUnsupported {
+		return err
+	} else if err != nil {
+		return migrationError{fmt.Errorf("migrate wallet %q from version %q: %v", filename, version, err)}
+	}
+
+	backup := fmt.Sprintf("%s.%s.%s", filename, version, BackupExt)
+	if err := writeMigrationBackup(backup, b); err != nil {
+		return migrationError{fmt.Errorf("backup wallet %q before migrating it: %v", filename, err)}
+	}
+
+	logger.WithField("filename", filename).Infof("Migrated wallet from version %s to %s, backup saved to %s", version, Version, backup)
+
+	mb, err := json.MarshalIndent(f, "", "    ")
+	if err != nil {
+		return migrationError{err}
+	}
+
+	if err := writeFileAtomic(filename, mb, 0600); err != nil {
+		return migrationError{err}
+	}
+
+	return nil
+}
+
+// writeMigrationBackup writes the backup of a wallet file made before migrating it.
+// An existing backup with the same contents is kept, any other existing file is an error.
+func writeMigrationBackup(filename string, b []byte) error {
+	err := writeBackup(filename, b)
+	if !os.IsExist(err) {
+		return err
+	}
+
+	eb, rerr := ioutil.ReadFile(filename)
+	if rerr != nil {
+		return rerr
+	}
+
+	if !bytes.Equal(eb, b) {
+		return err
+	}
+
+	return nil
+}
+
+// migrate applies the migrations of the wallet file's version, up to Version
+func migrate(f *walletFile) error {
+	version := f.Meta[metaVersion]
+	if version == "" {
+		return errors.New("version field not set")
+	}
+
+	newer, err := isNewerVersion(version, Version)
+	if err != nil {
+		return err
+	}
+	if newer {
+		return ErrWalletVersionUnsupported
+	}
+
+	for i, m := range migrations {
+		if m.from != version {
+			continue
+		}
+
+		for _, m := range migrations[i:] {
+			if err := m.apply(f); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("no migration from wallet version %q", version)
+}
+
+func writeBackup(filename string, b []byte) error {
+	bf, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := bf.Write(b); err != nil {
+		bf.Close()
+		return err
+	}
+
+	if err := bf.Sync(); err != nil {
+		bf.Close()
+		return err
+	}
+
+	return bf.Close()
+}
+
+// isNewerVersion returns true if wallet version a is newer than b. Versions are of the form major.minor.
+func isNewerVersion(a, b string) (bool, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i], nil
+		}
+	}
+	return false, nil
+}
+
+func parseVersion(v string) ([2]uint64, error) {
+	var pv [2]uint64
+	parts := strings.Split(v, ".")
+	if len(parts) != 2 {
+		return pv, fmt.Errorf("invalid wallet version %q", v)
+	}
+
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return pv, fmt.Errorf("invalid wallet version %q", v)
+		}
+		pv[i] = n
+	}
+
+	return pv, nil
+}
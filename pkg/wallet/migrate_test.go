@@ -0,0 +1,172 @@
+package wallet
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the wallet migration tests")
+
+// Each migration step has wallet files of its "from" version in testdata/migrations/<from>-<to>,
+// with the expected migrated wallet files in <name>.golden
+func TestMigrations(t *testing.T) {
+	require.Equal(t, Version, migrations[len(migrations)-1].to)
+
+	for i, m := range migrations {
+		if i > 0 {
+			require.Equal(t, migrations[i-1].to, m.from)
+		}
+
+		dir := filepath.Join("testdata", "migrations", m.from+"-"+m.to)
+		files, err := filepath.Glob(filepath.Join(dir, "*.wlt"))
+		require.NoError(t, err)
+		require.NotEmpty(t, files, "missing golden files of the %s to %s migration", m.from, m.to)
+
+		for _, fn := range files {
+			t.Run(filepath.Join(m.from+"-"+m.to, filepath.Base(fn)), func(t *testing.T) {
+				b, err := ioutil.ReadFile(fn)
+				require.NoError(t, err)
+
+				var f walletFile
+				require.NoError(t, json.Unmarshal(b, &f))
+				require.NoError(t, m.apply(&f))
+				require.Equal(t, m.to, f.Meta[metaVersion])
+
+				out, err := json.MarshalIndent(f, "", "    ")
+				require.NoError(t, err)
+				out = append(out, '\n')
+
+				golden := fn + ".golden"
+				if *update {
+					require.NoError(t, ioutil.WriteFile(golden, out, 0644))
+				}
+
+				expected, err := ioutil.ReadFile(golden)
+				require.NoError(t, err)
+				require.Equal(t, string(expected), string(out))
+
+				// A wallet is only migrated from its own version
+				require.Error(t, m.apply(&f))
+			})
+		}
+	}
+}
+
+func TestLoadMigratesWallet(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	// Wallet files of every version are migrated to Version on Load
+	files, err := filepath.Glob(filepath.Join("testdata", "migrations", "*", "*.wlt"))
+	require.NoError(t, err)
+	for _, fn := range files {
+		b, err := ioutil.ReadFile(fn)
+		require.NoError(t, err)
+
+		var f walletFile
+		require.NoError(t, json.Unmarshal(b, &f))
+
+		wltFile := filepath.Join(dir, f.Meta[metaVersion]+"-"+filepath.Base(fn))
+		require.NoError(t, ioutil.WriteFile(wltFile, b, 0600))
+
+		w, err := Load(wltFile)
+		require.NoError(t, err, fn)
+		require.Equal(t, Version, w.Version())
+		require.NoError(t, w.Validate())
+
+		// The original file is backed up and the file is rewritten
+		bak, err := ioutil.ReadFile(wltFile + "." + f.Meta[metaVersion] + ".bak")
+		require.NoError(t, err)
+		require.Equal(t, b, bak)
+
+		var mf walletFile
+		mb, err := ioutil.ReadFile(wltFile)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(mb, &mf))
+		require.Equal(t, Version, mf.Meta[metaVersion])
+
+		// Loading the migrated wallet doesn't migrate it again
+		w2, err := Load(wltFile)
+		require.NoError(t, err)
+		require.Equal(t, w.GetEntries(), w2.GetEntries())
+		mb2, err := ioutil.ReadFile(wltFile)
+		require.NoError(t, err)
+		require.Equal(t, mb, mb2)
+	}
+
+	// xpub wallets of version 0.4 only had chain level xpub keys
+	w, err := Load(filepath.Join(dir, "0.4-xpub.wlt"))
+	require.NoError(t, err)
+	require.Equal(t, XPubLevelChain, w.(*XPubWallet).Meta.XPubLevel())
+	require.Equal(t, "chain", w.(*XPubWallet).Meta[metaXPubLevel])
+	require.NoError(t, VerifyEntries(w))
+	more, err := w.GenerateAddresses(1)
+	require.NoError(t, err)
+	require.Equal(t, "1MNF5RSaabFwcbtJirJwKnDytsXXEsVsNb", more[0].String())
+
+	src := filepath.Join("testdata", "migrations", "0.1-0.2", "deterministic.wlt")
+	b, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+
+	// An existing different backup is never overwritten, and the wallet file isn't replaced by a timestamped backup
+	wltFile := filepath.Join(dir, "backup.wlt")
+	require.NoError(t, ioutil.WriteFile(wltFile, b, 0600))
+	require.NoError(t, ioutil.WriteFile(wltFile+".0.1.bak", []byte("backup"), 0600))
+	w, err = NewWallet("backup.wlt", Options{
+		Type: WalletTypeDeterministic,
+		Seed: testSeed,
+	})
+	require.NoError(t, err)
+	require.NoError(t, Save(w, dir))
+	require.NoError(t, Save(w, dir))
+	_, _, err = loadBackup(wltFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(wltFile, b, 0600))
+	_, err = Load(wltFile)
+	require.Error(t, err)
+	b2, err := ioutil.ReadFile(wltFile)
+	require.NoError(t, err)
+	require.Equal(t, b, b2)
+	bak, err := ioutil.ReadFile(wltFile + ".0.1.bak")
+	require.NoError(t, err)
+	require.Equal(t, []byte("backup"), bak)
+
+	// A backup left by an interrupted migration of the same file is reused
+	require.NoError(t, ioutil.WriteFile(wltFile+".0.1.bak", b, 0600))
+	w, err = Load(wltFile)
+	require.NoError(t, err)
+	require.Equal(t, Version, w.Version())
+
+	// Backups of earlier migrations don't prevent later ones
+	b4, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "0.4-0.5", "bip44.wlt"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(wltFile, b4, 0600))
+	w, err = Load(wltFile)
+	require.NoError(t, err)
+	require.Equal(t, WalletTypeBip44, w.Type())
+	bak, err = ioutil.ReadFile(wltFile + ".0.4.bak")
+	require.NoError(t, err)
+	require.Equal(t, b4, bak)
+
+	// Wallets of a newer version are refused
+	for _, v := range []string{"0.6", "1.0", "0.10"} {
+		var f walletFile
+		require.NoError(t, json.Unmarshal(b, &f))
+		f.Meta[metaVersion] = v
+		nb, err := json.Marshal(f)
+		require.NoError(t, err)
+
+		wltFile := filepath.Join(dir, "v"+v+".wlt")
+		require.NoError(t, ioutil.WriteFile(wltFile, nb, 0600))
+		_, err = Load(wltFile)
+		require.Equal(t, ErrWalletVersionUnsupported, err)
+		_, err = os.Stat(wltFile + "." + v + ".bak")
+		require.True(t, os.IsNotExist(err))
+	}
+}
@@ -0,0 +1,269 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SkycoinProject/skycoin/src/util/file"
+)
+
+// DefaultBackups is the default number of timestamped backups kept of each wallet file
+const DefaultBackups = 5
+
+// backupTimeFormat is the UTC timestamp of backup file names, which sorts in time order
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// SaveWithBackups saves the wallet to a directory. The wallet's filename is read from its metadata.
+//
+// The wallet is written to a temporary file which is synced and renamed over the wallet file,
+// so that a crash or a concurrent save never leaves a partially written wallet file.
+// Before the wallet file is replaced, it is copied to <filename>.<timestamp>.bak, and only the
+// newest backups of the file are kept. Backups are disabled if backups is 0.
+func SaveWithBackups(w Wallet, dir string, backups int) error {
+	if backups < 0 {
+		return NewError(fmt.Errorf("invalid number of wallet backups %d", backups))
+	}
+
+	b, err := json.MarshalIndent(w.ToReadable(), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(dir, w.Filename())
+
+	if backups > 0 {
+		if err := backupFile(filename); err != nil {
+			return fmt.Errorf("backup wallet %q: %v", filename, err)
+		}
+	}
+
+	if err := writeFileAtomic(filename, b, 0600); err != nil {
+		return err
+	}
+
+	if backups > 0 {
+		if err := pruneBackups(filename, backups); err != nil {
+			logger.WithError(err).WithField("filename", filename).Warning("SaveWithBackups: pruneBackups failed")
+		}
+	}
+
+	return nil
+}
+
+// SaveRekeyed saves a wallet whose encryption state, password or crypto type changed, and removes
+// the backups of its file once it's written. No backup of the replaced file is made. Backups hold
+// the wallet's secrets unencrypted, or encrypted with the previous password or crypto type,
+// and would leave them on disk.
+func SaveRekeyed(w Wallet, dir string) error {
+	if err := SaveWithBackups(w, dir, 0); err != nil {
+		return err
+	}
+
+	return RemoveBackups(filepath.Join(dir, w.Filename()))
+}
+
+// RemoveBackups removes the timestamped and migration backups of a wallet file
+func RemoveBackups(filename string) error {
+	dir := filepath.Dir(filename)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	prefix := filepath.Base(filename) + "."
+	suffix := "." + BackupExt
+
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Mode().IsRegular() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		// Match the backup name exactly, so that backups of other wallets which share
+		// the filename prefix are left alone
+		tag := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if _, err := time.Parse(backupTimeFormat, tag); err != nil {
+			if _, err := parseVersion(tag); err != nil {
+				continue
+			}
+		}
+
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return syncDir(dir)
+}
+
+// writeFileAtomic replaces filename with data. The data is written to a temporary file
+// in the same directory, which is synced, renamed to filename, and then the directory is synced.
+func writeFileAtomic(filename string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(filename)
+	f, err := ioutil.TempFile(dir, filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+
+	removeTmp := func() {
+		if err := os.Remove(tmpname); err != nil {
+			logger.WithError(err).WithField("filename", tmpname).Warning("writeFileAtomic: os.Remove failed")
+		}
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		removeTmp()
+		return err
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		removeTmp()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		removeTmp()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		removeTmp()
+		return err
+	}
+
+	if err := os.Rename(tmpname, filename); err != nil {
+		removeTmp()
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir syncs a directory, so that renames in it are durable.
+// Directories can't be synced on windows, where renames are durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
+}
+
+// backupFile copies filename to a new timestamped backup file, if filename exists
+func backupFile(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s.%s", filename, time.Now().UTC().Format(backupTimeFormat), BackupExt)
+	return writeBackup(backup, b)
+}
+
+// listBackups returns the timestamped backup files of filename, newest first
+func listBackups(filename string) ([]string, error) {
+	dir := filepath.Dir(filename)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(filename) + "."
+	suffix := "." + BackupExt
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Mode().IsRegular() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if _, err := time.Parse(backupTimeFormat, ts); err != nil {
+			continue
+		}
+
+		backups = append(backups, filepath.Join(dir, name))
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	return backups, nil
+}
+
+// pruneBackups removes the backup files of filename, except for the newest n
+func pruneBackups(filename string, n int) error {
+	backups, err := listBackups(filename)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= n {
+		return nil
+	}
+
+	for _, b := range backups[n:] {
+		if err := os.Remove(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadBackup loads the newest valid backup of a wallet file, and returns the name of the backup file.
+// Backups of an older wallet version are skipped, and are left on disk to be recovered manually.
+func loadBackup(filename string) (Wallet, string, error) {
+	backups, err := listBackups(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, b := range backups {
+		var m struct {
+			Meta map[string]string `json:"meta"`
+		}
+		if err := file.LoadJSON(b, &m); err != nil {
+			logger.WithError(err).WithField("filename", b).Warning("loadBackup: invalid backup file")
+			continue
+		}
+
+		if v := m.Meta[metaVersion]; v != Version {
+			logger.WithField("filename", b).Warningf("loadBackup: skipping backup of wallet version %q", v)
+			continue
+		}
+
+		w, err := readFile(b, filepath.Base(filename))
+		if err != nil {
+			logger.WithError(err).WithField("filename", b).Warning("loadBackup: invalid backup file")
+			continue
+		}
+
+		return w, b, nil
+	}
+
+	return nil, "", fmt.Errorf("no valid backup of wallet %q", filename)
+}
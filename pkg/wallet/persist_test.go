@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveWithBackups(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	filename := filepath.Join(dir, w.Filename())
+
+	// The first save has nothing to back up
+	require.NoError(t, SaveWithBackups(w, dir, 2))
+	backups, err := listBackups(filename)
+	require.NoError(t, err)
+	require.Empty(t, backups)
+
+	// Each save backs up the previous file, keeping the newest backups
+	var saved [][]byte
+	for i := 0; i < 4; i++ {
+		b, err := ioutil.ReadFile(filename)
+		require.NoError(t, err)
+		saved = append(saved, b)
+
+		_, err = w.(*Bip44Wallet).GenerateAddresses(1)
+		require.NoError(t, err)
+		require.NoError(t, SaveWithBackups(w, dir, 2))
+	}
+
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	for i, b := range backups {
+		d, err := ioutil.ReadFile(b)
+		require.NoError(t, err)
+		require.Equal(t, saved[len(saved)-1-i], d)
+	}
+
+	fi, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		require.False(t, strings.HasSuffix(e.Name(), ".tmp"), e.Name())
+	}
+
+	lw, err := Load(filename)
+	require.NoError(t, err)
+	require.Equal(t, w.GetEntries(), lw.GetEntries())
+
+	// Saving without backups leaves the existing backups untouched
+	require.NoError(t, SaveWithBackups(w, dir, 0))
+	nb, err := listBackups(filename)
+	require.NoError(t, err)
+	require.Equal(t, backups, nb)
+
+	require.Error(t, SaveWithBackups(w, dir, -1))
+}
+
+func TestLoadFallsBackToBackup(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	filename := filepath.Join(dir, w.Filename())
+
+	// A corrupt wallet file without backups fails to load
+	require.NoError(t, SaveWithBackups(w, dir, 3))
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{"meta":{`), 0600))
+	_, err = Load(filename)
+	require.Error(t, err)
+
+	require.NoError(t, SaveWithBackups(w, dir, 3))
+	entries := w.GetEntries()
+	_, err = w.(*Bip44Wallet).GenerateAddresses(1)
+	require.NoError(t, err)
+	require.NoError(t, SaveWithBackups(w, dir, 3))
+
+	// The wallet file is loaded while it's valid
+	lw, err := Load(filename)
+	require.NoError(t, err)
+	require.Equal(t, w.GetEntries(), lw.GetEntries())
+
+	// A corrupt wallet file is replaced by its newest valid backup
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{"meta":{`), 0600))
+	lw, err = Load(filename)
+	require.NoError(t, err)
+	require.Equal(t, entries, lw.GetEntries())
+	require.Equal(t, w.Filename(), lw.Filename())
+
+	// Corrupt backups and backups of other wallet versions are skipped.
+	// The older backup is the corrupt file that was saved over.
+	backups, err := listBackups(filename)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	b, err := ioutil.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(backups[0], []byte(strings.Replace(string(b), `"version": "`+Version+`"`, `"version": "0.4"`, 1)), 0600))
+
+	_, err = Load(filename)
+	require.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+)
+
+// ErrPSBTCoin is returned when updating a PSBT with the entries of a wallet which isn't a bitcoin wallet
+var ErrPSBTCoin = NewError(errors.New("psbts can only be used with bitcoin wallets"))
+
+// MasterFingerprint returns the bip32 fingerprint of the wallet's master key, the first 4 bytes of the hash160 of its public key.
+// The wallet must be decrypted.
+func (w *Bip44Wallet) MasterFingerprint() ([4]byte, error) {
+	if w.IsEncrypted() {
+		return [4]byte{}, ErrWalletEncrypted
+	}
+
+	seed, err := w.seed()
+	if err != nil {
+		return [4]byte{}, err
+	}
+
+	k, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return [4]byte{}, err
+	}
+
+	var fp [4]byte
+	copy(fp[:], k.Fingerprint())
+	return fp, nil
+}
+
+// UpdatePSBT adds the information needed to sign the inputs of a PSBT which spend the wallet's addresses,
+// and to check its change outputs: the redeem scripts of P2SH-P2WPKH addresses, and the bip32 derivation
+// paths of the keys of decrypted bip44 wallets.
+func UpdatePSBT(w Wallet, p *btc.PSBT) error {
+	if w.Coin() != CoinTypeBitcoin {
+		return ErrPSBTCoin
+	}
+
+	var fingerprint *[4]byte
+	entryPath := func(Entry) string { return "" }
+	if bw, ok := w.(*Bip44Wallet); ok && !bw.IsEncrypted() {
+		fp, err := bw.MasterFingerprint()
+		if err != nil {
+			return err
+		}
+		fingerprint = &fp
+		entryPath = bw.EntryPath
+	}
+
+	entries := make(map[string]Entry)
+	for _, e := range w.GetEntries() {
+		script, err := btc.PayToAddrScript(e.Address)
+		if err != nil {
+			continue
+		}
+		entries[string(script)] = e
+	}
+
+	derivation := func(e Entry) (btc.Bip32Derivation, bool) {
+		if fingerprint == nil {
+			return btc.Bip32Derivation{}, false
+		}
+
+		path, err := parseHDKeyPath(entryPath(e))
+		if err != nil {
+			return btc.Bip32Derivation{}, false
+		}
+
+		d := btc.Bip32Derivation{
+			PubKey:            append([]byte(nil), e.Public[:]...),
+			MasterFingerprint: *fingerprint,
+		}
+		for _, n := range path.Elements {
+			if !n.Master {
+				d.Path = append(d.Path, n.ChildNumber)
+			}
+		}
+		return d, true
+	}
+
+	for i := range p.Inputs {
+		utxo, err := p.Utxo(i)
+		if err != nil {
+			continue
+		}
+
+		e, ok := entries[string(utxo.PkScript)]
+		if !ok {
+			continue
+		}
+
+		in := &p.Inputs[i]
+		if _, ok := e.Address.(btc.NestedSegwitAddress); ok && in.RedeemScript == nil {
+			in.RedeemScript = btc.NestedSegwitRedeemScript(e.Public)
+		}
+
+		if d, ok := derivation(e); ok {
+			in.AddBip32Derivation(d)
+		}
+	}
+
+	for i, txOut := range p.UnsignedTx.TxOut {
+		e, ok := entries[string(txOut.PkScript)]
+		if !ok {
+			continue
+		}
+
+		out := &p.Outputs[i]
+		if _, ok := e.Address.(btc.NestedSegwitAddress); ok && out.RedeemScript == nil {
+			out.RedeemScript = btc.NestedSegwitRedeemScript(e.Public)
+		}
+
+		if d, ok := derivation(e); ok {
+			out.AddBip32Derivation(d)
+		}
+	}
+
+	return nil
+}
+
+// SignPSBT signs the P2PKH, P2WPKH and P2SH-P2WPKH inputs of a PSBT spent by the keys of a Signer,
+// adding their partial signatures, and returns the number of inputs signed.
+// The keys are looked up by the bip32 derivation paths of the inputs (see UpdatePSBT), and by the addresses
+// they spend for signers which identify keys by address. Inputs signed with the same key already are skipped.
+// The signatures are requested with a single SignTx call.
+func SignPSBT(s Signer, p *btc.PSBT) (int, error) {
+	type inputKey struct {
+		input  int
+		pubKey cipher.PubKey
+	}
+
+	var reqs []SignRequest
+	var keys []inputKey
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinalized() {
+			continue
+		}
+
+		_, h, err := p.InputScriptType(i)
+		if err != nil {
+			// Inputs of other signers may spend outputs we can't sign, e.g. multisig or taproot outputs
+			continue
+		}
+
+		paths := make([]string, 0, len(in.Bip32Derivation)+1)
+		for _, d := range in.Bip32Derivation {
+			paths = append(paths, formatBip32Path(d.Path))
+		}
+		if utxo, err := p.Utxo(i); err == nil {
+			if addr, err := btc.ScriptAddress(utxo.PkScript); err == nil {
+				paths = append(paths, addr.String())
+			}
+		}
+
+		for j, path := range paths {
+			pk, err := s.PublicKey(path)
+			switch err {
+			case nil:
+			case ErrUnknownKeyPath, ErrWalletWatchOnly:
+				continue
+			default:
+				return 0, err
+			}
+
+			if cipher.BitcoinPubKeyRipemd160(pk) != h {
+				continue
+			}
+
+			if j < len(in.Bip32Derivation) && !bytes.Equal(in.Bip32Derivation[j].PubKey, pk[:]) {
+				continue
+			}
+
+			if in.HasPartialSig(pk[:]) {
+				break
+			}
+
+			digest, err := p.SigHash(i)
+			if err != nil {
+				return 0, NewError(fmt.Errorf("input %d: %v", i, err))
+			}
+
+			reqs = append(reqs, SignRequest{
+				Path:   path,
+				Digest: digest,
+			})
+			keys = append(keys, inputKey{
+				input:  i,
+				pubKey: pk,
+			})
+			break
+		}
+	}
+
+	if len(reqs) == 0 {
+		return 0, nil
+	}
+
+	sigs, err := s.SignTx(reqs)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, k := range keys {
+		p.AddPartialSig(k.input, k.pubKey, sigs[i])
+	}
+
+	return len(reqs), nil
+}
+
+// formatBip32Path formats the nodes of a bip32 path as m/84'/0'/0'/0/1
+func formatBip32Path(nodes []uint32) string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, n := range nodes {
+		if n >= bip32.FirstHardenedChild {
+			fmt.Fprintf(&sb, "/%d'", n-bip32.FirstHardenedChild)
+		} else {
+			fmt.Fprintf(&sb, "/%d", n)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,294 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+)
+
+// newTestPSBTWallet creates a bitcoin bip44 wallet of an address type with 2 external entries and a change entry
+func newTestPSBTWallet(t *testing.T, seed string, addressType AddressType) *Bip44Wallet {
+	w, err := NewWallet("test.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeBitcoin,
+		Seed:        seed,
+		AddressType: addressType,
+		GenerateN:   2,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+	_, err = bw.GenerateChangeEntry(0)
+	require.NoError(t, err)
+	return bw
+}
+
+func TestSignPSBT(t *testing.T) {
+	p2wpkh := newTestPSBTWallet(t, testVectorSeed, AddressTypeP2WPKH)
+	nested := newTestPSBTWallet(t, testSeed, AddressTypeP2SHP2WPKH)
+	p2pkh := newTestPSBTWallet(t, testSeed, AddressTypeP2PKH)
+
+	// The wallets' addresses are funded by a previous transaction, whose outputs are spent
+	// to the first address of the P2WPKH wallet, bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu in bip84, and its change address
+	spent := []Entry{p2wpkh.GetEntryAt(1), nested.GetEntryAt(0), p2pkh.GetEntryAt(1)}
+	prevTx := wire.NewMsgTx(wire.TxVersion)
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), []byte{0x00}, nil))
+	for i, e := range spent {
+		script, err := btc.PayToAddrScript(e.Address)
+		require.NoError(t, err)
+		prevTx.AddTxOut(wire.NewTxOut(int64(i+1)*100000, script))
+	}
+	prevHash := prevTx.TxHash()
+
+	tx := wire.NewMsgTx(2)
+	for i := range spent {
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, uint32(i)), nil, nil))
+	}
+	addr, err := btc.DecodeAddress("bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu")
+	require.NoError(t, err)
+	addrScript, err := btc.PayToAddrScript(addr)
+	require.NoError(t, err)
+	_, change := p2wpkh.GetAccountEntries(0)
+	changeScript, err := btc.PayToAddrScript(change[0].Address)
+	require.NoError(t, err)
+	tx.AddTxOut(wire.NewTxOut(500000, addrScript))
+	tx.AddTxOut(wire.NewTxOut(90000, changeScript))
+
+	p, err := btc.NewPSBT(tx)
+	require.NoError(t, err)
+	for i := range spent {
+		if i == 2 {
+			p.Inputs[i].NonWitnessUtxo = prevTx
+		} else {
+			p.Inputs[i].WitnessUtxo = prevTx.TxOut[i]
+		}
+	}
+	creator, err := p.B64Encode()
+	require.NoError(t, err)
+
+	// Each wallet signs its own input of a copy of the PSBT
+	signed := make([]*btc.PSBT, len(spent))
+	for i, w := range []*Bip44Wallet{p2wpkh, nested, p2pkh} {
+		p, err := btc.DecodePSBT(creator)
+		require.NoError(t, err)
+
+		require.NoError(t, UpdatePSBT(w, p))
+		n, err := SignPSBT(w, p)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Len(t, p.Inputs[i].PartialSigs, 1)
+
+		// Signing again doesn't add signatures
+		n, err = SignPSBT(w, p)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		s, err := p.B64Encode()
+		require.NoError(t, err)
+		signed[i], err = btc.DecodePSBT(s)
+		require.NoError(t, err)
+	}
+
+	// The abandon ... about seed's derivation info matches bip84
+	bip84Account := []uint32{84 + bip32.FirstHardenedChild, bip32.FirstHardenedChild, bip32.FirstHardenedChild}
+	d := signed[0].Inputs[0].Bip32Derivation
+	require.Len(t, d, 1)
+	require.Equal(t, "73c5da0a", hex.EncodeToString(d[0].MasterFingerprint[:]))
+	require.Equal(t, append(bip84Account, 0, 1), d[0].Path)
+	require.Equal(t, spent[0].Public[:], d[0].PubKey)
+	d = signed[0].Outputs[0].Bip32Derivation
+	require.Len(t, d, 1)
+	require.Equal(t, append(bip84Account, 0, 0), d[0].Path)
+	require.Equal(t, "0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c", hex.EncodeToString(d[0].PubKey))
+	d = signed[0].Outputs[1].Bip32Derivation
+	require.Len(t, d, 1)
+	require.Equal(t, append(bip84Account, 1, 0), d[0].Path)
+	require.Empty(t, signed[1].Outputs[0].Bip32Derivation)
+	require.Equal(t, btc.NestedSegwitRedeemScript(spent[1].Public), signed[1].Inputs[1].RedeemScript)
+
+	// The digests signed by each wallet, checked against the extracted transaction's signatures
+	digests := make([]cipher.SHA256, len(spent))
+	for i := range spent {
+		digests[i], err = signed[i].SigHash(i)
+		require.NoError(t, err)
+	}
+
+	// Inputs can't be finalized before they're signed
+	partial, err := signed[0].B64Encode()
+	require.NoError(t, err)
+	p, err = btc.DecodePSBT(partial)
+	require.NoError(t, err)
+	require.Error(t, p.Finalize())
+	require.True(t, p.Inputs[0].IsFinalized())
+	require.False(t, p.Inputs[1].IsFinalized())
+	_, err = p.Extract()
+	require.Equal(t, btc.ErrPSBTNotFinalized, err)
+
+	p, err = btc.DecodePSBT(creator)
+	require.NoError(t, err)
+	require.NoError(t, p.Combine(signed...))
+	for i := range spent {
+		require.Len(t, p.Inputs[i].PartialSigs, 1)
+	}
+	require.NoError(t, p.Finalize())
+
+	signedTx, err := p.Extract()
+	require.NoError(t, err)
+	unsignedTx := signedTx.Copy()
+	for _, in := range unsignedTx.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+	}
+	require.Equal(t, tx.TxHash(), unsignedTx.TxHash())
+
+	checkSig := func(i int, sig, pubKey []byte) {
+		require.Equal(t, byte(btc.SigHashAll), sig[len(sig)-1])
+		s, err := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+		require.NoError(t, err)
+		pk, err := btcec.ParsePubKey(pubKey, btcec.S256())
+		require.NoError(t, err)
+		require.True(t, s.Verify(digests[i][:], pk))
+		require.Equal(t, spent[i].Public[:], pubKey)
+	}
+
+	// P2WPKH: empty scriptSig, witness [sig, pubkey]
+	require.Empty(t, signedTx.TxIn[0].SignatureScript)
+	require.Len(t, signedTx.TxIn[0].Witness, 2)
+	checkSig(0, signedTx.TxIn[0].Witness[0], signedTx.TxIn[0].Witness[1])
+
+	// P2SH-P2WPKH: scriptSig pushes the redeem script, witness [sig, pubkey]
+	require.Equal(t, btc.PushData(btc.NestedSegwitRedeemScript(spent[1].Public)), signedTx.TxIn[1].SignatureScript)
+	require.Len(t, signedTx.TxIn[1].Witness, 2)
+	checkSig(1, signedTx.TxIn[1].Witness[0], signedTx.TxIn[1].Witness[1])
+
+	// P2PKH: scriptSig <sig> <pubkey>
+	require.Empty(t, signedTx.TxIn[2].Witness)
+	script := signedTx.TxIn[2].SignatureScript
+	sigLen := int(script[0])
+	checkSig(2, script[1:1+sigLen], script[2+sigLen:])
+	require.Equal(t, len(cipher.PubKey{}), int(script[1+sigLen]))
+
+	// PSBTs of other transactions can't be combined
+	other, err := btc.DecodePSBT(creator)
+	require.NoError(t, err)
+	other.UnsignedTx.LockTime = 1
+	require.Equal(t, btc.ErrPSBTTxMismatch, p.Combine(other))
+
+	// P2PKH inputs are only signed with their previous transaction
+	p, err = btc.DecodePSBT(creator)
+	require.NoError(t, err)
+	p.Inputs[2].NonWitnessUtxo = nil
+	p.Inputs[2].WitnessUtxo = prevTx.TxOut[2]
+	require.NoError(t, UpdatePSBT(p2pkh, p))
+	_, err = SignPSBT(p2pkh, p)
+	require.Error(t, err)
+
+	// Wallets of other coins can't update PSBTs
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeSkycoin,
+		Seed: testSeed,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ErrPSBTCoin, UpdatePSBT(w, p))
+}
+
+func TestSignPSBTCollectionWallet(t *testing.T) {
+	// The keys of two HD wallets imported from a dump have the same HD key paths,
+	// like the keys of a Bitcoin Core wallet's previous seeds
+	var spent []Entry
+	var dump DumpWallet
+	for _, seed := range []string{testVectorSeed, testSeed} {
+		bw := newTestPSBTWallet(t, seed, AddressTypeP2WPKH)
+		d, err := NewDumpWallet(bw)
+		require.NoError(t, err)
+		dump.Entries = append(dump.Entries, d.Entries...)
+		spent = append(spent, bw.GetEntryAt(1))
+	}
+	w, err := NewWalletFromDump("dump.wlt", &dump, Options{
+		AddressType: AddressTypeP2WPKH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, w.GetEntryAt(1).HDKeyPath, w.GetEntryAt(4).HDKeyPath)
+
+	prevTx := wire.NewMsgTx(wire.TxVersion)
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), []byte{0x00}, nil))
+	for i, e := range spent {
+		script, err := btc.PayToAddrScript(e.Address)
+		require.NoError(t, err)
+		prevTx.AddTxOut(wire.NewTxOut(int64(i+1)*100000, script))
+	}
+	prevHash := prevTx.TxHash()
+
+	tx := wire.NewMsgTx(2)
+	for i := range spent {
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, uint32(i)), nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(290000, prevTx.TxOut[0].PkScript))
+
+	p, err := btc.NewPSBT(tx)
+	require.NoError(t, err)
+	for i := range spent {
+		p.Inputs[i].WitnessUtxo = prevTx.TxOut[i]
+	}
+
+	// The inputs' keys are found by the addresses of their previous outputs
+	require.NoError(t, UpdatePSBT(w, p))
+	n, err := SignPSBT(w.(Signer), p)
+	require.NoError(t, err)
+	require.Equal(t, len(spent), n)
+	for i, e := range spent {
+		require.Len(t, p.Inputs[i].PartialSigs, 1)
+		require.Equal(t, e.Public[:], p.Inputs[i].PartialSigs[0].PubKey)
+	}
+	require.NoError(t, p.Finalize())
+}
+
+func TestServiceSignPSBT(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeBitcoin,
+		Seed:        testVectorSeed,
+		AddressType: AddressTypeP2WPKH,
+		Encrypt:     true,
+		Password:    []byte("pwd"),
+		GenerateN:   2,
+	})
+	require.NoError(t, err)
+
+	script, err := btc.PayToAddrScript(w.GetEntryAt(1).Address)
+	require.NoError(t, err)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(90000, script))
+	p, err := btc.NewPSBT(tx)
+	require.NoError(t, err)
+	p.Inputs[0].WitnessUtxo = wire.NewTxOut(100000, script)
+
+	_, err = s.SignPSBT(w.Filename(), nil, p)
+	require.Equal(t, ErrMissingPassword, err)
+
+	n, err := s.SignPSBT(w.Filename(), []byte("pwd"), p)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, p.Inputs[0].PartialSigs, 1)
+	require.Len(t, p.Inputs[0].Bip32Derivation, 1)
+	require.NoError(t, p.Finalize())
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
 	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
 
 	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
 	"github.com/SkycoinProject/skycoin/src/cipher/bip44"
 )
 
@@ -16,7 +18,10 @@ type ReadableEntry struct {
 	Public      string  `json:"public_key"`
 	Secret      string  `json:"secret_key"`
 	ChildNumber *uint32 `json:"child_number,omitempty"` // For bip32/bip44
-	Change      *uint32 `json:"change,omitempty"`       // For bip44
+	Change      *uint32 `json:"change,omitempty"`       // For bip44/xpub
+	Account     *uint32 `json:"account,omitempty"`      // For bip44
+	Label       string  `json:"label,omitempty"`        // For collection wallets
+	HDKeyPath   string  `json:"hd_key_path,omitempty"`  // For collection wallets
 }
 
 // NewReadableEntry creates readable wallet entry
@@ -30,6 +35,20 @@ func NewReadableEntry(coinType CoinType, walletType string, e Entry) ReadableEnt
 		re.Public = e.Public.Hex()
 	}
 
+	if e.Label != "" {
+		if walletType != WalletTypeCollection {
+			logger.Panicf("wallet.Entry.Label is set but wallet type is %q", walletType)
+		}
+		re.Label = e.Label
+	}
+
+	if e.HDKeyPath != "" {
+		if walletType != WalletTypeCollection {
+			logger.Panicf("wallet.Entry.HDKeyPath is set but wallet type is %q", walletType)
+		}
+		re.HDKeyPath = e.HDKeyPath
+	}
+
 	if !e.Secret.Null() {
 		switch coinType {
 		case CoinTypeSkycoin:
@@ -49,11 +68,15 @@ func NewReadableEntry(coinType CoinType, walletType string, e Entry) ReadableEnt
 		re.ChildNumber = &cn
 		change := e.Change
 		re.Change = &change
+		account := e.Account
+		re.Account = &account
 	case WalletTypeXPub:
 		cn := e.ChildNumber
 		re.ChildNumber = &cn
-		if e.Change != 0 {
-			logger.Panicf("wallet.Entry.Change is not 0 but wallet type is %q", walletType)
+		change := e.Change
+		re.Change = &change
+		if e.Account != 0 {
+			logger.Panicf("wallet.Entry.Account is not 0 but wallet type is %q", walletType)
 		}
 	default:
 		if e.ChildNumber != 0 {
@@ -62,6 +85,9 @@ func NewReadableEntry(coinType CoinType, walletType string, e Entry) ReadableEnt
 		if e.Change != 0 {
 			logger.Panicf("wallet.Entry.Change is not 0 but wallet type is %q", walletType)
 		}
+		if e.Account != 0 {
+			logger.Panicf("wallet.Entry.Account is not 0 but wallet type is %q", walletType)
+		}
 	}
 
 	return re
@@ -105,29 +131,50 @@ func (res ReadableEntries) toWalletEntries(coinType CoinType, walletType string,
 	return entries, nil
 }
 
-// newEntryFromReadable creates WalletEntry base one ReadableWalletEntry
-func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntry) (*Entry, error) {
-	var a cipher.Addresser
-	var err error
-
+// DecodeAddress decodes an address of a coin
+func DecodeAddress(coinType CoinType, addr string) (cipher.Addresser, error) {
 	switch coinType {
 	case CoinTypeSkycoin:
-		a, err = cipher.DecodeBase58Address(re.Address)
+		return cipher.DecodeBase58Address(addr)
 	case CoinTypeBitcoin:
-		a, err = cipher.DecodeBase58BitcoinAddress(re.Address)
+		return btc.DecodeAddress(addr)
 	case CoinTypeEthereum:
-		a = eth.DecodeHexToEthereumAddress(re.Address)
+		return eth.DecodeEthereumAddress(addr)
 	default:
-		logger.Panicf("Invalid coin type %q", coinType)
+		return nil, ErrInvalidCoinType
 	}
+}
 
+// newEntryFromReadable creates WalletEntry base one ReadableWalletEntry
+func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntry) (*Entry, error) {
+	a, err := DecodeAddress(coinType, re.Address)
 	if err != nil {
+		if err == ErrInvalidCoinType {
+			logger.Panicf("Invalid coin type %q", coinType)
+		}
 		return nil, err
 	}
 
-	p, err := cipher.PubKeyFromHex(re.Public)
-	if err != nil {
-		return nil, err
+	// Entries of watch-only collection wallets only have an address
+	var p cipher.PubKey
+	if re.Public != "" || re.Secret != "" || walletType != WalletTypeCollection {
+		p, err = cipher.PubKeyFromHex(re.Public)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if re.Label != "" && walletType != WalletTypeCollection {
+		return nil, fmt.Errorf("label should not be set for %q wallet type", walletType)
+	}
+
+	if re.HDKeyPath != "" {
+		if walletType != WalletTypeCollection {
+			return nil, fmt.Errorf("hd_key_path should not be set for %q wallet type", walletType)
+		}
+		if _, err := parseHDKeyPath(re.HDKeyPath); err != nil {
+			return nil, fmt.Errorf("invalid hd_key_path %q: %v", re.HDKeyPath, err)
+		}
 	}
 
 	// Decodes the secret hex string if any
@@ -150,6 +197,7 @@ func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntr
 
 	var childNumber uint32
 	var change uint32
+	var account uint32
 	switch walletType {
 	case WalletTypeBip44:
 		if re.ChildNumber == nil {
@@ -168,6 +216,15 @@ func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntr
 			return nil, errors.New("change must be either 0 or 1")
 		}
 
+		// Wallets created before multiple accounts were supported
+		// don't have an account field, and only use account 0
+		if re.Account != nil {
+			account = *re.Account
+			if account >= bip32.FirstHardenedChild {
+				return nil, bip44.ErrInvalidAccount
+			}
+		}
+
 	case WalletTypeXPub:
 		if re.ChildNumber == nil {
 			return nil, fmt.Errorf("child_number required for %q wallet type", walletType)
@@ -175,8 +232,19 @@ func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntr
 
 		childNumber = *re.ChildNumber
 
+		// Wallets created before account level xpub keys were supported
+		// don't have a change field, and only use the external chain
 		if re.Change != nil {
-			return nil, fmt.Errorf("change should not be set for %q wallet type", walletType)
+			change = *re.Change
+			switch change {
+			case bip44.ExternalChainIndex, bip44.ChangeChainIndex:
+			default:
+				return nil, errors.New("change must be either 0 or 1")
+			}
+		}
+
+		if re.Account != nil {
+			return nil, fmt.Errorf("account should not be set for %q wallet type", walletType)
 		}
 
 	default:
@@ -186,6 +254,9 @@ func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntr
 		if re.Change != nil {
 			return nil, fmt.Errorf("change should not be set for %q wallet type", walletType)
 		}
+		if re.Account != nil {
+			return nil, fmt.Errorf("account should not be set for %q wallet type", walletType)
+		}
 	}
 
 	return &Entry{
@@ -194,6 +265,9 @@ func newEntryFromReadable(coinType CoinType, walletType string, re *ReadableEntr
 		Secret:      secret,
 		ChildNumber: childNumber,
 		Change:      change,
+		Account:     account,
+		Label:       re.Label,
+		HDKeyPath:   re.HDKeyPath,
 	}, nil
 }
 
@@ -0,0 +1,433 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// The remote signer protocol is a sequence of request and response messages over a stream connection,
+// usually a Unix socket. Each message is a JSON object prefixed with its length, as a 4 byte big-endian integer.
+// A connection may be used for any number of requests, each request is answered before the next one is read.
+
+// MaxSignerMessageSize is the maximum size of a remote signer protocol message
+const MaxSignerMessageSize = 1024 * 1024
+
+// DefaultSignerTimeout is the default timeout of a RemoteSigner request
+const DefaultSignerTimeout = 30 * time.Second
+
+// Remote signer protocol methods
+const (
+	signerMethodPublicKey  = "public_key"
+	signerMethodSignDigest = "sign_digest"
+	signerMethodSignTx     = "sign_tx"
+)
+
+type signerRequest struct {
+	Method string              `json:"method"`
+	Inputs []signerRequestItem `json:"inputs"`
+}
+
+type signerRequestItem struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest,omitempty"`
+}
+
+type signerResponse struct {
+	Error      string   `json:"error,omitempty"`
+	PublicKey  string   `json:"public_key,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+// writeSignerMessage writes a length-prefixed JSON message
+func writeSignerMessage(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if len(b) > MaxSignerMessageSize {
+		return errors.New("signer message too large")
+	}
+
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// readSignerMessage reads a length-prefixed JSON message
+func readSignerMessage(r io.Reader, v interface{}) error {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return err
+	}
+
+	n := binary.BigEndian.Uint32(length[:])
+	if n > MaxSignerMessageSize {
+		return errors.New("signer message too large")
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// RemoteSigner is a Signer which forwards requests to a signer process listening on a Unix socket, see SignerServer.
+// The process using a RemoteSigner never has the secret keys in memory.
+type RemoteSigner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewRemoteSigner creates a RemoteSigner for the signer process listening on a Unix socket
+func NewRemoteSigner(socket string) *RemoteSigner {
+	return &RemoteSigner{
+		network: "unix",
+		address: socket,
+		timeout: DefaultSignerTimeout,
+	}
+}
+
+// PublicKey returns the public key at a derivation path
+func (s *RemoteSigner) PublicKey(path string) (cipher.PubKey, error) {
+	resp, err := s.do(signerRequest{
+		Method: signerMethodPublicKey,
+		Inputs: []signerRequestItem{{Path: path}},
+	})
+	if err != nil {
+		return cipher.PubKey{}, err
+	}
+
+	return cipher.PubKeyFromHex(resp.PublicKey)
+}
+
+// SignDigest signs a digest with the key at a derivation path
+func (s *RemoteSigner) SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error) {
+	sigs, err := s.sign(signerMethodSignDigest, []SignRequest{{Path: path, Digest: digest}})
+	if err != nil {
+		return cipher.Sig{}, err
+	}
+	return sigs[0], nil
+}
+
+// SignTx signs the input digests of a transaction, returning a signature for each request
+func (s *RemoteSigner) SignTx(reqs []SignRequest) ([]cipher.Sig, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	return s.sign(signerMethodSignTx, reqs)
+}
+
+func (s *RemoteSigner) sign(method string, reqs []SignRequest) ([]cipher.Sig, error) {
+	req := signerRequest{
+		Method: method,
+		Inputs: make([]signerRequestItem, len(reqs)),
+	}
+	for i, r := range reqs {
+		req.Inputs[i] = signerRequestItem{
+			Path:   r.Path,
+			Digest: r.Digest.Hex(),
+		}
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Signatures) != len(reqs) {
+		return nil, fmt.Errorf("remote signer returned %d signatures for %d digests", len(resp.Signatures), len(reqs))
+	}
+
+	sigs := make([]cipher.Sig, len(reqs))
+	for i, sh := range resp.Signatures {
+		sig, err := cipher.SigFromHex(sh)
+		if err != nil {
+			return nil, fmt.Errorf("remote signer returned an invalid signature: %v", err)
+		}
+		sigs[i] = sig
+	}
+
+	return sigs, nil
+}
+
+// do sends a request over a new connection and reads its response
+func (s *RemoteSigner) do(req signerRequest) (*signerResponse, error) {
+	conn, err := net.DialTimeout(s.network, s.address, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return nil, err
+	}
+
+	if err := writeSignerMessage(conn, req); err != nil {
+		return nil, err
+	}
+
+	var resp signerResponse
+	if err := readSignerMessage(conn, &resp); err != nil {
+		return nil, err
+	}
+
+	switch resp.Error {
+	case "":
+	case ErrUnknownKeyPath.Error():
+		// Keep the error identity, so that callers can skip the keys the signer doesn't have
+		return nil, ErrUnknownKeyPath
+	default:
+		return nil, NewError(fmt.Errorf("remote signer: %s", resp.Error))
+	}
+
+	return &resp, nil
+}
+
+// SignerServer serves the requests of RemoteSigners with a Signer, such as a decrypted wallet
+type SignerServer struct {
+	signer Signer
+
+	sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewSignerServer creates a SignerServer
+func NewSignerServer(s Signer) *SignerServer {
+	return &SignerServer{
+		signer: s,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on a Unix socket, which is only accessible to the user of the process, and serves requests.
+// A socket left behind by a previous server is removed, other files at the socket path are an error.
+// The socket is removed when the server is closed.
+func (srv *SignerServer) ListenAndServe(socket string) error {
+	if err := removeSocket(socket); err != nil {
+		return err
+	}
+
+	l, err := listenPrivate(socket)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := removeSocket(socket); err != nil {
+			logger.WithError(err).WithField("socket", socket).Warning("SignerServer: removeSocket failed")
+		}
+	}()
+
+	return srv.Serve(l)
+}
+
+// removeSocket removes a Unix socket file, if it exists. Files which aren't sockets aren't removed.
+func removeSocket(socket string) error {
+	fi, err := os.Lstat(socket)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%q exists and is not a socket", socket)
+	}
+
+	return os.Remove(socket)
+}
+
+// listenPrivate listens on a Unix socket which is only accessible to the user of the process.
+// The socket is created in a new directory only accessible to the user, where nobody else can connect to it
+// before its permissions are restricted, then it's moved to its path.
+func listenPrivate(socket string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(socket), "."+filepath.Base(socket)+".")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.WithError(err).WithField("dir", dir).Warning("listenPrivate: os.RemoveAll failed")
+		}
+	}()
+
+	tmp := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The socket is moved, so the listener can't unlink it on close
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, socket); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Serve serves requests on a listener, until Close is called
+func (srv *SignerServer) Serve(l net.Listener) error {
+	srv.Lock()
+	if srv.closed {
+		srv.Unlock()
+		l.Close()
+		return errors.New("signer server is closed")
+	}
+	srv.listener = l
+	srv.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.Lock()
+			closed := srv.closed
+			srv.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		if !srv.track(conn) {
+			conn.Close()
+			return nil
+		}
+
+		go srv.serveConn(conn)
+	}
+}
+
+// Close stops the server and closes its connections
+func (srv *SignerServer) Close() error {
+	srv.Lock()
+	defer srv.Unlock()
+
+	srv.closed = true
+	for c := range srv.conns {
+		c.Close()
+	}
+
+	if srv.listener == nil {
+		return nil
+	}
+	return srv.listener.Close()
+}
+
+func (srv *SignerServer) track(conn net.Conn) bool {
+	srv.Lock()
+	defer srv.Unlock()
+
+	if srv.closed {
+		return false
+	}
+	srv.conns[conn] = struct{}{}
+	return true
+}
+
+func (srv *SignerServer) serveConn(conn net.Conn) {
+	defer func() {
+		srv.Lock()
+		delete(srv.conns, conn)
+		srv.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var req signerRequest
+		if err := readSignerMessage(conn, &req); err != nil {
+			if err != io.EOF {
+				logger.WithError(err).Error("SignerServer: readSignerMessage failed")
+			}
+			return
+		}
+
+		resp := srv.handle(req)
+		if err := writeSignerMessage(conn, resp); err != nil {
+			logger.WithError(err).Error("SignerServer: writeSignerMessage failed")
+			return
+		}
+	}
+}
+
+func (srv *SignerServer) handle(req signerRequest) signerResponse {
+	if len(req.Inputs) == 0 {
+		return signerResponse{Error: "request has no inputs"}
+	}
+
+	switch req.Method {
+	case signerMethodPublicKey:
+		if len(req.Inputs) != 1 {
+			return signerResponse{Error: "public_key requests have one input"}
+		}
+
+		pk, err := srv.signer.PublicKey(req.Inputs[0].Path)
+		if err != nil {
+			return signerResponse{Error: err.Error()}
+		}
+
+		return signerResponse{PublicKey: pk.Hex()}
+
+	case signerMethodSignDigest, signerMethodSignTx:
+		if req.Method == signerMethodSignDigest && len(req.Inputs) != 1 {
+			return signerResponse{Error: "sign_digest requests have one input"}
+		}
+
+		reqs := make([]SignRequest, len(req.Inputs))
+		for i, in := range req.Inputs {
+			d, err := hex.DecodeString(in.Digest)
+			if err != nil || len(d) != len(cipher.SHA256{}) {
+				return signerResponse{Error: fmt.Sprintf("invalid digest of input %d", i)}
+			}
+
+			reqs[i] = SignRequest{
+				Path:   in.Path,
+				Digest: cipher.MustSHA256FromBytes(d),
+			}
+		}
+
+		sigs, err := srv.signer.SignTx(reqs)
+		if err != nil {
+			return signerResponse{Error: err.Error()}
+		}
+
+		resp := signerResponse{
+			Signatures: make([]string, len(sigs)),
+		}
+		for i, sig := range sigs {
+			resp.Signatures[i] = sig.Hex()
+		}
+		return resp
+
+	default:
+		return signerResponse{Error: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// DefaultScanN is the default gap limit, the number of consecutive unused addresses
+// after which address scanning stops
+const DefaultScanN = 20
+
+var (
+	// ErrNilTransactionsFinder is returned if Options.ScanN > 0 but a nil TransactionsFinder was provided
+	ErrNilTransactionsFinder = NewError(errors.New("scan ahead requested but transactions finder is nil"))
+)
+
+// TransactionsFinder reports whether addresses have any transaction history.
+// Each coin provides its own implementation, backed by the coin's node or indexer.
+type TransactionsFinder interface {
+	// AddressesActivity returns a bool for each address, true if the address has transaction history
+	AddressesActivity(addrs []cipher.Addresser) ([]bool, error)
+}
+
+// scanAddresses implements gap limit address scanning for a sequence of addresses.
+// generateEntries generates `num` entries starting from childIdx. Entries are generated and
+// checked for activity until scanN consecutive unused entries are found. The generated entries
+// are returned, truncated after the last used entry.
+func scanAddresses(generateEntries func(num uint64, childIdx uint32) (Entries, error), scanN uint64, tf TransactionsFinder, initialChildIdx uint32) (Entries, error) {
+	if scanN == 0 {
+		return nil, nil
+	}
+
+	if tf == nil {
+		return nil, ErrNilTransactionsFinder
+	}
+
+	var newEntries Entries
+	var keepNum int
+	var gap uint64
+	childIdx := initialChildIdx
+
+	for gap < scanN {
+		// Only generate as many entries as could still close the gap
+		entries, err := generateEntries(scanN-gap, childIdx)
+		if err != nil {
+			return nil, err
+		}
+
+		// The bip32 child key sequence is finite and may be truncated at its limit
+		if len(entries) == 0 {
+			break
+		}
+
+		active, err := tf.AddressesActivity(entries.getAddresses())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(active) != len(entries) {
+			return nil, fmt.Errorf("transactions finder returned %d results for %d addresses", len(active), len(entries))
+		}
+
+		for i, used := range active {
+			if used {
+				keepNum = len(newEntries) + i + 1
+				gap = 0
+			} else {
+				gap++
+			}
+		}
+
+		newEntries = append(newEntries, entries...)
+		childIdx = nextChildIdx(entries)
+	}
+
+	return newEntries[:keepNum], nil
+}
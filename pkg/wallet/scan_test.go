@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip39"
+)
+
+// mockTransactionsFinder reports the addresses of a set as used
+type mockTransactionsFinder map[string]struct{}
+
+func newMockTransactionsFinder(addrs ...cipher.Addresser) mockTransactionsFinder {
+	tf := make(mockTransactionsFinder)
+	for _, a := range addrs {
+		tf[a.String()] = struct{}{}
+	}
+	return tf
+}
+
+func (tf mockTransactionsFinder) AddressesActivity(addrs []cipher.Addresser) ([]bool, error) {
+	active := make([]bool, len(addrs))
+	for i, a := range addrs {
+		_, active[i] = tf[a.String()]
+	}
+	return active, nil
+}
+
+func TestNewWalletScanAhead(t *testing.T) {
+	// Reference wallet to look up the addresses to mark as used
+	ref, err := NewWallet("ref.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 50,
+	})
+	require.NoError(t, err)
+	bref := ref.(*Bip44Wallet)
+	var change []Entry
+	for i := 0; i < 10; i++ {
+		e, err := bref.GenerateChangeEntry(0)
+		require.NoError(t, err)
+		change = append(change, e)
+	}
+
+	// Address 22 is within the gap limit of address 3, but address 43 is beyond the gap limit of address 22
+	// and is never reached
+	tf := newMockTransactionsFinder(
+		bref.ExternalEntries[3].Address,
+		bref.ExternalEntries[22].Address,
+		bref.ExternalEntries[43].Address,
+		change[5].Address,
+	)
+
+	w, err := NewWalletScanAhead("test.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeBitcoin,
+		Seed: testVectorSeed,
+	}, tf)
+	require.NoError(t, err)
+	external, changeEntries := w.(*Bip44Wallet).GetAccountEntries(0)
+	require.Len(t, external, 23)
+	require.Len(t, changeEntries, 6)
+	require.Equal(t, bref.ExternalEntries[:23], external)
+	require.Equal(t, Entries(change[:6]), changeEntries)
+	require.False(t, w.HasEntry(bref.ExternalEntries[43].Address))
+
+	// The gap limit is configurable
+	w, err = NewWalletScanAhead("test.wlt", Options{
+		Type:  WalletTypeBip44,
+		Coin:  CoinTypeBitcoin,
+		Seed:  testVectorSeed,
+		ScanN: 2,
+	}, tf)
+	require.NoError(t, err)
+	require.Equal(t, 1, w.EntriesLen())
+
+	// Deterministic wallets
+	dref, err := NewWallet("ref.wlt", Options{
+		Type:      WalletTypeDeterministic,
+		Seed:      testSeed,
+		GenerateN: 10,
+	})
+	require.NoError(t, err)
+	w, err = NewWalletScanAhead("test.wlt", Options{
+		Type: WalletTypeDeterministic,
+		Seed: testSeed,
+	}, newMockTransactionsFinder(dref.GetEntryAt(7).Address))
+	require.NoError(t, err)
+	require.Equal(t, 8, w.EntriesLen())
+	require.Equal(t, dref.GetEntries()[:8], w.GetEntries())
+
+	// The last seed is kept in sync with the scanned entries
+	_, err = w.GenerateAddresses(2)
+	require.NoError(t, err)
+	require.Equal(t, dref.GetEntries(), w.GetEntries())
+
+	// XPub wallets
+	seed, err := bip39.NewSeed(testVectorSeed, "")
+	require.NoError(t, err)
+	chain, err := bip32.NewPrivateKeyFromPath(seed, "m/44'/0'/0'/0")
+	require.NoError(t, err)
+	w, err = NewWalletScanAhead("test.wlt", Options{
+		Type: WalletTypeXPub,
+		Coin: CoinTypeBitcoin,
+		XPub: chain.PublicKey().String(),
+	}, tf)
+	require.NoError(t, err)
+	require.Equal(t, addressStrings(bref.ExternalEntries[:23].getAddresses()), addressStrings(w.GetAddresses()))
+
+	// A transactions finder is required
+	_, err = NewWalletScanAhead("test.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeBitcoin,
+		Seed: testVectorSeed,
+	}, nil)
+	require.Equal(t, ErrNilTransactionsFinder, err)
+
+	// Collection wallets can't be scanned
+	_, err = NewWalletScanAhead("test.wlt", Options{
+		Type: WalletTypeCollection,
+	}, tf)
+	require.Error(t, err)
+}
@@ -6,6 +6,7 @@ const (
 	secretSeed           = "seed"
 	secretLastSeed       = "lastSeed"
 	secretSeedPassphrase = "seedPassphrase"
+	secretMACKey         = "macKey"
 )
 
 // Secrets hold secret data, to be encrypted
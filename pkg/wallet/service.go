@@ -0,0 +1,1203 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/eth"
+	"github.com/SkycoinProject/multicoin-wallet/pkg/slip39"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/coin"
+	"github.com/SkycoinProject/skycoin/src/transaction"
+)
+
+// Service manages the set of wallets stored in a wallet directory.
+// All access to the wallets goes through the service, which serializes writes
+// and keeps the in-memory wallets in sync with the files on disk.
+type Service struct {
+	sync.RWMutex
+	wallets Wallets
+	config  Config
+	// fingerprints is used to check for duplicate generative wallets
+	fingerprints map[string]string
+	// transactionsFinders are used to scan ahead for used addresses when creating wallets
+	transactionsFinders map[CoinType]TransactionsFinder
+	// unspentOutputsFinder returns the unspent outputs spent by skycoin transactions
+	unspentOutputsFinder UnspentOutputsFinder
+	// signers sign for wallets instead of the wallets' own keys, e.g. remote signers of watch-only wallets
+	signers map[string]Signer
+	// unverified are the encrypted wallets which weren't authenticated on load
+	unverified map[string]struct{}
+	// macKey authenticates the encrypted wallets on load, see Config.MACKeyFile
+	macKey []byte
+}
+
+// Config wallet service config
+type Config struct {
+	WalletDir  string
+	CryptoType CryptoType
+	// Backups is the number of timestamped backups kept of each wallet file, 0 disables backups
+	Backups int
+	// VerifyEntries re-derives the entries of unencrypted wallets when loading them, see VerifyEntries
+	VerifyEntries bool
+	// MACKeyFile is the file of the key which authenticates encrypted wallets when they are loaded, see LoadMACKey.
+	// It must be outside of WalletDir. Without it, encrypted wallets are only authenticated when they are unlocked.
+	MACKeyFile string
+}
+
+// NewConfig creates a default Config
+func NewConfig() Config {
+	return Config{
+		WalletDir:  "./",
+		CryptoType: DefaultCryptoType,
+		Backups:    DefaultBackups,
+	}
+}
+
+// NewService creates a wallet service, loading all wallets from the configured wallet directory
+func NewService(c Config) (*Service, error) {
+	serv := &Service{
+		config:              c,
+		fingerprints:        make(map[string]string),
+		transactionsFinders: make(map[CoinType]TransactionsFinder),
+		signers:             make(map[string]Signer),
+		unverified:          make(map[string]struct{}),
+	}
+
+	if c.Backups < 0 {
+		return nil, fmt.Errorf("invalid number of wallet backups %d", c.Backups)
+	}
+
+	if err := os.MkdirAll(c.WalletDir, os.FileMode(0700)); err != nil {
+		return nil, fmt.Errorf("failed to create wallet directory %s: %v", c.WalletDir, err)
+	}
+
+	if c.MACKeyFile != "" {
+		if err := checkOutsideDir(c.MACKeyFile, c.WalletDir); err != nil {
+			return nil, fmt.Errorf("invalid MAC key file: %v", err)
+		}
+
+		key, err := LoadMACKey(c.MACKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the MAC key: %v", err)
+		}
+		serv.macKey = key
+	}
+
+	// Load all wallets from disk
+	w, err := loadWallets(serv.config.WalletDir, serv.macKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load all wallets: %v", err)
+	}
+
+	// Abort if there are duplicate wallets (identified by fingerprint) on disk
+	if wltID, fp, hasDup := w.containsDuplicate(); hasDup {
+		return nil, fmt.Errorf("duplicate wallet found with fingerprint %s in file %q", fp, wltID)
+	}
+
+	// Encrypted wallets without a load MAC can't be authenticated without their password, see UnverifiedWallets
+	for wltID, wlt := range w {
+		if wlt.IsEncrypted() && (serv.macKey == nil || wlt.LoadMAC() == "") {
+			serv.unverified[wltID] = struct{}{}
+		}
+	}
+
+	// Abort if the entries of unencrypted wallets don't match their seed or xpub key
+	if c.VerifyEntries {
+		for wltID, wlt := range w {
+			if wlt.IsEncrypted() {
+				continue
+			}
+			if err := VerifyEntries(wlt); err != nil {
+				return nil, fmt.Errorf("wallet %q: %v", wltID, err)
+			}
+		}
+	}
+
+	// Abort if there are empty generative wallets on disk
+	if wltID, hasEmpty := w.containsEmpty(); hasEmpty {
+		return nil, fmt.Errorf("empty wallet file found: %q", wltID)
+	}
+
+	serv.setWallets(w)
+
+	logger.WithFields(logrus.Fields{
+		"walletDir": serv.config.WalletDir,
+		"wallets":   len(w),
+	}).Debug("wallet.NewService complete")
+
+	return serv, nil
+}
+
+// WalletDir returns the configured wallet directory
+func (serv *Service) WalletDir() string {
+	return serv.config.WalletDir
+}
+
+// save saves a wallet to the wallet directory, keeping the configured number of backups
+func (serv *Service) save(w Wallet) error {
+	if err := serv.setLoadMAC(w); err != nil {
+		return err
+	}
+	return SaveWithBackups(w, serv.config.WalletDir, serv.config.Backups)
+}
+
+// saveRekeyed saves a wallet whose encryption state, password or crypto type changed, see SaveRekeyed
+func (serv *Service) saveRekeyed(w Wallet) error {
+	if err := serv.setLoadMAC(w); err != nil {
+		return err
+	}
+	return SaveRekeyed(w, serv.config.WalletDir)
+}
+
+// setLoadMAC authenticates an encrypted wallet before it's saved, so that it's authenticated when it's loaded
+func (serv *Service) setLoadMAC(w Wallet) error {
+	if serv.macKey == nil {
+		return nil
+	}
+	return setLoadMAC(w, serv.macKey)
+}
+
+// checkOutsideDir returns an error if a file is in a directory or in one of its subdirectories
+func checkOutsideDir(filename, dir string) error {
+	absFile, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return err
+	}
+
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is in the wallet directory %s", filename, dir)
+	}
+
+	return nil
+}
+
+// SetTransactionsFinder registers the TransactionsFinder of a coin.
+// Generative wallets of that coin are scanned ahead for used addresses on creation.
+// The multicoin daemon doesn't register any, since it has no coin node backends yet;
+// wallets are scanned by embedders of the service which provide one.
+func (serv *Service) SetTransactionsFinder(coin CoinType, tf TransactionsFinder) {
+	serv.Lock()
+	defer serv.Unlock()
+	serv.transactionsFinders[coin] = tf
+}
+
+// SetUnspentOutputsFinder registers the UnspentOutputsFinder used to create skycoin transactions
+func (serv *Service) SetUnspentOutputsFinder(uf UnspentOutputsFinder) {
+	serv.Lock()
+	defer serv.Unlock()
+	serv.unspentOutputsFinder = uf
+}
+
+func (serv *Service) updateOptions(opts Options) Options {
+	// Apply service-configured default settings for wallet options
+	if opts.Encrypt && opts.CryptoType == "" {
+		opts.CryptoType = serv.config.CryptoType
+	}
+	return opts
+}
+
+// CreateWallet creates a wallet with the given wallet file name and options.
+// If the wallet file name is empty, a unique one is generated.
+// If a TransactionsFinder is registered for the wallet's coin, generative wallets
+// are scanned ahead for addresses with transaction history.
+func (serv *Service) CreateWallet(wltName string, options Options) (Wallet, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	if wltName == "" {
+		wltName = serv.generateUniqueWalletFilename()
+	}
+
+	options = serv.updateOptions(options)
+
+	var w Wallet
+	var err error
+	if tf := serv.transactionsFinder(options); tf != nil {
+		w, err = NewWalletScanAhead(wltName, options, tf)
+	} else {
+		w, err = NewWallet(wltName, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return serv.addWallet(w)
+}
+
+// CreateWalletFromDump creates a bitcoin collection wallet from the keys of a Bitcoin Core wallet dump.
+// If the wallet file name is empty, a unique one is generated.
+func (serv *Service) CreateWalletFromDump(wltName string, d *DumpWallet, options Options) (Wallet, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	if wltName == "" {
+		wltName = serv.generateUniqueWalletFilename()
+	}
+
+	options = serv.updateOptions(options)
+
+	w, err := NewWalletFromDump(wltName, d, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return serv.addWallet(w)
+}
+
+// CreateWalletFromSlip39 creates a bip44 wallet from SLIP-39 mnemonic shares.
+// If the wallet file name is empty, a unique one is generated.
+// The wallet is scanned ahead like wallets created with CreateWallet.
+func (serv *Service) CreateWalletFromSlip39(wltName string, mnemonics []string, passphrase []byte, options Options) (Wallet, error) {
+	options, err := slip39Options(mnemonics, passphrase, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return serv.CreateWallet(wltName, options)
+}
+
+// transactionsFinder returns the registered TransactionsFinder used to scan a new wallet, if any
+func (serv *Service) transactionsFinder(opts Options) TransactionsFinder {
+	switch opts.Type {
+	case WalletTypeDeterministic, WalletTypeBip44, WalletTypeXPub:
+	default:
+		return nil
+	}
+
+	coin := opts.Coin
+	if coin == "" {
+		coin = CoinTypeSkycoin
+	}
+	coin, err := ResolveCoinType(string(coin))
+	if err != nil {
+		return nil
+	}
+
+	return serv.transactionsFinders[coin]
+}
+
+// addWallet adds a newly created wallet to the service and saves it to disk.
+// Fails if a wallet with the same fingerprint is already loaded.
+func (serv *Service) addWallet(w Wallet) (Wallet, error) {
+	fingerprint := w.Fingerprint()
+	if fingerprint != "" {
+		if _, ok := serv.fingerprints[fingerprint]; ok {
+			// Note: collection wallets do not have fingerprints
+			switch w.Type() {
+			case WalletTypeDeterministic, WalletTypeBip44:
+				return nil, ErrSeedUsed
+			case WalletTypeXPub:
+				return nil, ErrXPubKeyUsed
+			default:
+				logger.WithFields(logrus.Fields{
+					"walletType":  w.Type(),
+					"fingerprint": fingerprint,
+				}).Panic("Unhandled wallet type after fingerprint conflict")
+			}
+		}
+	}
+
+	if err := serv.wallets.add(w); err != nil {
+		return nil, err
+	}
+
+	if err := serv.save(w); err != nil {
+		// If save fails, remove the added wallet
+		serv.wallets.remove(w.Filename())
+		return nil, err
+	}
+
+	if fingerprint != "" {
+		serv.fingerprints[fingerprint] = w.Filename()
+	}
+
+	return w.Clone(), nil
+}
+
+func (serv *Service) generateUniqueWalletFilename() string {
+	wltName := NewWalletFilename()
+	for {
+		if w := serv.wallets.get(wltName); w == nil {
+			break
+		}
+		wltName = NewWalletFilename()
+	}
+
+	return wltName
+}
+
+// EncryptWallet encrypts wallet with password
+func (serv *Service) EncryptWallet(wltID string, password []byte) (Wallet, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+
+	if err := Lock(w, password, serv.config.CryptoType); err != nil {
+		return nil, err
+	}
+
+	// Save to disk first, removing the unencrypted backups
+	if err := serv.saveRekeyed(w); err != nil {
+		return nil, err
+	}
+
+	// Sets the encrypted wallet
+	serv.wallets.set(w)
+	return w, nil
+}
+
+// DecryptWallet decrypts wallet with password
+func (serv *Service) DecryptWallet(wltID string, password []byte) (Wallet, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Returns error if wallet is not encrypted
+	if !w.IsEncrypted() {
+		return nil, ErrWalletNotEncrypted
+	}
+
+	// Unlocks the wallet
+	unlockWlt, err := Unlock(w, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Updates the wallet file
+	if err := serv.saveRekeyed(unlockWlt); err != nil {
+		return nil, err
+	}
+
+	// Sets the decrypted wallet in memory
+	serv.wallets.set(unlockWlt)
+	return unlockWlt, nil
+}
+
+// ChangePassword re-encrypts a wallet with a new password and crypto type, see ChangePassword.
+// If cryptoType is empty, the wallet's crypto type is kept.
+// The wallet file is only rewritten once the wallet is re-encrypted.
+func (serv *Service) ChangePassword(wltID string, oldPassword, newPassword []byte, cryptoType CryptoType) (Wallet, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ChangePassword(w, oldPassword, newPassword, cryptoType); err != nil {
+		return nil, err
+	}
+
+	if err := serv.saveRekeyed(w); err != nil {
+		return nil, err
+	}
+
+	serv.wallets.set(w)
+	return w, nil
+}
+
+// UnverifiedWallets returns the sorted IDs of the encrypted wallets which weren't authenticated when the service
+// loaded them, because the service has no MAC key file or the wallets have no load MAC, see Config.MACKeyFile.
+// They are authenticated when they are unlocked, by the MAC of their encrypted secrets, see VerifyWallet.
+func (serv *Service) UnverifiedWallets() []string {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	ids := make([]string, 0, len(serv.unverified))
+	for id := range serv.unverified {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// InsecureWallets returns the sorted IDs of the wallets encrypted with an insecure crypto type
+func (serv *Service) InsecureWallets() []string {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	return serv.insecureWallets()
+}
+
+func (serv *Service) insecureWallets() []string {
+	var ids []string
+	for id, w := range serv.wallets {
+		if w.IsEncrypted() && w.CryptoType().IsInsecure() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// UpgradeInsecureWallets re-encrypts the wallets encrypted with an insecure crypto type with cryptoType,
+// keeping their passwords. cryptoType defaults to the service's crypto type and must not be insecure.
+// passwords maps the IDs of the wallets to upgrade to their password, see InsecureWallets.
+// All wallets are re-encrypted in memory before any wallet file is rewritten, so a wrong password
+// leaves every wallet untouched. Returns the IDs of the upgraded wallets.
+func (serv *Service) UpgradeInsecureWallets(passwords map[string][]byte, cryptoType CryptoType) ([]string, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	if cryptoType == "" {
+		cryptoType = serv.config.CryptoType
+	}
+	if _, err := CryptoTypeFromString(string(cryptoType)); err != nil {
+		return nil, NewError(err)
+	}
+	if cryptoType.IsInsecure() {
+		return nil, NewError(fmt.Errorf("can't upgrade wallets to the insecure crypto type %q", cryptoType))
+	}
+
+	if len(passwords) == 0 {
+		return nil, NewError(errors.New("no wallets to upgrade"))
+	}
+
+	ids := make([]string, 0, len(passwords))
+	for id := range passwords {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	wlts := make([]Wallet, 0, len(ids))
+	for _, id := range ids {
+		w, err := serv.getWallet(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if !w.IsEncrypted() || !w.CryptoType().IsInsecure() {
+			return nil, NewError(fmt.Errorf("wallet %q is not encrypted with an insecure crypto type", id))
+		}
+
+		if err := ChangePassword(w, passwords[id], passwords[id], cryptoType); err != nil {
+			if _, ok := err.(Error); ok {
+				return nil, NewError(fmt.Errorf("wallet %q: %v", id, err))
+			}
+			return nil, err
+		}
+
+		wlts = append(wlts, w)
+	}
+
+	upgraded := make([]string, 0, len(wlts))
+	for _, w := range wlts {
+		if err := serv.saveRekeyed(w); err != nil {
+			return upgraded, err
+		}
+
+		serv.wallets.set(w)
+		upgraded = append(upgraded, w.Filename())
+	}
+
+	return upgraded, nil
+}
+
+// NewAddresses generates address entries in the given wallet.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) NewAddresses(wltID string, password []byte, num uint64) ([]cipher.Addresser, error) {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []cipher.Addresser
+	f := func(wlt Wallet) error {
+		var err error
+		addrs, err = wlt.GenerateAddresses(num)
+		return err
+	}
+
+	if w.IsEncrypted() {
+		if err := GuardUpdate(w, password, f); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(password) != 0 {
+			return nil, ErrWalletNotEncrypted
+		}
+
+		if err := f(w); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save the wallet first
+	if err := serv.save(w); err != nil {
+		return nil, err
+	}
+
+	serv.wallets.set(w)
+
+	return addrs, nil
+}
+
+// AccountXPub returns the extended public key of a bip44 wallet account.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) AccountXPub(wltID string, password []byte, account uint32) (string, error) {
+	var xp string
+	if err := serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		bw, ok := w.(*Bip44Wallet)
+		if !ok {
+			return NewError(fmt.Errorf("wallet type is %q, account xpub keys are only available for %q wallets", w.Type(), WalletTypeBip44))
+		}
+
+		var err error
+		xp, err = bw.AccountXPub(account)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	return xp, nil
+}
+
+// ImportSecretKey imports a secret key encoded in the native format of a coin into a collection wallet.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) ImportSecretKey(wltID string, password []byte, coin CoinType, encoded string) (cipher.Addresser, error) {
+	var addr cipher.Addresser
+	if err := serv.UpdateSecrets(wltID, password, func(w Wallet) error {
+		cw, ok := w.(*CollectionWallet)
+		if !ok {
+			return NewError(fmt.Errorf("wallet type is %q, secret keys can only be imported into %q wallets", w.Type(), WalletTypeCollection))
+		}
+
+		var err error
+		addr, err = cw.ImportSecretKey(coin, encoded)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return addr, nil
+}
+
+// ImportKeystore imports the secret key of a keystore V3 JSON file into an ethereum collection wallet.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+// keystorePassword is the password of the keystore file.
+func (serv *Service) ImportKeystore(wltID string, password, keyjson, keystorePassword []byte) (cipher.Addresser, error) {
+	var addr cipher.Addresser
+	if err := serv.UpdateSecrets(wltID, password, func(w Wallet) error {
+		cw, ok := w.(*CollectionWallet)
+		if !ok {
+			return NewError(fmt.Errorf("wallet type is %q, keystore files can only be imported into %q wallets", w.Type(), WalletTypeCollection))
+		}
+
+		var err error
+		addr, err = cw.ImportKeystore(keyjson, keystorePassword)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return addr, nil
+}
+
+// ExportKeystore exports the secret key of an ethereum wallet entry as keystore V3 JSON,
+// encrypted with keystorePassword and the standard scrypt work factor.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) ExportKeystore(wltID string, password []byte, addr cipher.Addresser, keystorePassword []byte) ([]byte, error) {
+	var keyjson []byte
+	if err := serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		var err error
+		keyjson, err = ExportKeystore(w, addr, keystorePassword, eth.StandardScryptN, eth.StandardScryptP)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return keyjson, nil
+}
+
+// ExportKeystoreDir exports the secret keys of all entries of an ethereum wallet to a keystore directory,
+// encrypted with keystorePassword and the standard scrypt work factor.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) ExportKeystoreDir(wltID string, password []byte, dir string, keystorePassword []byte) ([]string, error) {
+	var files []string
+	if err := serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		var err error
+		files, err = ExportKeystoreDir(w, dir, keystorePassword, eth.StandardScryptN, eth.StandardScryptP)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// DumpWallet creates a Bitcoin Core wallet dump of the secret keys of a bitcoin wallet.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) DumpWallet(wltID string, password []byte) (*DumpWallet, error) {
+	var d *DumpWallet
+	if err := serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		var err error
+		d, err = NewDumpWallet(w)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Slip39Shares splits the master secret of a bip44 wallet into SLIP-39 mnemonic shares
+func (serv *Service) Slip39Shares(wltID string, password []byte, groupThreshold uint8, groups []slip39.Group, passphrase []byte, iterationExponent uint8) ([][]string, error) {
+	var shares [][]string
+	if err := serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		bw, ok := w.(*Bip44Wallet)
+		if !ok {
+			return NewError(fmt.Errorf("only %q wallets can be split into slip39 shares", WalletTypeBip44))
+		}
+
+		var err error
+		shares, err = bw.Slip39Shares(groupThreshold, groups, passphrase, iterationExponent)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// VerifyWallet checks that a wallet wasn't modified outside of the wallet software, returning ErrWalletTampered if it was.
+// The MAC of encrypted wallets is checked when they are decrypted, then the entries are re-derived, see VerifyEntries.
+// Verified encrypted wallets without a load MAC are saved with one, so that they are authenticated when they are loaded.
+// Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) VerifyWallet(wltID string, password []byte) error {
+	if err := serv.ViewSecrets(wltID, password, VerifyEntries); err != nil {
+		return err
+	}
+
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	if w.IsEncrypted() && serv.macKey != nil && w.LoadMAC() == "" {
+		if err := serv.save(w); err != nil {
+			return err
+		}
+		serv.wallets.set(w)
+	}
+
+	delete(serv.unverified, wltID)
+	return nil
+}
+
+// SignMessage signs a message with the key of a wallet address, in the message signature format of the coin,
+// which must be the wallet's coin. See SignMessage for the formats.
+// The message is signed by the wallet's Signer, see ViewSigner.
+// Set password as nil if the wallet is not encrypted or has a signer, otherwise the password must be provided.
+func (serv *Service) SignMessage(wltID string, password []byte, coin CoinType, addr string, msg []byte) (string, error) {
+	var sig string
+	if err := serv.viewEntrySigner(wltID, password, func(w Wallet, signer func(Entry) digestSigner) error {
+		if w.Coin() != coin {
+			return NewError(fmt.Errorf("wallet coin is %q, can't sign a %s message", w.Coin(), coin))
+		}
+
+		a, err := DecodeAddress(coin, addr)
+		if err != nil {
+			return NewError(fmt.Errorf("invalid %s address: %v", coin, err))
+		}
+
+		e, ok := w.GetEntry(a)
+		if !ok {
+			return ErrUnknownAddress
+		}
+
+		sig, err = signMessage(coin, e, msg, signer(e))
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	return sig, nil
+}
+
+// SignTypedData signs EIP-712 typed data with the key of an ethereum wallet address, see SignTypedData.
+// The typed data is signed by the wallet's Signer, see ViewSigner.
+// Set password as nil if the wallet is not encrypted or has a signer, otherwise the password must be provided.
+func (serv *Service) SignTypedData(wltID string, password []byte, addr string, td *eth.TypedData) (string, error) {
+	var sig string
+	if err := serv.viewEntrySigner(wltID, password, func(w Wallet, signer func(Entry) digestSigner) error {
+		if w.Coin() != CoinTypeEthereum {
+			return NewError(fmt.Errorf("wallet coin is %q, typed data can only be signed by %q wallets", w.Coin(), CoinTypeEthereum))
+		}
+
+		a, err := eth.DecodeEthereumAddress(addr)
+		if err != nil {
+			return NewError(fmt.Errorf("invalid %s address: %v", CoinTypeEthereum, err))
+		}
+
+		e, ok := w.GetEntry(a)
+		if !ok {
+			return ErrUnknownAddress
+		}
+
+		sig, err = signTypedData(e, td, signer(e))
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	return sig, nil
+}
+
+// SignEthereumTransaction signs an ethereum transaction with the key of an ethereum wallet address,
+// see SignEthereumTransaction. The transaction is signed by the wallet's Signer, see ViewSigner.
+// Set password as nil if the wallet is not encrypted or has a signer, otherwise the password must be provided.
+func (serv *Service) SignEthereumTransaction(wltID string, password []byte, addr string, tx *eth.Transaction) (*eth.Transaction, error) {
+	var signed *eth.Transaction
+	if err := serv.viewEntrySigner(wltID, password, func(w Wallet, signer func(Entry) digestSigner) error {
+		if w.Coin() != CoinTypeEthereum {
+			return NewError(fmt.Errorf("wallet coin is %q, ethereum transactions can only be signed by %q wallets", w.Coin(), CoinTypeEthereum))
+		}
+
+		a, err := eth.DecodeEthereumAddress(addr)
+		if err != nil {
+			return NewError(fmt.Errorf("invalid %s address: %v", CoinTypeEthereum, err))
+		}
+
+		e, ok := w.GetEntry(a)
+		if !ok {
+			return ErrUnknownAddress
+		}
+
+		signed, err = signEthereumTransaction(e, tx, signer(e))
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+// SignPSBT signs the inputs of a PSBT spent by a bitcoin wallet, see SignPSBT, and returns the number of inputs signed.
+// The PSBT is updated with the redeem scripts and derivation paths of the wallet's entries first, see UpdatePSBT.
+// Wallets with a Signer set with SetSigner only sign the inputs with derivation paths of the signer's keys, or spending
+// the addresses of its keys. Set password as nil if the wallet is not encrypted, otherwise the password must be provided.
+func (serv *Service) SignPSBT(wltID string, password []byte, p *btc.PSBT) (int, error) {
+	w, err := serv.GetWallet(wltID)
+	if err != nil {
+		return 0, err
+	}
+
+	if w.Coin() != CoinTypeBitcoin {
+		return 0, ErrPSBTCoin
+	}
+
+	var n int
+	if err := serv.ViewSigner(wltID, password, func(s Signer) error {
+		// The wallet's own signer is the decrypted wallet, which can add the derivation paths of its keys
+		uw := w
+		if sw, ok := s.(Wallet); ok {
+			uw = sw
+		}
+
+		if err := UpdatePSBT(uw, p); err != nil {
+			return err
+		}
+
+		var err error
+		n, err = SignPSBT(s, p)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// CreateTransaction creates and signs a skycoin transaction spending the unspent outputs of a skycoin wallet,
+// returned by the registered UnspentOutputsFinder, see CreateTransaction. The wallet is saved, since bip44 wallets
+// append the change address to their change chain. The inputs are signed by the signer set with SetSigner if
+// there is one, otherwise by the wallet's own keys. Encrypted bip44 wallets with a signer can't generate their
+// change address, which must be set. Set password as nil if the wallet is not encrypted or has a signer,
+// otherwise the password must be provided.
+//
+// The transaction is created with a copy of the wallet, without locking the service while the unspent outputs
+// are fetched and the inputs are signed. ErrWalletChanged is returned if the wallet changed in the meantime
+// and the transaction's change address had to be appended to it.
+func (serv *Service) CreateTransaction(wltID string, password []byte, p CreateTransactionParams) (*coin.Transaction, []transaction.UxBalance, error) {
+	serv.RLock()
+	s, hasSigner := serv.signers[wltID]
+	uf := serv.unspentOutputsFinder
+	w, err := serv.getWallet(wltID)
+	serv.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hasSigner && len(password) != 0 {
+		return nil, nil, ErrSignerPassword
+	}
+
+	// The copy is checked against the wallet before the change address is appended to it
+	snapshot, err := json.Marshal(w.ToReadable())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var txn *coin.Transaction
+	var inputs []transaction.UxBalance
+	create := func(w Wallet) error {
+		var err error
+		txn, inputs, err = createTransaction(w, p, uf, s)
+		return err
+	}
+
+	// With a signer, the wallet's secrets aren't used, only its entries and change chain
+	switch {
+	case hasSigner:
+		err = create(w)
+	case w.IsEncrypted():
+		err = GuardView(w, password, create)
+	case len(password) != 0:
+		err = ErrWalletNotEncrypted
+	default:
+		err = create(w)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !generatesChangeEntry(w, p, txn) {
+		return txn, inputs, nil
+	}
+
+	if err := serv.appendChangeEntry(wltID, password, snapshot, p.Account); err != nil {
+		return nil, nil, err
+	}
+
+	return txn, inputs, nil
+}
+
+// appendChangeEntry appends the next change entry of a bip44 account to a wallet and saves it,
+// if the wallet didn't change since snapshot was taken, see CreateTransaction
+func (serv *Service) appendChangeEntry(wltID string, password []byte, snapshot []byte, account uint32) error {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(w.ToReadable())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b, snapshot) {
+		return ErrWalletChanged
+	}
+
+	generate := func(w Wallet) error {
+		_, err := w.(*Bip44Wallet).GenerateChangeEntry(account)
+		return err
+	}
+
+	if w.IsEncrypted() {
+		err = GuardUpdate(w, password, generate)
+	} else {
+		err = generate(w)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := serv.save(w); err != nil {
+		return err
+	}
+
+	serv.wallets.set(w)
+
+	return nil
+}
+
+// SetSigner sets the Signer of a wallet, e.g. a RemoteSigner, which ViewSigner uses instead of the wallet's own keys.
+// A nil signer is removed.
+func (serv *Service) SetSigner(wltID string, s Signer) error {
+	serv.Lock()
+	defer serv.Unlock()
+
+	if _, err := serv.getWallet(wltID); err != nil {
+		return err
+	}
+
+	if s == nil {
+		delete(serv.signers, wltID)
+	} else {
+		serv.signers[wltID] = s
+	}
+
+	return nil
+}
+
+// ViewSigner calls f with the Signer of a wallet. The signer set with SetSigner is used if there is one,
+// and password must be nil. Otherwise the wallet signs with its own keys, decrypted with password if it's encrypted.
+func (serv *Service) ViewSigner(wltID string, password []byte, f func(Signer) error) error {
+	serv.RLock()
+	s, ok := serv.signers[wltID]
+	serv.RUnlock()
+
+	if ok {
+		if len(password) != 0 {
+			return ErrSignerPassword
+		}
+		return f(s)
+	}
+
+	return serv.ViewSecrets(wltID, password, func(w Wallet) error {
+		s, ok := w.(Signer)
+		if !ok {
+			return NewError(fmt.Errorf("%q wallets can't sign", w.Type()))
+		}
+		return f(s)
+	})
+}
+
+// viewEntrySigner calls f with a wallet and a function returning the digestSigner of its entries.
+// If the wallet has a signer, see SetSigner, the entries sign with it at their derivation paths,
+// and the wallet isn't decrypted. Otherwise the entries sign with their own secret keys,
+// decrypted with password if the wallet is encrypted.
+func (serv *Service) viewEntrySigner(wltID string, password []byte, f func(w Wallet, signer func(Entry) digestSigner) error) error {
+	serv.RLock()
+	_, ok := serv.signers[wltID]
+	serv.RUnlock()
+
+	if !ok {
+		return serv.ViewSecrets(wltID, password, func(w Wallet) error {
+			return f(w, secretKeySigner)
+		})
+	}
+
+	w, err := serv.GetWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	// XPub wallets are watch-only, and don't know the derivation paths of their keys from the master key
+	ep, ok := w.(entryPather)
+	if !ok {
+		return ErrWalletWatchOnly
+	}
+
+	return serv.ViewSigner(wltID, password, func(s Signer) error {
+		return f(w, func(e Entry) digestSigner {
+			return pathSigner(s, ep.EntryPath(e), e)
+		})
+	})
+}
+
+// GetWallet returns wallet by id
+func (serv *Service) GetWallet(wltID string) (Wallet, error) {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	return serv.getWallet(wltID)
+}
+
+// returns the clone of the wallet of given id
+func (serv *Service) getWallet(wltID string) (Wallet, error) {
+	w := serv.wallets.get(wltID)
+	if w == nil {
+		return nil, ErrWalletNotExist
+	}
+	return w.Clone(), nil
+}
+
+// GetWallets returns all wallet clones
+func (serv *Service) GetWallets() Wallets {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	wlts := make(Wallets, len(serv.wallets))
+	for k, w := range serv.wallets {
+		wlts[k] = w.Clone()
+	}
+	return wlts
+}
+
+// RenameWallet updates the wallet label
+func (serv *Service) RenameWallet(wltID, label string) error {
+	return serv.Update(wltID, func(w Wallet) error {
+		w.SetLabel(label)
+		return nil
+	})
+}
+
+// DeleteWallet removes the wallet of given wallet id from the service and deletes its file
+// and the file's backups from disk
+func (serv *Service) DeleteWallet(wltID string) error {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w := serv.wallets.get(wltID)
+	if w == nil {
+		return ErrWalletNotExist
+	}
+
+	filename := filepath.Join(serv.config.WalletDir, w.Filename())
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := RemoveBackups(filename); err != nil {
+		return err
+	}
+
+	if fp := w.Fingerprint(); fp != "" {
+		delete(serv.fingerprints, fp)
+	}
+
+	serv.wallets.remove(wltID)
+	delete(serv.signers, wltID)
+	delete(serv.unverified, wltID)
+	return nil
+}
+
+func (serv *Service) setWallets(wlts Wallets) {
+	serv.wallets = wlts
+
+	for wltID, wlt := range wlts {
+		if fp := wlt.Fingerprint(); fp != "" {
+			serv.fingerprints[fp] = wltID
+		}
+	}
+}
+
+// UpdateSecrets opens a wallet for modification of secret data and saves it safely.
+// If f changed the wallet's encryption, the backups of the wallet file are removed, see SaveRekeyed.
+func (serv *Service) UpdateSecrets(wltID string, password []byte, f func(Wallet) error) error {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	encrypted, cryptoType := w.IsEncrypted(), w.CryptoType()
+
+	if w.IsEncrypted() {
+		if err := GuardUpdate(w, password, f); err != nil {
+			return err
+		}
+	} else if len(password) != 0 {
+		return ErrWalletNotEncrypted
+	} else {
+		if err := f(w); err != nil {
+			return err
+		}
+	}
+
+	// Save the wallet first
+	save := serv.save
+	if w.IsEncrypted() != encrypted || w.CryptoType() != cryptoType {
+		save = serv.saveRekeyed
+	}
+	if err := save(w); err != nil {
+		return err
+	}
+
+	serv.wallets.set(w)
+
+	return nil
+}
+
+// Update opens a wallet for modification of non-secret data and saves it safely
+func (serv *Service) Update(wltID string, f func(Wallet) error) error {
+	serv.Lock()
+	defer serv.Unlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	if err := f(w); err != nil {
+		return err
+	}
+
+	// Save the wallet first
+	if err := serv.save(w); err != nil {
+		return err
+	}
+
+	serv.wallets.set(w)
+
+	return nil
+}
+
+// ViewSecrets opens a wallet for reading secret data
+func (serv *Service) ViewSecrets(wltID string, password []byte, f func(Wallet) error) error {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	if w.IsEncrypted() {
+		return GuardView(w, password, f)
+	} else if len(password) != 0 {
+		return ErrWalletNotEncrypted
+	} else {
+		return f(w)
+	}
+}
+
+// View opens a wallet for reading non-secret data
+func (serv *Service) View(wltID string, f func(Wallet) error) error {
+	serv.RLock()
+	defer serv.RUnlock()
+
+	w, err := serv.getWallet(wltID)
+	if err != nil {
+		return err
+	}
+
+	return f(w)
+}
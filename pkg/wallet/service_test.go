@@ -0,0 +1,334 @@
+package wallet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSeed = "voyage say extend find sheriff surge priority merit ignore maple cash argue"
+
+func prepareWltDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	return dir, func() {
+		os.RemoveAll(dir) //nolint:errcheck
+	}
+}
+
+func TestServiceCreateWallet(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+	require.Empty(t, s.GetWallets())
+
+	for _, coin := range []CoinType{CoinTypeSkycoin, CoinTypeBitcoin, CoinTypeEthereum} {
+		w, err := s.CreateWallet("", Options{
+			Type:      WalletTypeBip44,
+			Coin:      coin,
+			Seed:      testSeed,
+			GenerateN: 2,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, w.EntriesLen())
+		require.FileExists(t, filepath.Join(dir, w.Filename()))
+
+		// The same seed can't be used twice for the same coin
+		_, err = s.CreateWallet("", Options{
+			Type: WalletTypeBip44,
+			Coin: coin,
+			Seed: testSeed,
+		})
+		require.Equal(t, ErrSeedUsed, err)
+	}
+
+	require.Len(t, s.GetWallets(), 3)
+
+	// Reload the wallets from disk
+	s2, err := NewService(s.config)
+	require.NoError(t, err)
+	require.Len(t, s2.GetWallets(), 3)
+	for id, w := range s.GetWallets() {
+		w2, err := s2.GetWallet(id)
+		require.NoError(t, err)
+		require.Equal(t, w.Fingerprint(), w2.Fingerprint())
+		require.Equal(t, w.GetAddresses(), w2.GetAddresses())
+	}
+}
+
+func TestServiceWalletLifecycle(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:  WalletTypeBip44,
+		Coin:  CoinTypeBitcoin,
+		Seed:  testSeed,
+		Label: "foo",
+	})
+	require.NoError(t, err)
+
+	_, err = s.CreateWallet("t.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.Equal(t, ErrWalletNameConflict, err)
+
+	require.NoError(t, s.RenameWallet("t.wlt", "bar"))
+	w2, err := s.GetWallet("t.wlt")
+	require.NoError(t, err)
+	require.Equal(t, "bar", w2.Label())
+
+	addrs, err := s.NewAddresses("t.wlt", nil, 3)
+	require.NoError(t, err)
+	require.Len(t, addrs, 3)
+
+	password := []byte("pwd")
+	w2, err = s.EncryptWallet("t.wlt", password)
+	require.NoError(t, err)
+	require.True(t, w2.IsEncrypted())
+	require.Empty(t, w2.Seed())
+
+	_, err = s.NewAddresses("t.wlt", nil, 1)
+	require.Equal(t, ErrMissingPassword, err)
+	addrs, err = s.NewAddresses("t.wlt", password, 1)
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+
+	w2, err = s.DecryptWallet("t.wlt", password)
+	require.NoError(t, err)
+	require.False(t, w2.IsEncrypted())
+	require.Equal(t, testSeed, w2.Seed())
+	require.Equal(t, 5, w2.EntriesLen())
+	require.Equal(t, w.Fingerprint(), w2.Fingerprint())
+
+	require.NoError(t, s.DeleteWallet("t.wlt"))
+	_, err = os.Stat(filepath.Join(dir, "t.wlt"))
+	require.True(t, os.IsNotExist(err))
+	_, err = s.GetWallet("t.wlt")
+	require.Equal(t, ErrWalletNotExist, err)
+	require.Equal(t, ErrWalletNotExist, s.DeleteWallet("t.wlt"))
+
+	// The seed can be reused once the wallet is deleted
+	_, err = s.CreateWallet("", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeBitcoin,
+		Seed: testSeed,
+	})
+	require.NoError(t, err)
+}
+
+func TestServiceRekeyRemovesBackups(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+		Backups:    DefaultBackups,
+	})
+	require.NoError(t, err)
+
+	// The wallet file is saved unencrypted, and backed up, a few times
+	_, err = s.CreateWallet("t.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeBitcoin,
+		Seed: testSeed,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := s.NewAddresses("t.wlt", nil, 1)
+		require.NoError(t, err)
+	}
+
+	// A wallet which shares the filename prefix keeps its backups
+	_, err = s.CreateWallet("t.wlt.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.RenameWallet("t.wlt.wlt", "foo"))
+
+	filename := filepath.Join(dir, "t.wlt")
+	backups, err := listBackups(filename)
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+
+	// requireNoFileContains checks that no file of the wallet directory contains s
+	requireNoFileContains := func(s string) {
+		entries, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		for _, e := range entries {
+			b, err := ioutil.ReadFile(filepath.Join(dir, e.Name()))
+			require.NoError(t, err)
+			require.NotContains(t, string(b), s, e.Name())
+		}
+	}
+
+	_, err = s.EncryptWallet("t.wlt", []byte("pwd"))
+	require.NoError(t, err)
+	requireNoFileContains(testSeed)
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Empty(t, backups)
+
+	// Saves with the same password are backed up
+	_, err = s.NewAddresses("t.wlt", []byte("pwd"), 1)
+	require.NoError(t, err)
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	old, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	oldSecrets := s.wallets.get("t.wlt").Secrets()
+
+	_, err = s.ChangePassword("t.wlt", []byte("pwd"), []byte("new"), "")
+	require.NoError(t, err)
+	requireNoFileContains(testSeed)
+	requireNoFileContains(oldSecrets)
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Empty(t, backups)
+
+	// The wallet file is backed up again on the next save
+	require.NoError(t, s.RenameWallet("t.wlt", "bar"))
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	b, err := ioutil.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.NotEqual(t, old, b)
+
+	// Deleting the wallet deletes its backups, including migration backups
+	require.NoError(t, ioutil.WriteFile(filename+".0.4."+BackupExt, old, 0600))
+	require.NoError(t, s.DeleteWallet("t.wlt"))
+	backups, err = listBackups(filename)
+	require.NoError(t, err)
+	require.Empty(t, backups)
+	_, err = os.Stat(filename + ".0.4." + BackupExt)
+	require.True(t, os.IsNotExist(err))
+
+	backups, err = listBackups(filepath.Join(dir, "t.wlt.wlt"))
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+}
+
+func TestServiceUpgradeInsecureWallets(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	// Use a weak work factor for the secure crypto type to keep the test fast
+	secure := cryptoTable[CryptoTypeScryptChacha20poly1305]
+	cryptoTable[CryptoTypeScryptChacha20poly1305] = cryptoTable[CryptoTypeScryptChacha20poly1305Insecure]
+	defer func() {
+		cryptoTable[CryptoTypeScryptChacha20poly1305] = secure
+	}()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeSha256Xor,
+	})
+	require.NoError(t, err)
+
+	for i, coin := range []CoinType{CoinTypeSkycoin, CoinTypeBitcoin} {
+		_, err := s.CreateWallet(fmt.Sprintf("%d.wlt", i), Options{
+			Type:     WalletTypeBip44,
+			Coin:     coin,
+			Seed:     testSeed,
+			Encrypt:  true,
+			Password: []byte(fmt.Sprintf("pwd%d", i)),
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = s.CreateWallet("2.wlt", Options{
+		Type: WalletTypeBip44,
+		Coin: CoinTypeEthereum,
+		Seed: testSeed,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"0.wlt", "1.wlt"}, s.InsecureWallets())
+
+	// Change the password of a wallet, keeping its crypto type
+	_, err = s.ChangePassword("0.wlt", []byte("wrong"), []byte("new"), "")
+	require.Equal(t, ErrInvalidPassword, err)
+	_, err = s.ChangePassword("2.wlt", []byte("pwd2"), []byte("new"), "")
+	require.Equal(t, ErrWalletNotEncrypted, err)
+	w, err := s.ChangePassword("0.wlt", []byte("pwd0"), []byte("new"), "")
+	require.NoError(t, err)
+	require.Equal(t, CryptoTypeSha256Xor, w.CryptoType())
+	require.NoError(t, s.ViewSecrets("0.wlt", []byte("new"), func(w Wallet) error {
+		require.Equal(t, testSeed, w.Seed())
+		return nil
+	}))
+
+	// No wallet is upgraded if a password is wrong
+	files := make(map[string][]byte)
+	for _, id := range s.InsecureWallets() {
+		b, err := ioutil.ReadFile(filepath.Join(dir, id))
+		require.NoError(t, err)
+		files[id] = b
+	}
+
+	_, err = s.UpgradeInsecureWallets(map[string][]byte{
+		"0.wlt": []byte("new"),
+		"1.wlt": []byte("pwd0"),
+	}, "")
+	require.Error(t, err)
+	for id, b := range files {
+		b2, err := ioutil.ReadFile(filepath.Join(dir, id))
+		require.NoError(t, err)
+		require.Equal(t, b, b2)
+	}
+
+	_, err = s.UpgradeInsecureWallets(map[string][]byte{
+		"2.wlt": []byte("pwd2"),
+	}, CryptoTypeScryptChacha20poly1305)
+	require.Error(t, err)
+
+	_, err = s.UpgradeInsecureWallets(map[string][]byte{
+		"0.wlt": []byte("new"),
+	}, CryptoTypeScryptChacha20poly1305Insecure)
+	require.Error(t, err)
+
+	upgraded, err := s.UpgradeInsecureWallets(map[string][]byte{
+		"0.wlt": []byte("new"),
+		"1.wlt": []byte("pwd1"),
+	}, CryptoTypeScryptChacha20poly1305)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0.wlt", "1.wlt"}, upgraded)
+	require.Empty(t, s.InsecureWallets())
+
+	// The upgraded wallets are saved encrypted, with their passwords
+	s2, err := NewService(s.config)
+	require.NoError(t, err)
+	require.Empty(t, s2.InsecureWallets())
+	for id, p := range map[string]string{"0.wlt": "new", "1.wlt": "pwd1"} {
+		w, err := s2.GetWallet(id)
+		require.NoError(t, err)
+		require.True(t, w.IsEncrypted())
+		require.Equal(t, CryptoTypeScryptChacha20poly1305, w.CryptoType())
+		require.Empty(t, w.Seed())
+		require.NoError(t, s2.ViewSecrets(id, []byte(p), func(w Wallet) error {
+			require.Equal(t, testSeed, w.Seed())
+			return nil
+		}))
+	}
+}
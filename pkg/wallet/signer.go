@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+)
+
+// ErrUnknownKeyPath is returned by a Signer which has no key at a derivation path
+var ErrUnknownKeyPath = NewError(errors.New("no key at the derivation path"))
+
+// ErrSignerPassword is returned when a password is provided to sign for a wallet with a signer, see Service.SetSigner
+var ErrSignerPassword = NewError(errors.New("wallet has a signer, a password must not be provided"))
+
+// SignRequest is a digest to sign with the key at a derivation path
+type SignRequest struct {
+	Path   string
+	Digest cipher.SHA256
+}
+
+// Signer signs digests with secret keys identified by their bip32 derivation path, e.g. m/84'/0'/0'/0/1.
+// Keys of collection wallets are identified by their address, or by their path if they were imported from an HD wallet.
+// Signatures are compact recoverable secp256k1 signatures [r|s|v], see cipher.SignHash.
+//
+// Bip44Wallet, CollectionWallet and DeterministicWallet sign with their own keys, which must be decrypted.
+// Keys of deterministic wallets are identified by their address.
+// RemoteSigner signs with the keys of a separate signer process.
+type Signer interface {
+	// PublicKey returns the public key at a derivation path
+	PublicKey(path string) (cipher.PubKey, error)
+	// SignDigest signs a digest with the key at a derivation path
+	SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error)
+	// SignTx signs the input digests of a transaction, returning a signature for each request.
+	// Either all the digests are signed, or none of them.
+	SignTx(reqs []SignRequest) ([]cipher.Sig, error)
+}
+
+// entryPather is implemented by the wallets which map their entries to the derivation paths of their keys, see Signer
+type entryPather interface {
+	EntryPath(e Entry) string
+}
+
+// digestSigner signs a digest with the key of an entry
+type digestSigner func(digest cipher.SHA256) (cipher.Sig, error)
+
+// secretKeySigner returns a digestSigner signing with the secret key of an entry
+func secretKeySigner(e Entry) digestSigner {
+	return func(digest cipher.SHA256) (cipher.Sig, error) {
+		if e.Secret.Null() {
+			return cipher.Sig{}, ErrWalletWatchOnly
+		}
+		return cipher.SignHash(digest, e.Secret)
+	}
+}
+
+// pathSigner returns a digestSigner signing with the key of an entry at its derivation path, by a Signer.
+// The signatures are checked against the entry's address, since a Signer may have another key at the path.
+func pathSigner(s Signer, path string, e Entry) digestSigner {
+	return func(digest cipher.SHA256) (cipher.Sig, error) {
+		sig, err := s.SignDigest(path, digest)
+		if err != nil {
+			return cipher.Sig{}, err
+		}
+
+		pk, err := cipher.PubKeyFromSig(sig, digest)
+		if err != nil || e.Address.Verify(pk) != nil {
+			return cipher.Sig{}, NewError(fmt.Errorf("signer's signature doesn't match the key of %s", e.Address))
+		}
+
+		return sig, nil
+	}
+}
+
+// EntryPath returns the bip32 derivation path of an entry
+func (w *Bip44Wallet) EntryPath(e Entry) string {
+	return fmt.Sprintf("%s/%d/%d", w.accountPath(e.Account), e.Change, e.ChildNumber)
+}
+
+// PublicKey returns the public key at a derivation path
+func (w *Bip44Wallet) PublicKey(path string) (cipher.PubKey, error) {
+	e, err := w.findEntry(path)
+	if err != nil {
+		return cipher.PubKey{}, err
+	}
+	return e.Public, nil
+}
+
+// SignDigest signs a digest with the key at a derivation path
+func (w *Bip44Wallet) SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error) {
+	return signEntryDigest(w, path, digest, w.findEntry)
+}
+
+// SignTx signs the input digests of a transaction, returning a signature for each request
+func (w *Bip44Wallet) SignTx(reqs []SignRequest) ([]cipher.Sig, error) {
+	return signTx(w, reqs)
+}
+
+func (w *Bip44Wallet) findEntry(path string) (Entry, error) {
+	return findPathEntry(w.GetEntries(), path, w.EntryPath)
+}
+
+// EntryPath returns the address of an entry. The HD key paths of entries imported from HD wallets
+// don't identify their keys, e.g. the keys of a Bitcoin Core wallet's previous seeds have the same paths.
+func (w *CollectionWallet) EntryPath(e Entry) string {
+	return e.Address.String()
+}
+
+// PublicKey returns the public key of an address, or at a derivation path
+func (w *CollectionWallet) PublicKey(path string) (cipher.PubKey, error) {
+	e, err := w.findEntry(path)
+	if err != nil {
+		return cipher.PubKey{}, err
+	}
+
+	if e.IsWatchOnly() {
+		return cipher.PubKey{}, ErrWalletWatchOnly
+	}
+
+	return e.Public, nil
+}
+
+// SignDigest signs a digest with the key of an address, or at a derivation path
+func (w *CollectionWallet) SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error) {
+	return signEntryDigest(w, path, digest, w.findEntry)
+}
+
+// SignTx signs the input digests of a transaction, returning a signature for each request
+func (w *CollectionWallet) SignTx(reqs []SignRequest) ([]cipher.Sig, error) {
+	return signTx(w, reqs)
+}
+
+// findEntry returns the entry of an address, or the first entry imported at an HD key path.
+// Callers must check the entry's key, since several entries may have the same path.
+func (w *CollectionWallet) findEntry(path string) (Entry, error) {
+	e, err := findPathEntry(w.Entries, path, w.EntryPath)
+	if err != ErrUnknownKeyPath {
+		return e, err
+	}
+
+	return findPathEntry(w.Entries, path, func(e Entry) string {
+		return e.HDKeyPath
+	})
+}
+
+// EntryPath returns the address of an entry, deterministic wallets have no derivation paths
+func (w *DeterministicWallet) EntryPath(e Entry) string {
+	return e.Address.String()
+}
+
+// PublicKey returns the public key of an address
+func (w *DeterministicWallet) PublicKey(path string) (cipher.PubKey, error) {
+	e, err := w.findEntry(path)
+	if err != nil {
+		return cipher.PubKey{}, err
+	}
+	return e.Public, nil
+}
+
+// SignDigest signs a digest with the key of an address
+func (w *DeterministicWallet) SignDigest(path string, digest cipher.SHA256) (cipher.Sig, error) {
+	return signEntryDigest(w, path, digest, w.findEntry)
+}
+
+// SignTx signs the input digests of a transaction, returning a signature for each request
+func (w *DeterministicWallet) SignTx(reqs []SignRequest) ([]cipher.Sig, error) {
+	return signTx(w, reqs)
+}
+
+func (w *DeterministicWallet) findEntry(path string) (Entry, error) {
+	return findPathEntry(w.Entries, path, w.EntryPath)
+}
+
+// findPathEntry returns the entry at a derivation path. Paths are compared by their nodes,
+// so hardened nodes may be marked with ' or h. Paths which aren't bip32 paths are compared as is.
+func findPathEntry(entries Entries, path string, entryPath func(Entry) string) (Entry, error) {
+	p, err := parseHDKeyPath(path)
+	for _, e := range entries {
+		ep := entryPath(e)
+		if ep == path {
+			return e, nil
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if q, err := parseHDKeyPath(ep); err == nil && samePath(p, q) {
+			return e, nil
+		}
+	}
+
+	return Entry{}, ErrUnknownKeyPath
+}
+
+func signEntryDigest(w Wallet, path string, digest cipher.SHA256, findEntry func(path string) (Entry, error)) (cipher.Sig, error) {
+	if w.IsEncrypted() {
+		return cipher.Sig{}, ErrWalletEncrypted
+	}
+
+	e, err := findEntry(path)
+	if err != nil {
+		return cipher.Sig{}, err
+	}
+
+	if e.Secret.Null() {
+		return cipher.Sig{}, ErrWalletWatchOnly
+	}
+
+	return cipher.SignHash(digest, e.Secret)
+}
+
+func signTx(s Signer, reqs []SignRequest) ([]cipher.Sig, error) {
+	sigs := make([]cipher.Sig, len(reqs))
+	for i, r := range reqs {
+		sig, err := s.SignDigest(r.Path, r.Digest)
+		if err != nil {
+			logger.WithError(err).WithField("input", i).Error("signTx: SignDigest failed")
+			return nil, err
+		}
+		sigs[i] = sig
+	}
+	return sigs, nil
+}
+
+// samePath returns true if two bip32 paths have the same nodes
+func samePath(a, b *bip32.Path) bool {
+	if len(a.Elements) != len(b.Elements) {
+		return false
+	}
+
+	for i := range a.Elements {
+		if a.Elements[i] != b.Elements[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,350 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+func TestBip44WalletSigner(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:        WalletTypeBip44,
+		Coin:        CoinTypeBitcoin,
+		Seed:        testVectorSeed,
+		AddressType: AddressTypeP2WPKH,
+		GenerateN:   2,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+	change, err := bw.GenerateChangeEntry(0)
+	require.NoError(t, err)
+
+	require.Equal(t, "m/84'/0'/0'/0/1", bw.EntryPath(bw.GetEntryAt(1)))
+	require.Equal(t, "m/84'/0'/0'/1/0", bw.EntryPath(change))
+
+	digest := cipher.SumSHA256([]byte("digest"))
+	for _, e := range w.GetEntries() {
+		path := bw.EntryPath(e)
+		pk, err := bw.PublicKey(path)
+		require.NoError(t, err)
+		require.Equal(t, e.Public, pk)
+
+		sig, err := bw.SignDigest(path, digest)
+		require.NoError(t, err)
+		require.NoError(t, cipher.VerifyPubKeySignedHash(e.Public, sig, digest))
+	}
+
+	// Hardened nodes may be marked with h
+	pk, err := bw.PublicKey("m/84h/0h/0h/1/0")
+	require.NoError(t, err)
+	require.Equal(t, change.Public, pk)
+
+	_, err = bw.SignDigest("m/84'/0'/0'/0/2", digest)
+	require.Equal(t, ErrUnknownKeyPath, err)
+	_, err = bw.SignDigest("m/44'/0'/0'/0/0", digest)
+	require.Equal(t, ErrUnknownKeyPath, err)
+
+	reqs := []SignRequest{
+		{Path: "m/84'/0'/0'/1/0", Digest: digest},
+		{Path: "m/84'/0'/0'/0/0", Digest: cipher.SumSHA256([]byte("other digest"))},
+	}
+	sigs, err := bw.SignTx(reqs)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	require.NoError(t, cipher.VerifyPubKeySignedHash(change.Public, sigs[0], reqs[0].Digest))
+	require.NoError(t, cipher.VerifyPubKeySignedHash(w.GetEntryAt(0).Public, sigs[1], reqs[1].Digest))
+
+	_, err = bw.SignTx(append(reqs, SignRequest{Path: "m/84'/0'/0'/0/9", Digest: digest}))
+	require.Equal(t, ErrUnknownKeyPath, err)
+
+	// Encrypted wallets sign once decrypted
+	require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, err = bw.SignDigest("m/84'/0'/0'/0/0", digest)
+	require.Equal(t, ErrWalletEncrypted, err)
+	require.NoError(t, GuardView(w, []byte("pwd"), func(w Wallet) error {
+		_, err := w.(Signer).SignDigest("m/84'/0'/0'/0/0", digest)
+		return err
+	}))
+}
+
+func TestCollectionWalletSigner(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.NoError(t, err)
+	cw := w.(*CollectionWallet)
+
+	addr, err := cw.ImportSecretKey(CoinTypeBitcoin, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn")
+	require.NoError(t, err)
+	e := cw.GetEntryAt(0)
+
+	sk, err := cipher.NewSecKey(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+	pk := cipher.MustPubKeyFromSecKey(sk)
+	require.NoError(t, cw.AddEntry(Entry{
+		Address:   cipher.BitcoinAddressFromPubKey(pk),
+		Public:    pk,
+		Secret:    sk,
+		HDKeyPath: "m/0'/0'/5'",
+	}))
+
+	// The keys of previous seeds of an HD wallet may have the same path
+	sk2, err := cipher.NewSecKey(bytes.Repeat([]byte{4}, 32))
+	require.NoError(t, err)
+	pk2 := cipher.MustPubKeyFromSecKey(sk2)
+	addr2 := cipher.BitcoinAddressFromPubKey(pk2)
+	require.NoError(t, cw.AddEntry(Entry{
+		Address:   addr2,
+		Public:    pk2,
+		Secret:    sk2,
+		HDKeyPath: "m/0'/0'/5'",
+	}))
+	require.Equal(t, addr2.String(), cw.EntryPath(cw.GetEntryAt(2)))
+
+	// Keys are identified by their address, or by their HD key path
+	digest := cipher.SumSHA256([]byte("digest"))
+	sig, err := cw.SignDigest(addr.String(), digest)
+	require.NoError(t, err)
+	require.NoError(t, cipher.VerifyPubKeySignedHash(e.Public, sig, digest))
+
+	sig, err = cw.SignDigest(addr2.String(), digest)
+	require.NoError(t, err)
+	require.NoError(t, cipher.VerifyPubKeySignedHash(pk2, sig, digest))
+
+	p, err := cw.PublicKey(addr2.String())
+	require.NoError(t, err)
+	require.Equal(t, pk2, p)
+
+	p, err = cw.PublicKey("m/0h/0h/5h")
+	require.NoError(t, err)
+	require.Equal(t, pk, p)
+
+	_, err = cw.PublicKey(cipher.BitcoinAddressFromPubKey(cipher.MustPubKeyFromSecKey(cipher.MustNewSecKey(bytes.Repeat([]byte{3}, 32)))).String())
+	require.Equal(t, ErrUnknownKeyPath, err)
+
+	// Watch-only entries can't sign
+	ww, err := NewWallet("watch.wlt", Options{
+		Type:      WalletTypeCollection,
+		Coin:      CoinTypeBitcoin,
+		WatchOnly: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, ww.(*CollectionWallet).AddWatchAddress(addr.String(), ""))
+	_, err = ww.(*CollectionWallet).SignDigest(addr.String(), digest)
+	require.Equal(t, ErrWalletWatchOnly, err)
+}
+
+func TestDeterministicWalletSigner(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeDeterministic,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	dw := w.(*DeterministicWallet)
+
+	// Keys are identified by their address
+	e := dw.GetEntryAt(1)
+	digest := cipher.SumSHA256([]byte("digest"))
+	sig, err := dw.SignDigest(e.Address.String(), digest)
+	require.NoError(t, err)
+	require.NoError(t, cipher.VerifyPubKeySignedHash(e.Public, sig, digest))
+
+	pk, err := dw.PublicKey(e.Address.String())
+	require.NoError(t, err)
+	require.Equal(t, e.Public, pk)
+
+	_, err = dw.SignDigest("m/0/1", digest)
+	require.Equal(t, ErrUnknownKeyPath, err)
+
+	require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, err = dw.SignDigest(e.Address.String(), digest)
+	require.Equal(t, ErrWalletEncrypted, err)
+}
+
+func TestRemoteSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+	socket := filepath.Join(dir, "signer.sock")
+
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testVectorSeed,
+		GenerateN: 3,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+
+	// Files which aren't sockets aren't replaced
+	require.NoError(t, ioutil.WriteFile(socket, []byte("foo"), 0600))
+	require.Error(t, NewSignerServer(bw).ListenAndServe(socket))
+	b, err := ioutil.ReadFile(socket)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), b)
+	require.NoError(t, os.Remove(socket))
+
+	// A socket left behind is replaced
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	srv := NewSignerServer(bw)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe(socket)
+	}()
+
+	// Wait for the server to listen
+	for i := 0; ; i++ {
+		c, err := net.Dial("unix", socket)
+		if err == nil {
+			c.Close()
+			break
+		}
+		require.True(t, i < 100, "signer server didn't start: %v", err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fi, err := os.Stat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	rs := NewRemoteSigner(socket)
+	digest := cipher.SumSHA256([]byte("digest"))
+
+	e := bw.GetEntryAt(2)
+	path := bw.EntryPath(e)
+	pk, err := rs.PublicKey(path)
+	require.NoError(t, err)
+	require.Equal(t, e.Public, pk)
+
+	sig, err := rs.SignDigest(path, digest)
+	require.NoError(t, err)
+	require.NoError(t, cipher.VerifyPubKeySignedHash(e.Public, sig, digest))
+
+	reqs := make([]SignRequest, w.EntriesLen())
+	for i, e := range w.GetEntries() {
+		reqs[i] = SignRequest{
+			Path:   bw.EntryPath(e),
+			Digest: cipher.SumSHA256([]byte{byte(i)}),
+		}
+	}
+	sigs, err := rs.SignTx(reqs)
+	require.NoError(t, err)
+	require.Len(t, sigs, len(reqs))
+	for i, e := range w.GetEntries() {
+		require.NoError(t, cipher.VerifyPubKeySignedHash(e.Public, sigs[i], reqs[i].Digest))
+	}
+
+	// Signer errors are returned to the client
+	_, err = rs.SignDigest("m/44'/8000'/0'/0/9", digest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrUnknownKeyPath.Error())
+
+	// A connection serves several requests
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, writeSignerMessage(conn, signerRequest{
+			Method: signerMethodPublicKey,
+			Inputs: []signerRequestItem{{Path: path}},
+		}))
+		var resp signerResponse
+		require.NoError(t, readSignerMessage(conn, &resp))
+		require.Empty(t, resp.Error)
+		require.Equal(t, e.Public.Hex(), resp.PublicKey)
+	}
+
+	require.NoError(t, writeSignerMessage(conn, signerRequest{
+		Method: signerMethodSignDigest,
+		Inputs: []signerRequestItem{{Path: path, Digest: "00"}},
+	}))
+	var resp signerResponse
+	require.NoError(t, readSignerMessage(conn, &resp))
+	require.Equal(t, "invalid digest of input 0", resp.Error)
+
+	// Oversized messages are refused
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], MaxSignerMessageSize+1)
+	require.Error(t, readSignerMessage(bytes.NewReader(length[:]), &resp))
+
+	// Closing the server closes its connections
+	require.NoError(t, srv.Close())
+	require.NoError(t, <-done)
+	require.Error(t, readSignerMessage(conn, &resp))
+	conn.Close()
+
+	_, err = rs.PublicKey(path)
+	require.Error(t, err)
+
+	// The socket and its temporary directory are removed
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestServiceViewSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testSeed,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+
+	path := "m/44'/0'/0'/0/0"
+	digest := cipher.SumSHA256([]byte("digest"))
+	var sig cipher.Sig
+	require.NoError(t, s.ViewSigner(w.Filename(), []byte("pwd"), func(signer Signer) error {
+		var err error
+		sig, err = signer.SignDigest(path, digest)
+		return err
+	}))
+	require.NoError(t, cipher.VerifyPubKeySignedHash(w.GetEntryAt(0).Public, sig, digest))
+
+	err = s.ViewSigner(w.Filename(), nil, func(Signer) error { return nil })
+	require.Equal(t, ErrMissingPassword, err)
+
+	// A signer set for the wallet is used instead of its keys
+	other, err := NewWallet("other.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SetSigner(w.Filename(), other.(Signer)))
+	require.NoError(t, s.ViewSigner(w.Filename(), nil, func(signer Signer) error {
+		pk, err := signer.PublicKey(path)
+		require.NoError(t, err)
+		require.Equal(t, other.GetEntryAt(0).Public, pk)
+		return nil
+	}))
+
+	require.NoError(t, s.SetSigner(w.Filename(), nil))
+	require.Equal(t, ErrWalletNotExist, s.SetSigner("missing.wlt", other.(Signer)))
+}
@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/coin"
+	"github.com/SkycoinProject/skycoin/src/transaction"
+)
+
+var (
+	// ErrSkycoinTransactionCoin is returned when creating a skycoin transaction with a wallet which isn't a skycoin wallet
+	ErrSkycoinTransactionCoin = NewError(errors.New("skycoin transactions can only be created by skycoin wallets"))
+	// ErrNilUnspentOutputsFinder is returned when creating a skycoin transaction without an UnspentOutputsFinder
+	ErrNilUnspentOutputsFinder = NewError(errors.New("unspent outputs finder is nil"))
+	// ErrWalletChanged is returned when a wallet changed while the service created a transaction with it
+	ErrWalletChanged = NewError(errors.New("wallet changed while the transaction was created, create it again"))
+)
+
+// UnspentOutputsFinder returns the unspent outputs of skycoin addresses.
+// It's backed by a skycoin node or indexer.
+type UnspentOutputsFinder interface {
+	// UnspentOutputs returns the unspent outputs of addresses, and the time of the head block
+	// which the coin hours of the outputs are calculated at
+	UnspentOutputs(addrs []cipher.Address) (coin.AddressUxOuts, uint64, error)
+}
+
+// CreateTransactionParams defines the outputs of a skycoin transaction and which wallet addresses spend them
+type CreateTransactionParams struct {
+	// HoursSelection distributes the coin hours left after the burn fee, see transaction.HoursSelection
+	HoursSelection transaction.HoursSelection
+	// To are the outputs of the transaction
+	To []coin.TransactionOutput
+	// ChangeAddress receives the change. If nil, bip44 wallets use the next address of the account's change chain,
+	// which is appended to the wallet, and other wallets the spent address whose bytes sort first.
+	ChangeAddress *cipher.Address
+	// Addresses restricts the spent outputs to those of these wallet addresses, otherwise any address of the wallet
+	// (or of the account, for bip44 wallets) with a secret key spends
+	Addresses []cipher.Address
+	// Account is the bip44 account which spends, ignored by other wallets
+	Account uint32
+}
+
+// CreateTransaction creates a skycoin transaction spending the unspent outputs of a wallet's addresses,
+// returned by an UnspentOutputsFinder, and signs its inputs with the keys of the wallet's entries.
+// The outputs are chosen and the coin hours distributed after the burn fee by transaction.Create.
+// The spent outputs are returned with the transaction, in the order of its inputs.
+// The wallet must be decrypted, e.g. with GuardUpdate, and bip44 wallets must be saved after if the change
+// address was generated.
+func CreateTransaction(w Wallet, p CreateTransactionParams, uf UnspentOutputsFinder) (*coin.Transaction, []transaction.UxBalance, error) {
+	return createTransaction(w, p, uf, nil)
+}
+
+// createTransaction creates a skycoin transaction, see CreateTransaction. If s is nil, the inputs are signed with
+// the secret keys of the wallet's entries. Otherwise they are signed by s, with the keys at the derivation paths
+// of the entries, and the entries don't need secret keys.
+func createTransaction(w Wallet, p CreateTransactionParams, uf UnspentOutputsFinder, s Signer) (*coin.Transaction, []transaction.UxBalance, error) {
+	if w.Coin() != CoinTypeSkycoin {
+		return nil, nil, ErrSkycoinTransactionCoin
+	}
+
+	var ep entryPather
+	if s == nil {
+		if w.IsEncrypted() {
+			return nil, nil, ErrWalletEncrypted
+		}
+	} else {
+		var ok bool
+		if ep, ok = w.(entryPather); !ok {
+			return nil, nil, NewError(fmt.Errorf("%q wallets can't sign with a signer", w.Type()))
+		}
+	}
+
+	if uf == nil {
+		return nil, nil, ErrNilUnspentOutputsFinder
+	}
+
+	spendable, err := spendableEntries(w, p, s == nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addrs := make([]cipher.Address, len(spendable))
+	entries := make(map[cipher.Address]Entry, len(spendable))
+	for i, e := range spendable {
+		addrs[i] = e.SkycoinAddress()
+		entries[addrs[i]] = e
+	}
+
+	auxs, headTime, err := uf.UnspentOutputs(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Outputs of other addresses can't be signed
+	for a, uxa := range auxs {
+		if _, ok := entries[a]; !ok {
+			return nil, nil, fmt.Errorf("unspent outputs finder returned outputs of an unrequested address %s", a)
+		}
+
+		for _, ux := range uxa {
+			if ux.Body.Address != a {
+				return nil, nil, fmt.Errorf("unspent output %s is not owned by %s", ux.Hash().Hex(), a)
+			}
+		}
+	}
+
+	params := transaction.Params{
+		HoursSelection: p.HoursSelection,
+		To:             p.To,
+		ChangeAddress:  p.ChangeAddress,
+	}
+
+	// The change address of bip44 wallets is only appended to the wallet if the transaction has change
+	bw, ok := w.(*Bip44Wallet)
+	if ok && p.ChangeAddress == nil {
+		e, err := bw.PeekChangeEntry(p.Account)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		a := e.SkycoinAddress()
+		params.ChangeAddress = &a
+	}
+
+	txn, inputs, err := transaction.Create(params, auxs, headTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if generatesChangeEntry(w, p, txn) {
+		if _, err := bw.GenerateChangeEntry(p.Account); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if s == nil {
+		keys := make([]cipher.SecKey, len(inputs))
+		for i, in := range inputs {
+			keys[i] = entries[in.Address].Secret
+		}
+
+		txn.SignInputs(keys)
+	} else if err := signInputs(txn, inputs, s, func(a cipher.Address) string {
+		return ep.EntryPath(entries[a])
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	if err := txn.Verify(); err != nil {
+		logger.Critical().WithError(err).Error("CreateTransaction created an invalid transaction")
+		return nil, nil, err
+	}
+
+	return txn, inputs, nil
+}
+
+// generatesChangeEntry returns true if a transaction created by a wallet appended a change entry to it,
+// i.e. the wallet is a bip44 wallet which generated the change address of the transaction's change output
+func generatesChangeEntry(w Wallet, p CreateTransactionParams, txn *coin.Transaction) bool {
+	_, ok := w.(*Bip44Wallet)
+	return ok && p.ChangeAddress == nil && len(txn.Out) > len(p.To)
+}
+
+// signInputs signs the inputs of a transaction with a Signer, with the keys at the derivation paths of their addresses.
+// The signatures are checked against the addresses, since a Signer may have other keys at the paths.
+func signInputs(txn *coin.Transaction, inputs []transaction.UxBalance, s Signer, addressPath func(cipher.Address) string) error {
+	txn.InnerHash = txn.HashInner()
+
+	reqs := make([]SignRequest, len(inputs))
+	for i, in := range inputs {
+		reqs[i] = SignRequest{
+			Path:   addressPath(in.Address),
+			Digest: cipher.AddSHA256(txn.InnerHash, txn.In[i]),
+		}
+	}
+
+	sigs, err := s.SignTx(reqs)
+	if err != nil {
+		return err
+	}
+
+	if len(sigs) != len(reqs) {
+		return fmt.Errorf("signer returned %d signatures for %d inputs", len(sigs), len(reqs))
+	}
+
+	for i, sig := range sigs {
+		if err := cipher.VerifyAddressSignedHash(inputs[i].Address, sig, reqs[i].Digest); err != nil {
+			return NewError(fmt.Errorf("signer's signature doesn't match the key of %s", inputs[i].Address))
+		}
+	}
+
+	txn.Sigs = sigs
+	return nil
+}
+
+// spendableEntries returns the entries of the wallet addresses which spend a transaction.
+// If needSecrets is true, only entries with a secret key spend.
+func spendableEntries(w Wallet, p CreateTransactionParams, needSecrets bool) (Entries, error) {
+	var candidates Entries
+	if bw, ok := w.(*Bip44Wallet); ok {
+		if !bw.hasAccount(p.Account) {
+			return nil, NewError(fmt.Errorf("bip44 account %d does not exist", p.Account))
+		}
+
+		external, change := bw.GetAccountEntries(p.Account)
+		candidates = append(external, change...)
+	} else {
+		candidates = w.GetEntries()
+	}
+
+	if len(p.Addresses) == 0 {
+		var entries Entries
+		for _, e := range candidates {
+			if !needSecrets || !e.Secret.Null() {
+				entries = append(entries, e)
+			}
+		}
+
+		if len(entries) == 0 {
+			return nil, ErrWalletWatchOnly
+		}
+
+		return entries, nil
+	}
+
+	entries := make(Entries, 0, len(p.Addresses))
+	for _, a := range p.Addresses {
+		e, ok := candidates.get(a)
+		if !ok {
+			return nil, ErrUnknownAddress
+		}
+
+		if needSecrets && e.Secret.Null() {
+			return nil, ErrWalletWatchOnly
+		}
+
+		if entries.has(a) {
+			return nil, NewError(fmt.Errorf("duplicate address %s", a))
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
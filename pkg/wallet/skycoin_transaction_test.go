@@ -0,0 +1,451 @@
+package wallet
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+	"github.com/SkycoinProject/skycoin/src/coin"
+	"github.com/SkycoinProject/skycoin/src/params"
+	"github.com/SkycoinProject/skycoin/src/transaction"
+	"github.com/SkycoinProject/skycoin/src/util/fee"
+)
+
+const mockHeadTime = 1000
+
+// mockUnspentOutputsFinder returns the outputs of a set which are owned by the requested addresses
+type mockUnspentOutputsFinder coin.UxArray
+
+func (uf mockUnspentOutputsFinder) UnspentOutputs(addrs []cipher.Address) (coin.AddressUxOuts, uint64, error) {
+	auxs := make(coin.AddressUxOuts)
+	for _, a := range addrs {
+		for _, ux := range uf {
+			if ux.Body.Address == a {
+				auxs[a] = append(auxs[a], ux)
+			}
+		}
+	}
+	return auxs, mockHeadTime, nil
+}
+
+func makeUxOut(addr cipher.Address, coins, hours, seq uint64) coin.UxOut {
+	return coin.UxOut{
+		Head: coin.UxHead{
+			Time:  mockHeadTime,
+			BkSeq: seq,
+		},
+		Body: coin.UxBody{
+			SrcTransaction: cipher.SumSHA256([]byte{byte(seq)}),
+			Address:        addr,
+			Coins:          coins,
+			Hours:          hours,
+		},
+	}
+}
+
+func shareHours(factor string) transaction.HoursSelection {
+	d := decimal.RequireFromString(factor)
+	return transaction.HoursSelection{
+		Type:        transaction.HoursSelectionTypeAuto,
+		Mode:        transaction.HoursSelectionModeShare,
+		ShareFactor: &d,
+	}
+}
+
+func TestCreateTransactionBip44(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testVectorSeed,
+		GenerateN: 3,
+	})
+	require.NoError(t, err)
+	bw := w.(*Bip44Wallet)
+
+	to := cipher.MustDecodeBase58Address("2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv")
+	uf := mockUnspentOutputsFinder{
+		makeUxOut(w.GetEntryAt(0).SkycoinAddress(), 10e6, 100, 1),
+		makeUxOut(w.GetEntryAt(1).SkycoinAddress(), 5e6, 50, 2),
+		makeUxOut(w.GetEntryAt(2).SkycoinAddress(), 1e6, 10, 3),
+	}
+
+	change, err := bw.PeekChangeEntry(0)
+	require.NoError(t, err)
+
+	txn, inputs, err := CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 12e6}},
+	}, uf)
+	require.NoError(t, err)
+
+	// The outputs with the most coins are spent, and the change goes to the next change address
+	require.Len(t, inputs, 2)
+	require.Equal(t, uf[0].Hash(), inputs[0].Hash)
+	require.Equal(t, uf[1].Hash(), inputs[1].Hash)
+	require.Equal(t, []cipher.SHA256{uf[0].Hash(), uf[1].Hash()}, txn.In)
+	require.Len(t, txn.Out, 2)
+	require.Equal(t, to, txn.Out[0].Address)
+	require.Equal(t, uint64(12e6), txn.Out[0].Coins)
+	require.Equal(t, change.SkycoinAddress(), txn.Out[1].Address)
+	require.Equal(t, uint64(3e6), txn.Out[1].Coins)
+
+	// 150 hours are spent, 15 are burned and half of the rest is sent
+	require.Equal(t, uint64(67), txn.Out[0].Hours)
+	require.Equal(t, uint64(68), txn.Out[1].Hours)
+	f, err := fee.TransactionFee(txn, mockHeadTime, coin.UxArray{uf[0], uf[1]})
+	require.NoError(t, err)
+	require.NoError(t, fee.VerifyTransactionFee(txn, f, params.UserVerifyTxn.BurnFactor))
+
+	require.NoError(t, txn.Verify())
+	require.NoError(t, txn.VerifyInputSignatures(coin.UxArray{uf[0], uf[1]}))
+
+	// The change address is appended to the change chain
+	_, changeEntries := bw.GetAccountEntries(0)
+	require.Len(t, changeEntries, 1)
+	require.Equal(t, change.Address, changeEntries[0].Address)
+
+	// Without change, no change address is generated
+	txn, inputs, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("1"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 16e6}},
+	}, uf)
+	require.NoError(t, err)
+	require.Len(t, inputs, 3)
+	require.Len(t, txn.Out, 1)
+	require.Equal(t, uint64(144), txn.Out[0].Hours)
+	_, changeEntries = bw.GetAccountEntries(0)
+	require.Len(t, changeEntries, 1)
+
+	// The spent outputs are restricted to the requested addresses, and the change address can be set
+	txn, inputs, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: transaction.HoursSelection{Type: transaction.HoursSelectionTypeManual},
+		To:             []coin.TransactionOutput{{Address: to, Coins: 2e6, Hours: 10}},
+		ChangeAddress:  &to,
+		Addresses:      []cipher.Address{w.GetEntryAt(1).SkycoinAddress(), w.GetEntryAt(2).SkycoinAddress()},
+	}, uf)
+	require.NoError(t, err)
+	require.Len(t, inputs, 1)
+	require.Equal(t, uf[1].Hash(), inputs[0].Hash)
+	require.Len(t, txn.Out, 2)
+	require.Equal(t, to, txn.Out[1].Address)
+	require.Equal(t, uint64(10), txn.Out[0].Hours)
+	require.Equal(t, uint64(35), txn.Out[1].Hours)
+	require.NoError(t, txn.VerifyInputSignatures(coin.UxArray{uf[1]}))
+	_, changeEntries = bw.GetAccountEntries(0)
+	require.Len(t, changeEntries, 1)
+
+	_, _, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 7e6}},
+		Addresses:      []cipher.Address{w.GetEntryAt(1).SkycoinAddress(), w.GetEntryAt(2).SkycoinAddress()},
+	}, uf)
+	require.Equal(t, transaction.ErrInsufficientBalance, err)
+
+	_, _, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 1e6}},
+		Addresses:      []cipher.Address{to},
+	}, uf)
+	require.Equal(t, ErrUnknownAddress, err)
+
+	// Accounts spend their own outputs only
+	_, err = bw.NewAccount()
+	require.NoError(t, err)
+	_, _, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 1e6}},
+		Account:        1,
+	}, uf)
+	require.Equal(t, transaction.ErrNoUnspents, err)
+
+	_, _, err = CreateTransaction(w, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 1e6}},
+		Account:        2,
+	}, uf)
+	require.Error(t, err)
+}
+
+func TestCreateTransactionErrors(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeSkycoin,
+	})
+	require.NoError(t, err)
+	cw := w.(*CollectionWallet)
+	a1, err := cw.ImportSecretKey(CoinTypeSkycoin, "0000000000000000000000000000000000000000000000000000000000000001")
+	require.NoError(t, err)
+	a2, err := cw.ImportSecretKey(CoinTypeSkycoin, "0000000000000000000000000000000000000000000000000000000000000002")
+	require.NoError(t, err)
+
+	to := cipher.MustDecodeBase58Address("2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv")
+	uf := mockUnspentOutputsFinder{
+		makeUxOut(a1.(cipher.Address), 2e6, 20, 1),
+		makeUxOut(a2.(cipher.Address), 2e6, 20, 2),
+	}
+	p := CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 3e6}},
+	}
+
+	// Without a change address, the change goes to the spent address whose bytes sort first
+	txn, _, err := CreateTransaction(w, p, uf)
+	require.NoError(t, err)
+	require.Len(t, txn.Out, 2)
+	first := a1.(cipher.Address)
+	if string(a2.Bytes()) < string(a1.Bytes()) {
+		first = a2.(cipher.Address)
+	}
+	require.Equal(t, first, txn.Out[1].Address)
+
+	_, _, err = CreateTransaction(w, p, nil)
+	require.Equal(t, ErrNilUnspentOutputsFinder, err)
+
+	// Outputs of addresses which weren't requested are refused
+	foreign := mockUnspentOutputsFinder{makeUxOut(to, 5e6, 20, 3)}
+	_, _, err = CreateTransaction(w, p, unspentOutputsFinderFunc(func([]cipher.Address) (coin.AddressUxOuts, uint64, error) {
+		return coin.AddressUxOuts{to: coin.UxArray(foreign)}, mockHeadTime, nil
+	}))
+	require.Error(t, err)
+	_, _, err = CreateTransaction(w, p, unspentOutputsFinderFunc(func([]cipher.Address) (coin.AddressUxOuts, uint64, error) {
+		return coin.AddressUxOuts{a1.(cipher.Address): coin.UxArray(foreign)}, mockHeadTime, nil
+	}))
+	require.Error(t, err)
+
+	p.Addresses = []cipher.Address{a1.(cipher.Address), a1.(cipher.Address)}
+	_, _, err = CreateTransaction(w, p, uf)
+	require.Error(t, err)
+
+	// Encrypted and watch-only wallets can't spend
+	require.NoError(t, Lock(w, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	_, _, err = CreateTransaction(w, p, uf)
+	require.Equal(t, ErrWalletEncrypted, err)
+
+	xw, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeCollection,
+		Coin:      CoinTypeSkycoin,
+		WatchOnly: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, xw.(*CollectionWallet).AddEntry(Entry{Address: a1}))
+	_, _, err = CreateTransaction(xw, CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 1e6}},
+	}, uf)
+	require.Equal(t, ErrWalletWatchOnly, err)
+
+	bw, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	_, _, err = CreateTransaction(bw, p, uf)
+	require.Equal(t, ErrSkycoinTransactionCoin, err)
+}
+
+type unspentOutputsFinderFunc func(addrs []cipher.Address) (coin.AddressUxOuts, uint64, error)
+
+func (f unspentOutputsFinderFunc) UnspentOutputs(addrs []cipher.Address) (coin.AddressUxOuts, uint64, error) {
+	return f(addrs)
+}
+
+func TestServiceCreateTransaction(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+
+	to := cipher.MustDecodeBase58Address("2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv")
+	p := CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: to, Coins: 1e6}},
+	}
+
+	_, _, err = s.CreateTransaction(w.Filename(), []byte("pwd"), p)
+	require.Equal(t, ErrNilUnspentOutputsFinder, err)
+
+	uf := mockUnspentOutputsFinder{makeUxOut(w.GetEntryAt(1).SkycoinAddress(), 2e6, 20, 1)}
+	s.SetUnspentOutputsFinder(uf)
+
+	_, _, err = s.CreateTransaction(w.Filename(), nil, p)
+	require.Equal(t, ErrMissingPassword, err)
+
+	txn, inputs, err := s.CreateTransaction(w.Filename(), []byte("pwd"), p)
+	require.NoError(t, err)
+	require.Len(t, inputs, 1)
+	require.NoError(t, txn.VerifyInputSignatures(coin.UxArray{uf[0]}))
+
+	// The change address is saved in the wallet, which is still encrypted
+	s, err = NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+	w, err = s.GetWallet(w.Filename())
+	require.NoError(t, err)
+	require.True(t, w.IsEncrypted())
+	_, changeEntries := w.(*Bip44Wallet).GetAccountEntries(0)
+	require.Len(t, changeEntries, 1)
+	require.Equal(t, changeEntries[0].SkycoinAddress(), txn.Out[1].Address)
+}
+
+func TestServiceCreateTransactionWithSigner(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	// Skycoin transactions of an encrypted wallet are signed by a remote signer, without the wallet's password
+	w, err := s.CreateWallet("sky.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+
+	skySigner, err := NewWallet("sky.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+
+	socket := filepath.Join(dir, "sky.sock")
+	srv := NewSignerServer(skySigner.(Signer))
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe(socket)
+	}()
+	defer func() {
+		require.NoError(t, srv.Close())
+		require.NoError(t, <-done)
+	}()
+	for i := 0; ; i++ {
+		c, err := net.Dial("unix", socket)
+		if err == nil {
+			c.Close()
+			break
+		}
+		require.True(t, i < 100, "signer server didn't start: %v", err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.NoError(t, s.SetSigner("sky.wlt", NewRemoteSigner(socket)))
+
+	uf := mockUnspentOutputsFinder{makeUxOut(w.GetEntryAt(1).SkycoinAddress(), 2e6, 20, 1)}
+	s.SetUnspentOutputsFinder(uf)
+
+	p := CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: cipher.MustDecodeBase58Address("2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv"), Coins: 1e6}},
+	}
+
+	// The change address of an encrypted wallet can't be generated
+	_, _, err = s.CreateTransaction("sky.wlt", nil, p)
+	require.Equal(t, ErrWalletEncrypted, err)
+
+	change := w.GetEntryAt(0).SkycoinAddress()
+	p.ChangeAddress = &change
+	_, _, err = s.CreateTransaction("sky.wlt", []byte("pwd"), p)
+	require.Equal(t, ErrSignerPassword, err)
+
+	txn, inputs, err := s.CreateTransaction("sky.wlt", nil, p)
+	require.NoError(t, err)
+	require.Len(t, inputs, 1)
+	require.NoError(t, txn.VerifyInputSignatures(coin.UxArray{uf[0]}))
+}
+
+func TestServiceCreateTransactionWalletChanged(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+
+	// The service isn't locked while the unspent outputs are fetched, so the wallet can change meanwhile
+	uxs := coin.UxArray{makeUxOut(w.GetEntryAt(1).SkycoinAddress(), 2e6, 20, 1)}
+	var changeWallet bool
+	s.SetUnspentOutputsFinder(unspentOutputsFinderFunc(func(addrs []cipher.Address) (coin.AddressUxOuts, uint64, error) {
+		if changeWallet {
+			if err := s.Update("t.wlt", func(w Wallet) error {
+				_, err := w.(*Bip44Wallet).GenerateChangeEntry(0)
+				return err
+			}); err != nil {
+				return nil, 0, err
+			}
+		}
+		return mockUnspentOutputsFinder(uxs).UnspentOutputs(addrs)
+	}))
+
+	p := CreateTransactionParams{
+		HoursSelection: shareHours("0.5"),
+		To:             []coin.TransactionOutput{{Address: cipher.MustDecodeBase58Address("2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv"), Coins: 1e6}},
+	}
+
+	// The change address the transaction was created with is now used by the wallet
+	changeWallet = true
+	_, _, err = s.CreateTransaction("t.wlt", nil, p)
+	require.Equal(t, ErrWalletChanged, err)
+	w, err = s.GetWallet("t.wlt")
+	require.NoError(t, err)
+	_, changeEntries := w.(*Bip44Wallet).GetAccountEntries(0)
+	require.Len(t, changeEntries, 1)
+
+	changeWallet = false
+	txn, _, err := s.CreateTransaction("t.wlt", nil, p)
+	require.NoError(t, err)
+	w, err = s.GetWallet("t.wlt")
+	require.NoError(t, err)
+	_, changeEntries = w.(*Bip44Wallet).GetAccountEntries(0)
+	require.Len(t, changeEntries, 2)
+	require.Equal(t, changeEntries[1].SkycoinAddress(), txn.Out[1].Address)
+
+	// Transactions without change don't change the wallet
+	p.To[0].Coins = 2e6
+	_, _, err = s.CreateTransaction("t.wlt", nil, p)
+	require.NoError(t, err)
+	w, err = s.GetWallet("t.wlt")
+	require.NoError(t, err)
+	_, changeEntries = w.(*Bip44Wallet).GetAccountEntries(0)
+	require.Len(t, changeEntries, 2)
+}
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/slip39"
+)
+
+// ErrInvalidSlip39Seed is returned for slip39 master secrets that are not hex encoded,
+// shorter than 128 bits or of an odd length
+var ErrInvalidSlip39Seed = NewError(errors.New("slip39 seed must be a hex encoded master secret of at least 16 bytes and an even length"))
+
+// decodeSlip39Seed decodes the hex encoded master secret of a slip39 wallet, which is the bip32 seed
+func decodeSlip39Seed(seed string) ([]byte, error) {
+	b, err := hex.DecodeString(seed)
+	if err != nil || len(b) < 16 || len(b)%2 != 0 {
+		return nil, ErrInvalidSlip39Seed
+	}
+	return b, nil
+}
+
+// Slip39Shares splits the master secret of a bip44 wallet into SLIP-39 mnemonic shares.
+// The master secret is the bip32 seed of the wallet, so a wallet restored from the shares
+// with NewWalletFromSlip39 derives the same addresses. Electrum seeds are not supported.
+// The wallet must not be encrypted, use GuardView to split the seed of an encrypted wallet.
+func (w *Bip44Wallet) Slip39Shares(groupThreshold uint8, groups []slip39.Group, passphrase []byte, iterationExponent uint8) ([][]string, error) {
+	if w.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+
+	if w.Meta.SeedType().IsElectrum() {
+		return nil, NewError(errors.New("electrum seeds can't be split into slip39 shares"))
+	}
+
+	seed, err := w.seed()
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := slip39.GenerateMnemonics(groupThreshold, groups, seed, passphrase, iterationExponent)
+	if err != nil {
+		return nil, NewError(err)
+	}
+
+	return shares, nil
+}
+
+// NewWalletFromSlip39 creates a bip44 wallet from SLIP-39 mnemonic shares, decrypting
+// the master secret with the passphrase the shares were generated with.
+// The other options are those of NewWallet, opts.Seed and opts.SeedPassphrase must not be set.
+func NewWalletFromSlip39(wltName string, mnemonics []string, passphrase []byte, opts Options) (Wallet, error) {
+	opts, err := slip39Options(mnemonics, passphrase, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWallet(wltName, opts)
+}
+
+// slip39Options returns the wallet options of a bip44 wallet with the master secret of the shares
+func slip39Options(mnemonics []string, passphrase []byte, opts Options) (Options, error) {
+	if opts.Type == "" {
+		opts.Type = WalletTypeBip44
+	}
+	if opts.Type != WalletTypeBip44 {
+		return opts, NewError(fmt.Errorf("slip39 shares can only be restored into %q wallets", WalletTypeBip44))
+	}
+
+	if opts.Seed != "" {
+		return opts, NewError(errors.New("seed should not be provided, it is recovered from the slip39 shares"))
+	}
+
+	masterSecret, err := slip39.CombineMnemonics(mnemonics, passphrase)
+	if err != nil {
+		return opts, NewError(err)
+	}
+
+	opts.Seed = hex.EncodeToString(masterSecret)
+	opts.SeedType = SeedTypeSlip39
+
+	return opts, nil
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/slip39"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBip44WalletSlip39Shares(t *testing.T) {
+	for _, coin := range []CoinType{CoinTypeSkycoin, CoinTypeBitcoin, CoinTypeEthereum} {
+		t.Run(string(coin), func(t *testing.T) {
+			w, err := NewWallet("test.wlt", Options{
+				Type:           WalletTypeBip44,
+				Coin:           coin,
+				Seed:           testVectorSeed,
+				SeedPassphrase: "TREZOR",
+				GenerateN:      3,
+			})
+			require.NoError(t, err)
+			bw := w.(*Bip44Wallet)
+			addrs := w.GetAddresses()
+			change, err := bw.GenerateChangeEntry(0)
+			require.NoError(t, err)
+
+			groups := []slip39.Group{{Threshold: 3, Count: 5}}
+			shares, err := bw.Slip39Shares(1, groups, []byte("slip39"), 0)
+			require.NoError(t, err)
+			require.Len(t, shares, 1)
+			require.Len(t, shares[0], 5)
+
+			rw, err := NewWalletFromSlip39("restored.wlt", []string{shares[0][4], shares[0][0], shares[0][2]}, []byte("slip39"), Options{
+				Coin:      coin,
+				GenerateN: 3,
+			})
+			require.NoError(t, err)
+			rbw := rw.(*Bip44Wallet)
+			require.Equal(t, SeedTypeSlip39, rbw.Meta.SeedType())
+			require.Empty(t, rbw.Meta.SeedPassphrase())
+
+			// The restored wallet derives the same addresses
+			require.Equal(t, addrs, rw.GetAddresses())
+			rchange, err := rbw.GenerateChangeEntry(0)
+			require.NoError(t, err)
+			require.Equal(t, change.Address, rchange.Address)
+
+			xpub, err := bw.AccountXPub(0)
+			require.NoError(t, err)
+			rxpub, err := rbw.AccountXPub(0)
+			require.NoError(t, err)
+			require.Equal(t, xpub, rxpub)
+
+			// The seed type is persisted, and the restored wallet can be split again
+			lw, err := rw.ToReadable().ToWallet()
+			require.NoError(t, err)
+			require.Equal(t, SeedTypeSlip39, lw.(*Bip44Wallet).Meta.SeedType())
+			require.Equal(t, rw.GetEntries(), lw.GetEntries())
+
+			reshares, err := lw.(*Bip44Wallet).Slip39Shares(1, []slip39.Group{{Threshold: 1, Count: 1}}, nil, 0)
+			require.NoError(t, err)
+			sw, err := NewWalletFromSlip39("resplit.wlt", reshares[0], nil, Options{
+				Coin:      coin,
+				GenerateN: 3,
+			})
+			require.NoError(t, err)
+			require.Equal(t, addrs, sw.GetAddresses())
+
+			// Encrypted wallets can't be split without GuardView
+			require.NoError(t, Lock(rw, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+			_, err = rbw.Slip39Shares(1, groups, nil, 0)
+			require.Equal(t, ErrWalletEncrypted, err)
+			require.NoError(t, GuardView(rw, []byte("pwd"), func(w Wallet) error {
+				_, err := w.(*Bip44Wallet).Slip39Shares(1, groups, nil, 0)
+				return err
+			}))
+		})
+	}
+
+	// slip39 master secrets are not used with a seed passphrase
+	_, err := NewWallet("test.wlt", Options{
+		Type:           WalletTypeBip44,
+		Seed:           "bb54aac4b89dc868ba37d9cc21b2cece",
+		SeedType:       SeedTypeSlip39,
+		SeedPassphrase: "TREZOR",
+	})
+	require.Error(t, err)
+
+	_, err = NewWallet("test.wlt", Options{
+		Type:     WalletTypeBip44,
+		Seed:     testVectorSeed,
+		SeedType: SeedTypeSlip39,
+	})
+	require.Equal(t, ErrInvalidSlip39Seed, err)
+
+	// Electrum seeds can't be split
+	w, err := NewWallet("test.wlt", Options{
+		Type:     WalletTypeBip44,
+		Coin:     CoinTypeBitcoin,
+		Seed:     electrumStandardSeed,
+		SeedType: SeedTypeElectrumStandard,
+	})
+	require.NoError(t, err)
+	_, err = w.(*Bip44Wallet).Slip39Shares(1, []slip39.Group{{Threshold: 1, Count: 1}}, nil, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,295 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SkycoinProject/skycoin/src/cipher"
+)
+
+// ErrWalletTampered is returned when a wallet doesn't match its MAC, or its entries don't match its seed or xpub key
+var ErrWalletTampered = NewError(errors.New("wallet was modified outside of the wallet software"))
+
+// macKeySize is the size of the random MAC keys, stored in the encrypted secrets and in the service's MAC key file
+const macKeySize = 32
+
+// macExcludedMeta are the meta fields not covered by the MACs, which are changed without the wallet password.
+// The version is changed by migrations, and the filename is set from the wallet file name on load.
+var macExcludedMeta = []string{metaMAC, metaLoadMAC, metaFilename, metaLabel, metaVersion}
+
+// walletMAC returns the HMAC-SHA256 of an encrypted wallet's readable meta and entries
+func walletMAC(w Wallet, key []byte) (string, error) {
+	b, err := json.Marshal(w.ToReadable())
+	if err != nil {
+		return "", err
+	}
+
+	var rw struct {
+		Meta    map[string]string `json:"meta"`
+		Entries json.RawMessage   `json:"entries"`
+	}
+	if err := json.Unmarshal(b, &rw); err != nil {
+		return "", err
+	}
+
+	for _, k := range macExcludedMeta {
+		delete(rw.Meta, k)
+	}
+
+	// Map keys are marshaled in sorted order, so the encoding is canonical
+	b, err = json.Marshal(rw)
+	if err != nil {
+		return "", err
+	}
+
+	h := hmac.New(sha256.New, key)
+	if _, err := h.Write(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyMAC checks the MAC of an encrypted wallet with the MAC key of its decrypted secrets.
+// Wallets encrypted before MACs were added have neither a MAC nor a MAC key, and can't be checked.
+// They get a MAC the next time they are encrypted.
+func verifyMAC(w Wallet, ss Secrets) error {
+	k, ok := ss.get(secretMACKey)
+	if !ok {
+		if w.MAC() != "" {
+			return ErrWalletTampered
+		}
+		return nil
+	}
+
+	key, err := hex.DecodeString(k)
+	if err != nil || len(key) != macKeySize {
+		return errors.New("invalid MAC key in secrets")
+	}
+
+	if w.MAC() == "" {
+		return ErrWalletTampered
+	}
+
+	mac, err := walletMAC(w, key)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(w.MAC())) {
+		return ErrWalletTampered
+	}
+
+	return nil
+}
+
+// LoadMACKey reads the MAC key of a wallet service from a file, see Config.MACKeyFile.
+// A random key is written to the file if it doesn't exist.
+func LoadMACKey(filename string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	switch {
+	case err == nil:
+		key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(key) != macKeySize {
+			return nil, fmt.Errorf("invalid MAC key in %s", filename)
+		}
+		return key, nil
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.FileMode(0700)); err != nil {
+		return nil, err
+	}
+
+	key := cipher.RandByte(macKeySize)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(0600))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return nil, err
+	}
+
+	return key, f.Sync()
+}
+
+// setLoadMAC authenticates an encrypted wallet with the MAC key of a wallet service, see Config.MACKeyFile
+func setLoadMAC(w Wallet, key []byte) error {
+	if !w.IsEncrypted() {
+		return nil
+	}
+
+	mac, err := walletMAC(w, key)
+	if err != nil {
+		return err
+	}
+	w.setLoadMAC(mac)
+
+	return nil
+}
+
+// verifyLoadMAC checks the load MAC of an encrypted wallet with the MAC key of a wallet service.
+// Wallets which weren't saved by a service with a MAC key have no load MAC, and can't be checked
+// until they are unlocked, see verifyMAC.
+func verifyLoadMAC(w Wallet, key []byte) error {
+	if !w.IsEncrypted() || w.LoadMAC() == "" {
+		return nil
+	}
+
+	mac, err := walletMAC(w, key)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(w.LoadMAC())) {
+		return ErrWalletTampered
+	}
+
+	return nil
+}
+
+// VerifyEntries re-derives the entries of a wallet from its seed or xpub key and compares them with the wallet's entries,
+// returning ErrWalletTampered if any of them differs. Entries of collection wallets are checked against their secret keys,
+// watch-only entries can't be checked. Encrypted wallets must be decrypted first, e.g. with GuardView.
+func VerifyEntries(w Wallet) error {
+	if w.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	var err error
+	switch tw := w.(type) {
+	case *DeterministicWallet:
+		err = tw.verifyEntries()
+	case *CollectionWallet:
+		err = tw.verifyEntries()
+	case *Bip44Wallet:
+		err = tw.verifyEntries()
+	case *XPubWallet:
+		err = tw.verifyEntries()
+	default:
+		return ErrInvalidWalletType
+	}
+
+	if err != nil {
+		logger.WithError(err).WithField("wallet", w.Filename()).Error("VerifyEntries failed")
+		return ErrWalletTampered
+	}
+
+	return nil
+}
+
+func (w *DeterministicWallet) verifyEntries() error {
+	if len(w.Entries) == 0 {
+		return nil
+	}
+
+	seed, seckeys := cipher.MustGenerateDeterministicKeyPairsSeed([]byte(w.Meta.Seed()), len(w.Entries))
+	if hex.EncodeToString(seed) != w.Meta.LastSeed() {
+		return errors.New("lastSeed doesn't match the seed")
+	}
+
+	makeAddress := w.Meta.AddressConstructor()
+	for i, s := range seckeys {
+		p := cipher.MustPubKeyFromSecKey(s)
+		if err := compareEntry(w.Entries[i], Entry{
+			Address: makeAddress(p),
+			Public:  p,
+			Secret:  s,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *CollectionWallet) verifyEntries() error {
+	for _, e := range w.Entries {
+		if err := e.Verify(); err != nil {
+			return fmt.Errorf("entry %s: %v", e.Address, err)
+		}
+	}
+	return nil
+}
+
+func (w *Bip44Wallet) verifyEntries() error {
+	for _, account := range w.Accounts() {
+		external, change := w.GetAccountEntries(account)
+		for changeIdx, es := range []Entries{external, change} {
+			if err := verifyChainEntries(es, func(num uint64) (Entries, error) {
+				return w.generateEntries(num, account, uint32(changeIdx), 0)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *XPubWallet) verifyEntries() error {
+	for changeIdx, es := range []Entries{w.Entries, w.ChangeEntries} {
+		if err := verifyChainEntries(es, func(num uint64) (Entries, error) {
+			return w.generateEntries(num, uint32(changeIdx), 0)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChainEntries compares the entries of a bip32 chain with the entries derived by generate, by child number
+func verifyChainEntries(es Entries, generate func(num uint64) (Entries, error)) error {
+	if len(es) == 0 {
+		return nil
+	}
+
+	var maxChildIdx uint32
+	for _, e := range es {
+		if e.ChildNumber > maxChildIdx {
+			maxChildIdx = e.ChildNumber
+		}
+	}
+
+	derived, err := generate(uint64(maxChildIdx) + 1)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range es {
+		if int(e.ChildNumber) >= len(derived) {
+			return fmt.Errorf("entry %s: child number %d can't be derived", e.Address, e.ChildNumber)
+		}
+		if err := compareEntry(e, derived[e.ChildNumber]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareEntry compares an entry of a wallet with the entry derived for it
+func compareEntry(e, derived Entry) error {
+	switch {
+	case e.Address == nil || e.Address.String() != derived.Address.String():
+		return fmt.Errorf("entry %v: address doesn't match the derived address %s", e.Address, derived.Address)
+	case e.Public != derived.Public:
+		return fmt.Errorf("entry %s: public key doesn't match the derived public key", e.Address)
+	case e.Secret != derived.Secret:
+		return fmt.Errorf("entry %s: secret key doesn't match the derived secret key", e.Address)
+	case e.ChildNumber != derived.ChildNumber || e.Change != derived.Change || e.Account != derived.Account:
+		return fmt.Errorf("entry %s: derivation path doesn't match", e.Address)
+	default:
+		return nil
+	}
+}
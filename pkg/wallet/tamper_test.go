@@ -0,0 +1,415 @@
+package wallet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tamperWalletFile replaces the keys and address of the first entry of a wallet file with those of another wallet,
+// and applies f to the meta
+func tamperWalletFile(t *testing.T, filename string, other Wallet, f func(meta map[string]interface{})) {
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	var rw map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &rw))
+
+	if other != nil {
+		e := rw["entries"].([]interface{})[0].(map[string]interface{})
+		oe := other.GetEntryAt(0)
+		e["address"] = oe.Address.String()
+		e["public_key"] = oe.Public.Hex()
+		if sk, _ := e["secret_key"].(string); sk != "" {
+			e["secret_key"] = oe.Secret.Hex()
+		}
+	}
+
+	if f != nil {
+		f(rw["meta"].(map[string]interface{}))
+	}
+
+	b, err = json.MarshalIndent(rw, "", "    ")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, b, 0600))
+}
+
+func TestWalletMAC(t *testing.T) {
+	newWallets := func(t *testing.T, seed string) []Wallet {
+		bw, err := NewWallet("bip44.wlt", Options{
+			Type:      WalletTypeBip44,
+			Coin:      CoinTypeBitcoin,
+			Seed:      seed,
+			GenerateN: 2,
+		})
+		require.NoError(t, err)
+
+		dw, err := NewWallet("deterministic.wlt", Options{
+			Type:      WalletTypeDeterministic,
+			Coin:      CoinTypeSkycoin,
+			Seed:      seed,
+			GenerateN: 2,
+		})
+		require.NoError(t, err)
+
+		cw, err := NewWallet("collection.wlt", Options{
+			Type: WalletTypeCollection,
+			Coin: CoinTypeBitcoin,
+		})
+		require.NoError(t, err)
+		_, err = cw.(*CollectionWallet).importSecretKey(bw.GetEntryAt(1).Secret)
+		require.NoError(t, err)
+
+		return []Wallet{bw, dw, cw}
+	}
+
+	others := newWallets(t, testSeed)
+
+	for i, w := range newWallets(t, testVectorSeed) {
+		t.Run(w.Type(), func(t *testing.T) {
+			dir, teardown := prepareWltDir(t)
+			defer teardown()
+			filename := filepath.Join(dir, w.Filename())
+			password := []byte("pwd")
+
+			require.Empty(t, w.MAC())
+			require.NoError(t, Lock(w, password, CryptoTypeScryptChacha20poly1305Insecure))
+			require.Len(t, w.MAC(), 64)
+			require.NoError(t, SaveWithBackups(w, dir, 0))
+
+			guardView := func() error {
+				lw, err := Load(filename)
+				require.NoError(t, err)
+				return GuardView(lw, password, func(Wallet) error { return nil })
+			}
+
+			require.NoError(t, guardView())
+
+			// The label is changed without the password, and isn't authenticated
+			tamperWalletFile(t, filename, nil, func(meta map[string]interface{}) {
+				meta[metaLabel] = "new label"
+			})
+			require.NoError(t, guardView())
+
+			// Replaced entries are detected
+			tamperWalletFile(t, filename, others[i], nil)
+			require.Equal(t, ErrWalletTampered, guardView())
+
+			// Modified meta fields are detected
+			require.NoError(t, SaveWithBackups(w, dir, 0))
+			tamperWalletFile(t, filename, nil, func(meta map[string]interface{}) {
+				meta[metaTimestamp] = "1"
+			})
+			require.Equal(t, ErrWalletTampered, guardView())
+
+			// A removed MAC is detected
+			require.NoError(t, SaveWithBackups(w, dir, 0))
+			tamperWalletFile(t, filename, nil, func(meta map[string]interface{}) {
+				delete(meta, metaMAC)
+			})
+			require.Equal(t, ErrWalletTampered, guardView())
+
+			// A malformed MAC fails to load
+			require.NoError(t, SaveWithBackups(w, dir, 0))
+			tamperWalletFile(t, filename, nil, func(meta map[string]interface{}) {
+				meta[metaMAC] = "00"
+			})
+			_, err := Load(filename)
+			require.Error(t, err)
+
+			// A new MAC is made each time the wallet is encrypted, and it's removed when decrypted
+			mac := w.MAC()
+			require.NoError(t, GuardUpdate(w, password, func(Wallet) error { return nil }))
+			require.NotEqual(t, mac, w.MAC())
+			require.NoError(t, GuardView(w, password, func(Wallet) error { return nil }))
+
+			dw, err := Unlock(w, password)
+			require.NoError(t, err)
+			require.Empty(t, dw.MAC())
+			require.NoError(t, dw.Validate())
+		})
+	}
+}
+
+func TestVerifyEntries(t *testing.T) {
+	bw, err := NewWallet("bip44.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 3,
+	})
+	require.NoError(t, err)
+	account, err := bw.(*Bip44Wallet).NewAccount()
+	require.NoError(t, err)
+	_, err = bw.(*Bip44Wallet).GenerateAccountAddresses(account, 2)
+	require.NoError(t, err)
+	_, err = bw.(*Bip44Wallet).GenerateChangeEntry(account)
+	require.NoError(t, err)
+
+	xw, err := NewXPubWalletFromBip44("xpub.wlt", bw.(*Bip44Wallet), 0)
+	require.NoError(t, err)
+	_, err = xw.GenerateAddresses(2)
+	require.NoError(t, err)
+	_, err = xw.GenerateChangeEntry()
+	require.NoError(t, err)
+
+	dw, err := NewWallet("deterministic.wlt", Options{
+		Type:      WalletTypeDeterministic,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testVectorSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	_, err = dw.GenerateAddresses(3)
+	require.NoError(t, err)
+
+	cw, err := NewWallet("collection.wlt", Options{
+		Type: WalletTypeCollection,
+		Coin: CoinTypeBitcoin,
+	})
+	require.NoError(t, err)
+	_, err = cw.(*CollectionWallet).importSecretKey(bw.GetEntryAt(0).Secret)
+	require.NoError(t, err)
+
+	other, err := NewWallet("other.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	oe := other.GetEntryAt(0)
+
+	cases := []struct {
+		wallet Wallet
+		tamper func(w Wallet)
+	}{
+		{
+			wallet: bw,
+			tamper: func(w Wallet) {
+				e := &w.(*Bip44Wallet).ExternalEntries[4]
+				e.Address, e.Public, e.Secret = oe.Address, oe.Public, oe.Secret
+			},
+		},
+		{
+			wallet: xw,
+			tamper: func(w Wallet) {
+				e := &w.(*XPubWallet).ChangeEntries[0]
+				e.Address, e.Public = oe.Address, oe.Public
+			},
+		},
+		{
+			wallet: dw,
+			tamper: func(w Wallet) {
+				e := &w.(*DeterministicWallet).Entries[3]
+				e.Address, e.Public, e.Secret = oe.Address, oe.Public, oe.Secret
+			},
+		},
+		{
+			wallet: cw,
+			tamper: func(w Wallet) {
+				w.(*CollectionWallet).Entries[0].Address = oe.Address
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.wallet.Type(), func(t *testing.T) {
+			require.NoError(t, VerifyEntries(tc.wallet))
+
+			w := tc.wallet.Clone()
+			tc.tamper(w)
+			require.Equal(t, ErrWalletTampered, VerifyEntries(w))
+		})
+	}
+
+	// Encrypted wallets are verified once decrypted
+	require.NoError(t, Lock(dw, []byte("pwd"), CryptoTypeScryptChacha20poly1305Insecure))
+	require.Equal(t, ErrWalletEncrypted, VerifyEntries(dw))
+	require.NoError(t, GuardView(dw, []byte("pwd"), VerifyEntries))
+}
+
+func TestServiceVerifyEntries(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+
+	w, err := s.CreateWallet("t.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.VerifyWallet(w.Filename(), nil))
+
+	_, err = s.CreateWallet("e.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+	})
+	require.NoError(t, err)
+	require.Empty(t, s.UnverifiedWallets())
+
+	other, err := NewWallet("other.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeSkycoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+
+	// Tampered unencrypted wallets are only refused when verifying entries
+	tamperWalletFile(t, filepath.Join(dir, w.Filename()), other, nil)
+
+	_, err = NewService(Config{
+		WalletDir:     dir,
+		CryptoType:    CryptoTypeScryptChacha20poly1305Insecure,
+		VerifyEntries: true,
+	})
+	require.Error(t, err)
+
+	s, err = NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ErrWalletTampered, s.VerifyWallet(w.Filename(), nil))
+	require.Equal(t, []string{"e.wlt"}, s.UnverifiedWallets())
+
+	// Encrypted wallets are reported as unverified until they are verified with their password
+	require.NoError(t, s.DeleteWallet(w.Filename()))
+	s, err = NewService(Config{
+		WalletDir:     dir,
+		CryptoType:    CryptoTypeScryptChacha20poly1305Insecure,
+		VerifyEntries: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"e.wlt"}, s.UnverifiedWallets())
+
+	require.Equal(t, ErrInvalidPassword, s.VerifyWallet("e.wlt", []byte("wrong")))
+	require.Equal(t, []string{"e.wlt"}, s.UnverifiedWallets())
+	require.NoError(t, s.VerifyWallet("e.wlt", []byte("pwd")))
+	require.Empty(t, s.UnverifiedWallets())
+}
+
+func TestServiceLoadMAC(t *testing.T) {
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+	keyDir, keyTeardown := prepareWltDir(t)
+	defer keyTeardown()
+
+	c := Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+		MACKeyFile: filepath.Join(keyDir, "wallets.key"),
+	}
+
+	// The MAC key file must be outside of the wallet directory
+	_, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+		MACKeyFile: filepath.Join(dir, "keys", "wallets.key"),
+	})
+	require.Error(t, err)
+
+	// A wallet encrypted by a service without a MAC key has no load MAC
+	s, err := NewService(Config{
+		WalletDir:  dir,
+		CryptoType: CryptoTypeScryptChacha20poly1305Insecure,
+	})
+	require.NoError(t, err)
+	_, err = s.CreateWallet("old.wlt", Options{
+		Type:      WalletTypeDeterministic,
+		Coin:      CoinTypeSkycoin,
+		Seed:      "seed",
+		GenerateN: 1,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+	})
+	require.NoError(t, err)
+
+	// The MAC key is created with the first service using it
+	s, err = NewService(c)
+	require.NoError(t, err)
+	key, err := ioutil.ReadFile(c.MACKeyFile)
+	require.NoError(t, err)
+	fi, err := os.Stat(c.MACKeyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	require.Equal(t, []string{"old.wlt"}, s.UnverifiedWallets())
+
+	w, err := s.CreateWallet("e.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testSeed,
+		GenerateN: 2,
+		Encrypt:   true,
+		Password:  []byte("pwd"),
+	})
+	require.NoError(t, err)
+	require.Len(t, w.LoadMAC(), 64)
+
+	// Wallets without a load MAC get one once they are verified with their password
+	require.NoError(t, s.VerifyWallet("old.wlt", []byte("pwd")))
+	require.Empty(t, s.UnverifiedWallets())
+
+	s, err = NewService(c)
+	require.NoError(t, err)
+	require.Empty(t, s.UnverifiedWallets())
+	k, err := ioutil.ReadFile(c.MACKeyFile)
+	require.NoError(t, err)
+	require.Equal(t, key, k)
+
+	// Labels can be changed without the password, they aren't authenticated
+	require.NoError(t, s.Update("e.wlt", func(w Wallet) error {
+		w.SetLabel("label")
+		return nil
+	}))
+	s, err = NewService(c)
+	require.NoError(t, err)
+
+	// Tampered encrypted wallets are refused when they are loaded
+	other, err := NewWallet("other.wlt", Options{
+		Type:      WalletTypeBip44,
+		Coin:      CoinTypeBitcoin,
+		Seed:      testVectorSeed,
+		GenerateN: 1,
+	})
+	require.NoError(t, err)
+	filename := filepath.Join(dir, "e.wlt")
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	tamperWalletFile(t, filename, other, nil)
+
+	_, err = NewService(c)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrWalletTampered.Error())
+
+	// A wallet whose load MAC was removed is unverified, and refused when it is unlocked
+	tamperWalletFile(t, filename, nil, func(meta map[string]interface{}) {
+		delete(meta, metaLoadMAC)
+	})
+	s, err = NewService(c)
+	require.NoError(t, err)
+	require.Equal(t, []string{"e.wlt"}, s.UnverifiedWallets())
+	require.Equal(t, ErrWalletTampered, s.VerifyWallet("e.wlt", []byte("pwd")))
+
+	// Wallets loaded with another MAC key are refused
+	require.NoError(t, ioutil.WriteFile(filename, b, 0600))
+	c.MACKeyFile = filepath.Join(keyDir, "other.key")
+	_, err = NewService(c)
+	require.Error(t, err)
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -40,7 +39,7 @@ func NewError(err error) error {
 
 var (
 	// Version represents the current wallet version
-	Version = "0.4"
+	Version = "0.5"
 
 	logger = logging.MustGetLogger("wallet")
 
@@ -58,12 +57,26 @@ var (
 	ErrMissingSeed = NewError(errors.New("missing seed"))
 	// ErrWalletNotExist is returned if a wallet does not exist
 	ErrWalletNotExist = NewError(errors.New("wallet doesn't exist"))
+	// ErrSeedUsed is returned if a wallet already exists with the same seed
+	ErrSeedUsed = NewError(errors.New("a wallet already exists with this seed"))
+	// ErrXPubKeyUsed is returned if a wallet already exists with the same xpub
+	ErrXPubKeyUsed = NewError(errors.New("a wallet already exists with this xpub key"))
+	// ErrWalletNameConflict is returned if a wallet file name is already in use
+	ErrWalletNameConflict = NewError(errors.New("wallet name would conflict with existing wallet"))
 	// ErrWalletAPIDisabled is returned when trying to do wallet actions while the EnableWalletAPI option is false
 	ErrWalletAPIDisabled = NewError(errors.New("wallet api is disabled"))
 	// ErrInvalidCoinType is returned for invalid coin types
 	ErrInvalidCoinType = NewError(errors.New("invalid coin type"))
 	// ErrInvalidWalletType is returned for invalid wallet types
 	ErrInvalidWalletType = NewError(errors.New("invalid wallet type"))
+	// ErrInvalidAddressType is returned for invalid address types
+	ErrInvalidAddressType = NewError(errors.New("invalid address type"))
+	// ErrWalletWatchOnly is returned when trying to use secrets of a watch-only wallet
+	ErrWalletWatchOnly = NewError(errors.New("wallet is watch-only"))
+	// ErrInvalidXPubLevel is returned for invalid xpub levels
+	ErrInvalidXPubLevel = NewError(errors.New("invalid xpub level"))
+	// ErrUnknownAddress is returned if an address is not found in a wallet
+	ErrUnknownAddress = NewError(errors.New("address not found in wallet"))
 )
 
 const (
@@ -92,6 +105,16 @@ const (
 	// WalletTypeXPub xpub HD wallet type.
 	// Allows generating addresses without a secret key
 	WalletTypeXPub = "xpub"
+
+	// AddressTypeP2PKH legacy pay-to-pubkey-hash bitcoin addresses, derived along the bip44 path
+	AddressTypeP2PKH AddressType = "p2pkh"
+	// AddressTypeP2WPKH native segwit pay-to-witness-pubkey-hash bitcoin addresses, derived along the bip84 path
+	AddressTypeP2WPKH AddressType = "p2wpkh"
+	// AddressTypeP2SHP2WPKH pay-to-witness-pubkey-hash nested in pay-to-script-hash bitcoin addresses,
+	// derived along the bip49 path
+	AddressTypeP2SHP2WPKH AddressType = "p2sh-p2wpkh"
+	// AddressTypeP2TR single key pay-to-taproot bitcoin addresses, derived along the bip86 path
+	AddressTypeP2TR AddressType = "p2tr"
 )
 
 // ResolveCoinType normalizes a coin type string to a CoinType constant
@@ -124,6 +147,48 @@ func IsValidWalletType(t string) bool {
 // CoinType represents the wallet coin type, which refers to the pubkey2addr method used
 type CoinType string
 
+// AddressType represents the address format of a coin, which refers to the pubkey2addr
+// method and the HD derivation path used. Only bitcoin wallets support multiple address types.
+type AddressType string
+
+// AddressTypeFromString converts string to AddressType
+func AddressTypeFromString(s string) (AddressType, error) {
+	switch AddressType(strings.ToLower(s)) {
+	case AddressTypeP2PKH:
+		return AddressTypeP2PKH, nil
+	case AddressTypeP2WPKH:
+		return AddressTypeP2WPKH, nil
+	case AddressTypeP2SHP2WPKH:
+		return AddressTypeP2SHP2WPKH, nil
+	case AddressTypeP2TR:
+		return AddressTypeP2TR, nil
+	default:
+		return "", ErrInvalidAddressType
+	}
+}
+
+const (
+	// XPubLevelChain xpub keys are the node of a single chain, addresses are derived as xpub/i
+	XPubLevelChain XPubLevel = "chain"
+	// XPubLevelAccount xpub keys are bip44 account nodes, addresses are derived as xpub/0/i and xpub/1/i for change
+	XPubLevelAccount XPubLevel = "account"
+)
+
+// XPubLevel represents the level of an xpub wallet's key in the bip44 path
+type XPubLevel string
+
+// XPubLevelFromString converts string to XPubLevel
+func XPubLevelFromString(s string) (XPubLevel, error) {
+	switch XPubLevel(strings.ToLower(s)) {
+	case XPubLevelChain:
+		return XPubLevelChain, nil
+	case XPubLevelAccount:
+		return XPubLevelAccount, nil
+	default:
+		return "", ErrInvalidXPubLevel
+	}
+}
+
 // NewWalletFilename generates a filename from the current time and random bytes
 func NewWalletFilename() string {
 	timestamp := time.Now().Format(WalletTimestampFormat)
@@ -140,15 +205,20 @@ type Options struct {
 	Label          string          // wallet label
 	Seed           string          // wallet seed
 	SeedPassphrase string          // wallet seed passphrase (bip44 wallets only)
+	SeedType       SeedType        // seed mnemonic type (bip44 wallets only): bip39, electrum-standard, electrum-segwit or slip39. Defaults to bip39.
 	Encrypt        bool            // whether the wallet need to be encrypted.
 	Password       []byte          // password that would be used for encryption, and would only be used when 'Encrypt' is true.
-	CryptoType     CryptoType      // wallet encryption type, scrypt-chacha20poly1305 or sha256-xor.
+	CryptoType     CryptoType      // wallet encryption type, scrypt-chacha20poly1305, argon2id-chacha20poly1305 or sha256-xor.
 	GenerateN      uint64          // number of addresses to generate, regardless of balance
+	ScanN          uint64          // gap limit, the number of consecutive unused addresses to scan ahead for on each chain
 	XPub           string          // xpub key (xpub wallets only)
+	XPubLevel      XPubLevel       // xpub key level (xpub wallets only): chain or account. Inferred from the key depth if not set.
+	WatchOnly      bool            // whether the wallet only holds addresses, without keys (collection wallets only)
+	AddressType    AddressType     // address type (bitcoin wallets only): p2pkh, p2wpkh, p2sh-p2wpkh or p2tr. Defaults to p2pkh.
 }
 
 // newWallet creates a wallet instance with given name and options.
-func newWallet(wltName string, opts Options) (Wallet, error) {
+func newWallet(wltName string, opts Options, tf TransactionsFinder) (Wallet, error) {
 	wltType := opts.Type
 	if wltType == "" {
 		return nil, NewError(errors.New("wallet type is required"))
@@ -184,10 +254,40 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 		return nil, NewError(fmt.Errorf("seedPassphrase is only used for %q wallets", WalletTypeBip44))
 	}
 
+	if opts.SeedType != "" && wltType != WalletTypeBip44 {
+		return nil, NewError(fmt.Errorf("seedType is only used for %q wallets", WalletTypeBip44))
+	}
+
+	seedType := opts.SeedType
+	if seedType == "" {
+		seedType = SeedTypeBip39
+	}
+	seedType, err := SeedTypeFromString(string(seedType))
+	if err != nil {
+		return nil, err
+	}
+
+	if seedType == SeedTypeSlip39 && opts.SeedPassphrase != "" {
+		return nil, NewError(errors.New("seedPassphrase is not used with slip39 master secrets, the passphrase is applied when combining the shares"))
+	}
+
 	if opts.XPub != "" && wltType != WalletTypeXPub {
 		return nil, NewError(fmt.Errorf("xpub is only used for %q wallets", WalletTypeXPub))
 	}
 
+	if opts.WatchOnly {
+		if wltType != WalletTypeCollection {
+			return nil, NewError(fmt.Errorf("watchOnly is only used for %q wallets", WalletTypeCollection))
+		}
+		if opts.Encrypt {
+			return nil, NewError(errors.New("watch-only wallets have no secrets to encrypt"))
+		}
+	}
+
+	if opts.XPubLevel != "" && wltType != WalletTypeXPub {
+		return nil, NewError(fmt.Errorf("xpubLevel is only used for %q wallets", WalletTypeXPub))
+	}
+
 	switch wltType {
 	case WalletTypeDeterministic, WalletTypeBip44:
 		if opts.Seed == "" {
@@ -212,11 +312,45 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 	if coin == "" {
 		coin = CoinTypeSkycoin
 	}
-	coin, err := ResolveCoinType(string(coin))
+	coin, err = ResolveCoinType(string(coin))
 	if err != nil {
 		return nil, err
 	}
 
+	addressType := opts.AddressType
+	if seedType.IsElectrum() {
+		if coin != CoinTypeBitcoin {
+			return nil, NewError(fmt.Errorf("electrum seeds are only used for %q wallets", CoinTypeBitcoin))
+		}
+		// Electrum seed types imply the address type
+		if addressType != "" && addressType != seedType.addressType() {
+			return nil, NewError(fmt.Errorf("%s seeds are only used for %s addresses", seedType, seedType.addressType()))
+		}
+		addressType = seedType.addressType()
+	}
+
+	switch coin {
+	case CoinTypeBitcoin:
+		if wltType == WalletTypeXPub {
+			// ypub and zpub keys imply the address type
+			addressType, err = addressTypeFromXPub(opts.XPub, addressType)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if addressType == "" {
+			addressType = AddressTypeP2PKH
+		}
+		addressType, err = AddressTypeFromString(string(addressType))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		if addressType != "" {
+			return nil, NewError(fmt.Errorf("addressType is only used for %q wallets", CoinTypeBitcoin))
+		}
+	}
+
 	meta := Meta{
 		metaFilename:       wltName,
 		metaVersion:        Version,
@@ -231,6 +365,8 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 		metaCryptoType:     "",
 		metaSecrets:        "",
 		metaXPub:           opts.XPub,
+		metaAddressType:    string(addressType),
+		metaWatchOnly:      strconv.FormatBool(opts.WatchOnly),
 	}
 
 	// Create the wallet
@@ -242,9 +378,24 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 		w, err = newCollectionWallet(meta)
 	case WalletTypeBip44:
 		meta.setBip44Coin(bip44Coin)
+		if seedType != SeedTypeBip39 {
+			meta.setSeedType(seedType)
+		}
 		w, err = newBip44Wallet(meta)
 	case WalletTypeXPub:
 		meta.setXPub(opts.XPub)
+		xpubLevel := opts.XPubLevel
+		if xpubLevel == "" {
+			xpubLevel, err = xpubLevelFromDepth(opts.XPub)
+			if err != nil {
+				return nil, err
+			}
+		}
+		xpubLevel, err = XPubLevelFromString(string(xpubLevel))
+		if err != nil {
+			return nil, err
+		}
+		meta.setXPubLevel(xpubLevel)
 		w, err = newXPubWallet(meta)
 	default:
 		logger.Panic("unhandled wltType")
@@ -272,8 +423,23 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 			return nil, err
 		}
 
+		if opts.ScanN > 0 {
+			if tf == nil {
+				return nil, ErrNilTransactionsFinder
+			}
+
+			// Scan for addresses with transaction history
+			logger.WithFields(logrus.Fields{
+				"scanN":      opts.ScanN,
+				"walletType": wltType,
+			}).Info("Scanning addresses for wallet")
+			if err := w.ScanAddresses(opts.ScanN, tf); err != nil {
+				return nil, err
+			}
+		}
+
 	case WalletTypeCollection:
-		if opts.GenerateN != 0 {
+		if opts.GenerateN != 0 || opts.ScanN != 0 {
 			return nil, NewError(fmt.Errorf("wallet generation is not defined for %q wallets", wltType))
 		}
 
@@ -323,12 +489,17 @@ func newWallet(wltName string, opts Options) (Wallet, error) {
 
 // NewWallet creates wallet without scanning addresses
 func NewWallet(wltName string, opts Options) (Wallet, error) {
-	return newWallet(wltName, opts)
+	return newWallet(wltName, opts, nil)
 }
 
-// NewWalletScanAhead creates wallet and scan ahead N addresses
-func NewWalletScanAhead(wltName string, opts Options) (Wallet, error) {
-	return newWallet(wltName, opts)
+// NewWalletScanAhead creates wallet and scans ahead for addresses with transaction history,
+// until opts.ScanN consecutive unused addresses are found on each chain.
+// opts.ScanN defaults to DefaultScanN.
+func NewWalletScanAhead(wltName string, opts Options, tf TransactionsFinder) (Wallet, error) {
+	if opts.ScanN == 0 {
+		opts.ScanN = DefaultScanN
+	}
+	return newWallet(wltName, opts, tf)
 }
 
 // Lock encrypts the wallet with the given password and specific crypto type
@@ -341,6 +512,10 @@ func Lock(w Wallet, password []byte, cryptoType CryptoType) error {
 		return ErrWalletEncrypted
 	}
 
+	if w.Find(metaWatchOnly) == "true" {
+		return ErrWalletWatchOnly
+	}
+
 	wlt := w.Clone()
 
 	// Records seeds in secrets
@@ -353,6 +528,10 @@ func Lock(w Wallet, password []byte, cryptoType CryptoType) error {
 
 	wlt.PackSecrets(ss)
 
+	// Records a new MAC key, to authenticate the encrypted wallet with
+	macKey := cipher.RandByte(macKeySize)
+	ss.set(secretMACKey, hex.EncodeToString(macKey))
+
 	sb, err := ss.serialize()
 	if err != nil {
 		return err
@@ -378,6 +557,13 @@ func Lock(w Wallet, password []byte, cryptoType CryptoType) error {
 	// Wipes unencrypted sensitive data
 	wlt.Erase()
 
+	// Authenticates the encrypted wallet, so that changes to its entries or meta are detected on Unlock
+	mac, err := walletMAC(wlt, macKey)
+	if err != nil {
+		return err
+	}
+	wlt.setMAC(mac)
+
 	// Wipes the secret fields in w
 	w.Erase()
 
@@ -387,7 +573,7 @@ func Lock(w Wallet, password []byte, cryptoType CryptoType) error {
 }
 
 // Unlock decrypts the wallet into a temporary decrypted copy of the wallet
-// Returns error if the decryption fails
+// Returns error if the decryption fails, or ErrWalletTampered if the wallet doesn't match its MAC
 // The temporary decrypted wallet should be erased from memory when done.
 func Unlock(w Wallet, password []byte) (Wallet, error) {
 	if !w.IsEncrypted() {
@@ -437,6 +623,11 @@ func Unlock(w Wallet, password []byte) (Wallet, error) {
 		return nil, err
 	}
 
+	if err := verifyMAC(w, ss); err != nil {
+		logger.WithError(err).WithField("wallet", w.Filename()).Error("Unlock: verifyMAC failed")
+		return nil, err
+	}
+
 	if err := wlt.UnpackSecrets(ss); err != nil {
 		return nil, err
 	}
@@ -468,6 +659,10 @@ type Wallet interface {
 	SetVersion(string)
 	AddressConstructor() func(cipher.PubKey) cipher.Addresser
 	Secrets() string
+	MAC() string
+	setMAC(string)
+	LoadMAC() string
+	setLoadMAC(string)
 	XPub() string
 
 	UnpackSecrets(ss Secrets) error
@@ -491,6 +686,7 @@ type Wallet interface {
 	GetEntries() Entries
 
 	GenerateAddresses(num uint64) ([]cipher.Addresser, error)
+	ScanAddresses(scanN uint64, tf TransactionsFinder) error
 }
 
 // GuardUpdate executes a function within the context of a read-write managed decrypted wallet.
@@ -527,6 +723,44 @@ func GuardUpdate(w Wallet, password []byte, fn func(w Wallet) error) error {
 	return nil
 }
 
+// ChangePassword re-encrypts an encrypted wallet with a new password and crypto type.
+// If cryptoType is empty, the wallet's crypto type is kept.
+// The secrets are only decrypted into a temporary copy of the wallet, which is erased when done,
+// and w is only modified once it is re-encrypted, so the wallet never exists unencrypted.
+func ChangePassword(w Wallet, oldPassword, newPassword []byte, cryptoType CryptoType) error {
+	if !w.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	if len(oldPassword) == 0 || len(newPassword) == 0 {
+		return ErrMissingPassword
+	}
+
+	if cryptoType == "" {
+		cryptoType = w.CryptoType()
+	}
+	if _, err := CryptoTypeFromString(string(cryptoType)); err != nil {
+		return NewError(err)
+	}
+
+	wlt, err := Unlock(w, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	defer wlt.Erase()
+
+	if err := Lock(wlt, newPassword, cryptoType); err != nil {
+		return err
+	}
+
+	w.CopyFromRef(wlt)
+
+	// Wipes all sensitive data
+	w.Erase()
+	return nil
+}
+
 // GuardView executes a function within the context of a read-only managed decrypted wallet.
 // Returns ErrWalletNotEncrypted if wallet is not encrypted.
 func GuardView(w Wallet, password []byte, f func(w Wallet) error) error {
@@ -561,12 +795,52 @@ type walletLoader interface {
 	ToWallet() (Wallet, error)
 }
 
-// Load loads wallet from a given file
+// Load loads wallet from a given file.
+// Wallet files of older versions are migrated to Version first, see migrateFile.
+// If the wallet file is corrupt, the newest valid backup of it is loaded instead, see SaveWithBackups.
+// Files which can't be migrated aren't corrupt, and aren't replaced by a backup.
+// The MAC of encrypted wallets isn't checked on load, since its key is encrypted: encrypted wallets are
+// only authenticated when they are unlocked, see Unlock.
 func Load(filename string) (Wallet, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return nil, fmt.Errorf("wallet %q doesn't exist", filename)
 	}
 
+	w, err := loadFile(filename)
+	if err == nil || err == ErrWalletVersionUnsupported {
+		return w, err
+	}
+
+	if merr, ok := err.(migrationError); ok {
+		return nil, merr.error
+	}
+
+	w, backup, berr := loadBackup(filename)
+	if berr != nil {
+		logger.WithError(berr).WithField("filename", filename).Error("Load: no valid backup of the wallet file")
+		return nil, err
+	}
+
+	logger.WithError(err).WithFields(logrus.Fields{
+		"filename": filename,
+		"backup":   backup,
+	}).Warning("Load: wallet file is corrupt, loaded its newest valid backup")
+
+	return w, nil
+}
+
+// loadFile migrates and loads a wallet file
+func loadFile(filename string) (Wallet, error) {
+	if err := migrateFile(filename); err != nil {
+		logger.WithError(err).WithField("filename", filename).Error("Load: migrateFile failed")
+		return nil, err
+	}
+
+	return readFile(filename, filepath.Base(filename))
+}
+
+// readFile loads a wallet file of the current Version, naming the wallet wltName
+func readFile(filename, wltName string) (Wallet, error) {
 	// Load the wallet meta type field from JSON
 	var m walletLoadMeta
 	if err := file.LoadJSON(filename, &m); err != nil {
@@ -620,67 +894,13 @@ func Load(filename string) (Wallet, error) {
 	}
 	rw.SetCoin(ct)
 
-	rw.SetFilename(filepath.Base(filename))
+	rw.SetFilename(wltName)
 
 	return rw.ToWallet()
 }
 
-// Save saves the wallet to a directory. The wallet's filename is read from its metadata.
+// Save saves the wallet to a directory, keeping DefaultBackups backups of the wallet file.
+// The wallet's filename is read from its metadata.
 func Save(w Wallet, dir string) error {
-	rw := w.ToReadable()
-	return file.SaveJSON(filepath.Join(dir, w.Filename()), rw, 0600)
-}
-
-// removeBackupFiles removes any *.wlt.bak files whom have version 0.1 and *.wlt matched in the given directory
-func removeBackupFiles(dir string) error {
-	fs, err := filterDir(dir, ".wlt")
-	if err != nil {
-		return err
-	}
-
-	// Creates the .wlt file map
-	fm := make(map[string]struct{})
-	for _, f := range fs {
-		fm[f] = struct{}{}
-	}
-
-	// Filters all .wlt.bak files in the directory
-	bakFs, err := filterDir(dir, ".wlt.bak")
-	if err != nil {
-		return err
-	}
-
-	// Removes the .wlt.bak file that has .wlt matched.
-	for _, bf := range bakFs {
-		f := strings.TrimRight(bf, ".bak")
-		if _, ok := fm[f]; ok {
-			// Load and check the wallet version
-			w, err := Load(f)
-			if err != nil {
-				return err
-			}
-
-			if w.Version() == "0.1" {
-				if err := os.Remove(bf); err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-func filterDir(dir string, suffix string) ([]string, error) {
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-	res := []string{}
-	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), suffix) {
-			res = append(res, filepath.Join(dir, f.Name()))
-		}
-	}
-	return res, nil
+	return SaveWithBackups(w, dir, DefaultBackups)
 }
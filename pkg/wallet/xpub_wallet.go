@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -8,8 +9,11 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+
 	"github.com/SkycoinProject/skycoin/src/cipher"
 	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip44"
 	"github.com/SkycoinProject/skycoin/src/util/file"
 	"github.com/SkycoinProject/skycoin/src/util/mathutil"
 )
@@ -18,10 +22,15 @@ import (
 // Refer to the bip32 spec to understand xpub keys.
 // XPub wallets can generate new addresses and receive coins, but can't spend coins
 // because the private keys are not available.
+//
+// A chain level xpub key is the node of a single chain, and addresses are derived as xpub/i.
+// An account level xpub key is a bip44 account node, and addresses are derived
+// as xpub/0/i on the external chain and as xpub/1/i on the change chain.
 type XPubWallet struct {
 	Meta
-	Entries Entries
-	xpub    *bip32.PublicKey
+	Entries       Entries // external chain entries, or all entries of chain level xpub wallets
+	ChangeEntries Entries // change chain entries of account level xpub wallets
+	xpub          *bip32.PublicKey
 }
 
 // newXPubWallet creates a XPubWallet
@@ -38,6 +47,14 @@ func newXPubWallet(meta Meta) (*XPubWallet, error) {
 }
 
 func parseXPub(xp string) (*bip32.PublicKey, error) {
+	// ypub and zpub keys are xpub keys with a different version prefix
+	if v, err := btc.ExtendedPublicKeyVersion(xp); err == nil && !bytes.Equal(v, btc.XPubVersion) {
+		xp, err = btc.ConvertExtendedPublicKey(xp, btc.XPubVersion)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("invalid xpub key: %v", err))
+		}
+	}
+
 	xpub, err := bip32.DeserializeEncodedPublicKey(xp)
 	if err != nil {
 		logger.WithError(err).Error("bip32.DeserializeEncodedPublicKey failed")
@@ -47,6 +64,110 @@ func parseXPub(xp string) (*bip32.PublicKey, error) {
 	return xpub, nil
 }
 
+// slip132Versions maps the bitcoin address types to the SLIP-132 version of their extended public keys
+var slip132Versions = map[AddressType][]byte{
+	AddressTypeP2PKH:      btc.XPubVersion,
+	AddressTypeP2SHP2WPKH: btc.YPubVersion,
+	AddressTypeP2WPKH:     btc.ZPubVersion,
+}
+
+// addressTypeFromXPub returns the address type implied by the SLIP-132 version of a bitcoin extended public key.
+// xpub keys don't imply an address type, in which case the requested address type is returned.
+func addressTypeFromXPub(xp string, addressType AddressType) (AddressType, error) {
+	v, err := btc.ExtendedPublicKeyVersion(xp)
+	if err != nil {
+		return "", NewError(fmt.Errorf("invalid xpub key: %v", err))
+	}
+
+	if bytes.Equal(v, btc.XPubVersion) {
+		return addressType, nil
+	}
+
+	for t, tv := range slip132Versions {
+		if !bytes.Equal(v, tv) {
+			continue
+		}
+		if addressType != "" && addressType != t {
+			return "", NewError(fmt.Errorf("xpub key is for %q addresses, but address type is %q", t, addressType))
+		}
+		return t, nil
+	}
+
+	return "", NewError(fmt.Errorf("invalid xpub key: %v", btc.ErrUnknownExtendedKeyVersion))
+}
+
+// xpubLevelFromDepth infers the level of an xpub key from its bip32 depth.
+// Account nodes of bip44-style paths (m/purpose'/coin_type'/account') have depth 3.
+func xpubLevelFromDepth(xp string) (XPubLevel, error) {
+	xpub, err := parseXPub(xp)
+	if err != nil {
+		return "", err
+	}
+
+	if xpub.Depth == 3 {
+		return XPubLevelAccount, nil
+	}
+
+	return XPubLevelChain, nil
+}
+
+// encodeXPub serializes an extended public key. Bitcoin keys are serialized with the
+// SLIP-132 version of the address type, if it has one.
+func encodeXPub(pk *bip32.PublicKey, coin CoinType, addressType AddressType) (string, error) {
+	xp := pk.String()
+	if coin != CoinTypeBitcoin {
+		return xp, nil
+	}
+
+	v, ok := slip132Versions[addressType]
+	if !ok || bytes.Equal(v, btc.XPubVersion) {
+		return xp, nil
+	}
+
+	return btc.ConvertExtendedPublicKey(xp, v)
+}
+
+// NewXPubWalletFromBip44 creates an account level XPubWallet for a bip44 wallet account.
+// The XPubWallet derives the same external and change addresses as the account, and has as many of them.
+func NewXPubWalletFromBip44(wltName string, w *Bip44Wallet, account uint32) (*XPubWallet, error) {
+	xp, err := w.AccountXPub(account)
+	if err != nil {
+		return nil, err
+	}
+
+	var addressType AddressType
+	if w.Meta.Coin() == CoinTypeBitcoin {
+		addressType = w.Meta.AddressType()
+	}
+
+	generateN := uint64(len(w.ExternalEntries.forAccount(account)))
+	if generateN == 0 {
+		generateN = 1
+	}
+
+	xw, err := NewWallet(wltName, Options{
+		Type:        WalletTypeXPub,
+		Coin:        w.Meta.Coin(),
+		Label:       w.Meta.Label(),
+		XPub:        xp,
+		XPubLevel:   XPubLevelAccount,
+		AddressType: addressType,
+		GenerateN:   generateN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	xpw := xw.(*XPubWallet)
+	for range w.ChangeEntries.forAccount(account) {
+		if _, err := xpw.GenerateChangeEntry(); err != nil {
+			return nil, err
+		}
+	}
+
+	return xpw, nil
+}
+
 // PackSecrets does nothing because XPubWallet has no secrets
 func (w *XPubWallet) PackSecrets(ss Secrets) {
 }
@@ -64,9 +185,10 @@ func (w *XPubWallet) Clone() Wallet {
 	}
 
 	return &XPubWallet{
-		Meta:    w.Meta.clone(),
-		Entries: w.Entries.clone(),
-		xpub:    xpub,
+		Meta:          w.Meta.clone(),
+		Entries:       w.Entries.clone(),
+		ChangeEntries: w.ChangeEntries.clone(),
+		xpub:          xpub,
 	}
 }
 
@@ -79,6 +201,7 @@ func (w *XPubWallet) CopyFrom(src Wallet) {
 	w.xpub = xpub
 	w.Meta = src.(*XPubWallet).Meta.clone()
 	w.Entries = src.(*XPubWallet).Entries.clone()
+	w.ChangeEntries = src.(*XPubWallet).ChangeEntries.clone()
 }
 
 // CopyFromRef copies the src wallet with a pointer dereference
@@ -96,6 +219,7 @@ func (w *XPubWallet) CopyFromRef(src Wallet) {
 func (w *XPubWallet) Erase() {
 	w.Meta.eraseSeeds()
 	w.Entries.erase()
+	w.ChangeEntries.erase()
 }
 
 // ToReadable converts the wallet to its readable (serializable) format
@@ -110,36 +234,71 @@ func (w *XPubWallet) Validate() error {
 
 // GetAddresses returns all addresses in wallet
 func (w *XPubWallet) GetAddresses() []cipher.Addresser {
-	return w.Entries.getAddresses()
+	return append(w.Entries.getAddresses(), w.ChangeEntries.getAddresses()...)
 }
 
 // GetEntries returns a copy of all entries held by the wallet
 func (w *XPubWallet) GetEntries() Entries {
-	return w.Entries.clone()
+	if w.EntriesLen() == 0 {
+		return nil
+	}
+	return append(w.Entries.clone(), w.ChangeEntries.clone()...)
 }
 
 // EntriesLen returns the number of entries in the wallet
 func (w *XPubWallet) EntriesLen() int {
-	return len(w.Entries)
+	return len(w.Entries) + len(w.ChangeEntries)
 }
 
 // GetEntryAt returns entry at a given index in the entries array
 func (w *XPubWallet) GetEntryAt(i int) Entry {
+	if i >= len(w.Entries) {
+		return w.ChangeEntries[i-len(w.Entries)]
+	}
 	return w.Entries[i]
 }
 
 // GetEntry returns entry of given address
 func (w *XPubWallet) GetEntry(a cipher.Addresser) (Entry, bool) {
-	return w.Entries.get(a)
+	if e, ok := w.Entries.get(a); ok {
+		return e, true
+	}
+
+	return w.ChangeEntries.get(a)
 }
 
 // HasEntry returns true if the wallet has an Entry with a given cipher.Address.
 func (w *XPubWallet) HasEntry(a cipher.Addresser) bool {
-	return w.Entries.has(a)
+	return w.Entries.has(a) || w.ChangeEntries.has(a)
 }
 
-// generateEntries generates up to `num` addresses
-func (w *XPubWallet) generateEntries(num uint64, initialChildIdx uint32) (Entries, error) {
+// chainNode returns the xpub node of a chain (should be 0 or 1).
+// Chain level xpub keys are the node of their only chain, which is the external chain.
+func (w *XPubWallet) chainNode(changeIdx uint32) (*bip32.PublicKey, error) {
+	switch changeIdx {
+	case bip44.ExternalChainIndex, bip44.ChangeChainIndex:
+	default:
+		return nil, NewError(errors.New("change must be either 0 or 1"))
+	}
+
+	if w.Meta.XPubLevel() != XPubLevelAccount {
+		if changeIdx != bip44.ExternalChainIndex {
+			return nil, NewError(errors.New("chain level xpub wallets don't have a change chain"))
+		}
+		return w.xpub, nil
+	}
+
+	chain, err := w.xpub.NewPublicChildKey(changeIdx)
+	if err != nil {
+		logger.Critical().WithError(err).WithField("changeIdx", changeIdx).Error("Failed to derive the xpub chain node")
+		return nil, err
+	}
+
+	return chain, nil
+}
+
+// generateEntries generates up to `num` addresses for a chain (should be 0 or 1) starting from an initial child number
+func (w *XPubWallet) generateEntries(num uint64, changeIdx, initialChildIdx uint32) (Entries, error) {
 	if w.Meta.IsEncrypted() {
 		return nil, ErrWalletEncrypted
 	}
@@ -157,13 +316,18 @@ func (w *XPubWallet) generateEntries(num uint64, initialChildIdx uint32) (Entrie
 		return nil, nil
 	}
 
-	// Generate `num` secret keys from the external chain HDNode, skipping any children that
+	chain, err := w.chainNode(changeIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate `num` public keys from the chain HDNode, skipping any children that
 	// are invalid (note that this has probability ~2^-128)
 	var pubkeys []*bip32.PublicKey
 	var addressIndices []uint32
 	j := initialChildIdx
 	for i := uint32(0); i < uint32(num); i++ {
-		k, err := w.xpub.NewPublicChildKey(j)
+		k, err := chain.NewPublicChildKey(j)
 
 		var addErr error
 		j, addErr = mathutil.AddUint32(j, 1)
@@ -199,6 +363,7 @@ func (w *XPubWallet) generateEntries(num uint64, initialChildIdx uint32) (Entrie
 			Address:     makeAddress(pk),
 			Public:      pk,
 			ChildNumber: addressIndices[i],
+			Change:      changeIdx,
 		}
 	}
 
@@ -207,7 +372,7 @@ func (w *XPubWallet) generateEntries(num uint64, initialChildIdx uint32) (Entrie
 
 // GenerateAddresses generates addresses for the external chain, and appends them to the wallet's entries array
 func (w *XPubWallet) GenerateAddresses(num uint64) ([]cipher.Addresser, error) {
-	entries, err := w.generateEntries(num, nextChildIdx(w.Entries))
+	entries, err := w.generateEntries(num, bip44.ExternalChainIndex, nextChildIdx(w.Entries))
 	if err != nil {
 		return nil, err
 	}
@@ -217,6 +382,70 @@ func (w *XPubWallet) GenerateAddresses(num uint64) ([]cipher.Addresser, error) {
 	return entries.getAddresses(), nil
 }
 
+// PeekChangeEntry creates and returns an entry for the change chain of an account level xpub wallet.
+// If used, the caller appends it with GenerateChangeEntry
+func (w *XPubWallet) PeekChangeEntry() (Entry, error) {
+	entries, err := w.generateEntries(1, bip44.ChangeChainIndex, nextChildIdx(w.ChangeEntries))
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if len(entries) == 0 {
+		return Entry{}, NewError(errors.New("PeekChangeEntry: no more change addresses"))
+	}
+
+	return entries[0], nil
+}
+
+// GenerateChangeEntry creates, appends and returns an entry for the change chain of an account level xpub wallet
+func (w *XPubWallet) GenerateChangeEntry() (Entry, error) {
+	e, err := w.PeekChangeEntry()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	w.ChangeEntries = append(w.ChangeEntries, e)
+
+	return e, nil
+}
+
+// ScanAddresses scans ahead N addresses, truncating up to the highest address with any transaction history.
+func (w *XPubWallet) ScanAddresses(scanN uint64, tf TransactionsFinder) error {
+	if w.Meta.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if scanN == 0 {
+		return nil
+	}
+
+	w2 := w.Clone().(*XPubWallet)
+
+	entries, err := scanAddresses(func(num uint64, childIdx uint32) (Entries, error) {
+		return w2.generateEntries(num, bip44.ExternalChainIndex, childIdx)
+	}, scanN, tf, nextChildIdx(w2.Entries))
+	if err != nil {
+		return err
+	}
+
+	w2.Entries = append(w2.Entries, entries...)
+
+	if w2.Meta.XPubLevel() == XPubLevelAccount {
+		changeEntries, err := scanAddresses(func(num uint64, childIdx uint32) (Entries, error) {
+			return w2.generateEntries(num, bip44.ChangeChainIndex, childIdx)
+		}, scanN, tf, nextChildIdx(w2.ChangeEntries))
+		if err != nil {
+			return err
+		}
+
+		w2.ChangeEntries = append(w2.ChangeEntries, changeEntries...)
+	}
+
+	*w = *w2
+
+	return nil
+}
+
 // Fingerprint returns a unique ID fingerprint for this wallet, using the first
 // child address of the xpub key
 func (w *XPubWallet) Fingerprint() string {
@@ -225,7 +454,7 @@ func (w *XPubWallet) Fingerprint() string {
 	addr := ""
 	if len(w.Entries) == 0 {
 		if !w.IsEncrypted() {
-			entries, err := w.generateEntries(1, 0)
+			entries, err := w.generateEntries(1, bip44.ExternalChainIndex, 0)
 			if err != nil {
 				logger.WithError(err).Panic("Fingerprint failed to generate initial entry for empty wallet")
 			}
@@ -260,7 +489,7 @@ func LoadReadableXPubWallet(wltFile string) (*ReadableXPubWallet, error) {
 func NewReadableXPubWallet(w *XPubWallet) *ReadableXPubWallet {
 	return &ReadableXPubWallet{
 		Meta:            w.Meta.clone(),
-		ReadableEntries: newReadableEntries(w.Entries, w.Meta.Coin(), w.Meta.Type()),
+		ReadableEntries: newReadableEntries(w.GetEntries(), w.Meta.Coin(), w.Meta.Type()),
 	}
 }
 
@@ -276,20 +505,38 @@ func (rw *ReadableXPubWallet) ToWallet() (Wallet, error) {
 		return nil, err
 	}
 
+	xpub, err := parseXPub(w.Meta.XPub())
+	if err != nil {
+		logger.WithError(err).Error("ReadableXPubWallet.ToWallet parseXPub failed")
+		return nil, err
+	}
+	w.xpub = xpub
+
 	ets, err := rw.ReadableEntries.toWalletEntries(w.Meta.Coin(), w.Meta.Type(), w.Meta.IsEncrypted())
 	if err != nil {
 		logger.WithError(err).Error("ReadableXPubWallet.ToWallet toWalletEntries failed")
 		return nil, err
 	}
 
-	w.Entries = ets
+	for _, e := range ets {
+		switch e.Change {
+		case bip44.ExternalChainIndex:
+			w.Entries = append(w.Entries, e)
+		case bip44.ChangeChainIndex:
+			if w.Meta.XPubLevel() != XPubLevelAccount {
+				return nil, fmt.Errorf("invalid wallet %q: change entries are only used by %q level xpub wallets", w.Filename(), XPubLevelAccount)
+			}
+			w.ChangeEntries = append(w.ChangeEntries, e)
+		}
+	}
 
 	// Sort childNumber low to high
 	sort.Slice(w.Entries, func(i, j int) bool {
 		return w.Entries[i].ChildNumber < w.Entries[j].ChildNumber
 	})
-
-	w.Entries = ets
+	sort.Slice(w.ChangeEntries, func(i, j int) bool {
+		return w.ChangeEntries[i].ChildNumber < w.ChangeEntries[j].ChildNumber
+	})
 
 	return w, nil
 }
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SkycoinProject/multicoin-wallet/pkg/coin/btc"
+
+	"github.com/SkycoinProject/skycoin/src/cipher/bip32"
+	"github.com/SkycoinProject/skycoin/src/cipher/bip39"
+)
+
+func TestXPubWalletSLIP132(t *testing.T) {
+	seed, err := bip39.NewSeed(testVectorSeed, "")
+	require.NoError(t, err)
+
+	chain, err := bip32.NewPrivateKeyFromPath(seed, "m/49'/0'/0'/0")
+	require.NoError(t, err)
+
+	ypub, err := btc.ConvertExtendedPublicKey(chain.PublicKey().String(), btc.YPubVersion)
+	require.NoError(t, err)
+
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeXPub,
+		Coin:      CoinTypeBitcoin,
+		XPub:      ypub,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AddressTypeP2SHP2WPKH, w.(*XPubWallet).AddressType())
+	require.Equal(t, []string{
+		"37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf",
+		"3LtMnn87fqUeHBUG414p9CWwnoV6E2pNKS",
+	}, addressStrings(w.GetAddresses()))
+	for _, e := range w.GetEntries() {
+		require.NoError(t, e.VerifyPublic())
+	}
+
+	// The address type must match the ypub
+	_, err = NewWallet("test.wlt", Options{
+		Type:        WalletTypeXPub,
+		Coin:        CoinTypeBitcoin,
+		XPub:        ypub,
+		AddressType: AddressTypeP2WPKH,
+	})
+	require.Error(t, err)
+
+	// An xpub key can be used with any address type
+	w, err = NewWallet("test.wlt", Options{
+		Type:        WalletTypeXPub,
+		Coin:        CoinTypeBitcoin,
+		XPub:        chain.PublicKey().String(),
+		AddressType: AddressTypeP2SHP2WPKH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf", w.GetEntryAt(0).Address.String())
+}
+
+func TestXPubWalletAccountLevel(t *testing.T) {
+	// bip84 reference account zpub, m/84'/0'/0'
+	zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+	w, err := NewWallet("test.wlt", Options{
+		Type:      WalletTypeXPub,
+		Coin:      CoinTypeBitcoin,
+		XPub:      zpub,
+		GenerateN: 2,
+	})
+	require.NoError(t, err)
+	xw := w.(*XPubWallet)
+	require.Equal(t, XPubLevelAccount, xw.XPubLevel())
+
+	e, err := xw.GenerateChangeEntry()
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), e.Change)
+	require.Equal(t, []string{
+		"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		"bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+		"bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+	}, addressStrings(xw.GetAddresses()))
+
+	// The change chain is persisted
+	dir, teardown := prepareWltDir(t)
+	defer teardown()
+	require.NoError(t, Save(xw, dir))
+	w2, err := Load(filepath.Join(dir, xw.Filename()))
+	require.NoError(t, err)
+	xw2 := w2.(*XPubWallet)
+	require.Equal(t, xw.GetEntries(), xw2.GetEntries())
+	require.Len(t, xw2.ChangeEntries, 1)
+
+	e, err = xw2.GenerateChangeEntry()
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), e.ChildNumber)
+
+	// The chain level mode is still available for account level keys
+	w, err = NewWallet("test.wlt", Options{
+		Type:      WalletTypeXPub,
+		Coin:      CoinTypeBitcoin,
+		XPub:      zpub,
+		XPubLevel: XPubLevelChain,
+	})
+	require.NoError(t, err)
+	require.Equal(t, XPubLevelChain, w.(*XPubWallet).XPubLevel())
+	_, err = w.(*XPubWallet).GenerateChangeEntry()
+	require.Error(t, err)
+}